@@ -0,0 +1,168 @@
+package agent
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/jayantasamaddar/go-httpsigner/utils/backoff"
+)
+
+// An Error returned by the agent, as opposed to an error communicating with the agent
+type Error struct {
+	Message string
+}
+
+func (e *Error) Error() string {
+	return "agent: " + e.Message
+}
+
+// A Client sends requests to the agent. It is safe for concurrent use: requests are serialized over one connection.
+//
+// The connection is established on the first request, and re-established following the backoff strategy if it fails
+// (once, immediately, by default. See `SetBackoff`), so that a Client survives restarts of the agent. Requests are idempotent, hence safe to retry.
+type Client struct {
+	socketPath string
+	timeout    time.Duration
+	backoff    backoff.Strategy
+
+	mu     sync.Mutex
+	conn   net.Conn
+	reader *bufio.Reader
+}
+
+// Returns a Client of the agent listening on the unix socket at `socketPath`, without connecting.
+// `timeout` bounds connecting and each request, if positive.
+func NewClient(socketPath string, timeout time.Duration) *Client {
+	return &Client{socketPath: socketPath, timeout: timeout, backoff: DEFAULT_RECONNECT_BACKOFF}
+}
+
+// The default backoff of reconnecting to the agent: 1 retry, immediately
+var DEFAULT_RECONNECT_BACKOFF = backoff.Constant(0, 1)
+
+// Set the backoff strategy of reconnecting to the agent when a request fails. Errors returned by the agent are not retried.
+func (c *Client) SetBackoff(strategy backoff.Strategy) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.backoff = strategy
+}
+
+// Connects to the agent listening on the unix socket at `socketPath`. See `NewClient`.
+func Dial(socketPath string, timeout time.Duration) (*Client, error) {
+	c := NewClient(socketPath, timeout)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.connect(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Returns the `ACCESS_KEY_ID` and the region of the agent
+func (c *Client) Identity(ctx context.Context) (accessKeyID, region string, err error) {
+	res, err := c.Do(ctx, &Request{Op: OP_IDENTITY})
+	if err != nil {
+		return "", "", err
+	}
+	return res.AccessKeyID, res.Region, nil
+}
+
+// Returns the signature of the `stringToSign` with the signing key of the scope
+func (c *Client) Sign(ctx context.Context, algorithm, date, region, service, stringToSign string) (string, error) {
+	res, err := c.Do(ctx, &Request{Op: OP_SIGN, Algorithm: algorithm, Date: date, Region: region, Service: service, StringToSign: stringToSign})
+	if err != nil {
+		return "", err
+	}
+	return res.Signature, nil
+}
+
+// Sends a request and returns the response of the agent. An error of the agent is returned as an `*Error`.
+func (c *Client) Do(ctx context.Context, req *Request) (*Response, error) {
+	b, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// Reconnect on failure, E.g. if the agent was restarted
+	var res *Response
+	err = backoff.Retry(ctx, c.backoff, func(ctx context.Context) (err error) {
+		res, err = c.roundTrip(ctx, b)
+		if err != nil && ctx.Err() != nil {
+			return backoff.Permanent(err)
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if res.Error != "" {
+		return nil, &Error{Message: res.Error}
+	}
+	return res, nil
+}
+
+// `roundTrip` sends a request over the connection, connecting if needed. The connection is dropped on failure.
+func (c *Client) roundTrip(ctx context.Context, b []byte) (*Response, error) {
+	if c.conn == nil {
+		if err := c.connect(); err != nil {
+			return nil, err
+		}
+	}
+
+	deadline, ok := ctx.Deadline()
+	if c.timeout > 0 && (!ok || time.Until(deadline) > c.timeout) {
+		deadline = time.Now().Add(c.timeout)
+	}
+	if err := c.conn.SetDeadline(deadline); err != nil {
+		c.disconnect()
+		return nil, err
+	}
+
+	if _, err := c.conn.Write(append(b, '\n')); err != nil {
+		c.disconnect() // The stream may be out of sync
+		return nil, err
+	}
+	line, err := c.reader.ReadBytes('\n')
+	if err != nil {
+		c.disconnect() // A late response would be read by the next request
+		return nil, err
+	}
+	res := new(Response)
+	if err := json.Unmarshal(line, res); err != nil {
+		c.disconnect()
+		return nil, err
+	}
+	return res, nil
+}
+
+func (c *Client) connect() error {
+	conn, err := net.DialTimeout("unix", c.socketPath, c.timeout)
+	if err != nil {
+		return err
+	}
+	c.conn, c.reader = conn, bufio.NewReader(conn)
+	return nil
+}
+
+func (c *Client) disconnect() {
+	c.conn.Close()
+	c.conn, c.reader = nil, nil
+}
+
+// Closes the connection to the agent. The Client reconnects on the next request.
+func (c *Client) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.conn == nil {
+		return nil
+	}
+	err := c.conn.Close()
+	c.conn, c.reader = nil, nil
+	return err
+}
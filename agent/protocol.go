@@ -0,0 +1,46 @@
+// Package agent implements a local signing agent, in the spirit of ssh-agent: the agent holds the credentials
+// and returns signatures to the processes connected to its unix socket, so that these never possess the long-lived secret.
+//
+// The protocol is newline-delimited JSON: each `Request` on a connection is answered by one `Response`, in order.
+package agent
+
+// Operations of a `Request`
+const (
+	OP_IDENTITY = "identity" // Returns the `ACCESS_KEY_ID` and the region of the agent
+	OP_SIGN     = "sign"     // Derives the signing key of the scope, and returns the signature of the `stringToSign`
+)
+
+// Errors
+const (
+	ERROR_UNKNOWN_OPERATION = "unknown operation"
+	ERROR_INVALID_REQUEST   = "invalid signing request"
+)
+
+// A Request to the agent
+type Request struct {
+	Op string `json:"op"`
+	// Signing parameters of `OP_SIGN`. The scope (`Date`, `Region` and `Service`) must match the credential scope in the `StringToSign`.
+	Algorithm    string `json:"algorithm,omitempty"`
+	Date         string `json:"date,omitempty"` // The scope date, as formatted in the credential scope
+	Region       string `json:"region,omitempty"`
+	Service      string `json:"service,omitempty"`
+	StringToSign string `json:"string_to_sign,omitempty"`
+	// The termination string of the credential scope, and the prefix of the secret in the derivation of the signing key.
+	// Default to `DEFAULT_TERMINATOR` and `DEFAULT_KEY_PREFIX` if empty.
+	Terminator string `json:"terminator,omitempty"`
+	KeyPrefix  string `json:"key_prefix,omitempty"`
+}
+
+// The termination string of the credential scope and the prefix of the signing key of a `Request` that does not set them
+const (
+	DEFAULT_TERMINATOR = "aws4_request"
+	DEFAULT_KEY_PREFIX = "AWS4"
+)
+
+// A Response of the agent
+type Response struct {
+	AccessKeyID string `json:"access_key_id,omitempty"`
+	Region      string `json:"region,omitempty"`
+	Signature   string `json:"signature,omitempty"`
+	Error       string `json:"error,omitempty"`
+}
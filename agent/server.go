@@ -0,0 +1,164 @@
+package agent
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/jayantasamaddar/go-httpsigner/sigv4core"
+)
+
+// The credentials held by the agent
+type Credentials struct {
+	ACCESS_KEY_ID     string
+	SECRET_ACCESS_KEY string
+	REGION            string
+}
+
+// A Server answers the requests of the clients connected to its listener.
+type Server struct {
+	creds Credentials
+
+	mu       sync.Mutex
+	listener net.Listener
+	conns    map[net.Conn]struct{}
+	closed   bool
+}
+
+// Returns a Server signing with the credentials
+func NewServer(creds Credentials) *Server {
+	return &Server{creds: creds, conns: make(map[net.Conn]struct{})}
+}
+
+// Listens on the unix socket at `socketPath`, only accessible by the current user. A stale socket file is removed.
+func Listen(socketPath string) (net.Listener, error) {
+	if err := os.Remove(socketPath); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+	listener, err := net.Listen("unix", socketPath)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(socketPath, 0o600); err != nil {
+		listener.Close()
+		return nil, err
+	}
+	return listener, nil
+}
+
+// Serves the connections of the listener until it is closed, or the Server is closed.
+func (s *Server) Serve(listener net.Listener) error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return net.ErrClosed
+	}
+	s.listener = listener
+	s.mu.Unlock()
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if s.isClosed() {
+				return nil
+			}
+			return err
+		}
+		if !s.track(conn) {
+			conn.Close()
+			return nil
+		}
+		go s.serveConn(conn)
+	}
+}
+
+// Closes the listener and all the connections
+func (s *Server) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closed = true
+	for conn := range s.conns {
+		conn.Close()
+	}
+	if s.listener != nil {
+		return s.listener.Close()
+	}
+	return nil
+}
+
+func (s *Server) isClosed() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.closed
+}
+
+func (s *Server) track(conn net.Conn) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return false
+	}
+	s.conns[conn] = struct{}{}
+	return true
+}
+
+func (s *Server) serveConn(conn net.Conn) {
+	defer func() {
+		s.mu.Lock()
+		delete(s.conns, conn)
+		s.mu.Unlock()
+		conn.Close()
+	}()
+
+	scanner := bufio.NewScanner(conn)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	encoder := json.NewEncoder(conn)
+	for scanner.Scan() {
+		var req Request
+		res := new(Response)
+		if err := json.Unmarshal(scanner.Bytes(), &req); err != nil {
+			res.Error = fmt.Sprintf("%s: %v", ERROR_INVALID_REQUEST, err)
+		} else {
+			res = s.handle(&req)
+		}
+		if err := encoder.Encode(res); err != nil {
+			return
+		}
+	}
+}
+
+// `handle` answers a request
+func (s *Server) handle(req *Request) *Response {
+	switch req.Op {
+	case OP_IDENTITY:
+		return &Response{AccessKeyID: s.creds.ACCESS_KEY_ID, Region: s.creds.REGION}
+	case OP_SIGN:
+		newHash, ok := sigv4core.HashFunc(req.Algorithm)
+		if !ok {
+			return &Response{Error: fmt.Sprintf("%s: unsupported algorithm %q", ERROR_INVALID_REQUEST, req.Algorithm)}
+		}
+		terminator, keyPrefix := req.Terminator, req.KeyPrefix
+		if terminator == "" {
+			terminator = DEFAULT_TERMINATOR
+		}
+		if keyPrefix == "" {
+			keyPrefix = DEFAULT_KEY_PREFIX
+		}
+		// Only sign a `stringToSign` of the requested algorithm and scope, so that the agent cannot be used to sign arbitrary data
+		scope := sigv4core.CredentialScope(req.Date, req.Region, req.Service, terminator)
+		lines := strings.Split(req.StringToSign, "\n")
+		if len(lines) != 4 || lines[0] != req.Algorithm || lines[2] != scope {
+			return &Response{Error: fmt.Sprintf("%s: stringToSign does not match the algorithm and scope", ERROR_INVALID_REQUEST)}
+		}
+		key := sigv4core.SigningKeyHash(newHash, keyPrefix, s.creds.SECRET_ACCESS_KEY, req.Date, req.Region, req.Service, terminator)
+		return &Response{AccessKeyID: s.creds.ACCESS_KEY_ID, Signature: sigv4core.SignatureHash(newHash, key, req.StringToSign)}
+	default:
+		return &Response{Error: fmt.Sprintf("%s: %q", ERROR_UNKNOWN_OPERATION, req.Op)}
+	}
+}
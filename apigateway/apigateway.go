@@ -0,0 +1,161 @@
+// Package apigateway reconstructs the signed `*http.Request` of Amazon API Gateway proxy events and verifies it,
+// so that services running on AWS Lambda can verify signed requests without an HTTP listener.
+//
+// The event types mirror the JSON of the payload format versions 1.0 (REST APIs, `ProxyRequest`) and 2.0 (HTTP APIs, `HTTPRequestV2`),
+// hence the event received by the Lambda handler can be unmarshaled into them directly, without depending on aws-lambda-go.
+package apigateway
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/jayantasamaddar/go-httpsigner/auth"
+)
+
+// Errors
+const (
+	ERROR_INVALID_EVENT = "invalid API Gateway proxy event"
+)
+
+// An Event is an API Gateway proxy event that can be reconstructed as the request received by API Gateway
+type Event interface {
+	HTTPRequest(ctx context.Context) (*http.Request, error)
+}
+
+// # Payload format version 1.0 (REST APIs)
+//
+// The subset of the proxy event used to reconstruct the request. Multi-value headers and query parameters take precedence over single-value ones.
+type ProxyRequest struct {
+	HTTPMethod                      string              `json:"httpMethod"`
+	Path                            string              `json:"path"`
+	Headers                         map[string]string   `json:"headers"`
+	MultiValueHeaders               map[string][]string `json:"multiValueHeaders"`
+	QueryStringParameters           map[string]string   `json:"queryStringParameters"`
+	MultiValueQueryStringParameters map[string][]string `json:"multiValueQueryStringParameters"`
+	Body                            string              `json:"body"`
+	IsBase64Encoded                 bool                `json:"isBase64Encoded"`
+	RequestContext                  struct {
+		DomainName string `json:"domainName"`
+		// The path of the request including the stage (E.g. `/prod/items`), as signed by the client
+		Path string `json:"path"`
+	} `json:"requestContext"`
+}
+
+// Reconstructs the request received by API Gateway.
+//
+// The path is taken from `requestContext.path` if present, as it includes the stage of the default `execute-api` endpoint the client signed,
+// unlike `path`. Behind a custom domain name with a base path mapping, the client signs the path of the custom domain,
+// which is `path` prefixed with the base path: build the request with `path` in that case.
+func (e *ProxyRequest) HTTPRequest(ctx context.Context) (*http.Request, error) {
+	path := e.RequestContext.Path
+	if path == "" {
+		path = e.Path
+	}
+
+	query := make(url.Values)
+	for name, value := range e.QueryStringParameters {
+		query.Set(name, value)
+	}
+	for name, values := range e.MultiValueQueryStringParameters {
+		query[name] = values
+	}
+
+	header := make(http.Header)
+	for name, value := range e.Headers {
+		header.Set(name, value)
+	}
+	for name, values := range e.MultiValueHeaders {
+		header[http.CanonicalHeaderKey(name)] = values
+	}
+
+	// The path is decoded by API Gateway, hence re-escaped
+	escapedPath := (&url.URL{Path: path}).EscapedPath()
+	return newRequest(ctx, e.HTTPMethod, escapedPath, query.Encode(), header, e.RequestContext.DomainName, e.Body, e.IsBase64Encoded)
+}
+
+// # Payload format version 2.0 (HTTP APIs)
+//
+// The subset of the proxy event used to reconstruct the request.
+type HTTPRequestV2 struct {
+	RawPath         string            `json:"rawPath"`
+	RawQueryString  string            `json:"rawQueryString"`
+	Cookies         []string          `json:"cookies"`
+	Headers         map[string]string `json:"headers"`
+	Body            string            `json:"body"`
+	IsBase64Encoded bool              `json:"isBase64Encoded"`
+	RequestContext  struct {
+		DomainName string `json:"domainName"`
+		HTTP       struct {
+			Method string `json:"method"`
+		} `json:"http"`
+	} `json:"requestContext"`
+}
+
+// Reconstructs the request received by API Gateway.
+//
+// API Gateway combines the values of a repeated header with commas, which is also how they are canonicalized,
+// and moves the `Cookie` header to `cookies`, from which it is restored.
+func (e *HTTPRequestV2) HTTPRequest(ctx context.Context) (*http.Request, error) {
+	header := make(http.Header)
+	for name, value := range e.Headers {
+		header.Set(name, value)
+	}
+	if len(e.Cookies) > 0 {
+		header.Set("Cookie", strings.Join(e.Cookies, "; "))
+	}
+	return newRequest(ctx, e.RequestContext.HTTP.Method, e.RawPath, e.RawQueryString, header, e.RequestContext.DomainName, e.Body, e.IsBase64Encoded)
+}
+
+// `newRequest` builds the request from the parts of an event. The host is taken from the `Host` header, else the `domainName`.
+func newRequest(ctx context.Context, method, escapedPath, rawQuery string, header http.Header, domainName, body string, isBase64Encoded bool) (*http.Request, error) {
+	payload := []byte(body)
+	if isBase64Encoded {
+		decoded, err := base64.StdEncoding.DecodeString(body)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", ERROR_INVALID_EVENT, err)
+		}
+		payload = decoded
+	}
+
+	host := header.Get("Host")
+	if host == "" {
+		host = domainName
+	}
+	if method == "" || host == "" {
+		return nil, fmt.Errorf("%s: %s", ERROR_INVALID_EVENT, "method or host not specified")
+	}
+
+	if !strings.HasPrefix(escapedPath, "/") {
+		escapedPath = "/" + escapedPath
+	}
+	rawURL := "https://" + host + escapedPath
+	if rawQuery != "" {
+		rawURL += "?" + rawQuery
+	}
+	req, err := http.NewRequestWithContext(ctx, method, rawURL, bytes.NewReader(payload))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", ERROR_INVALID_EVENT, err)
+	}
+	req.Header = header
+	req.Host = host
+	return req, nil
+}
+
+// # Verify an event
+//
+// Reconstructs the request of the event and verifies it with the Verifier. Returns the Identity of the client if the Verifier is an `auth.Authenticator`.
+func Authenticate(ctx context.Context, verifier auth.Verifier, event Event) (*auth.Identity, error) {
+	req, err := event.HTTPRequest(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if authenticator, ok := verifier.(auth.Authenticator); ok {
+		return authenticator.Authenticate(req)
+	}
+	return nil, verifier.VerifySignature(req)
+}
@@ -1,6 +1,9 @@
 package auth
 
-import "net/http"
+import (
+	"context"
+	"net/http"
+)
 
 // Signer interface to be implemented by any signing mechanism.
 type Signer interface {
@@ -11,3 +14,53 @@ type Signer interface {
 type Verifier interface {
 	VerifySignature(req *http.Request) error
 }
+
+// A ContextSigner is a Signer that observes the deadline and the cancellation of a context while signing, E.g. while reading the body.
+type ContextSigner interface {
+	Signer
+	SignHTTPRequestContext(ctx context.Context, req *http.Request) error
+}
+
+// A ContextVerifier is a Verifier that observes the deadline and the cancellation of a context while verifying,
+// E.g. while reading the body and retrieving the secret.
+type ContextVerifier interface {
+	Verifier
+	VerifySignatureContext(ctx context.Context, req *http.Request) error
+}
+
+// A SkippingVerifier is a Verifier that lets some requests through without verification, E.g. unsigned CORS preflights,
+// and reports which, so that they are not mistaken for verified requests.
+type SkippingVerifier interface {
+	Verifier
+	SkipsVerification(req *http.Request) bool
+}
+
+// Checks if the Verifier lets the request through without verification. False if the Verifier is not a `SkippingVerifier`.
+func SkipsVerification(verifier Verifier, req *http.Request) bool {
+	v, ok := verifier.(SkippingVerifier)
+	return ok && v.SkipsVerification(req)
+}
+
+// Signs the request with the Signer, observing `ctx` if the Signer is a `ContextSigner`.
+// Else the request is signed with its own context, once `ctx` is checked not to be done.
+func SignHTTPRequestContext(ctx context.Context, signer Signer, req *http.Request) error {
+	if s, ok := signer.(ContextSigner); ok {
+		return s.SignHTTPRequestContext(ctx, req)
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return signer.SignHTTPRequest(req)
+}
+
+// Verifies the request with the Verifier, observing `ctx` if the Verifier is a `ContextVerifier`.
+// Else the request is verified with its own context, once `ctx` is checked not to be done.
+func VerifySignatureContext(ctx context.Context, verifier Verifier, req *http.Request) error {
+	if v, ok := verifier.(ContextVerifier); ok {
+		return v.VerifySignatureContext(ctx, req)
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return verifier.VerifySignature(req)
+}
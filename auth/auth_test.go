@@ -0,0 +1,43 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+)
+
+type signerFunc func(req *http.Request) error
+
+func (f signerFunc) SignHTTPRequest(req *http.Request) error { return f(req) }
+
+type verifierFunc func(req *http.Request) error
+
+func (f verifierFunc) VerifySignature(req *http.Request) error { return f(req) }
+
+// Test that Signers and Verifiers without context-aware methods are called unless the context is done
+func Test_ContextFallback(t *testing.T) {
+	calls := 0
+	signer := signerFunc(func(req *http.Request) error { calls++; return nil })
+	verifier := verifierFunc(func(req *http.Request) error { calls++; return nil })
+	req, _ := http.NewRequest(http.MethodGet, "http://example.com", nil)
+
+	if err := SignHTTPRequestContext(context.Background(), signer, req); err != nil {
+		t.Error(err)
+	}
+	if err := VerifySignatureContext(context.Background(), verifier, req); err != nil {
+		t.Error(err)
+	}
+
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := SignHTTPRequestContext(canceled, signer, req); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled, got: %v", err)
+	}
+	if err := VerifySignatureContext(canceled, verifier, req); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled, got: %v", err)
+	}
+	if calls != 2 {
+		t.Errorf("Expected 2 calls, got: %d", calls)
+	}
+}
@@ -0,0 +1,24 @@
+package auth
+
+import "errors"
+
+// Errors wrapped by Verifiers to classify the cause of a failed verification, irrespective of the signing mechanism.
+// Use `errors.Is` to test for them.
+var (
+	// The request carries no signature, E.g. it has no `Authorization` header
+	ErrSignatureMissing = errors.New("signature missing")
+	// The signature computed by the Verifier does not match the signature of the request
+	ErrSignatureMismatch = errors.New("signature mismatch")
+	// The signature of the request is no longer (or not yet) valid
+	ErrSignatureExpired = errors.New("signature expired")
+	// The secret needed to verify the request could not be retrieved from its backend
+	ErrSecretUnavailable = errors.New("secret unavailable")
+	// The request has already been verified
+	ErrRequestReplayed = errors.New("request replayed")
+	// The client has exceeded its rate limit
+	ErrRateLimited = errors.New("rate limited")
+	// The request is authentic, but exceeds the capabilities granted by its signature
+	ErrConstraintViolated = errors.New("constraint violated")
+	// The verification did not complete within its time budget
+	ErrVerificationTimeout = errors.New("verification timed out")
+)
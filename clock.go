@@ -0,0 +1,203 @@
+package httpsigner
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+)
+
+// Errors
+const (
+	ERROR_INVALID_NTP_RESPONSE = "invalid NTP response"
+	ERROR_NTP_KISS_OF_DEATH    = "NTP server refused the request"
+)
+
+// The maximum drift of the local clock tolerated by `ClockDriftMonitor` by default, well within the 15 minutes of skew accepted by Verifiers
+const DEFAULT_MAX_CLOCK_DRIFT = time.Minute
+
+// A TimeSource returns the current time of a reference clock. See `NTPTimeSource`.
+type TimeSource interface {
+	Now(ctx context.Context) (time.Time, error)
+}
+
+// An adapter to use a function as a `TimeSource`
+type TimeSourceFunc func(ctx context.Context) (time.Time, error)
+
+func (f TimeSourceFunc) Now(ctx context.Context) (time.Time, error) {
+	return f(ctx)
+}
+
+// The difference between the NTP epoch (1900) and the Unix epoch (1970), in seconds
+const ntpEpochOffset = 2208988800
+
+// # NTP time source
+//
+// Returns a `TimeSource` querying the (S)NTP server at `addr` (E.g. `pool.ntp.org`, or `time.aws.com:123`; the port defaults to 123).
+// The returned time is corrected for half the round trip of the query.
+func NTPTimeSource(addr string) TimeSource {
+	if _, _, err := net.SplitHostPort(addr); err != nil {
+		addr = net.JoinHostPort(addr, "123")
+	}
+	return TimeSourceFunc(func(ctx context.Context) (time.Time, error) {
+		return ntpNow(ctx, addr)
+	})
+}
+
+// `ntpNow` sends an SNTPv4 client request (RFC 4330), and returns the local time shifted by the clock offset of the response
+func ntpNow(ctx context.Context, addr string) (time.Time, error) {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "udp", addr)
+	if err != nil {
+		return time.Time{}, err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	} else {
+		conn.SetDeadline(time.Now().Add(5 * time.Second))
+	}
+
+	req := make([]byte, 48)
+	req[0] = 0<<6 | 4<<3 | 3 // LI = 0, VN = 4, Mode = 3 (client)
+	t1 := time.Now()
+	binary.BigEndian.PutUint64(req[40:], toNTPTimestamp(t1)) // Transmit Timestamp, echoed as the Originate Timestamp
+	if _, err := conn.Write(req); err != nil {
+		return time.Time{}, err
+	}
+
+	res := make([]byte, 48)
+	n, err := conn.Read(res)
+	t4 := time.Now()
+	if err != nil {
+		return time.Time{}, err
+	}
+	if n < 48 || res[0]&0x07 != 4 || binary.BigEndian.Uint64(res[24:]) != binary.BigEndian.Uint64(req[40:]) {
+		return time.Time{}, errors.New(ERROR_INVALID_NTP_RESPONSE)
+	}
+	if res[1] == 0 {
+		return time.Time{}, fmt.Errorf("%s: %q", ERROR_NTP_KISS_OF_DEATH, res[12:16])
+	}
+
+	// Clock offset: ((T2 - T1) + (T3 - T4)) / 2
+	t2 := fromNTPTimestamp(binary.BigEndian.Uint64(res[32:]))
+	t3 := fromNTPTimestamp(binary.BigEndian.Uint64(res[40:]))
+	offset := (t2.Sub(t1) + t3.Sub(t4)) / 2
+	return t4.Add(offset), nil
+}
+
+func toNTPTimestamp(t time.Time) uint64 {
+	seconds := uint64(t.Unix() + ntpEpochOffset)
+	fraction := uint64(t.Nanosecond()) << 32 / uint64(time.Second)
+	return seconds<<32 | fraction
+}
+
+func fromNTPTimestamp(ts uint64) time.Time {
+	seconds := int64(ts>>32) - ntpEpochOffset
+	nanoseconds := int64((ts & 0xffffffff) * uint64(time.Second) >> 32)
+	return time.Unix(seconds, nanoseconds)
+}
+
+// # Clock drift
+//
+// Returns the drift of the local clock against the time source: positive if the local clock is ahead, negative if behind.
+// Requests signed with a drifting clock fail verification once the drift exceeds the skew accepted by the Verifier.
+func ClockDrift(ctx context.Context, source TimeSource) (time.Duration, error) {
+	reference, err := source.Now(ctx)
+	if err != nil {
+		return 0, err
+	}
+	return time.Since(reference), nil
+}
+
+// The ClockDriftObserver hook is called by the `ClockDriftMonitor` after each check, with the drift of the local clock,
+// whether it exceeds the maximum drift, or the error if the time source could not be queried.
+type ClockDriftObserver interface {
+	ObserveClockDrift(drift time.Duration, exceeded bool, err error)
+}
+
+// An adapter to use a function as a `ClockDriftObserver`, E.g. to log or alert when the drift is exceeded
+type ClockDriftObserverFunc func(drift time.Duration, exceeded bool, err error)
+
+func (f ClockDriftObserverFunc) ObserveClockDrift(drift time.Duration, exceeded bool, err error) {
+	f(drift, exceeded, err)
+}
+
+// The state of a `ClockDriftMonitor`
+type ClockDriftStatus struct {
+	Drift     time.Duration // The drift measured by the last successful check
+	Exceeded  bool          // Whether the drift measured by the last successful check exceeds the maximum drift
+	LastCheck time.Time     // The time of the last successful check. Zero if none.
+	LastError error         // The error of the last check, if it failed
+	Failures  uint64        // The number of failed checks
+}
+
+// # Clock drift monitor
+//
+// A ClockDriftMonitor checks the local clock against a `TimeSource` at startup and periodically, and surfaces the drift
+// through its `Status` (E.g. exported as a gauge) and the `ClockDriftObserver` hooks.
+//
+//	monitor := httpsigner.NewClockDriftMonitor(httpsigner.NTPTimeSource("time.aws.com"), httpsigner.DEFAULT_MAX_CLOCK_DRIFT, observer)
+//	go monitor.Run(ctx, 10*time.Minute)
+type ClockDriftMonitor struct {
+	source    TimeSource
+	maxDrift  time.Duration
+	observers []ClockDriftObserver
+
+	mu     sync.Mutex
+	status ClockDriftStatus
+}
+
+// Create a `ClockDriftMonitor` flagging a drift of more than `maxDrift` (`DEFAULT_MAX_CLOCK_DRIFT` if not positive) in either direction
+func NewClockDriftMonitor(source TimeSource, maxDrift time.Duration, observers ...ClockDriftObserver) *ClockDriftMonitor {
+	if maxDrift <= 0 {
+		maxDrift = DEFAULT_MAX_CLOCK_DRIFT
+	}
+	return &ClockDriftMonitor{source: source, maxDrift: maxDrift, observers: observers}
+}
+
+// Checks the drift of the local clock once, updating the status and notifying the observers
+func (m *ClockDriftMonitor) Check(ctx context.Context) (time.Duration, error) {
+	drift, err := ClockDrift(ctx, m.source)
+	exceeded := err == nil && (drift > m.maxDrift || drift < -m.maxDrift)
+
+	m.mu.Lock()
+	m.status.LastError = err
+	if err != nil {
+		m.status.Failures++
+	} else {
+		m.status.Drift = drift
+		m.status.Exceeded = exceeded
+		m.status.LastCheck = time.Now()
+	}
+	m.mu.Unlock()
+
+	for _, observer := range m.observers {
+		observer.ObserveClockDrift(drift, exceeded, err)
+	}
+	return drift, err
+}
+
+// Checks the drift immediately, then every `interval` until the context is done
+func (m *ClockDriftMonitor) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		m.Check(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Returns the state of the monitor
+func (m *ClockDriftMonitor) Status() ClockDriftStatus {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.status
+}
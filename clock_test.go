@@ -0,0 +1,105 @@
+package httpsigner
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"net"
+	"testing"
+	"time"
+)
+
+// Starts a mock NTP server whose clock is `offset` ahead of the local clock
+func newNTPServer(t *testing.T, offset time.Duration) string {
+	t.Helper()
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	go func() {
+		buf := make([]byte, 48)
+		for {
+			n, addr, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			if n < 48 {
+				continue
+			}
+			res := make([]byte, 48)
+			res[0] = 4<<3 | 4 // VN = 4, Mode = 4 (server)
+			res[1] = 1        // Stratum
+			copy(res[24:32], buf[40:48])
+			now := toNTPTimestamp(time.Now().Add(offset))
+			binary.BigEndian.PutUint64(res[32:], now)
+			binary.BigEndian.PutUint64(res[40:], now)
+			conn.WriteTo(res, addr)
+		}
+	}()
+	return conn.LocalAddr().String()
+}
+
+func Test_NTPTimeSource(t *testing.T) {
+	addr := newNTPServer(t, 5*time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	drift, err := ClockDrift(ctx, NTPTimeSource(addr))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The local clock is 5 seconds behind the server
+	if expected := -5 * time.Second; drift < expected-100*time.Millisecond || drift > expected+100*time.Millisecond {
+		t.Errorf("Expected a drift of %s, got: %s", expected, drift)
+	}
+}
+
+func Test_NTPTimestamp(t *testing.T) {
+	now := time.Date(2024, 2, 29, 12, 30, 45, 123456789, time.UTC)
+	if got := fromNTPTimestamp(toNTPTimestamp(now)); got.Sub(now).Abs() > time.Nanosecond {
+		t.Errorf("Expected: %s, got: %s", now, got)
+	}
+}
+
+func Test_ClockDriftMonitor(t *testing.T) {
+	offset := 2 * time.Minute
+	var failing bool
+	source := TimeSourceFunc(func(ctx context.Context) (time.Time, error) {
+		if failing {
+			return time.Time{}, errors.New("unreachable")
+		}
+		return time.Now().Add(-offset), nil
+	})
+
+	var observed []bool
+	monitor := NewClockDriftMonitor(source, 0, ClockDriftObserverFunc(func(drift time.Duration, exceeded bool, err error) {
+		observed = append(observed, exceeded)
+	}))
+
+	if _, err := monitor.Check(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if status := monitor.Status(); !status.Exceeded || status.Drift < offset || status.LastCheck.IsZero() {
+		t.Errorf("Expected the drift of %s to exceed %s, got: %+v", offset, DEFAULT_MAX_CLOCK_DRIFT, status)
+	}
+
+	offset = time.Second
+	monitor.Check(context.Background())
+	if status := monitor.Status(); status.Exceeded {
+		t.Errorf("Expected the drift of %s not to exceed %s, got: %+v", offset, DEFAULT_MAX_CLOCK_DRIFT, status)
+	}
+
+	// A failed check keeps the last measured drift
+	failing = true
+	if _, err := monitor.Check(context.Background()); err == nil {
+		t.Fatal("Expected the check to fail")
+	}
+	if status := monitor.Status(); status.Failures != 1 || status.LastError == nil || status.Drift < offset {
+		t.Errorf("Unexpected status: %+v", status)
+	}
+
+	if len(observed) != 3 || !observed[0] || observed[1] || observed[2] {
+		t.Errorf("Unexpected observations: %v", observed)
+	}
+}
@@ -0,0 +1,110 @@
+// Command httpsigner-agent is a local signing agent holding SigV4 credentials, serving signatures over a unix socket. See package `agent`.
+//
+// Credentials are read from the `ACCESS_KEY_ID`, `SECRET_ACCESS_KEY` and `REGION` environment variables,
+// else from the profile of the ini files in the credentials directory (`$HOME/.[org]` by default), like `sigv4.NewSigV4Signer`.
+//
+// Usage:
+//
+//	httpsigner-agent -socket /run/user/1000/httpsigner.sock [-org AWS] [-dir ~/.aws] [-profile default]
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+
+	"github.com/jayantasamaddar/go-httpsigner/agent"
+	"github.com/jayantasamaddar/go-httpsigner/utils"
+)
+
+func main() {
+	socketPath := flag.String("socket", "", "Path of the unix socket to listen on (required)")
+	org := flag.String("org", "AWS", "Name of the organization, used in the names of the credential keys and the default directory")
+	dir := flag.String("dir", "", "Directory of the credential files. Defaults to $HOME/.[org]")
+	profile := flag.String("profile", "default", "Profile of the credential files")
+	flag.Parse()
+
+	if *socketPath == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	creds, err := loadCredentials(*org, *dir, *profile)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	listener, err := agent.Listen(*socketPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+	server := agent.NewServer(*creds)
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-signals
+		server.Close()
+	}()
+
+	log.Printf("Signing as %s on %s", creds.ACCESS_KEY_ID, *socketPath)
+	if err := server.Serve(listener); err != nil {
+		log.Fatal(err)
+	}
+	os.Remove(*socketPath)
+}
+
+// `loadCredentials` reads the credentials from the environment variables, else from the credential files
+func loadCredentials(org, dir, profile string) (*agent.Credentials, error) {
+	creds := &agent.Credentials{
+		ACCESS_KEY_ID:     os.Getenv("ACCESS_KEY_ID"),
+		SECRET_ACCESS_KEY: os.Getenv("SECRET_ACCESS_KEY"),
+		REGION:            os.Getenv("REGION"),
+	}
+	if creds.ACCESS_KEY_ID != "" && creds.SECRET_ACCESS_KEY != "" && creds.REGION != "" {
+		return creds, nil
+	}
+
+	if dir == "" {
+		homeDir, err := utils.HomeDir()
+		if err != nil {
+			return nil, err
+		}
+		dir = filepath.Join(homeDir, "."+strings.ToLower(org))
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("could not read credentials from %s: %w", dir, err)
+	}
+
+	prefix := strings.ToLower(org)
+	for _, file := range entries {
+		if ext := strings.ToLower(filepath.Ext(file.Name())); file.IsDir() || (ext != "" && ext != ".ini" && ext != ".conf" && ext != ".config") {
+			continue
+		}
+		for p := range utils.ReadIniFile(filepath.Join(dir, file.Name())) {
+			if p.Name != profile {
+				continue
+			}
+			if creds.ACCESS_KEY_ID == "" {
+				creds.ACCESS_KEY_ID = p.Map[prefix+"_access_key_id"]
+			}
+			if creds.SECRET_ACCESS_KEY == "" {
+				creds.SECRET_ACCESS_KEY = p.Map[prefix+"_secret_access_key"]
+			}
+			if creds.REGION == "" {
+				creds.REGION = p.Map["region"]
+			}
+		}
+	}
+
+	if creds.ACCESS_KEY_ID == "" || creds.SECRET_ACCESS_KEY == "" || creds.REGION == "" {
+		return nil, fmt.Errorf("incomplete credentials for profile %q in %s", profile, dir)
+	}
+	return creds, nil
+}
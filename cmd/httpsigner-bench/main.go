@@ -0,0 +1,277 @@
+// Command httpsigner-bench drives a sign and verify workload against the `sigv4` package in-process, and prints the latency percentiles,
+// the throughput and the allocations per operation, so that capacity planning and performance regressions are measurable.
+//
+// Requests are signed with `-keys` distinct access keys in turn, with bodies of the `-body-sizes` in turn, and verified against an in-process
+// secret backend, so that the cost of the secret retrieval is included unless cached (See `-secret-cache`). With `-qps`, operations are
+// started at the given rate instead of as fast as the `-concurrency` workers allow, E.g. to soak test at the expected production rate.
+//
+// Usage:
+//
+//	httpsigner-bench [-mode both|sign|verify] [-duration 10s] [-concurrency GOMAXPROCS] [-qps 0] [-body-sizes 0,1024,65536] [-keys 1] [-hash-payload] [-secret-cache 0s] [-signing-key-cache 0] [-org SYM] [-abbr sym] [-service bench]
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/signal"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/jayantasamaddar/go-httpsigner/auth"
+	"github.com/jayantasamaddar/go-httpsigner/sigv4"
+)
+
+// Modes of the workload
+const (
+	MODE_BOTH   = "both"   // Sign then verify each request, measuring both
+	MODE_SIGN   = "sign"   // Only sign requests
+	MODE_VERIFY = "verify" // Only verify requests, signed ahead of each operation without being measured
+)
+
+func main() {
+	mode := flag.String("mode", MODE_BOTH, "Operations measured: both, sign or verify")
+	duration := flag.Duration("duration", 10*time.Second, "Duration of the workload")
+	concurrency := flag.Int("concurrency", runtime.GOMAXPROCS(0), "Number of concurrent workers")
+	qps := flag.Float64("qps", 0, "Operations started per second, across workers. Unlimited if 0")
+	bodySizes := flag.String("body-sizes", "0,1024,65536", "Comma-separated sizes of the request bodies, in bytes, used in turn")
+	keys := flag.Int("keys", 1, "Number of distinct access keys, used in turn")
+	hashPayload := flag.Bool("hash-payload", false, "Sign the hash of the payload")
+	secretCache := flag.Duration("secret-cache", 0, "TTL of the secret cache of the Verifier. Disabled if 0")
+	signingKeyCache := flag.Int("signing-key-cache", 0, "Maximum entries of the signing key cache of the Signers and the Verifier. Disabled if 0")
+	org := flag.String("org", "SYM", "Name of the organization")
+	abbr := flag.String("abbr", "sym", "Abbreviation used in the header names")
+	service := flag.String("service", "bench", "Service the requests are signed for")
+	flag.Parse()
+
+	sizes, err := parseSizes(*bodySizes)
+	if err != nil || *keys < 1 || *concurrency < 1 || (*mode != MODE_BOTH && *mode != MODE_SIGN && *mode != MODE_VERIFY) {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	// The secret backend answers the secret of every benchmark key
+	secretServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			AccessKeyID string `json:"access_key_id"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]string{"secret_access_key": secret(body.AccessKeyID)})
+	}))
+	defer secretServer.Close()
+
+	var opts []sigv4.Option
+	if *signingKeyCache > 0 {
+		opts = append(opts, sigv4.WithSigningKeyCache(*signingKeyCache))
+	}
+	signers := make([]auth.Signer, *keys)
+	for i := range signers {
+		accessKeyID := fmt.Sprintf("AKIDBENCH%08d", i)
+		env := &sigv4.SigV4EnvConfig{ACCESS_KEY_ID: accessKeyID, SECRET_ACCESS_KEY: secret(accessKeyID), REGION: "us-east-1"}
+		if signers[i], err = sigv4.NewSigV4Signer(*org, *abbr, *service, env, *hashPayload, opts...); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if *secretCache > 0 {
+		opts = append(opts, sigv4.WithSecretCache(*secretCache))
+	}
+	verifier, err := sigv4.NewSigV4Verifier(*org, *abbr, *service, secretServer.URL, opts...)
+	if err != nil {
+		log.Fatal(err)
+	}
+	bodies := make([][]byte, len(sizes))
+	for i, size := range sizes {
+		bodies[i] = []byte(strings.Repeat("x", size))
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	ctx, cancel := context.WithTimeout(ctx, *duration)
+	defer cancel()
+
+	b := &bench{mode: *mode, signers: signers, verifier: verifier, bodies: bodies}
+	fmt.Printf("mode: %s, concurrency: %d, qps: %s, body sizes: %v, keys: %d, secret cache: %v, signing key cache: %d\n",
+		*mode, *concurrency, formatQPS(*qps), sizes, *keys, *secretCache, *signingKeyCache)
+	b.run(ctx, *concurrency, *qps)
+	b.report(os.Stdout)
+}
+
+// `secret` returns the deterministic secret of a benchmark access key
+func secret(accessKeyID string) string {
+	return "bench-secret-" + accessKeyID
+}
+
+// `parseSizes` parses the comma-separated body sizes
+func parseSizes(str string) ([]int, error) {
+	var sizes []int
+	for _, field := range strings.Split(str, ",") {
+		size, err := strconv.Atoi(strings.TrimSpace(field))
+		if err != nil || size < 0 {
+			return nil, fmt.Errorf("invalid body size: %q", field)
+		}
+		sizes = append(sizes, size)
+	}
+	return sizes, nil
+}
+
+func formatQPS(qps float64) string {
+	if qps <= 0 {
+		return "unlimited"
+	}
+	return strconv.FormatFloat(qps, 'f', -1, 64)
+}
+
+// The state of a workload
+type bench struct {
+	mode     string
+	signers  []auth.Signer
+	verifier auth.Verifier
+	bodies   [][]byte
+
+	next   atomic.Uint64 // Index of the next operation, selecting the key and the body size
+	errors atomic.Uint64
+
+	mu      sync.Mutex
+	signs   []time.Duration
+	verifys []time.Duration
+
+	elapsed              time.Duration
+	mallocs, allocBytes  uint64
+	gcs                  uint32
+	operations, failures uint64
+}
+
+// `run` runs the workload until the context is done, with `concurrency` workers started at `qps` operations per second if positive
+func (b *bench) run(ctx context.Context, concurrency int, qps float64) {
+	var tokens <-chan time.Time
+	if qps > 0 {
+		ticker := time.NewTicker(time.Duration(float64(time.Second) / qps))
+		defer ticker.Stop()
+		tokens = ticker.C
+	}
+
+	var before, after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+	start := time.Now()
+
+	var wg sync.WaitGroup
+	for range concurrency {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var signs, verifys []time.Duration
+			for {
+				if tokens != nil {
+					select {
+					case <-ctx.Done():
+					case <-tokens:
+					}
+				}
+				if ctx.Err() != nil {
+					break
+				}
+				sign, verify, err := b.operation()
+				if err != nil {
+					if b.errors.Add(1) == 1 {
+						log.Println("first error:", err)
+					}
+					continue
+				}
+				if b.mode != MODE_VERIFY {
+					signs = append(signs, sign)
+				}
+				if b.mode != MODE_SIGN {
+					verifys = append(verifys, verify)
+				}
+			}
+			b.mu.Lock()
+			b.signs = append(b.signs, signs...)
+			b.verifys = append(b.verifys, verifys...)
+			b.mu.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	b.elapsed = time.Since(start)
+	runtime.ReadMemStats(&after)
+	b.mallocs = after.Mallocs - before.Mallocs
+	b.allocBytes = after.TotalAlloc - before.TotalAlloc
+	b.gcs = after.NumGC - before.NumGC
+	b.operations = b.next.Load()
+	b.failures = b.errors.Load()
+}
+
+// `operation` signs a request with the next key and body, and verifies it, returning the duration of each step
+func (b *bench) operation() (sign, verify time.Duration, err error) {
+	i := b.next.Add(1) - 1
+	signer := b.signers[i%uint64(len(b.signers))]
+	body := b.bodies[i%uint64(len(b.bodies))]
+
+	req, err := http.NewRequest(http.MethodPost, "https://bench.example.com/api/items?page=1", bytes.NewReader(body))
+	if err != nil {
+		return 0, 0, err
+	}
+	req.Header.Set("Content-Type", "application/octet-stream")
+	start := time.Now()
+	if err := signer.SignHTTPRequest(req); err != nil {
+		return 0, 0, err
+	}
+	sign = time.Since(start)
+	if b.mode == MODE_SIGN {
+		return sign, 0, nil
+	}
+
+	start = time.Now()
+	if err := b.verifier.VerifySignature(req); err != nil {
+		return 0, 0, err
+	}
+	verify = time.Since(start)
+	_, _ = io.Copy(io.Discard, req.Body)
+	return sign, verify, nil
+}
+
+// `report` prints the throughput, the latency percentiles of each measured step and the allocations per operation
+func (b *bench) report(w io.Writer) {
+	succeeded := b.operations - b.failures
+	fmt.Fprintf(w, "%d operations (%d failed) in %v: %.1f ops/s\n",
+		b.operations, b.failures, b.elapsed.Round(time.Millisecond), float64(succeeded)/b.elapsed.Seconds())
+	if b.mode != MODE_VERIFY {
+		printLatencies(w, "sign", b.signs)
+	}
+	if b.mode != MODE_SIGN {
+		printLatencies(w, "verify", b.verifys)
+	}
+	if b.operations > 0 {
+		// Includes the allocations of the in-process secret backend, and of the unmeasured signing in verify mode
+		fmt.Fprintf(w, "allocations: %d allocs/op, %d B/op, %d GC cycles\n",
+			b.mallocs/b.operations, b.allocBytes/b.operations, b.gcs)
+	}
+}
+
+// `printLatencies` prints the percentiles of the durations of a step
+func printLatencies(w io.Writer, step string, durations []time.Duration) {
+	if len(durations) == 0 {
+		fmt.Fprintf(w, "%s: no samples\n", step)
+		return
+	}
+	sort.Slice(durations, func(i, j int) bool { return durations[i] < durations[j] })
+	percentile := func(p float64) time.Duration { return durations[int(float64(len(durations)-1)*p/100)] }
+	fmt.Fprintf(w, "%s latency: p50 %v, p90 %v, p99 %v, p99.9 %v, max %v\n",
+		step, percentile(50), percentile(90), percentile(99), percentile(99.9), durations[len(durations)-1])
+}
@@ -0,0 +1,132 @@
+// Command httpsigner-replay re-signs captured requests with current SigV4 credentials and prints or replays them. See package `replay`.
+//
+// Requests are loaded from a HAR file (`-har`), or from raw HTTP messages (`-raw`). Without `-send`, the re-signed requests are
+// written to the standard output as raw HTTP messages, E.g. to reproduce an incident with `curl` or `nc`. With `-send`,
+// they are sent `-repeat` times with `-concurrency` requests in flight, and a summary of the responses is printed, E.g. for load testing.
+//
+// Credentials are read like `sigv4.NewSigV4Signer`: from the `ACCESS_KEY_ID`, `SECRET_ACCESS_KEY` and `REGION` environment variables,
+// else from the credential files in `$HOME/.[org]`.
+//
+// Usage:
+//
+//	httpsigner-replay -service myapplication (-har capture.har | -raw requests.txt) [-scheme https] [-org AWS] [-abbr amz] [-hash-payload] [-send [-concurrency 1] [-repeat 1] [-timeout 30s]]
+package main
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"sort"
+	"time"
+
+	"github.com/jayantasamaddar/go-httpsigner/replay"
+	"github.com/jayantasamaddar/go-httpsigner/sigv4"
+)
+
+func main() {
+	harPath := flag.String("har", "", "HAR file to load the requests from")
+	rawPath := flag.String("raw", "", "File of raw HTTP messages to load the requests from")
+	scheme := flag.String("scheme", "https", "Scheme of the URLs of raw HTTP messages")
+	org := flag.String("org", "AWS", "Name of the organization")
+	abbr := flag.String("abbr", "amz", "Abbreviation used in the header names")
+	service := flag.String("service", "", "Service the requests are signed for (required)")
+	hashPayload := flag.Bool("hash-payload", false, "Sign the hash of the payload")
+	send := flag.Bool("send", false, "Send the re-signed requests instead of printing them")
+	concurrency := flag.Int("concurrency", 1, "Number of requests in flight, with -send")
+	repeat := flag.Int("repeat", 1, "Number of times each request is sent, with -send")
+	timeout := flag.Duration("timeout", 30*time.Second, "Timeout of each request, with -send")
+	flag.Parse()
+
+	if *service == "" || (*harPath == "") == (*rawPath == "") {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	reqs, err := load(*harPath, *rawPath, *scheme)
+	if err != nil {
+		log.Fatal(err)
+	}
+	signer, err := sigv4.NewSigV4Signer(*org, *abbr, *service, nil, *hashPayload)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if !*send {
+		out := bufio.NewWriter(os.Stdout)
+		defer out.Flush()
+		for _, req := range reqs {
+			resigned, err := replay.Resign(signer, req)
+			if err != nil {
+				log.Fatalf("%s %s: %v", req.Method, req.URL, err)
+			}
+			if err := resigned.Write(out); err != nil {
+				log.Fatal(err)
+			}
+			fmt.Fprintln(out)
+		}
+		return
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	replayer := &replay.Replayer{
+		Signer:      signer,
+		Client:      &http.Client{Timeout: *timeout},
+		Concurrency: *concurrency,
+		Repeat:      *repeat,
+	}
+	start := time.Now()
+	results := replayer.Run(ctx, reqs)
+	summarize(os.Stdout, results, time.Since(start))
+}
+
+// `load` loads the requests from the HAR file or the file of raw HTTP messages
+func load(harPath, rawPath, scheme string) ([]*http.Request, error) {
+	path := harPath
+	if path == "" {
+		path = rawPath
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	if harPath != "" {
+		return replay.LoadHAR(f)
+	}
+	return replay.LoadRaw(f, scheme)
+}
+
+// `summarize` prints the number of responses per status code, the errors, and the latencies
+func summarize(w io.Writer, results []replay.Result, elapsed time.Duration) {
+	statuses := map[int]int{}
+	var durations []time.Duration
+	for _, result := range results {
+		if result.Err != nil {
+			fmt.Fprintf(w, "error: %s %s: %v\n", result.Request.Method, result.Request.URL, result.Err)
+			continue
+		}
+		statuses[result.StatusCode]++
+		durations = append(durations, result.Duration)
+	}
+	codes := make([]int, 0, len(statuses))
+	for code := range statuses {
+		codes = append(codes, code)
+	}
+	sort.Ints(codes)
+	fmt.Fprintf(w, "%d requests in %v\n", len(results), elapsed.Round(time.Millisecond))
+	for _, code := range codes {
+		fmt.Fprintf(w, "  %d: %d\n", code, statuses[code])
+	}
+	if len(durations) > 0 {
+		sort.Slice(durations, func(i, j int) bool { return durations[i] < durations[j] })
+		percentile := func(p int) time.Duration { return durations[(len(durations)-1)*p/100] }
+		fmt.Fprintf(w, "latency: p50 %v, p90 %v, p99 %v, max %v\n", percentile(50), percentile(90), percentile(99), durations[len(durations)-1])
+	}
+}
@@ -0,0 +1,106 @@
+// Command httpsigner-vectors emits a deterministic JSON corpus of SigV4 test vectors for the configured org and abbr,
+// so that client implementations in other languages can be validated against this library. See `sigv4.TestVector`.
+//
+// Each vector holds the request, the credentials, the signing time, the expected canonical request, string-to-sign,
+// signing key and signature, and the headers set by signing. The credentials are examples, never real ones.
+//
+// Usage:
+//
+//	httpsigner-vectors [-org AWS] [-abbr amz] [-service service] [-region us-east-1] [-time 2015-08-30T12:36:00Z] [-hash-payload] [-strict-query-encoding] [-strict-aws] [-double-uri-encode] [-normalize-path] [-strict-uri-encoding] > vectors.json
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/jayantasamaddar/go-httpsigner/sigv4"
+)
+
+// The requests of the corpus
+var cases = []struct {
+	name, method, url, body string
+	header                  http.Header
+}{
+	{"get-vanilla", http.MethodGet, "https://example.com/", "", nil},
+	{"get-path", http.MethodGet, "https://example.com/api/items/1", "", nil},
+	{"get-query-sorted", http.MethodGet, "https://example.com/?Param2=value2&Param1=value1", "", nil},
+	{"get-query-duplicate-keys", http.MethodGet, "https://example.com/?key=b&key=a", "", nil},
+	{"get-query-space", http.MethodGet, "https://example.com/?q=a%20b", "", nil},
+	{"get-query-empty-value", http.MethodGet, "https://example.com/?acl", "", nil},
+	{"get-unreserved", http.MethodGet, "https://example.com/-._~0123456789", "", nil},
+	{"get-encoded-slash", http.MethodGet, "https://example.com/bucket/photos%2Fa.jpg", "", nil},
+	{"get-header-multiple-values", http.MethodGet, "https://example.com/", "", http.Header{"My-Header1": {"value1", "value2"}}},
+	{"get-header-trimmed", http.MethodGet, "https://example.com/", "", http.Header{"My-Header1": {"  value1   with   spaces  "}}},
+	{"get-port", http.MethodGet, "https://example.com:8443/", "", nil},
+	{"post-empty", http.MethodPost, "https://example.com/", "", nil},
+	{"post-form", http.MethodPost, "https://example.com/", "Param1=value1", http.Header{"Content-Type": {"application/x-www-form-urlencoded"}}},
+	{"post-json", http.MethodPost, "https://example.com/api/items", `{"name":"example"}`, http.Header{"Content-Type": {"application/json"}}},
+	{"put-utf8-body", http.MethodPut, "https://example.com/api/items/1", `{"name":"ሴ"}`, http.Header{"Content-Type": {"application/json; charset=utf-8"}}},
+	{"delete", http.MethodDelete, "https://example.com/api/items/1", "", nil},
+}
+
+// The corpus written to the standard output
+type corpus struct {
+	Generator string              `json:"generator"`
+	Vectors   []*sigv4.TestVector `json:"vectors"`
+}
+
+func main() {
+	org := flag.String("org", "AWS", "Name of the organization")
+	abbr := flag.String("abbr", "amz", "Abbreviation used in the header names")
+	service := flag.String("service", "service", "Service the requests are signed for")
+	region := flag.String("region", "us-east-1", "Region the requests are signed for")
+	signingTime := flag.String("time", "2015-08-30T12:36:00Z", "Signing time, in RFC 3339 format")
+	hashPayload := flag.Bool("hash-payload", false, "Sign the hash of the payload")
+	strictQueryEncoding := flag.Bool("strict-query-encoding", false, "Encode the query following the SigV4 UriEncode rules")
+	doubleURIEncode := flag.Bool("double-uri-encode", false, "URI-encode the canonical URI twice, as AWS services other than S3 do")
+	strictURIEncoding := flag.Bool("strict-uri-encoding", false, "Percent-encode every non-ASCII byte of the path and the query, as AWS does")
+	normalizePath := flag.Bool("normalize-path", false, "Remove the dot segments and duplicate slashes of the path, as AWS services other than S3 do")
+	strictAWS := flag.Bool("strict-aws", false, "Sign exactly as AWS does: ISO 8601 basic dates, zero-padded scope dates and RFC 3986 query encoding")
+	flag.Parse()
+
+	t, err := time.Parse(time.RFC3339, *signingTime)
+	if err != nil {
+		log.Fatal(err)
+	}
+	env := &sigv4.SigV4EnvConfig{
+		ACCESS_KEY_ID:     "AKIDEXAMPLE",
+		SECRET_ACCESS_KEY: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY",
+		REGION:            *region,
+	}
+	opts := []sigv4.Option{sigv4.WithStrictQueryEncoding(*strictQueryEncoding), sigv4.WithDoubleURIEncode(*doubleURIEncode), sigv4.WithPathNormalization(*normalizePath), sigv4.WithStrictURIEncoding(*strictURIEncoding)}
+	if *strictAWS {
+		opts = append(opts, sigv4.WithStrictAWSMode())
+	}
+	signer, err := sigv4.NewSigV4Signer(*org, *abbr, *service, env, *hashPayload, opts...)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	out := corpus{Generator: "httpsigner-vectors"}
+	for _, c := range cases {
+		req, err := http.NewRequest(c.method, c.url, bytes.NewBufferString(c.body))
+		if err != nil {
+			log.Fatal(err)
+		}
+		for name, values := range c.header {
+			req.Header[name] = values
+		}
+		vector, err := signer.(*sigv4.SigV4).TestVector(c.name, req, t)
+		if err != nil {
+			log.Fatalf("%s: %v", c.name, err)
+		}
+		out.Vectors = append(out.Vectors, vector)
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(out); err != nil {
+		log.Fatal(err)
+	}
+}
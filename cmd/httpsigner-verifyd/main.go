@@ -0,0 +1,71 @@
+// Command httpsigner-verifyd is a verification service, exposing `/verify` to verify serialized SigV4 requests. See package `verifyd`.
+//
+// Usage:
+//
+//	httpsigner-verifyd -service myapplication -secret-url http://keys.internal/api/secret [-addr :8080] [-org AWS] [-abbr amz] [-secret-cache-ttl 5m]
+//
+// Example request:
+//
+//	curl -X POST localhost:8080/verify -d '{"method":"GET","url":"https://api.example.com/items","headers":{"Authorization":["..."],"X-Amz-Date":["..."]},"body_hash":"e3b0c4...","content_length":0}'
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/jayantasamaddar/go-httpsigner/auth"
+	"github.com/jayantasamaddar/go-httpsigner/sigv4"
+	"github.com/jayantasamaddar/go-httpsigner/verifyd"
+)
+
+func main() {
+	addr := flag.String("addr", ":8080", "Address to listen on")
+	org := flag.String("org", "AWS", "Name of the organization")
+	abbr := flag.String("abbr", "amz", "Abbreviation used in the header names")
+	service := flag.String("service", "", "Service the requests are signed for (required)")
+	secretURL := flag.String("secret-url", "", "URL to retrieve the secrets from (required)")
+	secretCacheTTL := flag.Duration("secret-cache-ttl", 0, "Cache the retrieved secrets for the duration. Disabled if zero")
+	flag.Parse()
+
+	if *service == "" || *secretURL == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	opts := []sigv4.Option{sigv4.WithPayloadHasher(verifyd.PayloadHasher)}
+	if *secretCacheTTL > 0 {
+		opts = append(opts, sigv4.WithSecretCache(*secretCacheTTL))
+	}
+	verifier, err := sigv4.NewSigV4Verifier(*org, *abbr, *service, *secretURL, opts...)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/verify", verifyd.Handler(verifier.(auth.Authenticator)))
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+	server := &http.Server{Addr: *addr, Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-signals
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		server.Shutdown(ctx)
+	}()
+
+	log.Printf("Verifying %s requests on %s", *service, *addr)
+	if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,96 @@
+// Package conformance compares the canonicalization of this library with other SigV4 implementations.
+//
+// Signatures themselves cannot be compared, since the `sigv4` package keeps legacy date and scope formats by default
+// (signatures of aws-sdk-go-v2 are only verified in strict AWS mode, See `sigv4.WithStrictAWSMode`).
+// Instead, the `CanonicalRequest` of each implementation is split into its parts and diffed, catching canonicalization drift.
+// The comparison against aws-sdk-go-v2 lives in the tests of this package, so that it remains a test-only dependency.
+package conformance
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/jayantasamaddar/go-httpsigner/sigv4core"
+)
+
+// The parts of a `CanonicalRequest`
+type CanonicalParts struct {
+	Method        string
+	URI           string
+	Query         string
+	Headers       []string // The canonical headers, one `name:value` per entry
+	SignedHeaders string
+	PayloadHash   string
+}
+
+// Splits a `CanonicalRequest` into its parts. Both the AWS format, which has a blank line after the canonical headers,
+// and the format of `sigv4core.CanonicalRequest`, which does not, are accepted.
+func ParseCanonicalRequest(canonicalRequest string) (*CanonicalParts, error) {
+	lines := strings.Split(canonicalRequest, "\n")
+	if len(lines) < 5 {
+		return nil, fmt.Errorf("malformed canonical request: %q", canonicalRequest)
+	}
+	parts := &CanonicalParts{
+		Method:        lines[0],
+		URI:           lines[1],
+		Query:         lines[2],
+		SignedHeaders: lines[len(lines)-2],
+		PayloadHash:   lines[len(lines)-1],
+	}
+	for _, line := range lines[3 : len(lines)-2] {
+		if line != "" {
+			parts.Headers = append(parts.Headers, line)
+		}
+	}
+	return parts, nil
+}
+
+// Returns a description of every difference between the `CanonicalParts` of a reference implementation and of this library.
+// Returns nil if they are equal.
+func Diff(reference, library *CanonicalParts) []string {
+	var diffs []string
+	compare := func(part, want, got string) {
+		if want != got {
+			diffs = append(diffs, fmt.Sprintf("%s: reference %q, library %q", part, want, got))
+		}
+	}
+	compare("method", reference.Method, library.Method)
+	compare("uri", reference.URI, library.URI)
+	compare("query", reference.Query, library.Query)
+	compare("signed headers", reference.SignedHeaders, library.SignedHeaders)
+	compare("payload hash", reference.PayloadHash, library.PayloadHash)
+	for _, header := range reference.Headers {
+		if !slices.Contains(library.Headers, header) {
+			diffs = append(diffs, fmt.Sprintf("header: reference %q missing from library", header))
+		}
+	}
+	for _, header := range library.Headers {
+		if !slices.Contains(reference.Headers, header) {
+			diffs = append(diffs, fmt.Sprintf("header: library %q missing from reference", header))
+		}
+	}
+	return diffs
+}
+
+// Computes the `CanonicalRequest` of a request already signed by a reference implementation with this library,
+// covering the same `signedHeaders` (E.g. `host;x-amz-date`), with strict query encoding as AWS does.
+func LibraryCanonicalRequest(req *http.Request, payloadHash, signedHeaders string) (string, error) {
+	signed := strings.Split(signedHeaders, ";")
+	cr, _, err := sigv4core.CanonicalRequest(&sigv4core.Request{
+		Method:        req.Method,
+		Path:          req.URL.EscapedPath(),
+		RawQuery:      req.URL.RawQuery,
+		Host:          req.Host,
+		Header:        req.Header,
+		ContentLength: req.ContentLength,
+		PayloadHash:   payloadHash,
+	}, &sigv4core.Options{
+		StrictQueryEncoding: true,
+		SkipHeader: func(name string) bool {
+			return !slices.Contains(signed, strings.ToLower(name))
+		},
+	})
+	return cr, err
+}
@@ -0,0 +1,161 @@
+package conformance
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+	"github.com/aws/smithy-go/logging"
+	"github.com/jayantasamaddar/go-httpsigner/sigv4"
+)
+
+// Captures the canonical request logged by the aws-sdk-go-v2 signer
+type canonicalRequestLogger struct {
+	canonicalRequest string
+}
+
+func (l *canonicalRequestLogger) Logf(_ logging.Classification, format string, v ...interface{}) {
+	message := fmt.Sprintf(format, v...)
+	const begin, end = "---[ CANONICAL STRING  ]-----------------------------\n", "\n---[ STRING TO SIGN ]"
+	if start := strings.Index(message, begin); start >= 0 {
+		message = message[start+len(begin):]
+		if stop := strings.Index(message, end); stop >= 0 {
+			l.canonicalRequest = message[:stop]
+		}
+	}
+}
+
+// Test that the canonicalization of `sigv4core` agrees with aws-sdk-go-v2 (with S3 path escaping, as this library does)
+func Test_Conformance_AWSSDKGoV2(t *testing.T) {
+	cases := []struct {
+		name, method, url, body string
+		header                  http.Header
+	}{
+		{"get vanilla", http.MethodGet, "https://example.amazonaws.com/", "", nil},
+		{"get with query", http.MethodGet, "https://example.amazonaws.com/?Param2=value2&Param1=value1", "", nil},
+		{"get with duplicate query values", http.MethodGet, "https://example.amazonaws.com/?Param1=value2&Param1=Value1", "", nil},
+		{"get with space in query", http.MethodGet, "https://example.amazonaws.com/?q=a%20b", "", nil},
+		{"get with unreserved characters", http.MethodGet, "https://example.amazonaws.com/-._~0123456789", "", nil},
+		{"get with encoded slash", http.MethodGet, "https://example.amazonaws.com/bucket/photos%2Fa.jpg", "", nil},
+		{"get with subresource", http.MethodGet, "https://example.amazonaws.com/bucket?acl", "", nil},
+		{"post with body", http.MethodPost, "https://example.amazonaws.com/", "Param1=value1", http.Header{"Content-Type": {"application/x-www-form-urlencoded"}}},
+		{"header with multiple values", http.MethodGet, "https://example.amazonaws.com/", "", http.Header{"My-Header1": {"value1", "value2"}}},
+		{"header value trimmed", http.MethodGet, "https://example.amazonaws.com/", "", http.Header{"My-Header1": {"  value1  "}}},
+	}
+
+	signer := v4.NewSigner(func(o *v4.SignerOptions) {
+		o.DisableURIPathEscaping = true
+		o.LogSigning = true
+	})
+	credentials := aws.Credentials{AccessKeyID: "AKIDEXAMPLE", SecretAccessKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY"}
+	signingTime := time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC)
+
+	for _, c := range cases {
+		req, err := http.NewRequest(c.method, c.url, bytes.NewBufferString(c.body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for name, values := range c.header {
+			req.Header[name] = values
+		}
+		sum := sha256.Sum256([]byte(c.body))
+		payloadHash := hex.EncodeToString(sum[:])
+
+		logger := &canonicalRequestLogger{}
+		err = signer.SignHTTP(context.Background(), credentials, req, payloadHash, "service", "us-east-1", signingTime, func(o *v4.SignerOptions) {
+			o.Logger = logger
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		reference, err := ParseCanonicalRequest(logger.canonicalRequest)
+		if err != nil {
+			t.Fatalf("%s: %v", c.name, err)
+		}
+		cr, err := LibraryCanonicalRequest(req, payloadHash, reference.SignedHeaders)
+		if err != nil {
+			t.Fatalf("%s: %v", c.name, err)
+		}
+		library, err := ParseCanonicalRequest(cr)
+		if err != nil {
+			t.Fatalf("%s: %v", c.name, err)
+		}
+		for _, diff := range Diff(reference, library) {
+			t.Errorf("%s: %s", c.name, diff)
+		}
+	}
+}
+
+// Test that requests signed by aws-sdk-go-v2 are verified by a Verifier in strict AWS mode
+func Test_Conformance_AWSSDKGoV2_StrictAWSMode(t *testing.T) {
+	credentials := aws.Credentials{AccessKeyID: "AKIDEXAMPLE", SecretAccessKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY"}
+	secretServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]string{"secret_access_key": credentials.SecretAccessKey})
+	}))
+	defer secretServer.Close()
+	verifier, err := sigv4.NewSigV4Verifier("AWS", "amz", "service", secretServer.URL, sigv4.WithStrictAWSMode())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	signer := v4.NewSigner(func(o *v4.SignerOptions) { o.DisableURIPathEscaping = true })
+	for _, c := range []struct{ method, url, body string }{
+		{http.MethodGet, "https://example.amazonaws.com/", ""},
+		{http.MethodGet, "https://example.amazonaws.com/bucket/a%20b.jpg?q=a%20b&Param1=value1", ""},
+		{http.MethodPost, "https://example.amazonaws.com/", "Param1=value1"},
+	} {
+		req, err := http.NewRequest(c.method, c.url, bytes.NewBufferString(c.body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		sum := sha256.Sum256([]byte(c.body))
+		if err := signer.SignHTTP(context.Background(), credentials, req, hex.EncodeToString(sum[:]), "service", "us-east-1", time.Now()); err != nil {
+			t.Fatal(err)
+		}
+		if err := verifier.VerifySignature(req); err != nil {
+			t.Errorf("%s %s: %v", c.method, c.url, err)
+		}
+	}
+}
+
+// Test that requests signed by aws-sdk-go-v2 for services other than S3, which encode the path twice, are verified with `WithDoubleURIEncode`
+func Test_Conformance_AWSSDKGoV2_DoubleURIEncode(t *testing.T) {
+	credentials := aws.Credentials{AccessKeyID: "AKIDEXAMPLE", SecretAccessKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY"}
+	secretServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]string{"secret_access_key": credentials.SecretAccessKey})
+	}))
+	defer secretServer.Close()
+	verifier, err := sigv4.NewSigV4Verifier("AWS", "amz", "execute-api", secretServer.URL, sigv4.WithStrictAWSMode(), sigv4.WithDoubleURIEncode(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	signer := v4.NewSigner()
+	for _, url := range []string{
+		"https://example.execute-api.us-east-1.amazonaws.com/stage/items",
+		"https://example.execute-api.us-east-1.amazonaws.com/stage/documents%20and%20settings/a%2Fb",
+	} {
+		req, err := http.NewRequest(http.MethodGet, url, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sum := sha256.Sum256(nil)
+		if err := signer.SignHTTP(context.Background(), credentials, req, hex.EncodeToString(sum[:]), "execute-api", "us-east-1", time.Now()); err != nil {
+			t.Fatal(err)
+		}
+		if err := verifier.VerifySignature(req); err != nil {
+			t.Errorf("%s: %v", url, err)
+		}
+	}
+}
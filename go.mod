@@ -2,6 +2,10 @@ module github.com/jayantasamaddar/go-httpsigner
 
 go 1.22.1
 
-require github.com/go-ini/ini v1.67.0
+require (
+	github.com/aws/aws-sdk-go-v2 v1.30.3
+	github.com/aws/smithy-go v1.20.3
+	github.com/go-ini/ini v1.67.0
+)
 
 require github.com/stretchr/testify v1.9.0 // indirect
@@ -0,0 +1,24 @@
+package httpsigner
+
+import (
+	"net/http"
+
+	"github.com/jayantasamaddar/go-httpsigner/auth"
+)
+
+// # Verifying handler
+//
+// Returns a handler that verifies each request with the Verifier before passing it to `next`, for frameworks and routers that compose
+// plain handlers rather than middlewares. E.g. `mux.Handle("/api/", httpsigner.Handler(verifier, api))`
+//
+// Behaves like `Middleware`: requests that fail verification are rejected with a `401 Unauthorized` response (See `WithErrorHandler`),
+// and if the Verifier is an `auth.Authenticator`, the Identity is added to the context of verified requests (See `auth.IdentityFromContext`).
+func Handler(verifier auth.Verifier, next http.Handler, opts ...MiddlewareOption) http.Handler {
+	return Middleware(verifier, opts...)(next)
+}
+
+// Returns a handler function that verifies each request with the Verifier before calling `next`, like `Handler`.
+// E.g. `mux.HandleFunc("/api/important", httpsigner.HandlerFunc(verifier, important))`
+func HandlerFunc(verifier auth.Verifier, next http.HandlerFunc, opts ...MiddlewareOption) http.HandlerFunc {
+	return Handler(verifier, next, opts...).ServeHTTP
+}
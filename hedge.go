@@ -0,0 +1,52 @@
+package httpsigner
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/jayantasamaddar/go-httpsigner/auth"
+)
+
+// Signs `n` independent clones of the request for hedged or mirrored sends. Each clone is signed separately (with its own date),
+// and has its own headers, URL and body, so that the clones share no mutable state with each other or with `req`.
+//
+// The body of each clone is obtained from `req.GetBody` if set. Else the body of `req` is read into memory once,
+// and `req.Body` and `req.GetBody` are reset to replay it.
+func SignClones(signer auth.Signer, req *http.Request, n int) ([]*http.Request, error) {
+	if n < 1 {
+		return nil, errors.New("number of clones must be at least 1")
+	}
+
+	getBody := req.GetBody
+	if getBody == nil && req.Body != nil && req.Body != http.NoBody {
+		b, err := io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		getBody = func() (io.ReadCloser, error) {
+			return io.NopCloser(bytes.NewReader(b)), nil
+		}
+		req.Body, _ = getBody()
+		req.GetBody = getBody
+	}
+
+	clones := make([]*http.Request, n)
+	for i := range clones {
+		clone := req.Clone(req.Context())
+		if getBody != nil {
+			body, err := getBody()
+			if err != nil {
+				return nil, err
+			}
+			clone.Body = body
+		}
+		if err := signer.SignHTTPRequest(clone); err != nil {
+			return nil, err
+		}
+		clones[i] = clone
+	}
+	return clones, nil
+}
@@ -0,0 +1,126 @@
+package httpsigner
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/jayantasamaddar/go-httpsigner/auth"
+)
+
+// Outcomes of a verification, used as labels by the `Metrics` hook
+const (
+	OUTCOME_OK            = "ok"            // The request was verified
+	OUTCOME_SKIPPED       = "skipped"       // The request is an unsigned CORS preflight let through the Verifier. See `sigv4.WithSkipPreflight`.
+	OUTCOME_EXPIRED       = "expired"       // The signature is no longer (or not yet) valid
+	OUTCOME_MISMATCH      = "mismatch"      // The signature does not match
+	OUTCOME_BACKEND_ERROR = "backend-error" // The secret could not be retrieved
+	OUTCOME_REPLAYED      = "replayed"      // The request has already been verified
+	OUTCOME_THROTTLED     = "throttled"     // The client has exceeded its rate limit
+	OUTCOME_TIMEOUT       = "timeout"       // The verification did not complete within its time budget
+	OUTCOME_INVALID       = "invalid"       // Any other failure, E.g. a missing or malformed signature
+)
+
+// The Metrics hook is called by the verification middleware after each verification,
+// with the outcome of the verification (one of the `OUTCOME_*` constants) and its latency.
+type Metrics interface {
+	ObserveVerification(outcome string, latency time.Duration)
+}
+
+// Classifies the error returned by a Verifier into an outcome
+func Outcome(err error) string {
+	switch {
+	case err == nil:
+		return OUTCOME_OK
+	case errors.Is(err, auth.ErrVerificationTimeout):
+		return OUTCOME_TIMEOUT
+	case errors.Is(err, auth.ErrSignatureExpired):
+		return OUTCOME_EXPIRED
+	case errors.Is(err, auth.ErrSignatureMismatch):
+		return OUTCOME_MISMATCH
+	case errors.Is(err, auth.ErrSecretUnavailable):
+		return OUTCOME_BACKEND_ERROR
+	case errors.Is(err, auth.ErrRequestReplayed):
+		return OUTCOME_REPLAYED
+	case errors.Is(err, auth.ErrRateLimited):
+		return OUTCOME_THROTTLED
+	default:
+		return OUTCOME_INVALID
+	}
+}
+
+// Default bucket upper bounds of a `LatencyHistograms`
+var DefaultLatencyBuckets = []time.Duration{
+	time.Millisecond,
+	5 * time.Millisecond,
+	10 * time.Millisecond,
+	25 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	250 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+	5 * time.Second,
+}
+
+// LatencyHistograms is an in-memory `Metrics` implementation keeping a latency histogram per outcome.
+// Use it directly, or as a reference to adapt the hook to a metrics library.
+type LatencyHistograms struct {
+	mu         sync.Mutex
+	buckets    []time.Duration
+	histograms map[string]*LatencyHistogram
+}
+
+// A snapshot of the latency histogram of an outcome.
+// `Counts[i]` is the number of observations less than or equal to `Buckets[i]`, `Counts[len(Buckets)]` the number of all observations.
+type LatencyHistogram struct {
+	Buckets []time.Duration
+	Counts  []uint64
+	Sum     time.Duration
+}
+
+// Create `LatencyHistograms` with the given bucket upper bounds, or `DefaultLatencyBuckets` if none are given.
+func NewLatencyHistograms(buckets ...time.Duration) *LatencyHistograms {
+	if len(buckets) == 0 {
+		buckets = DefaultLatencyBuckets
+	}
+	buckets = append([]time.Duration(nil), buckets...)
+	sort.Slice(buckets, func(i, j int) bool { return buckets[i] < buckets[j] })
+	return &LatencyHistograms{buckets: buckets, histograms: make(map[string]*LatencyHistogram)}
+}
+
+// `Metrics` implementation
+func (h *LatencyHistograms) ObserveVerification(outcome string, latency time.Duration) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	histogram, ok := h.histograms[outcome]
+	if !ok {
+		histogram = &LatencyHistogram{Buckets: h.buckets, Counts: make([]uint64, len(h.buckets)+1)}
+		h.histograms[outcome] = histogram
+	}
+	for i, bucket := range h.buckets {
+		if latency <= bucket {
+			histogram.Counts[i]++
+		}
+	}
+	histogram.Counts[len(h.buckets)]++
+	histogram.Sum += latency
+}
+
+// Returns a copy of the latency histograms by outcome
+func (h *LatencyHistograms) Snapshot() map[string]LatencyHistogram {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	snapshot := make(map[string]LatencyHistogram, len(h.histograms))
+	for outcome, histogram := range h.histograms {
+		snapshot[outcome] = LatencyHistogram{
+			Buckets: histogram.Buckets,
+			Counts:  append([]uint64(nil), histogram.Counts...),
+			Sum:     histogram.Sum,
+		}
+	}
+	return snapshot
+}
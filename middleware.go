@@ -0,0 +1,163 @@
+package httpsigner
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/jayantasamaddar/go-httpsigner/auth"
+)
+
+// A MiddlewareOption configures the verification middleware.
+type MiddlewareOption func(*middleware)
+
+type middleware struct {
+	verifier     auth.Verifier
+	metrics      Metrics
+	errorHandler func(w http.ResponseWriter, r *http.Request, err error)
+	// Shadow mode: never reject requests. See `WithShadowMode`.
+	shadow       bool
+	shadowReport func(r *http.Request, err error)
+	// Soft-fail mode: the header annotating the request with the outcome, instead of rejecting. See `WithSignatureStatusHeader`.
+	statusHeader string
+}
+
+// The default header of the soft-fail mode. See `WithSignatureStatusHeader`.
+const SIGNATURE_STATUS_HEADER = "X-Signature-Status"
+
+// Values of the header of the soft-fail mode. Failures are followed by the outcome, E.g. `failed; reason=mismatch`
+const (
+	SIGNATURE_STATUS_VERIFIED = "verified" // The request was verified
+	SIGNATURE_STATUS_FAILED   = "failed"   // The request is signed, but failed verification
+	SIGNATURE_STATUS_ABSENT   = "absent"   // The request is not signed (See `auth.ErrSignatureMissing`)
+	SIGNATURE_STATUS_SKIPPED  = "skipped"  // The request is a CORS preflight let through without verification (See `sigv4.WithSkipPreflight`)
+)
+
+// Record the outcome and latency of each verification with the `Metrics` hook
+func WithMetrics(metrics Metrics) MiddlewareOption {
+	return func(m *middleware) {
+		m.metrics = metrics
+	}
+}
+
+// Respond to requests that fail verification with `handler`, instead of the default `401 Unauthorized` (or `429 Too Many Requests`, `503 Service Unavailable`) response
+func WithErrorHandler(handler func(w http.ResponseWriter, r *http.Request, err error)) MiddlewareOption {
+	return func(m *middleware) {
+		m.errorHandler = handler
+	}
+}
+
+// # Shadow (dry-run) verification mode
+//
+// Verify each request and record the outcome with the `Metrics` hook, but never reject a request: requests that fail verification
+// are reported to `report` (if not nil), E.g. to be logged, and passed to the next handler without an Identity.
+// Intended for rolling out signature enforcement against existing traffic, to measure breakage before enforcing.
+func WithShadowMode(report func(r *http.Request, err error)) MiddlewareOption {
+	return func(m *middleware) {
+		m.shadow = true
+		m.shadowReport = report
+	}
+}
+
+// # Soft-fail mode
+//
+// Verify each request, but never reject a request: instead, annotate it with the outcome in the `header` (`SIGNATURE_STATUS_HEADER` if empty)
+// and pass it to the next handler, so that legacy backends make their own allow or deny decisions while migrating to signed requests.
+// The value is `verified`, `absent`, `skipped` (an unsigned CORS preflight, See `sigv4.WithSkipPreflight`), or `failed; reason=[outcome]` (E.g. `failed; reason=expired`, See `Outcome`).
+//
+// The header is trusted: any value sent by the client is replaced. The Identity of verified requests is added to the context as usual.
+func WithSignatureStatusHeader(header string) MiddlewareOption {
+	return func(m *middleware) {
+		if header == "" {
+			header = SIGNATURE_STATUS_HEADER
+		}
+		m.statusHeader = header
+	}
+}
+
+// `verificationOutcome` returns the outcome of the verification (See `Outcome`). A request the Verifier let through without verification
+// (E.g. an unsigned CORS preflight, See `sigv4.WithSkipPreflight` and `auth.SkippingVerifier`) is `OUTCOME_SKIPPED` rather than verified.
+func verificationOutcome(verifier auth.Verifier, r *http.Request, err error) string {
+	if err == nil && auth.SkipsVerification(verifier, r) {
+		return OUTCOME_SKIPPED
+	}
+	return Outcome(err)
+}
+
+// `signatureStatus` returns the value of the header of the soft-fail mode for the outcome and the error of the verification
+func signatureStatus(outcome string, err error) string {
+	switch {
+	case outcome == OUTCOME_SKIPPED:
+		return SIGNATURE_STATUS_SKIPPED
+	case err == nil:
+		return SIGNATURE_STATUS_VERIFIED
+	case errors.Is(err, auth.ErrSignatureMissing):
+		return SIGNATURE_STATUS_ABSENT
+	}
+	return SIGNATURE_STATUS_FAILED + "; reason=" + outcome
+}
+
+// # Verification middleware
+//
+// Returns a middleware that verifies each request with the Verifier before passing it to the next handler.
+// Requests that fail verification are rejected with a `401 Unauthorized` response, `429 Too Many Requests` if throttled,
+// or `503 Service Unavailable` if the verification timed out, unless configured otherwise.
+// If the Verifier is an `auth.Authenticator`, the Identity is added to the context of verified requests (See `auth.IdentityFromContext`).
+func Middleware(verifier auth.Verifier, opts ...MiddlewareOption) func(http.Handler) http.Handler {
+	m := &middleware{verifier: verifier, errorHandler: unauthorized}
+	for _, opt := range opts {
+		opt(m)
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			identity, err := authenticate(m.verifier, r)
+			outcome := verificationOutcome(m.verifier, r, err)
+			if m.metrics != nil {
+				m.metrics.ObserveVerification(outcome, time.Since(start))
+			}
+			recordVerification(r.Context(), outcome, identity)
+			if m.statusHeader != "" {
+				// Replaces any value sent by the client, after verification, as the client may have signed it
+				r.Header.Set(m.statusHeader, signatureStatus(outcome, err))
+			}
+			if err != nil {
+				if !m.shadow && m.statusHeader == "" {
+					m.errorHandler(w, r, err)
+					return
+				}
+				if m.shadowReport != nil {
+					m.shadowReport(r, err)
+				}
+				next.ServeHTTP(w, r)
+				return
+			}
+			if identity != nil {
+				r = r.WithContext(auth.WithIdentity(r.Context(), identity))
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// Verifies the request, returning the Identity if the Verifier is an `auth.Authenticator`
+func authenticate(verifier auth.Verifier, r *http.Request) (*auth.Identity, error) {
+	if authenticator, ok := verifier.(auth.Authenticator); ok {
+		return authenticator.Authenticate(r)
+	}
+	return nil, verifier.VerifySignature(r)
+}
+
+// The default error handler of the middleware
+func unauthorized(w http.ResponseWriter, r *http.Request, err error) {
+	if errors.Is(err, auth.ErrRateLimited) {
+		http.Error(w, http.StatusText(http.StatusTooManyRequests), http.StatusTooManyRequests)
+		return
+	}
+	if errors.Is(err, auth.ErrVerificationTimeout) {
+		http.Error(w, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+		return
+	}
+	http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+}
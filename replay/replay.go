@@ -0,0 +1,233 @@
+// Package replay loads captured requests from HAR files or raw HTTP messages, re-signs them with current credentials,
+// and optionally replays them, for load testing and incident reproduction against signed APIs. See `cmd/httpsigner-replay`.
+package replay
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jayantasamaddar/go-httpsigner/auth"
+)
+
+// Errors
+const (
+	ERROR_INCORRECT_FORMAT_HAR = "incorrectly formatted HAR file"
+	ERROR_INCORRECT_FORMAT_RAW = "incorrectly formatted raw HTTP message"
+)
+
+// Headers removed from captured requests before re-signing: the signature of the capture, and headers derived by `net/http`
+var StripHeaders = []string{"Authorization", "Content-Length", "Connection", "Transfer-Encoding", "Keep-Alive", "Upgrade", "Proxy-Connection", "Te", "Trailer"}
+
+// The subset of the HAR 1.2 format read by `LoadHAR`
+type har struct {
+	Log struct {
+		Entries []struct {
+			Request struct {
+				Method  string `json:"method"`
+				URL     string `json:"url"`
+				Headers []struct {
+					Name  string `json:"name"`
+					Value string `json:"value"`
+				} `json:"headers"`
+				PostData *struct {
+					MimeType string `json:"mimeType"`
+					Text     string `json:"text"`
+					Encoding string `json:"encoding"` // "base64" for binary payloads
+				} `json:"postData"`
+			} `json:"request"`
+		} `json:"entries"`
+	} `json:"log"`
+}
+
+// Loads the requests of the entries of a HAR file, in order
+func LoadHAR(r io.Reader) ([]*http.Request, error) {
+	h := new(har)
+	if err := json.NewDecoder(r).Decode(h); err != nil {
+		return nil, fmt.Errorf("%s: %w", ERROR_INCORRECT_FORMAT_HAR, err)
+	}
+
+	reqs := make([]*http.Request, 0, len(h.Log.Entries))
+	for i, entry := range h.Log.Entries {
+		var body []byte
+		if postData := entry.Request.PostData; postData != nil {
+			body = []byte(postData.Text)
+			if postData.Encoding == "base64" {
+				decoded, err := base64.StdEncoding.DecodeString(postData.Text)
+				if err != nil {
+					return nil, fmt.Errorf("%s: entry %d: %w", ERROR_INCORRECT_FORMAT_HAR, i, err)
+				}
+				body = decoded
+			}
+		}
+		req, err := http.NewRequest(entry.Request.Method, entry.Request.URL, bytes.NewReader(body))
+		if err != nil {
+			return nil, fmt.Errorf("%s: entry %d: %w", ERROR_INCORRECT_FORMAT_HAR, i, err)
+		}
+		for _, header := range entry.Request.Headers {
+			// HTTP/2 pseudo-headers (E.g. `:authority`) are part of the request line
+			if strings.HasPrefix(header.Name, ":") {
+				continue
+			}
+			if strings.EqualFold(header.Name, "Host") {
+				req.Host = header.Value
+				continue
+			}
+			req.Header.Add(header.Name, header.Value)
+		}
+		if postData := entry.Request.PostData; postData != nil && postData.MimeType != "" && req.Header.Get("Content-Type") == "" {
+			req.Header.Set("Content-Type", postData.MimeType)
+		}
+		reqs = append(reqs, req)
+	}
+	return reqs, nil
+}
+
+// Loads the requests of one or more consecutive raw HTTP/1.x messages (E.g. copied from a proxy or `curl -v`), in order.
+// The URLs of the requests are made absolute with the `scheme` (E.g. "https") and the `Host` header.
+func LoadRaw(r io.Reader, scheme string) ([]*http.Request, error) {
+	reader := bufio.NewReader(r)
+	var reqs []*http.Request
+	for {
+		// Skip the blank lines between messages
+		for {
+			b, err := reader.Peek(1)
+			if err != nil || (b[0] != '\r' && b[0] != '\n') {
+				break
+			}
+			reader.ReadByte()
+		}
+		if _, err := reader.Peek(1); errors.Is(err, io.EOF) {
+			return reqs, nil
+		}
+
+		parsed, err := http.ReadRequest(reader)
+		if err != nil {
+			return nil, fmt.Errorf("%s: message %d: %w", ERROR_INCORRECT_FORMAT_RAW, len(reqs), err)
+		}
+		body, err := io.ReadAll(parsed.Body)
+		if err != nil {
+			return nil, fmt.Errorf("%s: message %d: %w", ERROR_INCORRECT_FORMAT_RAW, len(reqs), err)
+		}
+		url := parsed.RequestURI
+		if !strings.Contains(url, "://") {
+			url = scheme + "://" + parsed.Host + url
+		}
+		req, err := http.NewRequest(parsed.Method, url, bytes.NewReader(body))
+		if err != nil {
+			return nil, fmt.Errorf("%s: message %d: %w", ERROR_INCORRECT_FORMAT_RAW, len(reqs), err)
+		}
+		req.Header = parsed.Header
+		req.Host = parsed.Host
+		reqs = append(reqs, req)
+	}
+}
+
+// Returns a copy of the captured request re-signed by the `signer`, with the headers in `StripHeaders` removed.
+// The captured request is not modified, hence it can be re-signed repeatedly.
+func Resign(signer auth.Signer, req *http.Request) (*http.Request, error) {
+	resigned := req.Clone(req.Context())
+	for _, header := range StripHeaders {
+		resigned.Header.Del(header)
+	}
+	if req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		resigned.Body = body
+	}
+	if err := signer.SignHTTPRequest(resigned); err != nil {
+		return nil, err
+	}
+	return resigned, nil
+}
+
+// The Result of replaying a request
+type Result struct {
+	Request    *http.Request // The captured request
+	StatusCode int           // The status code of the response. Zero if the request failed.
+	Duration   time.Duration // The duration of the round trip
+	Err        error         // The error re-signing or sending the request, if any
+}
+
+// A Replayer re-signs and sends captured requests
+type Replayer struct {
+	Signer auth.Signer
+	// The client sending the requests. `http.DefaultClient` if nil.
+	Client *http.Client
+	// The number of requests sent concurrently. One if not positive.
+	Concurrency int
+	// The number of times each request is sent. Once if not positive.
+	Repeat int
+}
+
+// Re-signs and sends each request `Repeat` times, with up to `Concurrency` requests in flight, until done or the context is done.
+// Every request is re-signed before each send, so that its signature is fresh. Returns the results in order of completion.
+func (rp *Replayer) Run(ctx context.Context, reqs []*http.Request) []Result {
+	client := rp.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	jobs := make(chan *http.Request)
+	go func() {
+		defer close(jobs)
+		for i := 0; i < max(rp.Repeat, 1); i++ {
+			for _, req := range reqs {
+				select {
+				case jobs <- req:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+
+	var mu sync.Mutex
+	var results []Result
+	var wg sync.WaitGroup
+	for i := 0; i < max(rp.Concurrency, 1); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for req := range jobs {
+				result := rp.send(ctx, client, req)
+				mu.Lock()
+				results = append(results, result)
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	return results
+}
+
+// `send` re-signs and sends the captured request, discarding the response body
+func (rp *Replayer) send(ctx context.Context, client *http.Client, req *http.Request) Result {
+	result := Result{Request: req}
+	resigned, err := Resign(rp.Signer, req.WithContext(ctx))
+	if err != nil {
+		result.Err = err
+		return result
+	}
+	start := time.Now()
+	res, err := client.Do(resigned)
+	result.Duration = time.Since(start)
+	if err != nil {
+		result.Err = err
+		return result
+	}
+	defer res.Body.Close()
+	io.Copy(io.Discard, res.Body)
+	result.StatusCode = res.StatusCode
+	return result
+}
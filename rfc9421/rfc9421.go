@@ -0,0 +1,186 @@
+// Package rfc9421 implements the signing side of HTTP Message Signatures (RFC 9421) with `hmac-sha256`,
+// and the `Content-Digest` header of Digest Fields (RFC 9530).
+package rfc9421
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jayantasamaddar/go-httpsigner/utils"
+)
+
+// Errors
+const (
+	ERROR_UNSUPPORTED_COMPONENT = "unsupported derived component"
+	ERROR_MISSING_COMPONENT     = "component not found on the request"
+)
+
+// The `alg` of HMAC using SHA-256
+const ALGORITHM_HMAC_SHA256 = "hmac-sha256"
+
+// The signature parameters of RFC 9421 Section 2.3. Zero values are omitted.
+type Params struct {
+	Created time.Time
+	KeyID   string
+	Alg     string
+}
+
+// Returns the serialized `@signature-params` of the covered `components` (E.g. `("@method" "@authority");created=1618884473;keyid="key"`),
+// which is also the value of the `Signature-Input` header for a label.
+func SignatureParams(components []string, params Params) string {
+	quoted := make([]string, len(components))
+	for i, component := range components {
+		quoted[i] = strconv.Quote(strings.ToLower(component))
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "(%s)", strings.Join(quoted, " "))
+	if !params.Created.IsZero() {
+		fmt.Fprintf(&b, ";created=%d", params.Created.Unix())
+	}
+	if params.KeyID != "" {
+		fmt.Fprintf(&b, ";keyid=%s", strconv.Quote(params.KeyID))
+	}
+	if params.Alg != "" {
+		fmt.Fprintf(&b, ";alg=%s", strconv.Quote(params.Alg))
+	}
+	return b.String()
+}
+
+// # Signature base
+//
+// Builds the signature base of RFC 9421 Section 2.5 for the request, covering the `components` in order.
+// Components are either header names or the derived components `@method`, `@authority`, `@scheme`, `@target-uri`, `@path` and `@query`.
+// `signatureParams` is the output of `SignatureParams` for the same components.
+func SignatureBase(req *http.Request, components []string, signatureParams string) (string, error) {
+	var b strings.Builder
+	for _, component := range components {
+		component = strings.ToLower(component)
+		value, err := componentValue(req, component)
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(&b, "%q: %s\n", component, value)
+	}
+	fmt.Fprintf(&b, "%q: %s", "@signature-params", signatureParams)
+	return b.String(), nil
+}
+
+// Returns the value of a component of the request
+func componentValue(req *http.Request, component string) (string, error) {
+	if !strings.HasPrefix(component, "@") {
+		values := req.Header.Values(component)
+		if len(values) == 0 {
+			return "", fmt.Errorf("%s: %q", ERROR_MISSING_COMPONENT, component)
+		}
+		// Multiple field values are combined as a list, with leading and trailing whitespace removed
+		for i, value := range values {
+			values[i] = strings.TrimSpace(value)
+		}
+		return strings.Join(values, ", "), nil
+	}
+
+	switch component {
+	case "@method":
+		return req.Method, nil
+	case "@authority":
+		host := req.Host
+		if host == "" {
+			host = req.URL.Host
+		}
+		return strings.ToLower(host), nil
+	case "@scheme":
+		if req.URL.Scheme == "" {
+			return "http", nil
+		}
+		return strings.ToLower(req.URL.Scheme), nil
+	case "@target-uri":
+		return req.URL.String(), nil
+	case "@path":
+		if path := req.URL.EscapedPath(); path != "" {
+			return path, nil
+		}
+		return "/", nil
+	case "@query":
+		return "?" + req.URL.RawQuery, nil
+	default:
+		return "", fmt.Errorf("%s: %q", ERROR_UNSUPPORTED_COMPONENT, component)
+	}
+}
+
+// Returns the `Content-Digest` header value (RFC 9530) of a SHA-256 checksum. E.g. `sha-256=:X48E9qOokqqrvdts8nOJRJN3OWDUoyWxBf7kbu9DBPE=:`
+func ContentDigestSHA256(sum []byte) string {
+	return "sha-256=:" + base64.StdEncoding.EncodeToString(sum) + ":"
+}
+
+// Returns the `hmac-sha256` signature of the signature base, as a byte sequence for the `Signature` header. E.g. `:pxcQw6G3...=:`
+func SignHMACSHA256(key []byte, signatureBase string) string {
+	return ":" + base64.StdEncoding.EncodeToString(utils.HMAC_SHA256.Sum(key, []byte(signatureBase))) + ":"
+}
+
+// Signs the request with `hmac-sha256`, covering the `components`, and sets the `Signature-Input` and `Signature` headers for the `label`.
+// An earlier signature of the `label` is replaced, and signatures of other labels already on the request are preserved.
+func Sign(req *http.Request, label string, components []string, key []byte, params Params) error {
+	signatureParams := SignatureParams(components, params)
+	base, err := SignatureBase(req, components, signatureParams)
+	if err != nil {
+		return err
+	}
+	RemoveSignature(req.Header, label)
+	req.Header.Add("Signature-Input", label+"="+signatureParams)
+	req.Header.Add("Signature", label+"="+SignHMACSHA256(key, base))
+	return nil
+}
+
+// Removes the signature of the `label` from the `Signature-Input` and `Signature` headers, preserving the signatures of other labels.
+// Headers left without a signature are deleted.
+func RemoveSignature(header http.Header, label string) {
+	for _, name := range []string{"Signature-Input", "Signature"} {
+		var members []string
+		for _, value := range header.Values(name) {
+			for _, member := range splitMembers(value) {
+				if key, _, _ := strings.Cut(member, "="); strings.TrimSpace(key) != label {
+					members = append(members, member)
+				}
+			}
+		}
+		header.Del(name)
+		if len(members) > 0 {
+			header.Set(name, strings.Join(members, ", "))
+		}
+	}
+}
+
+// Splits a Dictionary Structured Field (RFC 8941 Section 3.2) into its members, ignoring commas within strings and inner lists
+func splitMembers(value string) []string {
+	var members []string
+	depth, quoted, escaped, start := 0, false, false, 0
+	for i, c := range value {
+		switch {
+		case escaped:
+			escaped = false
+		case quoted && c == '\\':
+			escaped = true
+		case c == '"':
+			quoted = !quoted
+		case quoted:
+		case c == '(':
+			depth++
+		case c == ')':
+			depth--
+		case c == ',' && depth == 0:
+			if member := strings.TrimSpace(value[start:i]); member != "" {
+				members = append(members, member)
+			}
+			start = i + 1
+		}
+	}
+	if member := strings.TrimSpace(value[start:]); member != "" {
+		members = append(members, member)
+	}
+	return members
+}
@@ -0,0 +1,86 @@
+package rfc9421
+
+import (
+	"encoding/base64"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Test vector of RFC 9421 Appendix B.2.5: Signing a request using `hmac-sha256`
+func Test_SignHMACSHA256_RFCVector(t *testing.T) {
+	key, _ := base64.StdEncoding.DecodeString("uzvJfB4u3N0Jy4T7NZ75MDVcr8zSTInedJtkgcu46YW4XByzNJjxBdtjUkdJPBtbmHhIDi6pcl8jsasjlTMtDQ==")
+
+	req, _ := http.NewRequest(http.MethodPost, "http://example.com/foo?param=Value&Pet=dog", nil)
+	req.Header.Set("Date", "Tue, 20 Apr 2021 02:07:55 GMT")
+	req.Header.Set("Content-Type", "application/json")
+
+	components := []string{"date", "@authority", "content-type"}
+	params := SignatureParams(components, Params{Created: time.Unix(1618884473, 0), KeyID: "test-shared-secret"})
+	base, err := SignatureBase(req, components, params)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expectedBase := `"date": Tue, 20 Apr 2021 02:07:55 GMT
+"@authority": example.com
+"content-type": application/json
+"@signature-params": ("date" "@authority" "content-type");created=1618884473;keyid="test-shared-secret"`
+	if base != expectedBase {
+		t.Errorf("Signature base mismatch; expected:\n%s\ngot:\n%s", expectedBase, base)
+	}
+
+	if signature := SignHMACSHA256(key, base); signature != ":pxcQw6G3AjtMBQjwo8XzkZf/bws5LelbaMk5rGIGtE8=:" {
+		t.Errorf("Signature mismatch, got: %s", signature)
+	}
+}
+
+// Test the derived components
+func Test_SignatureBase_DerivedComponents(t *testing.T) {
+	req, _ := http.NewRequest(http.MethodPost, "https://Example.com/path/a%20b?x=1", nil)
+	components := []string{"@method", "@authority", "@scheme", "@path", "@query"}
+	base, err := SignatureBase(req, components, SignatureParams(components, Params{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `"@method": POST
+"@authority": example.com
+"@scheme": https
+"@path": /path/a%20b
+"@query": ?x=1
+"@signature-params": ("@method" "@authority" "@scheme" "@path" "@query")`
+	if base != expected {
+		t.Errorf("Signature base mismatch; expected:\n%s\ngot:\n%s", expected, base)
+	}
+
+	if _, err := SignatureBase(req, []string{"content-digest"}, ""); err == nil {
+		t.Error("Expected an error for a missing header")
+	}
+}
+
+// Test that signing again replaces the signature of the label, preserving the signatures of other labels
+func Test_Sign_ReplacesLabel(t *testing.T) {
+	req, _ := http.NewRequest(http.MethodGet, "http://example.com/foo", nil)
+	req.Header.Add("Signature-Input", `other=("@method");keyid="a, b"`)
+	req.Header.Add("Signature", "other=:b3RoZXI=:")
+
+	for i := 0; i < 2; i++ {
+		if err := Sign(req, "sig1", []string{"@method", "@authority"}, []byte("key"), Params{Created: time.Unix(int64(i), 0)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	expectedInput := `other=("@method");keyid="a, b", sig1=("@method" "@authority");created=1`
+	if got := strings.Join(req.Header.Values("Signature-Input"), ", "); got != expectedInput {
+		t.Errorf("Expected Signature-Input: %q, got: %q", expectedInput, got)
+	}
+	if got := strings.Join(req.Header.Values("Signature"), ", "); strings.Count(got, "sig1=") != 1 || !strings.HasPrefix(got, "other=:b3RoZXI=:, ") {
+		t.Errorf("Unexpected Signature: %q", got)
+	}
+
+	RemoveSignature(req.Header, "sig1")
+	RemoveSignature(req.Header, "other")
+	if len(req.Header.Values("Signature-Input")) != 0 || len(req.Header.Values("Signature")) != 0 {
+		t.Errorf("Expected the signature headers to be deleted, got: %v", req.Header)
+	}
+}
@@ -0,0 +1,174 @@
+package httpsigner
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/jayantasamaddar/go-httpsigner/auth"
+)
+
+// Errors
+const (
+	ERROR_UNKNOWN_SCHEME        = "signing scheme is not registered"
+	ERROR_SCHEME_CANNOT_SIGN    = "signing scheme has no Signer"
+	ERROR_SCHEME_CANNOT_VERIFY  = "signing scheme has no Verifier"
+	ERROR_SCHEME_NOT_DETECTABLE = "signing scheme cannot detect its requests"
+	ERROR_NO_MATCHING_SCHEME    = "no signing scheme matches the request"
+)
+
+// A SchemeFactory constructs the Signers and Verifiers of a signing scheme. See `RegisterScheme`.
+type SchemeFactory struct {
+	// Constructs a Signer of the scheme with the arguments passed to `NewSchemeSigner`. Nil for schemes that only verify.
+	NewSigner func(args ...any) (auth.Signer, error)
+	// Constructs a Verifier of the scheme with the arguments passed to `NewSchemeVerifier`. Nil for schemes that only sign.
+	NewVerifier func(args ...any) (auth.Verifier, error)
+	// Reports whether the request carries a signature of the scheme, E.g. from the algorithm of its `Authorization` header,
+	// so that the multi-verifier selects the Verifier of the scheme (See `NewMultiVerifier`).
+	Detect func(req *http.Request) bool
+}
+
+var (
+	schemesMu sync.RWMutex
+	schemes   = map[string]SchemeFactory{}
+)
+
+// # Signing scheme registration
+//
+// Registers a signing scheme under the `name` (case-insensitive), replacing any existing scheme of the name, so that its Signers and Verifiers
+// are constructed with `NewSchemeSigner` and `NewSchemeVerifier`, and its requests are selected by `NewMultiVerifier`.
+// Third-party packages usually register their schemes in an `init` function, so that importing the package is enough.
+// E.g. `httpsigner.RegisterScheme("hmac-sha512", httpsigner.SchemeFactory{NewSigner: newSigner, NewVerifier: newVerifier, Detect: isSigned})`
+func RegisterScheme(name string, factory SchemeFactory) {
+	schemesMu.Lock()
+	defer schemesMu.Unlock()
+	schemes[strings.ToLower(name)] = factory
+}
+
+// Returns the sorted names of the registered signing schemes. See `RegisterScheme`.
+func Schemes() []string {
+	schemesMu.RLock()
+	defer schemesMu.RUnlock()
+	names := make([]string, 0, len(schemes))
+	for name := range schemes {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// `scheme` returns the factory of a registered signing scheme
+func scheme(name string) (SchemeFactory, error) {
+	schemesMu.RLock()
+	defer schemesMu.RUnlock()
+	factory, ok := schemes[strings.ToLower(name)]
+	if !ok {
+		return SchemeFactory{}, fmt.Errorf("%s: %q", ERROR_UNKNOWN_SCHEME, name)
+	}
+	return factory, nil
+}
+
+// Constructs a Signer of the registered signing scheme `name` with the `args` of its factory. See `RegisterScheme`.
+func NewSchemeSigner(name string, args ...any) (auth.Signer, error) {
+	factory, err := scheme(name)
+	if err != nil {
+		return nil, err
+	}
+	if factory.NewSigner == nil {
+		return nil, fmt.Errorf("%s: %q", ERROR_SCHEME_CANNOT_SIGN, name)
+	}
+	return factory.NewSigner(args...)
+}
+
+// Constructs a Verifier of the registered signing scheme `name` with the `args` of its factory. See `RegisterScheme`.
+func NewSchemeVerifier(name string, args ...any) (auth.Verifier, error) {
+	factory, err := scheme(name)
+	if err != nil {
+		return nil, err
+	}
+	if factory.NewVerifier == nil {
+		return nil, fmt.Errorf("%s: %q", ERROR_SCHEME_CANNOT_VERIFY, name)
+	}
+	return factory.NewVerifier(args...)
+}
+
+// A Verifier of a signing scheme, selected by the `detect` of the scheme
+type schemeVerifier struct {
+	name     string
+	detect   func(req *http.Request) bool
+	verifier auth.Verifier
+}
+
+type multiVerifier struct {
+	verifiers []schemeVerifier
+}
+
+// # Multi-scheme verification
+//
+// Returns a Verifier of the requests signed with any of the registered signing schemes, keyed by name in `verifiers`
+// (E.g. during a migration from one scheme to another). Each request is verified by the Verifier of the first scheme,
+// in the order of the names, whose `Detect` matches the request. Requests matching no scheme fail with `auth.ErrSignatureMissing`.
+//
+// The returned Verifier is an `auth.Authenticator`, an `auth.ContextVerifier` and an `auth.SkippingVerifier`, delegating to the selected Verifier if it is one.
+// Returns an error if a scheme is not registered, or cannot detect its requests.
+func NewMultiVerifier(verifiers map[string]auth.Verifier) (auth.Verifier, error) {
+	m := &multiVerifier{verifiers: make([]schemeVerifier, 0, len(verifiers))}
+	for name, verifier := range verifiers {
+		factory, err := scheme(name)
+		if err != nil {
+			return nil, err
+		}
+		if factory.Detect == nil {
+			return nil, fmt.Errorf("%s: %q", ERROR_SCHEME_NOT_DETECTABLE, name)
+		}
+		m.verifiers = append(m.verifiers, schemeVerifier{name: strings.ToLower(name), detect: factory.Detect, verifier: verifier})
+	}
+	slices.SortFunc(m.verifiers, func(a, b schemeVerifier) int { return strings.Compare(a.name, b.name) })
+	return m, nil
+}
+
+// `verifierOf` returns the Verifier of the first scheme detecting the request
+func (m *multiVerifier) verifierOf(req *http.Request) (auth.Verifier, error) {
+	for _, v := range m.verifiers {
+		if v.detect(req) {
+			return v.verifier, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: %s", auth.ErrSignatureMissing, ERROR_NO_MATCHING_SCHEME)
+}
+
+// `auth.Verifier` implementation
+func (m *multiVerifier) VerifySignature(req *http.Request) error {
+	verifier, err := m.verifierOf(req)
+	if err != nil {
+		return err
+	}
+	return verifier.VerifySignature(req)
+}
+
+// `auth.ContextVerifier` implementation
+func (m *multiVerifier) VerifySignatureContext(ctx context.Context, req *http.Request) error {
+	verifier, err := m.verifierOf(req)
+	if err != nil {
+		return err
+	}
+	return auth.VerifySignatureContext(ctx, verifier, req)
+}
+
+// `auth.SkippingVerifier` implementation
+func (m *multiVerifier) SkipsVerification(req *http.Request) bool {
+	verifier, err := m.verifierOf(req)
+	return err == nil && auth.SkipsVerification(verifier, req)
+}
+
+// `auth.Authenticator` implementation. The Identity is nil if the selected Verifier is not an `auth.Authenticator`.
+func (m *multiVerifier) Authenticate(req *http.Request) (*auth.Identity, error) {
+	verifier, err := m.verifierOf(req)
+	if err != nil {
+		return nil, err
+	}
+	return authenticate(verifier, req)
+}
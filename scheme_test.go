@@ -0,0 +1,90 @@
+package httpsigner
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/jayantasamaddar/go-httpsigner/auth"
+)
+
+// A Signer setting the `Authorization` header to a fixed token of the scheme
+type tokenSigner string
+
+func (s tokenSigner) SignHTTPRequest(req *http.Request) error {
+	req.Header.Set("Authorization", string(s))
+	return nil
+}
+
+// Registers a scheme signing with a token, whose Verifier checks the token
+func registerTokenScheme(name, prefix string) {
+	RegisterScheme(name, SchemeFactory{
+		NewSigner: func(args ...any) (auth.Signer, error) {
+			return tokenSigner(prefix + " " + args[0].(string)), nil
+		},
+		NewVerifier: func(args ...any) (auth.Verifier, error) {
+			token := prefix + " " + args[0].(string)
+			return verifierFunc(func(req *http.Request) error {
+				if req.Header.Get("Authorization") != token {
+					return auth.ErrSignatureMismatch
+				}
+				return nil
+			}), nil
+		},
+		Detect: func(req *http.Request) bool {
+			return strings.HasPrefix(req.Header.Get("Authorization"), prefix+" ")
+		},
+	})
+}
+
+// Test that registered schemes are constructed by name, and that the multi-verifier selects the Verifier of the scheme of each request
+func Test_RegisterScheme(t *testing.T) {
+	registerTokenScheme("Test-Alpha", "ALPHA")
+	registerTokenScheme("test-beta", "BETA")
+	RegisterScheme("test-sign-only", SchemeFactory{NewSigner: func(args ...any) (auth.Signer, error) { return tokenSigner("X"), nil }})
+
+	if names := Schemes(); !slices.Contains(names, "test-alpha") || !slices.Contains(names, "test-beta") {
+		t.Errorf("Expected the registered schemes, got: %v", names)
+	}
+	if _, err := NewSchemeSigner("test-unknown"); err == nil || !strings.Contains(err.Error(), ERROR_UNKNOWN_SCHEME) {
+		t.Errorf("Expected error: %q, got: %v", ERROR_UNKNOWN_SCHEME, err)
+	}
+	if _, err := NewSchemeVerifier("test-sign-only"); err == nil || !strings.Contains(err.Error(), ERROR_SCHEME_CANNOT_VERIFY) {
+		t.Errorf("Expected error: %q, got: %v", ERROR_SCHEME_CANNOT_VERIFY, err)
+	}
+
+	alphaVerifier, _ := NewSchemeVerifier("test-alpha", "a-secret")
+	betaVerifier, _ := NewSchemeVerifier("TEST-BETA", "b-secret")
+	verifier, err := NewMultiVerifier(map[string]auth.Verifier{"test-alpha": alphaVerifier, "test-beta": betaVerifier})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewMultiVerifier(map[string]auth.Verifier{"test-sign-only": alphaVerifier}); err == nil || !strings.Contains(err.Error(), ERROR_SCHEME_NOT_DETECTABLE) {
+		t.Errorf("Expected error: %q, got: %v", ERROR_SCHEME_NOT_DETECTABLE, err)
+	}
+
+	for _, tc := range []struct {
+		scheme, token string
+		expected      error
+	}{
+		{"test-alpha", "a-secret", nil},
+		{"test-beta", "b-secret", nil},
+		{"test-beta", "a-secret", auth.ErrSignatureMismatch},
+		{"test-sign-only", "", auth.ErrSignatureMissing},
+	} {
+		signer, err := NewSchemeSigner(tc.scheme, tc.token)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		if err := signer.SignHTTPRequest(req); err != nil {
+			t.Fatal(err)
+		}
+		if err := verifier.VerifySignature(req); !errors.Is(err, tc.expected) {
+			t.Errorf("Expected error: %v for scheme %q, got: %v", tc.expected, tc.scheme, err)
+		}
+	}
+}
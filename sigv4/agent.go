@@ -0,0 +1,119 @@
+package sigv4
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/jayantasamaddar/go-httpsigner/agent"
+	"github.com/jayantasamaddar/go-httpsigner/auth"
+)
+
+// Errors
+const (
+	ERROR_AGENT_MESSAGE_SIGNATURE = "RFC 9421 message signatures are not supported with a signing agent"
+)
+
+// The default timeout of the requests to the signing agent
+const DEFAULT_AGENT_TIMEOUT = 5 * time.Second
+
+// `agentSigner` delegates signing to the signing agent, which holds the secret
+type agentSigner struct {
+	client *agent.Client
+
+	mu          sync.Mutex
+	accessKeyID string
+	region      string
+}
+
+// Set the timeout of connecting and of each request to the signing agent. Defaults to `DEFAULT_AGENT_TIMEOUT`.
+// Only used by `NewSigV4AgentSigner`.
+func WithAgentTimeout(timeout time.Duration) Option {
+	return func(s *SigV4) {
+		s.agentTimeout = timeout
+	}
+}
+
+// # Constructor to create a Signer delegating to a signing agent
+//
+// Creates a Signer like `NewSigV4Signer`, that asks the signing agent listening on the unix socket at `socketPath` (See `cmd/httpsigner-agent`)
+// for the `ACCESS_KEY_ID`, the region and the signatures, so that the process never possesses the `SECRET_ACCESS_KEY`.
+//
+// The agent is contacted on the first request, and reconnected to if the connection fails. See `WithAgentTimeout`.
+func NewSigV4AgentSigner(org, abbr, service, socketPath string, opts ...Option) (auth.Signer, error) {
+	if !isValidService(service) {
+		return nil, &ErrInvalidService{Service: service}
+	}
+	s := &SigV4{org: org, abbr: abbr, service: service, env: new(SigV4EnvConfig), agentTimeout: DEFAULT_AGENT_TIMEOUT}
+	agentSource := FieldSource{Origin: CREDENTIAL_SOURCE_AGENT}
+	s.credentialSource = SourceReport{AccessKeyID: agentSource, SecretAccessKey: agentSource, Region: agentSource}
+	for _, opt := range servicePreset(service, opts) {
+		opt(s)
+	}
+	if org == "" {
+		s.org = "AWS"
+	}
+	if abbr == "" {
+		s.abbr = "amz"
+	}
+	if !isAlphanumeric(s.org) {
+		return nil, &ErrInvalidOrg{Org: s.org}
+	}
+	if !isAlphanumeric(s.abbr) {
+		return nil, &ErrInvalidAbbr{Abbr: s.abbr}
+	}
+	if err := s.validateOptions(); err != nil {
+		return nil, err
+	}
+	s.agent = &agentSigner{client: agent.NewClient(socketPath, s.agentTimeout)}
+	return s, nil
+}
+
+// `identity` returns the `ACCESS_KEY_ID` and the region of the agent, asking the agent once
+func (a *agentSigner) identity(ctx context.Context) (accessKeyID, region string, err error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.accessKeyID == "" {
+		if a.accessKeyID, a.region, err = a.client.Identity(ctx); err != nil {
+			return "", "", fmt.Errorf("signing agent: %w", err)
+		}
+	}
+	return a.accessKeyID, a.region, nil
+}
+
+// `signingIdentity` returns the `ACCESS_KEY_ID` and the region the Signer signs with
+func (s *SigV4) signingIdentity(ctx context.Context) (accessKeyID, region string, err error) {
+	if s.agent != nil {
+		return s.agent.identity(ctx)
+	}
+	return s.env.ACCESS_KEY_ID, s.env.REGION, nil
+}
+
+// `sign` calculates the signature of the `stringToSign` of the scope, with the `SECRET_ACCESS_KEY` or by the signing agent
+func (s *SigV4) sign(ctx context.Context, algorithm string, t time.Time, region, stringToSign string) (string, error) {
+	if s.agent != nil {
+		res, err := s.agent.client.Do(ctx, &agent.Request{
+			Op:           agent.OP_SIGN,
+			Algorithm:    algorithm,
+			Date:         s.scopeDate(t),
+			Region:       region,
+			Service:      s.service,
+			StringToSign: stringToSign,
+			Terminator:   s.terminator,
+			KeyPrefix:    s.signingKeyPrefix(),
+		})
+		if err != nil {
+			return "", fmt.Errorf("signing agent: %w", err)
+		}
+		return res.Signature, nil
+	}
+
+	// (3) Generate the `SigningKey`
+	sk, err := s.signingKey(algorithm, s.env.SECRET_ACCESS_KEY, t, region, s.service)
+	if err != nil {
+		return "", err
+	}
+	// (4) Calculate the signature
+	return s.generateSignature(algorithm, sk, stringToSign)
+}
@@ -0,0 +1,86 @@
+package sigv4
+
+import (
+	"net/http"
+	"path/filepath"
+	"testing"
+
+	"github.com/jayantasamaddar/go-httpsigner/agent"
+)
+
+// Starts a signing agent with the test credentials on `socketPath`
+func startSigningAgent(t *testing.T, socketPath string) *agent.Server {
+	t.Helper()
+	listener, err := agent.Listen(socketPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := agent.NewServer(agent.Credentials{
+		ACCESS_KEY_ID:     testEnvConfig.ACCESS_KEY_ID,
+		SECRET_ACCESS_KEY: testEnvConfig.SECRET_ACCESS_KEY,
+		REGION:            testEnvConfig.REGION,
+	})
+	go server.Serve(listener)
+	t.Cleanup(func() { server.Close() })
+	return server
+}
+
+// Test that requests signed through the signing agent verify, and that the Signer reconnects after the agent restarts
+func Test_NewSigV4AgentSigner(t *testing.T) {
+	server := newSecretRetrievalServer(t, testEnvConfig.SECRET_ACCESS_KEY)
+	verifier, _ := NewSigV4Verifier("SYM", "sym", "certificatemanager", server.URL)
+
+	socketPath := filepath.Join(t.TempDir(), "agent.sock")
+	signer, err := NewSigV4AgentSigner("SYM", "sym", "certificatemanager", socketPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if source := signer.(*SigV4).CredentialSource(); source.SecretAccessKey.Origin != CREDENTIAL_SOURCE_AGENT {
+		t.Errorf("Expected the secret to be held by the agent, got: %s", source)
+	}
+
+	sign := func() error {
+		req, _ := http.NewRequest(http.MethodGet, "http://validate.127.0.0.1.sslip.io/api/cmagent", nil)
+		if err := signer.SignHTTPRequest(req); err != nil {
+			return err
+		}
+		return verifier.VerifySignature(req)
+	}
+
+	// The agent is not running yet
+	if err := sign(); err == nil {
+		t.Error("Expected an error without a running agent")
+	}
+
+	agentServer := startSigningAgent(t, socketPath)
+	if err := sign(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Restart the agent
+	agentServer.Close()
+	startSigningAgent(t, socketPath)
+	if err := sign(); err != nil {
+		t.Errorf("Expected the Signer to reconnect, got: %v", err)
+	}
+}
+
+// Test that the signing agent signs with the terminator and the signing key prefix of the Signer
+func Test_NewSigV4AgentSigner_ScopeTerminator(t *testing.T) {
+	server := newSecretRetrievalServer(t, testEnvConfig.SECRET_ACCESS_KEY)
+	verifier, _ := NewSigV4Verifier("ZEN", "zen", "certificatemanager", server.URL, WithScopeTerminator("zen4_request"))
+
+	socketPath := filepath.Join(t.TempDir(), "agent.sock")
+	startSigningAgent(t, socketPath)
+	signer, err := NewSigV4AgentSigner("ZEN", "zen", "certificatemanager", socketPath, WithScopeTerminator("zen4_request"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req, _ := http.NewRequest(http.MethodGet, "http://validate.127.0.0.1.sslip.io/api/cmagent", nil)
+	if err := signer.SignHTTPRequest(req); err != nil {
+		t.Fatal(err)
+	}
+	if err := verifier.VerifySignature(req); err != nil {
+		t.Error(err)
+	}
+}
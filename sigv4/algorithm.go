@@ -0,0 +1,82 @@
+package sigv4
+
+import (
+	"fmt"
+	"hash"
+	"slices"
+
+	"github.com/jayantasamaddar/go-httpsigner/sigv4core"
+)
+
+// Errors
+const (
+	ERROR_UNSUPPORTED_ALGORITHM = "unsupported signing algorithm"
+)
+
+// The algorithm signed with and accepted by default, labelled for the org (See `SigV4.AlgorithmName`)
+const DEFAULT_ALGORITHM = sigv4core.ALGORITHM_HMAC_SHA256
+
+// Returns the name of the default algorithm labelled for the org, written in the `Authorization` header and the `stringToSign`.
+// E.g. `SYM4-HMAC-SHA256` for the org "SYM", and `AWS4-HMAC-SHA256` for the org "AWS". See `sigv4core.AlgorithmName`.
+func (s *SigV4) AlgorithmName() string {
+	return sigv4core.AlgorithmName(s.org, DEFAULT_ALGORITHM)
+}
+
+// # Algorithm negotiation and fallback
+//
+// Sign requests with the `preferred` algorithm (E.g. `AWS4-HMAC-SHA512`). If `fallback` is not empty,
+// the request is also signed with the `fallback` algorithm and the resulting authorization is declared in the `X-[Abbr]-Fallback-Authorization` header,
+// so that Verifiers that do not accept the preferred algorithm yet can verify the fallback instead.
+// This enables staged migrations: upgrade the Signers first, then the Verifiers (See `WithAcceptedAlgorithms`), then drop the fallback.
+//
+// Supported algorithms are `[ORG]4-HMAC-SHA256` (the default, See `SigV4.AlgorithmName`) and `[ORG]4-HMAC-SHA512`, E.g. `SYM4-HMAC-SHA512`.
+// The algorithms are written as given: the `AWS4-` names of earlier Signers of a custom org can still be signed with, E.g. as the `fallback`.
+// Signing fails with an unsupported algorithm.
+func WithAlgorithm(preferred, fallback string) Option {
+	return func(s *SigV4) {
+		s.algorithm = preferred
+		s.fallbackAlgorithm = fallback
+	}
+}
+
+// Set the allow-list of the algorithms accepted by the Verifier, matched exactly. E.g. `SYM4-HMAC-SHA256`.
+//
+// Defaults to the `SigV4.AlgorithmName` of the org, and to `AWS4-HMAC-SHA256` which Signers of a custom org wrote before the algorithm was labelled for the org.
+// Set the allow-list to the name of the org only, once every Signer is upgraded.
+//
+// If the algorithm of the `Authorization` header is not accepted, the `X-[Abbr]-Fallback-Authorization` header is verified instead, if accepted.
+func WithAcceptedAlgorithms(algorithms ...string) Option {
+	return func(s *SigV4) {
+		s.acceptedAlgorithms = algorithms
+	}
+}
+
+// Returns the name of the header declaring the fallback authorization
+func (s *SigV4) fallbackHeaderName() string {
+	return fmt.Sprintf("X-%s-Fallback-Authorization", s.abbr)
+}
+
+// Returns the algorithm the Signer signs with
+func (s *SigV4) signingAlgorithm() string {
+	if s.algorithm == "" {
+		return s.AlgorithmName()
+	}
+	return s.algorithm
+}
+
+// Checks if the Verifier accepts the algorithm
+func (s *SigV4) isAcceptedAlgorithm(algorithm string) bool {
+	if len(s.acceptedAlgorithms) == 0 {
+		return algorithm == s.AlgorithmName() || algorithm == DEFAULT_ALGORITHM
+	}
+	return slices.Contains(s.acceptedAlgorithms, algorithm)
+}
+
+// Returns the hash function of the algorithm. See `sigv4core.HashFunc`.
+func hashFunc(algorithm string) (func() hash.Hash, error) {
+	newHash, ok := sigv4core.HashFunc(algorithm)
+	if !ok {
+		return nil, fmt.Errorf("%s: %q", ERROR_UNSUPPORTED_ALGORITHM, algorithm)
+	}
+	return newHash, nil
+}
@@ -0,0 +1,116 @@
+package sigv4
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/jayantasamaddar/go-httpsigner/auth"
+	"github.com/jayantasamaddar/go-httpsigner/sigv4core"
+)
+
+// Test a staged migration from `SYM4-HMAC-SHA256` to `SYM4-HMAC-SHA512`
+func Test_AlgorithmNegotiation(t *testing.T) {
+	sha256 := sigv4core.AlgorithmName("SYM", sigv4core.ALGORITHM_HMAC_SHA256)
+	sha512 := sigv4core.AlgorithmName("SYM", sigv4core.ALGORITHM_HMAC_SHA512)
+	server := newSecretRetrievalServer(t, testEnvConfig.SECRET_ACCESS_KEY)
+
+	legacySigner, _ := NewSigV4Signer("SYM", "sym", "certificatemanager", testEnvConfig, false)
+	migratingSigner, _ := NewSigV4Signer("SYM", "sym", "certificatemanager", testEnvConfig, false,
+		WithAlgorithm(sha512, sha256))
+	upgradedSigner, _ := NewSigV4Signer("SYM", "sym", "certificatemanager", testEnvConfig, false,
+		WithAlgorithm(sha512, ""))
+
+	legacyVerifier, _ := NewSigV4Verifier("SYM", "sym", "certificatemanager", server.URL)
+	migratingVerifier, _ := NewSigV4Verifier("SYM", "sym", "certificatemanager", server.URL,
+		WithAcceptedAlgorithms(sha512, sha256))
+	upgradedVerifier, _ := NewSigV4Verifier("SYM", "sym", "certificatemanager", server.URL,
+		WithAcceptedAlgorithms(sha512))
+
+	tests := []struct {
+		name     string
+		signer   auth.Signer
+		verifier *SigV4
+		verified bool
+	}{
+		{"legacy signer, legacy verifier", legacySigner, legacyVerifier.(*SigV4), true},
+		{"migrating signer, legacy verifier", migratingSigner, legacyVerifier.(*SigV4), true},
+		{"migrating signer, migrating verifier", migratingSigner, migratingVerifier.(*SigV4), true},
+		{"migrating signer, upgraded verifier", migratingSigner, upgradedVerifier.(*SigV4), true},
+		{"legacy signer, migrating verifier", legacySigner, migratingVerifier.(*SigV4), true},
+		{"legacy signer, upgraded verifier", legacySigner, upgradedVerifier.(*SigV4), false},
+		{"upgraded signer, legacy verifier", upgradedSigner, legacyVerifier.(*SigV4), false},
+	}
+	for _, test := range tests {
+		req, _ := http.NewRequest(http.MethodGet, "http://validate.127.0.0.1.sslip.io/api/cmagent", nil)
+		if err := test.signer.SignHTTPRequest(req); err != nil {
+			t.Fatal(err)
+		}
+		report, err := test.verifier.Explain(req)
+		if (err == nil) != test.verified {
+			t.Errorf("%s: expected verified: %t, got: %v", test.name, test.verified, err)
+		}
+		if !test.verified && report.FailedStage != STAGE_ALGORITHM {
+			t.Errorf("%s: expected stage %q, got: %q", test.name, STAGE_ALGORITHM, report.FailedStage)
+		}
+	}
+
+	// The preferred algorithm is used when accepted
+	req, _ := http.NewRequest(http.MethodGet, "http://validate.127.0.0.1.sslip.io/api/cmagent", nil)
+	_ = migratingSigner.SignHTTPRequest(req)
+	if !strings.HasPrefix(req.Header.Get("Authorization"), sha512) || req.Header.Get("X-Sym-Fallback-Authorization") == "" {
+		t.Errorf("Unexpected headers: %v", req.Header)
+	}
+	if report, _ := migratingVerifier.(*SigV4).Explain(req); report.Algorithm != sha512 {
+		t.Errorf("Expected algorithm %q, got: %q", sha512, report.Algorithm)
+	}
+
+	// Unsupported algorithm
+	invalidSigner, _ := NewSigV4Signer("SYM", "sym", "certificatemanager", testEnvConfig, false, WithAlgorithm("AWS4-HMAC-MD5", ""))
+	if err := invalidSigner.SignHTTPRequest(req); err == nil || !strings.Contains(err.Error(), ERROR_UNSUPPORTED_ALGORITHM) {
+		t.Errorf("Expected error: %q, got: %v", ERROR_UNSUPPORTED_ALGORITHM, err)
+	}
+}
+
+// Test that the algorithm is labelled for the org, and that the `AWS4-HMAC-SHA256` label of earlier Signers is accepted unless restricted
+func Test_AlgorithmName(t *testing.T) {
+	server := newSecretRetrievalServer(t, testEnvConfig.SECRET_ACCESS_KEY)
+
+	signer, _ := NewSigV4Signer("SYM", "sym", "certificatemanager", testEnvConfig, false)
+	legacySigner, _ := NewSigV4Signer("SYM", "sym", "certificatemanager", testEnvConfig, false, WithAlgorithm(DEFAULT_ALGORITHM, ""))
+	verifier, _ := NewSigV4Verifier("SYM", "sym", "certificatemanager", server.URL)
+	strictVerifier, _ := NewSigV4Verifier("SYM", "sym", "certificatemanager", server.URL, WithAcceptedAlgorithms("SYM4-HMAC-SHA256"))
+
+	if name := signer.(*SigV4).AlgorithmName(); name != "SYM4-HMAC-SHA256" {
+		t.Errorf("Expected: %q, got: %q", "SYM4-HMAC-SHA256", name)
+	}
+	awsSigner, _ := NewSigV4Signer("AWS", "amz", "certificatemanager", testEnvConfig, false)
+	if name := awsSigner.(*SigV4).AlgorithmName(); name != DEFAULT_ALGORITHM {
+		t.Errorf("Expected: %q, got: %q", DEFAULT_ALGORITHM, name)
+	}
+
+	tests := []struct {
+		name     string
+		signer   auth.Signer
+		verifier auth.Verifier
+		prefix   string
+		verified bool
+	}{
+		{"signer, verifier", signer, verifier, "SYM4-HMAC-SHA256 ", true},
+		{"legacy signer, verifier", legacySigner, verifier, "AWS4-HMAC-SHA256 ", true},
+		{"signer, restricted verifier", signer, strictVerifier, "SYM4-HMAC-SHA256 ", true},
+		{"legacy signer, restricted verifier", legacySigner, strictVerifier, "AWS4-HMAC-SHA256 ", false},
+	}
+	for _, test := range tests {
+		req, _ := http.NewRequest(http.MethodGet, "http://validate.127.0.0.1.sslip.io/api/cmagent", nil)
+		if err := test.signer.SignHTTPRequest(req); err != nil {
+			t.Fatal(err)
+		}
+		if header := req.Header.Get("Authorization"); !strings.HasPrefix(header, test.prefix) {
+			t.Errorf("%s: expected the prefix %q, got: %q", test.name, test.prefix, header)
+		}
+		if err := test.verifier.VerifySignature(req); (err == nil) != test.verified {
+			t.Errorf("%s: expected verified: %t, got: %v", test.name, test.verified, err)
+		}
+	}
+}
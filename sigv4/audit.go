@@ -0,0 +1,102 @@
+package sigv4
+
+import (
+	"crypto/hmac"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/jayantasamaddar/go-httpsigner/auth"
+	"github.com/jayantasamaddar/go-httpsigner/sigv4core"
+)
+
+// Errors
+const (
+	ERROR_INCORRECT_FORMAT_AUDIT_RECORD = "incorrectly formatted audit record"
+)
+
+// # Audit records
+//
+// An AuditRecord holds the exact canonical form of a signed request and its signature metadata, without any secret,
+// so that a disputed request can be re-verified offline from logs with `ReVerify`. Marshals to compact JSON.
+//
+// The canonical request contains the values of the signed headers, and the hash of the payload rather than the payload.
+type AuditRecord struct {
+	KeyID     string `json:"key_id"`
+	Algorithm string `json:"algorithm"`
+	DateTime  string `json:"date_time"` // The date of the `stringToSign`
+	Scope     string `json:"scope"`     // The credential scope. E.g. `2015830/us-east-1/s3/aws4_request`
+	// The prefix of the secret in the derivation of the signing key, if not `DEFAULT_SIGNING_KEY_PREFIX`. See `WithScopeTerminator`.
+	KeyPrefix        string   `json:"key_prefix,omitempty"`
+	SignedHeaders    []string `json:"signed_headers"`
+	CanonicalRequest string   `json:"canonical_request"`
+	Signature        string   `json:"signature"`
+}
+
+// Returns the AuditRecord of a request signed in the `Authorization` header, as canonicalized by the Verifier.
+// The request is not verified, and its body is read and reassigned.
+func (s *SigV4) AuditRecord(req *http.Request) (*AuditRecord, error) {
+	authorization, _ := s.signatureAuthorization(req)
+	authHeaders, err := s.parseAuthHeaders(authorization)
+	if err != nil {
+		return nil, err
+	}
+	signingTime, err := s.parseDate(getHeader(req.Header, s.dateHeader()))
+	if err != nil {
+		return nil, err
+	}
+	clonedReq, err := s.signedHeadersRequest(req, authHeaders.SignedHeaders)
+	if err != nil {
+		return nil, err
+	}
+	payloadHash, contentLength, err := s.payloadHash(req.Context(), clonedReq)
+	if err != nil {
+		return nil, err
+	}
+	canonicalRequest, err := s.signedCanonicalRequest(clonedReq, authHeaders.SignedHeaders, payloadHash, contentLength)
+	if err != nil {
+		return nil, err
+	}
+	req.Body = clonedReq.Body // The req.Body gets read inside the canonicalRequest, and needs to be reassigned
+
+	var keyPrefix string
+	if prefix := s.signingKeyPrefix(); prefix != DEFAULT_SIGNING_KEY_PREFIX {
+		keyPrefix = prefix
+	}
+	return &AuditRecord{
+		KeyID:            authHeaders.Credential.ACCESS_KEY_ID,
+		Algorithm:        authHeaders.Algorithm,
+		DateTime:         s.formatDate(signingTime),
+		Scope:            s.getCredentialScope(signingTime, authHeaders.Credential.Region, authHeaders.Credential.Service),
+		KeyPrefix:        keyPrefix,
+		SignedHeaders:    authHeaders.SignedHeaders,
+		CanonicalRequest: canonicalRequest,
+		Signature:        authHeaders.Signature,
+	}, nil
+}
+
+// # Offline re-verification
+//
+// Re-verifies the signature of an AuditRecord with the `secret` of its key, without the original request or the secret backend.
+// Returns an error wrapping `auth.ErrSignatureMismatch` if the signature does not match. Expiry is not checked, as records are verified after the fact.
+func ReVerify(record *AuditRecord, secret string) error {
+	scope := strings.Split(record.Scope, "/")
+	if len(scope) != 4 {
+		return fmt.Errorf("%s: scope %q", ERROR_INCORRECT_FORMAT_AUDIT_RECORD, record.Scope)
+	}
+	newHash, err := hashFunc(record.Algorithm)
+	if err != nil {
+		return err
+	}
+	stringToSign := sigv4core.StringToSign(record.Algorithm, record.DateTime, record.Scope, record.CanonicalRequest)
+	keyPrefix := record.KeyPrefix
+	if keyPrefix == "" {
+		keyPrefix = DEFAULT_SIGNING_KEY_PREFIX
+	}
+	signingKey := sigv4core.SigningKeyHash(newHash, keyPrefix, secret, scope[0], scope[1], scope[2], scope[3])
+	signature := sigv4core.SignatureHash(newHash, signingKey, stringToSign)
+	if !hmac.Equal([]byte(signature), []byte(record.Signature)) {
+		return fmt.Errorf("%w: %s", auth.ErrSignatureMismatch, ERROR_SIGNATURE_MISMATCH)
+	}
+	return nil
+}
@@ -0,0 +1,62 @@
+package sigv4
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/jayantasamaddar/go-httpsigner/auth"
+)
+
+// Test that a request is re-verified offline from its audit record, and that tampered records or wrong secrets are rejected
+func Test_ReVerify(t *testing.T) {
+	signer, _ := NewSigV4Signer("SYM", "sym", "certificatemanager", testEnvConfig, false)
+	verifier, _ := NewSigV4Verifier("SYM", "sym", "certificatemanager", "http://keys.internal/api/secret")
+
+	req, _ := http.NewRequest(http.MethodPost, "http://validate.127.0.0.1.sslip.io/api/cmagent?id=1", bytes.NewBufferString(`{"name":"example"}`))
+	if err := signer.SignHTTPRequest(req); err != nil {
+		t.Fatal(err)
+	}
+	record, err := verifier.(*SigV4).AuditRecord(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Round-trip through the logs
+	b, err := json.Marshal(record)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(b), testEnvConfig.SECRET_ACCESS_KEY) {
+		t.Fatal("Expected the audit record not to contain the secret")
+	}
+	logged := new(AuditRecord)
+	if err := json.Unmarshal(b, logged); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ReVerify(logged, testEnvConfig.SECRET_ACCESS_KEY); err != nil {
+		t.Errorf("Expected the record to be re-verified, got: %v", err)
+	}
+	if err := ReVerify(logged, "wrong-secret"); !errors.Is(err, auth.ErrSignatureMismatch) {
+		t.Errorf("Expected auth.ErrSignatureMismatch for a wrong secret, got: %v", err)
+	}
+	tampered := *logged
+	tampered.CanonicalRequest = strings.Replace(tampered.CanonicalRequest, "id=1", "id=2", 1)
+	if err := ReVerify(&tampered, testEnvConfig.SECRET_ACCESS_KEY); !errors.Is(err, auth.ErrSignatureMismatch) {
+		t.Errorf("Expected auth.ErrSignatureMismatch for a tampered record, got: %v", err)
+	}
+	tampered.Scope = "malformed"
+	if err := ReVerify(&tampered, testEnvConfig.SECRET_ACCESS_KEY); err == nil || !strings.HasPrefix(err.Error(), ERROR_INCORRECT_FORMAT_AUDIT_RECORD) {
+		t.Errorf("Expected error: %s, got: %v", ERROR_INCORRECT_FORMAT_AUDIT_RECORD, err)
+	}
+
+	// The body remains readable
+	if body, _ := io.ReadAll(req.Body); string(body) != `{"name":"example"}` {
+		t.Errorf("Expected the body to be reassigned, got: %q", body)
+	}
+}
@@ -0,0 +1,54 @@
+package sigv4
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// Errors
+const (
+	ERROR_INVALID_AUTH_MODE = "invalid authentication mode"
+)
+
+// An AuthMode selects where `SignHTTPRequestAs` places the signature of a request.
+type AuthMode int
+
+const (
+	// Sign the request in the `Authorization` header. See `SignHTTPRequest`.
+	AuthInHeader AuthMode = iota
+	// Sign the request in the query string, E.g. for links opened by browsers and redirect-based downloads. See `PresignHTTPRequest`.
+	AuthInQuery
+)
+
+// The validity of the requests signed with `AuthInQuery`
+const DEFAULT_QUERY_AUTH_EXPIRES = 15 * time.Minute
+
+// Returns the name of the mode. E.g. `header`
+func (m AuthMode) String() string {
+	switch m {
+	case AuthInHeader:
+		return "header"
+	case AuthInQuery:
+		return "query"
+	}
+	return fmt.Sprintf("AuthMode(%d)", int(m))
+}
+
+// # Per-call authentication mode
+//
+// Signs the request in the `Authorization` header (`AuthInHeader`, See `SignHTTPRequest`), or in the query string (`AuthInQuery`),
+// so that a single Signer serves both API clients and URLs handed to browsers. A request signed in the query string is valid for
+// `DEFAULT_QUERY_AUTH_EXPIRES`, without constraints (See `PresignHTTPRequest` for other validities), and any `Authorization` header is removed.
+//
+// The Verifier accepts both modes without configuration.
+func (s *SigV4) SignHTTPRequestAs(req *http.Request, mode AuthMode) error {
+	switch mode {
+	case AuthInHeader:
+		return s.SignHTTPRequest(req)
+	case AuthInQuery:
+		deleteHeader(req.Header, "Authorization")
+		return s.PresignHTTPRequest(req, DEFAULT_QUERY_AUTH_EXPIRES, nil)
+	}
+	return fmt.Errorf("%s: %v", ERROR_INVALID_AUTH_MODE, mode)
+}
@@ -0,0 +1,51 @@
+package sigv4
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// Test that a single Signer signs requests in the header or in the query per call, and that the Verifier accepts both
+func Test_SignHTTPRequestAs(t *testing.T) {
+	server := newSecretRetrievalServer(t, testEnvConfig.SECRET_ACCESS_KEY)
+	signer, err := NewSigV4Signer("SYM", "sym", "certificatemanager", testEnvConfig, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	verifier, err := NewSigV4Verifier("SYM", "sym", "certificatemanager", server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := signer.(*SigV4)
+
+	req, _ := http.NewRequest(http.MethodGet, "http://validate.127.0.0.1.sslip.io/api/cmagent/report.pdf", nil)
+	if err := s.SignHTTPRequestAs(req, AuthInHeader); err != nil {
+		t.Fatal(err)
+	}
+	if req.Header.Get("Authorization") == "" || strings.Contains(req.URL.RawQuery, s.queryParamName("Signature")) {
+		t.Errorf("Expected a signature in the header, got: %q, %q", req.Header.Get("Authorization"), req.URL.RawQuery)
+	}
+	if err := verifier.VerifySignature(req); err != nil {
+		t.Error(err)
+	}
+
+	// The same request, re-signed in the query, e.g. as a download link
+	if err := s.SignHTTPRequestAs(req, AuthInQuery); err != nil {
+		t.Fatal(err)
+	}
+	if req.Header.Get("Authorization") != "" || !strings.Contains(req.URL.RawQuery, s.queryParamName("Expires")+"=900") {
+		t.Errorf("Expected a signature in the query, got: %q, %q", req.Header.Get("Authorization"), req.URL.RawQuery)
+	}
+	link, _ := http.NewRequest(http.MethodGet, req.URL.String(), nil)
+	if err := verifier.VerifySignature(link); err != nil {
+		t.Error(err)
+	}
+
+	if err := s.SignHTTPRequestAs(req, AuthMode(7)); err == nil || !strings.Contains(err.Error(), ERROR_INVALID_AUTH_MODE) {
+		t.Errorf("Expected error %q, got: %v", ERROR_INVALID_AUTH_MODE, err)
+	}
+	if AuthInQuery.String() != "query" {
+		t.Errorf("Unexpected name: %q", AuthInQuery)
+	}
+}
@@ -0,0 +1,59 @@
+package sigv4
+
+import (
+	"strings"
+	"time"
+)
+
+// The format of the Date Header in strict AWS mode: ISO 8601 basic format. E.g. `20130524T000000Z`
+const AWS_DATE_FORMAT = "20060102T150405Z"
+
+// The format of the date of the credential scope in strict AWS mode: zero-padded `YYYYMMDD`. E.g. `20130524`
+const AWS_SCOPE_DATE_FORMAT = "20060102"
+
+// # Strict AWS interoperability mode
+//
+// Produces and verifies signatures exactly as AWS does, so that requests signed by this package are accepted by AWS endpoints,
+// and requests signed by aws-sdk clients are accepted by the Verifier:
+//   - The Date Header is in ISO 8601 basic format in UTC (See `AWS_DATE_FORMAT`), instead of RFC 3339 with nanoseconds.
+//   - The date of the credential scope and of the signing key is the zero-padded UTC date (See `AWS_SCOPE_DATE_FORMAT`), instead of `%d%d%d`.
+//   - Query parameters are encoded following RFC 3986 (See `WithStrictQueryEncoding`).
+//   - Every non-ASCII byte of the path and the query is percent-encoded (See `WithStrictURIEncoding`).
+//   - The `CanonicalHeaders` are followed by a blank line in the `CanonicalRequest`.
+//   - The `content-length` is only signed for non-empty payloads, as `net/http` does not send it for e.g. GET requests.
+//   - The parts of the `Authorization` header are separated by ", ".
+//
+// Signatures of the default (legacy) mode and of the strict AWS mode are not interchangeable: use the option on both the Signer and the Verifier.
+// Use the "AWS" org and the "amz" abbr to sign requests to AWS. E.g.
+//
+//	signer, err := sigv4.NewSigV4Signer("AWS", "amz", "execute-api", nil, false, sigv4.WithStrictAWSMode())
+func WithStrictAWSMode() Option {
+	return func(s *SigV4) {
+		s.strictAWS = true
+		s.scopes.zeroPadded = true
+		WithStrictQueryEncoding(true)(s)
+		WithStrictURIEncoding(true)(s)
+	}
+}
+
+// `authorizationSeparator` returns the separator of the parts of the `Authorization` header
+func (s *SigV4) authorizationSeparator() string {
+	if s.strictAWS {
+		return ", "
+	}
+	return ","
+}
+
+// `normalizeAuthorization` removes the spaces following the separators of the parts of the `Authorization` header in strict AWS mode,
+// as aws-sdk clients separate them with ", "
+func (s *SigV4) normalizeAuthorization(str string) string {
+	if !s.strictAWS {
+		return str
+	}
+	return strings.ReplaceAll(str, ", ", ",")
+}
+
+// `scopeDate` returns the date of the credential scope and of the signing key
+func (s *SigV4) scopeDate(t time.Time) string {
+	return formatScopeDate(t, s.strictAWS)
+}
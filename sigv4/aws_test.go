@@ -0,0 +1,86 @@
+package sigv4
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+// Test that the `get-vanilla` vector of the AWS SigV4 test suite is signed exactly as AWS does in strict AWS mode
+func Test_StrictAWSMode_GetVanilla(t *testing.T) {
+	env := &SigV4EnvConfig{
+		ACCESS_KEY_ID:     "AKIDEXAMPLE",
+		SECRET_ACCESS_KEY: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY",
+		REGION:            "us-east-1",
+	}
+	signer, err := NewSigV4Signer("AWS", "amz", "service", env, false, WithStrictAWSMode())
+	if err != nil {
+		t.Fatal(err)
+	}
+	req, _ := http.NewRequest(http.MethodGet, "https://example.amazonaws.com/", nil)
+	signingTime := time.Date(2015, 8, 30, 12, 36, 0, 0, time.FixedZone("IST", 5*60*60+30*60))
+	if err := signer.(*SigV4).signHTTPRequestAt(req.Context(), req, signingTime, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if date := req.Header.Get("X-Amz-Date"); date != "20150830T070600Z" {
+		t.Errorf("Expected the date: %s, got: %s", "20150830T070600Z", date)
+	}
+	req, _ = http.NewRequest(http.MethodGet, "https://example.amazonaws.com/", nil)
+	signingTime = time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC)
+	if err := signer.(*SigV4).signHTTPRequestAt(req.Context(), req, signingTime, nil); err != nil {
+		t.Fatal(err)
+	}
+	expected := "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, SignedHeaders=host;x-amz-date, Signature=5fa00fa31553b73ebf1942676e86291e8372ff2a2260956d9b8aae1d763fbf31"
+	if auth := req.Header.Get("Authorization"); auth != expected {
+		t.Errorf("Expected: %s, got: %s", expected, auth)
+	}
+}
+
+// Test that the scope date is zero-padded in strict AWS mode, and in UTC
+func Test_StrictAWSMode_ScopeDate(t *testing.T) {
+	s := &SigV4{}
+	WithStrictAWSMode()(s)
+	tm := time.Date(2024, time.March, 10, 1, 0, 0, 0, time.FixedZone("IST", 5*60*60+30*60))
+	if scope := s.getCredentialScope(tm, "ap-south-1", "s3"); scope != "20240309/ap-south-1/s3/aws4_request" {
+		t.Errorf("Unexpected scope: %q", scope)
+	}
+	if date := s.scopeDate(tm); date != "20240309" {
+		t.Errorf("Unexpected scope date: %q", date)
+	}
+
+	// Legacy dates are rejected
+	if _, err := s.parseDate((&SigV4{}).formatDate(tm)); err == nil {
+		t.Error("Expected an RFC 3339 date to be rejected in strict AWS mode")
+	}
+}
+
+// Test that requests signed in strict AWS mode are verified by a Verifier in strict AWS mode only
+func Test_StrictAWSMode_Verify(t *testing.T) {
+	secretServer := newSecretRetrievalServer(t, testEnvConfig.SECRET_ACCESS_KEY)
+	signer, err := NewSigV4Signer("SYM", "sym", "certificatemanager", testEnvConfig, true, WithStrictAWSMode())
+	if err != nil {
+		t.Fatal(err)
+	}
+	verifier, err := NewSigV4Verifier("SYM", "sym", "certificatemanager", secretServer.URL, WithStrictAWSMode())
+	if err != nil {
+		t.Fatal(err)
+	}
+	legacy, err := NewSigV4Verifier("SYM", "sym", "certificatemanager", secretServer.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, method := range []string{http.MethodGet, http.MethodPost} {
+		req, _ := http.NewRequest(method, "http://validate.127.0.0.1.sslip.io/api/cmagent?q=a+b&tag=x%2By", nil)
+		if err := signer.SignHTTPRequest(req); err != nil {
+			t.Fatal(err)
+		}
+		if err := verifier.VerifySignature(req); err != nil {
+			t.Errorf("%s: %v", method, err)
+		}
+		if err := legacy.VerifySignature(req); err == nil {
+			t.Errorf("%s: Expected the legacy Verifier to reject the request", method)
+		}
+	}
+}
@@ -0,0 +1,124 @@
+package sigv4
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/jayantasamaddar/go-httpsigner/secretapi"
+)
+
+// The default window during which the secret lookups are coalesced into a batch. See `WithSecretBatching`.
+const DEFAULT_SECRET_BATCH_WINDOW = 5 * time.Millisecond
+
+// # Secret retrieval batching
+//
+// Coalesce the secret lookups of the Verifier started within `window` of each other into a single call to the batched endpoint at `batchURL`
+// (See `secretapi.BatchRequest`), of up to `maxBatch` lookups, reducing the load on the key service during traffic spikes of distinct access keys.
+// The `window` defaults to `DEFAULT_SECRET_BATCH_WINDOW` if not positive, and `maxBatch` to `secretapi.MAX_BATCH_SIZE` if not positive or greater.
+//
+// Each lookup waits at most `window` for its batch, then for the call to the key service, and is retried like a single lookup if the call fails
+// (See `WithSecretRetrievalBackoff`). Use it for key services serving the batched endpoint, E.g. with `secretapi.BatchHandler`.
+// Returns an `*ErrInvalidSecretRetrievalURL` from the constructor if `batchURL` is not an absolute http(s) URL.
+func WithSecretBatching(batchURL string, window time.Duration, maxBatch int) Option {
+	return func(s *SigV4) {
+		if window <= 0 {
+			window = DEFAULT_SECRET_BATCH_WINDOW
+		}
+		if maxBatch <= 0 || maxBatch > secretapi.MAX_BATCH_SIZE {
+			maxBatch = secretapi.MAX_BATCH_SIZE
+		}
+		s.secretBatcher = &secretBatcher{
+			url:      batchURL,
+			client:   secretapi.NewClient(batchURL, &http.Client{Timeout: 15 * time.Second}),
+			window:   window,
+			maxBatch: maxBatch,
+		}
+	}
+}
+
+// `secretBatcher` coalesces the secret lookups started within the `window` into batches
+type secretBatcher struct {
+	url      string
+	client   *secretapi.Client
+	window   time.Duration
+	maxBatch int
+
+	mu      sync.Mutex
+	pending []*batchedLookup
+	timer   *time.Timer
+}
+
+// A lookup waiting for its batch
+type batchedLookup struct {
+	request  secretapi.Request
+	response *secretapi.Response
+	err      error
+	done     chan struct{}
+}
+
+// `retrieve` adds the request to the pending batch, sent once the `window` elapses or the batch is full, and waits for its result
+func (b *secretBatcher) retrieve(ctx context.Context, r *secretapi.Request) (*secretapi.Response, error) {
+	lookup := &batchedLookup{request: *r, done: make(chan struct{})}
+
+	b.mu.Lock()
+	b.pending = append(b.pending, lookup)
+	var batch []*batchedLookup
+	switch {
+	case len(b.pending) >= b.maxBatch:
+		batch = b.takePending()
+	case len(b.pending) == 1:
+		b.timer = time.AfterFunc(b.window, b.flush)
+	}
+	b.mu.Unlock()
+	if batch != nil {
+		go b.send(batch)
+	}
+
+	select {
+	case <-lookup.done:
+		return lookup.response, lookup.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// `flush` sends the pending batch, once the `window` elapsed
+func (b *secretBatcher) flush() {
+	b.mu.Lock()
+	batch := b.takePending()
+	b.mu.Unlock()
+	if len(batch) > 0 {
+		b.send(batch)
+	}
+}
+
+// `takePending` returns the pending batch and starts a new one. Must be called with the lock held.
+func (b *secretBatcher) takePending() []*batchedLookup {
+	if b.timer != nil {
+		b.timer.Stop()
+		b.timer = nil
+	}
+	batch := b.pending
+	b.pending = nil
+	return batch
+}
+
+// `send` retrieves the secrets of the batch in a single call, and hands each lookup its result.
+// The call is not canceled with the lookups, as it serves the other lookups of the batch.
+func (b *secretBatcher) send(batch []*batchedLookup) {
+	req := &secretapi.BatchRequest{Requests: make([]secretapi.Request, len(batch))}
+	for i, lookup := range batch {
+		req.Requests[i] = lookup.request
+	}
+	res, err := b.client.RetrieveBatch(context.Background(), req)
+	for i, lookup := range batch {
+		if err != nil {
+			lookup.err = err
+		} else if lookup.err = res.Results[i].Err(); lookup.err == nil {
+			lookup.response = res.Results[i].Response
+		}
+		close(lookup.done)
+	}
+}
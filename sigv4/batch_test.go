@@ -0,0 +1,87 @@
+package sigv4
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/jayantasamaddar/go-httpsigner/auth"
+	"github.com/jayantasamaddar/go-httpsigner/secretapi"
+	"github.com/jayantasamaddar/go-httpsigner/utils/backoff"
+)
+
+// Test that concurrent lookups of distinct access keys are coalesced into a single batched call, and rejected keys fail alone
+func Test_WithSecretBatching(t *testing.T) {
+	const keys = 8
+	var batches, lookups atomic.Int32
+	batchHandler := secretapi.BatchHandler(func(ctx context.Context, r *secretapi.Request) (*secretapi.Response, error) {
+		lookups.Add(1)
+		if r.AccessKeyID == "AKIAUNKNOWN" {
+			return nil, &secretapi.ErrorResponse{Code: secretapi.CODE_UNKNOWN_ACCESS_KEY}
+		}
+		return &secretapi.Response{SecretAccessKey: "secret-" + r.AccessKeyID}, nil
+	})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != secretapi.DEFAULT_BATCH_PATH {
+			t.Errorf("Unexpected single lookup: %s", r.URL.Path)
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		batches.Add(1)
+		batchHandler.ServeHTTP(w, r)
+	}))
+	defer server.Close()
+
+	verifier, err := NewSigV4Verifier("SYM", "sym", "certificatemanager", server.URL+secretapi.DEFAULT_PATH,
+		WithSecretBatching(server.URL+secretapi.DEFAULT_BATCH_PATH, 50*time.Millisecond, 0), WithSecretRetrievalBackoff(backoff.Constant(0, 0)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	errs := make([]error, keys+1)
+	for i := 0; i <= keys; i++ {
+		accessKeyID := fmt.Sprintf("AKIAEXAMPLE%d", i)
+		if i == keys {
+			accessKeyID = "AKIAUNKNOWN"
+		}
+		env := &SigV4EnvConfig{ACCESS_KEY_ID: accessKeyID, SECRET_ACCESS_KEY: "secret-" + accessKeyID, REGION: "ap-south-1"}
+		signer, _ := NewSigV4Signer("SYM", "sym", "certificatemanager", env, false)
+		req, _ := http.NewRequest(http.MethodGet, "http://validate.127.0.0.1.sslip.io/api/cmagent", nil)
+		if err := signer.SignHTTPRequest(req); err != nil {
+			t.Fatal(err)
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = verifier.VerifySignature(req)
+		}()
+	}
+	wg.Wait()
+
+	for i, err := range errs[:keys] {
+		if err != nil {
+			t.Errorf("Key %d: %v", i, err)
+		}
+	}
+	if !errors.Is(errs[keys], auth.ErrSecretUnavailable) {
+		t.Errorf("Expected the unknown key to fail with auth.ErrSecretUnavailable, got: %v", errs[keys])
+	}
+	if n := batches.Load(); n != 1 {
+		t.Errorf("Expected 1 batched call, got: %d", n)
+	}
+	if n := lookups.Load(); n != keys+1 {
+		t.Errorf("Expected %d lookups, got: %d", keys+1, n)
+	}
+
+	// The batch URL is validated
+	if _, err := NewSigV4Verifier("SYM", "sym", "certificatemanager", server.URL, WithSecretBatching("/v1/secrets", 0, 0)); err == nil {
+		t.Error("Expected a relative batch URL to be rejected")
+	}
+}
@@ -0,0 +1,49 @@
+package sigv4
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/jayantasamaddar/go-httpsigner/auth"
+)
+
+// The cause of the context of a verification canceled by the verification budget
+var errVerificationBudgetExceeded = errors.New("verification budget exceeded")
+
+// # Verification budget
+//
+// Bound the total time of a single verification, covering the parsing, the secret retrieval with its retries, and the hashing of the payload,
+// so that the authentication layer has a predictable worst-case latency. A verification exceeding the `budget` fails with an `*ErrVerificationTimeout`.
+//
+// The budget is applied on top of the context of the verification (the context of the request, unless verified with `VerifySignatureContext`), hence a request canceled by its client still fails with the error of its context.
+// A non-positive `budget` disables the limit.
+func WithVerificationBudget(budget time.Duration) Option {
+	return func(s *SigV4) {
+		s.verificationBudget = budget
+	}
+}
+
+// `withBudget` runs a verification pipeline within the verification budget, if any, turning the failures caused by the budget into an `*ErrVerificationTimeout`
+func (s *SigV4) withBudget(ctx context.Context, req *http.Request, report *VerificationReport, pipeline func(ctx context.Context, req *http.Request, report *VerificationReport) (*auth.Identity, error)) (*auth.Identity, error) {
+	if s.verificationBudget <= 0 {
+		return pipeline(ctx, req, report)
+	}
+	ctx, cancel := context.WithTimeoutCause(ctx, s.verificationBudget, errVerificationBudgetExceeded)
+	defer cancel()
+
+	identity, err := pipeline(ctx, req, report)
+	if err != nil && context.Cause(ctx) == errVerificationBudgetExceeded {
+		return nil, &ErrVerificationTimeout{Budget: s.verificationBudget, Stage: report.FailedStage, Err: err}
+	}
+	return identity, err
+}
+
+// `checkBudget` returns the error of the context if the verification budget is exhausted, E.g. after a stage that does not observe the context
+func checkBudget(ctx context.Context) error {
+	if context.Cause(ctx) == errVerificationBudgetExceeded {
+		return ctx.Err()
+	}
+	return nil
+}
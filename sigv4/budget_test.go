@@ -0,0 +1,54 @@
+package sigv4
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/jayantasamaddar/go-httpsigner/auth"
+	"github.com/jayantasamaddar/go-httpsigner/utils/backoff"
+)
+
+// Test that a verification stalled on the secret backend fails with a timeout once the budget is exhausted
+func Test_VerificationBudget(t *testing.T) {
+	secretServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(5 * time.Second):
+		}
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer secretServer.Close()
+
+	signer, err := NewSigV4Signer("SYM", "sym", "certificatemanager", testEnvConfig, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	verifier, err := NewSigV4Verifier("SYM", "sym", "certificatemanager", secretServer.URL,
+		WithSecretRetrievalBackoff(backoff.Constant(time.Second, 3)),
+		WithVerificationBudget(50*time.Millisecond),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req, _ := http.NewRequest(http.MethodGet, "http://validate.127.0.0.1.sslip.io/api/cmagent", nil)
+	if err := signer.SignHTTPRequest(req); err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now()
+	err = verifier.VerifySignature(req)
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Expected the verification to end within the budget, took: %s", elapsed)
+	}
+
+	var timeout *ErrVerificationTimeout
+	if !errors.As(err, &timeout) || !errors.Is(err, auth.ErrVerificationTimeout) {
+		t.Fatalf("Expected: %v, got: %v", auth.ErrVerificationTimeout, err)
+	}
+	if timeout.Stage != STAGE_SECRET || timeout.Budget != 50*time.Millisecond {
+		t.Errorf("Unexpected timeout: %v", timeout)
+	}
+}
@@ -0,0 +1,132 @@
+package sigv4
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/jayantasamaddar/go-httpsigner/auth"
+)
+
+// Errors
+const (
+	ERROR_INCORRECT_FORMAT_CHAIN = "incorrectly formatted signature chain"
+)
+
+// A hop of the signature chain: the signature of an earlier hop, with the values of the request it covered that later hops replace
+type chainEntry struct {
+	Date          string `json:"date"`
+	Host          string `json:"host"`
+	Authorization string `json:"authorization"`
+}
+
+// # Signature chaining for multi-hop gateways
+//
+// Signer: if the request is already signed (E.g. by a client, when the Signer is a gateway), the existing signature is preserved as a hop
+// in the `X-[Abbr]-Signature-Chain` header before re-signing the request with the identity of the Signer. The chain header is signed along
+// with the other headers, binding the earlier hops to the signature of the Signer.
+//
+// Verifier: after verifying the signature of the request, every hop of the chain is verified too, and their identities are returned in
+// `auth.Identity.Chain`, starting with the client. A request fails verification if any hop fails.
+//
+// Hops may replace the host and the Date Header, which are preserved in the chain, but must not modify the other headers signed by earlier hops.
+// A hop rewriting the host must pass the original host in the `X-Forwarded-Host` header, which should be skipped (See `WithSkipHeaders`)
+// along with the other headers rewritten by every hop.
+func WithSignatureChaining(chain bool) Option {
+	return func(s *SigV4) {
+		s.chain = chain
+	}
+}
+
+// Returns the name of the header carrying the signature chain
+func (s *SigV4) chainHeaderName() string {
+	return fmt.Sprintf("X-%s-Signature-Chain", s.abbr)
+}
+
+// `appendChainEntry` preserves the existing signature of the request as a hop of the chain
+func (s *SigV4) appendChainEntry(req *http.Request) error {
+	authorization := req.Header.Get("Authorization")
+	if authorization == "" {
+		return nil
+	}
+	// Gateways rewriting the host pass the host signed by the earlier hop in `X-Forwarded-Host` (E.g. `httputil.ProxyRequest.SetXForwarded`)
+	host := s.canonicalHost(req)
+	if forwarded := req.Header.Get("X-Forwarded-Host"); forwarded != "" {
+		host = strings.TrimSpace(strings.Split(forwarded, ",")[0])
+	}
+	b, err := json.Marshal(&chainEntry{
+		Date:          getHeader(req.Header, s.dateHeader()),
+		Host:          host,
+		Authorization: authorization,
+	})
+	if err != nil {
+		return err
+	}
+
+	entries := slices.Clone(headerValues(req.Header, s.chainHeaderName()))
+	entries = append(entries, base64.RawURLEncoding.EncodeToString(b))
+	s.setHeader(req.Header, s.chainHeaderName(), strings.Join(entries, ","))
+	req.Header.Del("Authorization")
+	return nil
+}
+
+// `chainEntries` returns the hops of the signature chain of the request, starting with the client, and their encoded form.
+func (s *SigV4) chainEntries(req *http.Request) ([]*chainEntry, []string, error) {
+	var entries []*chainEntry
+	var encodedEntries []string
+	for _, value := range headerValues(req.Header, s.chainHeaderName()) {
+		for _, encoded := range strings.Split(value, ",") {
+			if encoded = strings.TrimSpace(encoded); encoded == "" {
+				continue
+			}
+			b, err := base64.RawURLEncoding.DecodeString(encoded)
+			if err != nil {
+				return nil, nil, fmt.Errorf("%s: %w", ERROR_INCORRECT_FORMAT_CHAIN, err)
+			}
+			entry := new(chainEntry)
+			if err := json.Unmarshal(b, entry); err != nil {
+				return nil, nil, fmt.Errorf("%s: %w", ERROR_INCORRECT_FORMAT_CHAIN, err)
+			}
+			entries = append(entries, entry)
+			encodedEntries = append(encodedEntries, encoded)
+		}
+	}
+	return entries, encodedEntries, nil
+}
+
+// `verifyChain` verifies every hop of the signature chain, and returns their identities, starting with the client
+func (s *SigV4) verifyChain(ctx context.Context, req *http.Request) ([]auth.Identity, error) {
+	entries, encodedEntries, err := s.chainEntries(req)
+	if err != nil {
+		return nil, err
+	}
+
+	var identities []auth.Identity
+	for i, entry := range entries {
+		// Restore the request as signed by the hop
+		hop := req.Clone(req.Context())
+		for _, header := range s.hostHeaders {
+			hop.Header.Del(header)
+		}
+		hop.Host = entry.Host
+		s.setHeader(hop.Header, s.dateHeader(), entry.Date)
+		hop.Header.Set("Authorization", entry.Authorization)
+		// A hop signed the chain of the hops before it
+		deleteHeader(hop.Header, s.chainHeaderName())
+		if i > 0 {
+			s.setHeader(hop.Header, s.chainHeaderName(), strings.Join(encodedEntries[:i], ","))
+		}
+
+		identity, err := s.verify(ctx, hop, &VerificationReport{hop: true})
+		req.Body = hop.Body // The body is read and reassigned by the verification of the hop
+		if err != nil {
+			return nil, fmt.Errorf("hop %d of the signature chain: %w", i, err)
+		}
+		identities = append(identities, *identity)
+	}
+	return identities, nil
+}
@@ -0,0 +1,71 @@
+package sigv4
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"testing"
+)
+
+// Test that a gateway re-signing a client request preserves the client signature, and that the origin verifies both
+func Test_SignatureChaining(t *testing.T) {
+	server := newSecretRetrievalServer(t, testEnvConfig.SECRET_ACCESS_KEY)
+	gatewayEnv := *testEnvConfig
+	gatewayEnv.ACCESS_KEY_ID = "AKIAGATEWAYEXAMPLE"
+
+	// Forwarded headers are rewritten by every gateway
+	skip := WithSkipHeaders("X-Forwarded-Host", "X-Forwarded-For")
+
+	client, _ := NewSigV4Signer("SYM", "sym", "certificatemanager", testEnvConfig, false)
+	gateway, _ := NewSigV4Signer("SYM", "sym", "certificatemanager", &gatewayEnv, false, skip, WithSignatureChaining(true))
+	origin, _ := NewSigV4Verifier("SYM", "sym", "certificatemanager", server.URL, skip, WithSignatureChaining(true))
+
+	newChainedRequest := func() *http.Request {
+		req, _ := http.NewRequest(http.MethodPost, "http://gateway.example.com/api/cmagent", bytes.NewBufferString("payload"))
+		req.Header.Set("X-Client-Header", "client")
+		if err := client.SignHTTPRequest(req); err != nil {
+			t.Fatal(err)
+		}
+		// The gateway forwards the request to the origin
+		req.Host = "origin.internal"
+		req.Header.Set("X-Forwarded-Host", "gateway.example.com")
+		req.Header.Set("X-Forwarded-For", "203.0.113.7")
+		if err := gateway.SignHTTPRequest(req); err != nil {
+			t.Fatal(err)
+		}
+		return req
+	}
+
+	req := newChainedRequest()
+	identity, err := origin.(*SigV4).Authenticate(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if identity.KeyID != gatewayEnv.ACCESS_KEY_ID || len(identity.Chain) != 1 || identity.Chain[0].KeyID != testEnvConfig.ACCESS_KEY_ID {
+		t.Errorf("Unexpected identity: %+v", identity)
+	}
+	if b, _ := io.ReadAll(req.Body); string(b) != "payload" {
+		t.Errorf("Expected the body to be readable after verification, got: %q", b)
+	}
+
+	// A header signed by the client, modified by the gateway
+	req = newChainedRequest()
+	req.Header.Set("X-Client-Header", "tampered")
+	if err := gateway.SignHTTPRequest(req); err != nil {
+		t.Fatal(err)
+	}
+	if report, err := origin.(*SigV4).Explain(req); err == nil || report.FailedStage != STAGE_CHAIN {
+		t.Errorf("Expected a failure at stage %q, got: %v at %q", STAGE_CHAIN, err, report.FailedStage)
+	}
+
+	// A second gateway
+	req = newChainedRequest()
+	secondGateway, _ := NewSigV4Signer("SYM", "sym", "certificatemanager", testEnvConfig, false, skip, WithSignatureChaining(true))
+	req.Header.Set("X-Forwarded-Host", "origin.internal")
+	if err := secondGateway.SignHTTPRequest(req); err != nil {
+		t.Fatal(err)
+	}
+	if identity, err := origin.(*SigV4).Authenticate(req); err != nil || len(identity.Chain) != 2 || identity.Chain[1].KeyID != gatewayEnv.ACCESS_KEY_ID {
+		t.Errorf("Unexpected identity: %+v, %v", identity, err)
+	}
+}
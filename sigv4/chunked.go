@@ -0,0 +1,554 @@
+package sigv4
+
+import (
+	"bufio"
+	"context"
+	"crypto/hmac"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/jayantasamaddar/go-httpsigner/auth"
+	"github.com/jayantasamaddar/go-httpsigner/sigv4core"
+)
+
+// Errors
+const (
+	ERROR_INVALID_CHUNK_SIZE                = "chunk size exceeds the maximum chunk size"
+	ERROR_INVALID_DECODED_CONTENT_LENGTH    = "invalid decoded content length"
+	ERROR_DECODED_CONTENT_LENGTH_NOT_SIGNED = "decoded content length header is not signed"
+	ERROR_DECODED_CONTENT_LENGTH_MISMATCH   = "length of the payload does not match the decoded content length"
+	ERROR_INCORRECT_FORMAT_CHUNK            = "incorrectly formatted aws-chunked payload"
+	ERROR_CHUNK_SIGNATURE_MISMATCH          = "chunk signature does not match"
+	ERROR_CHUNKED_BODY_WRITER_CLOSED        = "chunked body writer is closed"
+	ERROR_TRAILER_NOT_DECLARED              = "trailer is not declared"
+	ERROR_TRAILER_NOT_SET                   = "value of the trailer is not set"
+	ERROR_TRAILER_NOT_SIGNED                = "trailer header is not signed"
+	ERROR_TRAILER_SIGNATURE_MISMATCH        = "trailer signature does not match"
+)
+
+// The default size of the chunks of a streaming payload
+const DEFAULT_CHUNK_SIZE = 64 * 1024
+
+// The maximum size of a chunk accepted by the Verifier
+const MAX_CHUNK_SIZE = 16 * 1024 * 1024
+
+// Returns the `HashedPayload` of streaming payloads signed with the algorithm. E.g. `STREAMING-AWS4-HMAC-SHA256-PAYLOAD`
+func streamingPayload(algorithm string) string {
+	return "STREAMING-" + algorithm + "-PAYLOAD"
+}
+
+// Returns the `HashedPayload` of streaming payloads followed by signed trailers. E.g. `STREAMING-AWS4-HMAC-SHA256-PAYLOAD-TRAILER`
+func streamingTrailerPayload(algorithm string) string {
+	return streamingPayload(algorithm) + "-TRAILER"
+}
+
+// Generate the Trailer Header name, declaring the comma-separated names of the signed trailers of a streaming payload
+func (s *SigV4) trailerHeader() string {
+	return fmt.Sprintf("X-%s-Trailer", s.abbr)
+}
+
+// Returns the name of the trailer carrying the trailer signature, E.g. `x-amz-trailer-signature`
+func (s *SigV4) trailerSignatureName() string {
+	return strings.ToLower(fmt.Sprintf("x-%s-trailer-signature", s.abbr))
+}
+
+// # Signed trailers
+//
+// Sign the trailers with the given names (E.g. `X-Amz-Checksum-Crc32`) after the chunks of streaming payloads, for values only known
+// once the payload is written, such as checksums. The values are set with `ChunkedBodyWriter.SetTrailer` before closing the writer.
+//
+// The `HashedPayload` of the request becomes `STREAMING-[Algorithm]-PAYLOAD-TRAILER`, and the names are declared in the signed `X-[Abbr]-Trailer` header.
+// As the length of the trailers is not known in advance, the `Content-Length` of the request is unknown (-1), hence the body is sent with the chunked transfer encoding.
+func WithTrailers(names ...string) Option {
+	return func(s *SigV4) {
+		s.trailers = nil
+		for _, name := range names {
+			s.trailers = append(s.trailers, strings.ToLower(strings.TrimSpace(name)))
+		}
+	}
+}
+
+// Generate the Decoded Content Length Header name, declaring the length of the payload before chunking
+func (s *SigV4) decodedContentLengthHeader() string {
+	return fmt.Sprintf("X-%s-Decoded-Content-Length", s.abbr)
+}
+
+// `chunkedContentLength` returns the length of the aws-chunked encoding of a payload of `decodedContentLength` bytes, in chunks of `chunkSize` bytes:
+//
+//	hex(size) + ";chunk-signature=" + signature + "\r\n" + data + "\r\n"
+//
+// for every chunk, followed by the final chunk of size zero.
+func chunkedContentLength(algorithm string, decodedContentLength int64, chunkSize int) int64 {
+	signatureLength := int64(64)
+	if strings.HasSuffix(algorithm, "-HMAC-SHA512") {
+		signatureLength = 128
+	}
+	chunkLength := func(size int64) int64 {
+		return int64(len(strconv.FormatInt(size, 16))) + int64(len(";chunk-signature=")) + signatureLength + 2 + size + 2
+	}
+	full, rest := decodedContentLength/int64(chunkSize), decodedContentLength%int64(chunkSize)
+	length := full*chunkLength(int64(chunkSize)) + chunkLength(0)
+	if rest > 0 {
+		length += chunkLength(rest)
+	}
+	return length
+}
+
+// `chunkStringToSign` returns the `stringToSign` of a chunk, chaining the signature of the previous chunk (the seed signature for the first chunk):
+//
+//	Algorithm + "-PAYLOAD" + "\n" + RequestDateTime + "\n" + CredentialScope + "\n" + PreviousSignature + "\n" + Hex(SHA256Hash("")) + "\n" + Hex(SHA256Hash(chunk))
+func chunkStringToSign(algorithm, dateTime, credentialScope, previousSignature string, chunk []byte) string {
+	return strings.Join([]string{
+		algorithm + "-PAYLOAD",
+		dateTime,
+		credentialScope,
+		previousSignature,
+		EMPTY_PAYLOAD_HASH,
+		sigv4core.HashPayload(chunk),
+	}, "\n")
+}
+
+// `trailerStringToSign` returns the `stringToSign` of the trailers, chaining the signature of the final chunk:
+//
+//	Algorithm + "-TRAILER" + "\n" + RequestDateTime + "\n" + CredentialScope + "\n" + PreviousSignature + "\n" + Hex(SHA256Hash(CanonicalTrailers))
+//
+// where `CanonicalTrailers` is `Lowercase(Name) + ":" + Trim(Value) + "\n"` for each trailer, in the order they are sent.
+func trailerStringToSign(algorithm, dateTime, credentialScope, previousSignature, canonicalTrailers string) string {
+	return strings.Join([]string{
+		algorithm + "-TRAILER",
+		dateTime,
+		credentialScope,
+		previousSignature,
+		sigv4core.HashPayload([]byte(canonicalTrailers)),
+	}, "\n")
+}
+
+// # Streaming (aws-chunked) payload signing
+//
+// Signs the headers of a request whose payload of `decodedContentLength` bytes is streamed instead of buffered, E.g. a very large upload,
+// and returns the `ChunkedBodyWriter` writing the payload to `body` as signed chunks of `chunkSize` bytes (`DEFAULT_CHUNK_SIZE` if not positive).
+//
+// The `HashedPayload` of the request is `STREAMING-[Algorithm]-PAYLOAD`, declared in the signed `X-[Abbr]-Content-Sha256` header, along with
+// `Content-Encoding: aws-chunked` and the `X-[Abbr]-Decoded-Content-Length`. The `Content-Length` of the request is set to the length of the encoded payload.
+// The signature of the `Authorization` header is the seed signature, and each chunk is signed with the signature of the previous chunk.
+//
+// Typically `body` is the writing end of an `io.Pipe` whose reading end is the request body:
+//
+//	pr, pw := io.Pipe()
+//	req, _ := http.NewRequest(http.MethodPut, url, pr)
+//	w, err := signer.SignStreamingHTTPRequest(req, size, 0, pw)
+//	go func() { _, err := io.Copy(w, file); w.CloseWithError(err) }()
+//	res, err := http.DefaultClient.Do(req)
+//
+// Trailers following the payload are signed if configured with `WithTrailers`.
+// The fallback algorithm (See `WithAlgorithm`) is not used for streaming payloads.
+func (s *SigV4) SignStreamingHTTPRequest(req *http.Request, decodedContentLength int64, chunkSize int, body io.Writer) (*ChunkedBodyWriter, error) {
+	if decodedContentLength < 0 {
+		return nil, fmt.Errorf("%s: %d", ERROR_INVALID_DECODED_CONTENT_LENGTH, decodedContentLength)
+	}
+	if chunkSize <= 0 {
+		chunkSize = DEFAULT_CHUNK_SIZE
+	}
+	if chunkSize > MAX_CHUNK_SIZE {
+		return nil, fmt.Errorf("%s: %d", ERROR_INVALID_CHUNK_SIZE, chunkSize)
+	}
+	signingTime := s.now()
+	algorithm := s.signingAlgorithm()
+	accessKeyID, region, err := s.signingIdentity(req.Context())
+	if err != nil {
+		return nil, err
+	}
+
+	// Set Headers
+	s.setHeader(req.Header, s.dateHeader(), s.formatDate(signingTime))
+	if token := s.sessionToken(); token != "" {
+		s.setHeader(req.Header, s.securityTokenHeader(), token)
+	}
+	if _, err := s.SetIdempotencyKey(req); err != nil {
+		return nil, err
+	}
+	payloadHash := streamingPayload(algorithm)
+	if len(s.trailers) > 0 {
+		payloadHash = streamingTrailerPayload(algorithm)
+		s.setHeader(req.Header, s.trailerHeader(), strings.Join(s.trailers, ","))
+	}
+	s.setHeader(req.Header, s.contentSHA256Header(), payloadHash)
+	s.setHeader(req.Header, s.decodedContentLengthHeader(), strconv.FormatInt(decodedContentLength, 10))
+	if encoding := req.Header.Get("Content-Encoding"); encoding != "" && encoding != "aws-chunked" {
+		req.Header.Set("Content-Encoding", "aws-chunked,"+encoding)
+	} else {
+		req.Header.Set("Content-Encoding", "aws-chunked")
+	}
+	req.ContentLength = chunkedContentLength(algorithm, decodedContentLength, chunkSize)
+	if len(s.trailers) > 0 {
+		req.ContentLength = -1
+	}
+
+	// The seed signature
+	cr, sh, err := s.canonicalRequestWithPayload(req, payloadHash, req.ContentLength)
+	if err != nil {
+		return nil, err
+	}
+	scope := s.getCredentialScope(signingTime, region, s.service)
+	seedSignature, err := s.sign(req.Context(), algorithm, signingTime, region, s.stringToSign(algorithm, signingTime, region, s.service, cr))
+	if err != nil {
+		return nil, err
+	}
+	sep := s.authorizationSeparator()
+	req.Header.Set("Authorization", fmt.Sprintf("%s Credential=%s/%s%sSignedHeaders=%s%sSignature=%s", algorithm, accessKeyID, scope, sep, sh, sep, seedSignature))
+
+	return &ChunkedBodyWriter{
+		w:         body,
+		chunkSize: chunkSize,
+		remaining: decodedContentLength,
+		previous:  seedSignature,
+		sign: func(ctx context.Context, stringToSign string) (string, error) {
+			return s.sign(ctx, algorithm, signingTime, region, stringToSign)
+		},
+		stringToSign: func(previous string, chunk []byte) string {
+			return chunkStringToSign(algorithm, s.formatDate(signingTime), scope, previous, chunk)
+		},
+		trailers: s.trailers,
+		trailerStringToSign: func(previous, canonicalTrailers string) string {
+			return trailerStringToSign(algorithm, s.formatDate(signingTime), scope, previous, canonicalTrailers)
+		},
+		trailerSignatureName: s.trailerSignatureName(),
+		ctx:                  req.Context(),
+	}, nil
+}
+
+// # Chunked body writer
+//
+// A ChunkedBodyWriter writes a payload as aws-chunked signed chunks. See `SigV4.SignStreamingHTTPRequest`.
+// Exactly the decoded content length must be written before `Close`. The ChunkedBodyWriter is not safe for concurrent use.
+type ChunkedBodyWriter struct {
+	w            io.Writer
+	chunkSize    int
+	buf          []byte
+	remaining    int64 // The number of bytes of the payload not written yet
+	previous     string
+	sign         func(ctx context.Context, stringToSign string) (string, error)
+	stringToSign func(previous string, chunk []byte) string
+	// The lowercase names of the signed trailers, and their values. See `WithTrailers`.
+	trailers             []string
+	trailerValues        map[string]string
+	trailerStringToSign  func(previous, canonicalTrailers string) string
+	trailerSignatureName string
+	ctx                  context.Context
+	err                  error
+}
+
+// Sets the value of a trailer declared with `WithTrailers`, E.g. a checksum of the payload. Must be called before `Close`.
+func (c *ChunkedBodyWriter) SetTrailer(name, value string) error {
+	name = strings.ToLower(name)
+	if !slices.Contains(c.trailers, name) {
+		return fmt.Errorf("%s: %s", ERROR_TRAILER_NOT_DECLARED, name)
+	}
+	if c.trailerValues == nil {
+		c.trailerValues = make(map[string]string, len(c.trailers))
+	}
+	c.trailerValues[name] = strings.TrimSpace(value)
+	return nil
+}
+
+// Buffers the payload, writing a signed chunk whenever `chunkSize` bytes are buffered
+func (c *ChunkedBodyWriter) Write(p []byte) (int, error) {
+	if c.err != nil {
+		return 0, c.err
+	}
+	if int64(len(p)) > c.remaining {
+		c.err = errors.New(ERROR_DECODED_CONTENT_LENGTH_MISMATCH)
+		return 0, c.err
+	}
+	c.remaining -= int64(len(p))
+	c.buf = append(c.buf, p...)
+	for len(c.buf) >= c.chunkSize {
+		if err := c.writeChunk(c.buf[:c.chunkSize]); err != nil {
+			return 0, err
+		}
+		c.buf = c.buf[c.chunkSize:]
+	}
+	return len(p), nil
+}
+
+// Writes the last chunk, the final chunk of size zero, and the signed trailers, if any. If the underlying writer is an `io.Closer` (E.g. an `*io.PipeWriter`), it is closed.
+func (c *ChunkedBodyWriter) Close() error {
+	return c.CloseWithError(nil)
+}
+
+// Closes the ChunkedBodyWriter like `Close` if `err` is nil. Else, the payload is abandoned, and `err` is passed on if the underlying writer is an `*io.PipeWriter`,
+// so that the request fails instead of sending a truncated payload.
+func (c *ChunkedBodyWriter) CloseWithError(err error) error {
+	if err == nil {
+		err = c.finish()
+	}
+	c.err = errors.New(ERROR_CHUNKED_BODY_WRITER_CLOSED)
+	if pw, ok := c.w.(*io.PipeWriter); ok {
+		return errors.Join(err, pw.CloseWithError(err))
+	}
+	if closer, ok := c.w.(io.Closer); ok && err == nil {
+		return closer.Close()
+	}
+	return err
+}
+
+// `finish` writes the buffered data as the last chunk, followed by the final chunk of size zero and the trailers
+func (c *ChunkedBodyWriter) finish() error {
+	if c.err != nil {
+		return c.err
+	}
+	if c.remaining != 0 {
+		return errors.New(ERROR_DECODED_CONTENT_LENGTH_MISMATCH)
+	}
+	for _, name := range c.trailers {
+		if _, ok := c.trailerValues[name]; !ok {
+			return fmt.Errorf("%s: %s", ERROR_TRAILER_NOT_SET, name)
+		}
+	}
+	if len(c.buf) > 0 {
+		if err := c.writeChunk(c.buf); err != nil {
+			return err
+		}
+		c.buf = nil
+	}
+	if err := c.writeChunk(nil); err != nil {
+		return err
+	}
+	if len(c.trailers) == 0 {
+		return nil
+	}
+
+	// The trailers follow the final chunk, in the declared order
+	var canonicalTrailers strings.Builder
+	for _, name := range c.trailers {
+		canonicalTrailers.WriteString(name + ":" + c.trailerValues[name] + "\n")
+	}
+	signature, err := c.sign(c.ctx, c.trailerStringToSign(c.previous, canonicalTrailers.String()))
+	if err == nil {
+		trailers := strings.ReplaceAll(canonicalTrailers.String(), "\n", "\r\n")
+		_, err = fmt.Fprintf(c.w, "%s%s:%s\r\n\r\n", trailers, c.trailerSignatureName, signature)
+	}
+	if err != nil {
+		c.err = err
+	}
+	return err
+}
+
+// `writeChunk` signs and writes a chunk, recording the error if any.
+// The final chunk of size zero is not terminated by a CRLF if trailers follow it.
+func (c *ChunkedBodyWriter) writeChunk(chunk []byte) error {
+	signature, err := c.sign(c.ctx, c.stringToSign(c.previous, chunk))
+	if err == nil {
+		c.previous = signature
+		terminator := "\r\n"
+		if len(chunk) == 0 && len(c.trailers) > 0 {
+			terminator = ""
+		}
+		_, err = fmt.Fprintf(c.w, "%x;chunk-signature=%s\r\n%s%s", len(chunk), signature, chunk, terminator)
+	}
+	if err != nil {
+		c.err = err
+	}
+	return err
+}
+
+// `streamingPayloadHash` returns the `HashedPayload` of a request if its payload is streamed, i.e. the signed `X-[Abbr]-Content-Sha256` header
+// declares a streaming payload, with or without trailers, or an event stream (See `SignEventStreamHTTPRequest`). Else returns an empty string.
+func (s *SigV4) streamingPayloadHash(req *http.Request, algorithm string, signedHeaders []string) string {
+	if !slices.Contains(signedHeaders, strings.ToLower(s.contentSHA256Header())) {
+		return ""
+	}
+	switch hash := getHeader(req.Header, s.contentSHA256Header()); hash {
+	case streamingPayload(algorithm), streamingTrailerPayload(algorithm), streamingEventsPayload(algorithm):
+		return hash
+	}
+	return ""
+}
+
+// `declaredTrailers` returns the lowercase names of the trailers declared in the signed `X-[Abbr]-Trailer` header of a streaming payload
+func (s *SigV4) declaredTrailers(req *http.Request, signedHeaders []string) ([]string, error) {
+	if !slices.Contains(signedHeaders, strings.ToLower(s.trailerHeader())) {
+		return nil, fmt.Errorf("%s: %s", ERROR_TRAILER_NOT_SIGNED, s.trailerHeader())
+	}
+	var trailers []string
+	for _, name := range strings.Split(getHeader(req.Header, s.trailerHeader()), ",") {
+		if name = strings.ToLower(strings.TrimSpace(name)); name != "" && !slices.Contains(trailers, name) {
+			trailers = append(trailers, name)
+		}
+	}
+	return trailers, nil
+}
+
+// A chunkedReader decodes an aws-chunked payload, verifying the signature of each chunk as it is read
+type chunkedReader struct {
+	r         *bufio.Reader
+	body      io.Closer
+	chunk     []byte // The data of the current chunk not read yet
+	previous  string
+	expected  func(previous string, chunk []byte) (string, error)
+	remaining int64 // The number of bytes of the decoded payload not read yet
+	done      bool
+	err       error
+	// The declared trailers, the expected signature of the trailers, and the header the verified trailers are set to (the `Trailer` of the request)
+	trailers             []string
+	expectedTrailer      func(previous, canonicalTrailers string) (string, error)
+	trailerSignatureName string
+	trailer              http.Header
+}
+
+// Returns a reader of the decoded payload of the aws-chunked `body`. See `SigV4.SignStreamingHTTPRequest`.
+// If `trailers` are declared, the verified trailers are set to `trailer` once the payload is read.
+func (s *SigV4) newChunkedReader(body io.ReadCloser, decodedContentLength int64, algorithm, dateTime, credentialScope, seedSignature string, signingKey []byte, trailers []string, trailer http.Header) *chunkedReader {
+	return &chunkedReader{
+		r:         bufio.NewReader(body),
+		body:      body,
+		previous:  seedSignature,
+		remaining: decodedContentLength,
+		expected: func(previous string, chunk []byte) (string, error) {
+			return s.generateSignature(algorithm, signingKey, chunkStringToSign(algorithm, dateTime, credentialScope, previous, chunk))
+		},
+		trailers: trailers,
+		expectedTrailer: func(previous, canonicalTrailers string) (string, error) {
+			return s.generateSignature(algorithm, signingKey, trailerStringToSign(algorithm, dateTime, credentialScope, previous, canonicalTrailers))
+		},
+		trailerSignatureName: s.trailerSignatureName(),
+		trailer:              trailer,
+	}
+}
+
+func (c *chunkedReader) Read(p []byte) (int, error) {
+	for len(c.chunk) == 0 {
+		if c.err != nil {
+			return 0, c.err
+		}
+		if c.done {
+			return 0, io.EOF
+		}
+		c.err = c.readChunk()
+	}
+	n := copy(p, c.chunk)
+	c.chunk = c.chunk[n:]
+	return n, nil
+}
+
+func (c *chunkedReader) Close() error {
+	return c.body.Close()
+}
+
+// `readChunk` reads and verifies the next chunk
+func (c *chunkedReader) readChunk() error {
+	line, err := c.r.ReadString('\n')
+	if err != nil {
+		return fmt.Errorf("%s: %w", ERROR_INCORRECT_FORMAT_CHUNK, err)
+	}
+	size, signature, ok := strings.Cut(strings.TrimSuffix(line, "\r\n"), ";chunk-signature=")
+	if !ok {
+		return errors.New(ERROR_INCORRECT_FORMAT_CHUNK)
+	}
+	n, err := strconv.ParseInt(size, 16, 64)
+	if err != nil || n < 0 || n > MAX_CHUNK_SIZE {
+		return fmt.Errorf("%s: chunk size %q", ERROR_INCORRECT_FORMAT_CHUNK, size)
+	}
+	if n > c.remaining {
+		return errors.New(ERROR_DECODED_CONTENT_LENGTH_MISMATCH)
+	}
+	terminator := 2
+	if n == 0 && len(c.trailers) > 0 {
+		terminator = 0 // The trailers follow the final chunk
+	}
+	chunk := make([]byte, n+int64(terminator))
+	if _, err := io.ReadFull(c.r, chunk); err != nil || string(chunk[n:]) != "\r\n"[:terminator] {
+		return fmt.Errorf("%s: truncated chunk", ERROR_INCORRECT_FORMAT_CHUNK)
+	}
+	chunk = chunk[:n]
+
+	expected, err := c.expected(c.previous, chunk)
+	if err != nil {
+		return err
+	}
+	if !hmac.Equal([]byte(expected), []byte(signature)) {
+		return fmt.Errorf("%w: %s", auth.ErrSignatureMismatch, ERROR_CHUNK_SIGNATURE_MISMATCH)
+	}
+	c.previous = signature
+	c.remaining -= n
+	c.chunk = chunk
+	if n == 0 {
+		if c.remaining != 0 {
+			return errors.New(ERROR_DECODED_CONTENT_LENGTH_MISMATCH)
+		}
+		if len(c.trailers) > 0 {
+			if err := c.readTrailers(); err != nil {
+				return err
+			}
+		}
+		c.done = true
+	}
+	return nil
+}
+
+// `readTrailers` reads and verifies the trailers following the final chunk, and sets them to the `Trailer` of the request
+func (c *chunkedReader) readTrailers() error {
+	var canonicalTrailers strings.Builder
+	values := make(map[string]string, len(c.trailers))
+	var signature string
+	for signature == "" {
+		line, err := c.r.ReadString('\n')
+		if err != nil {
+			return fmt.Errorf("%s: %w", ERROR_INCORRECT_FORMAT_CHUNK, err)
+		}
+		name, value, ok := strings.Cut(strings.TrimSuffix(line, "\r\n"), ":")
+		if !ok {
+			return fmt.Errorf("%s: trailer %q", ERROR_INCORRECT_FORMAT_CHUNK, line)
+		}
+		name = strings.ToLower(name)
+		switch {
+		case name == c.trailerSignatureName:
+			signature = value
+		case !slices.Contains(c.trailers, name):
+			return fmt.Errorf("%s: %s", ERROR_TRAILER_NOT_DECLARED, name)
+		default:
+			if _, ok := values[name]; ok {
+				return fmt.Errorf("%s: duplicate trailer %s", ERROR_INCORRECT_FORMAT_CHUNK, name)
+			}
+			values[name] = strings.TrimSpace(value)
+			canonicalTrailers.WriteString(name + ":" + values[name] + "\n")
+		}
+	}
+	if line, err := c.r.ReadString('\n'); err != nil || line != "\r\n" {
+		return fmt.Errorf("%s: trailers not terminated", ERROR_INCORRECT_FORMAT_CHUNK)
+	}
+	for _, name := range c.trailers {
+		if _, ok := values[name]; !ok {
+			return fmt.Errorf("%s: %s", ERROR_TRAILER_NOT_SET, name)
+		}
+	}
+
+	expected, err := c.expectedTrailer(c.previous, canonicalTrailers.String())
+	if err != nil {
+		return err
+	}
+	if !hmac.Equal([]byte(expected), []byte(signature)) {
+		return fmt.Errorf("%w: %s", auth.ErrSignatureMismatch, ERROR_TRAILER_SIGNATURE_MISMATCH)
+	}
+	for name, value := range values {
+		c.trailer.Set(name, value)
+	}
+	return nil
+}
+
+// `decodedContentLength` returns the signed `X-[Abbr]-Decoded-Content-Length` of a streaming payload
+func (s *SigV4) decodedContentLength(req *http.Request, signedHeaders []string) (int64, error) {
+	if !slices.Contains(signedHeaders, strings.ToLower(s.decodedContentLengthHeader())) {
+		return 0, fmt.Errorf("%s: %s", ERROR_DECODED_CONTENT_LENGTH_NOT_SIGNED, s.decodedContentLengthHeader())
+	}
+	n, err := strconv.ParseInt(getHeader(req.Header, s.decodedContentLengthHeader()), 10, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("%s: %s", ERROR_INVALID_DECODED_CONTENT_LENGTH, s.decodedContentLengthHeader())
+	}
+	return n, nil
+}
@@ -0,0 +1,204 @@
+package sigv4
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/jayantasamaddar/go-httpsigner/auth"
+)
+
+// Test that a streamed payload is verified chunk by chunk on the server, and that a tampered chunk is detected
+func Test_SignStreamingHTTPRequest(t *testing.T) {
+	secretServer := newSecretRetrievalServer(t, testEnvConfig.SECRET_ACCESS_KEY)
+	signer, err := NewSigV4Signer("SYM", "sym", "certificatemanager", testEnvConfig, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	verifier, err := NewSigV4Verifier("SYM", "sym", "certificatemanager", secretServer.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	payload := bytes.Repeat([]byte("0123456789"), 2500) // 25000 bytes: 3 chunks of 8 KiB, and a chunk of 424 bytes
+	var received []byte
+	var verifyErr error
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if verifyErr = verifier.VerifySignature(r); verifyErr != nil {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		received, verifyErr = io.ReadAll(r.Body)
+	}))
+	defer server.Close()
+
+	// Streams the payload as a signed request, transforming the encoded body
+	send := func(transform func([]byte) []byte) {
+		t.Helper()
+		pr, pw := io.Pipe()
+		req, _ := http.NewRequest(http.MethodPut, server.URL+"/certificates/upload", pr)
+		w, err := signer.(*SigV4).SignStreamingHTTPRequest(req, int64(len(payload)), 8*1024, pw)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if transform != nil {
+			var encoded bytes.Buffer
+			w.w = &encoded
+			if _, err := w.Write(payload); err != nil {
+				t.Fatal(err)
+			}
+			if err := w.Close(); err != nil {
+				t.Fatal(err)
+			}
+			req.Body = io.NopCloser(bytes.NewReader(transform(encoded.Bytes())))
+		} else {
+			go func() {
+				_, err := io.Copy(w, bytes.NewReader(payload))
+				w.CloseWithError(err)
+			}()
+		}
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.Body.Close()
+	}
+
+	send(nil)
+	if verifyErr != nil {
+		t.Fatal(verifyErr)
+	}
+	if !bytes.Equal(received, payload) {
+		t.Errorf("Expected %d bytes of the payload, got: %d bytes", len(payload), len(received))
+	}
+
+	// Tampering with the data of a chunk
+	send(func(b []byte) []byte { return bytes.Replace(b, []byte("0123"), []byte("3210"), 1) })
+	if !errors.Is(verifyErr, auth.ErrSignatureMismatch) || !strings.Contains(verifyErr.Error(), ERROR_CHUNK_SIGNATURE_MISMATCH) {
+		t.Errorf("Expected: %s, got: %v", ERROR_CHUNK_SIGNATURE_MISMATCH, verifyErr)
+	}
+
+	// Truncating the payload after the first chunk, keeping the length of the body
+	send(func(b []byte) []byte {
+		first := bytes.Index(b, []byte("\r\n2000;"))
+		return append(b[:first+2], bytes.Repeat([]byte(" "), len(b)-first-2)...)
+	})
+	if verifyErr == nil || !strings.Contains(verifyErr.Error(), ERROR_INCORRECT_FORMAT_CHUNK) {
+		t.Errorf("Expected: %s, got: %v", ERROR_INCORRECT_FORMAT_CHUNK, verifyErr)
+	}
+}
+
+// Test that the writer enforces the decoded content length
+func Test_ChunkedBodyWriter_DecodedContentLength(t *testing.T) {
+	signer, err := NewSigV4Signer("SYM", "sym", "certificatemanager", testEnvConfig, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req, _ := http.NewRequest(http.MethodPut, "https://example.com/upload", nil)
+	var body bytes.Buffer
+	w, err := signer.(*SigV4).SignStreamingHTTPRequest(req, 10, 0, &body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Write([]byte("short")); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err == nil || err.Error() != ERROR_DECODED_CONTENT_LENGTH_MISMATCH {
+		t.Errorf("Expected: %s, got: %v", ERROR_DECODED_CONTENT_LENGTH_MISMATCH, err)
+	}
+
+	// The encoded length declared in the Content-Length matches the written body
+	req, _ = http.NewRequest(http.MethodPut, "https://example.com/upload", nil)
+	body.Reset()
+	if w, err = signer.(*SigV4).SignStreamingHTTPRequest(req, 10, 4, &body); err != nil {
+		t.Fatal(err)
+	}
+	w.Write([]byte("0123456789"))
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if int64(body.Len()) != req.ContentLength {
+		t.Errorf("Expected Content-Length: %d, got: %d", body.Len(), req.ContentLength)
+	}
+}
+
+// Test that signed trailers are verified after the payload and set to the `Trailer` of the request, and that a tampered trailer is detected
+func Test_SignStreamingHTTPRequest_Trailers(t *testing.T) {
+	secretServer := newSecretRetrievalServer(t, testEnvConfig.SECRET_ACCESS_KEY)
+	signer, err := NewSigV4Signer("SYM", "sym", "certificatemanager", testEnvConfig, false, WithTrailers("X-Sym-Checksum-Crc32"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	verifier, err := NewSigV4Verifier("SYM", "sym", "certificatemanager", secretServer.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	payload := bytes.Repeat([]byte("0123456789"), 1000)
+	checksum := base64.StdEncoding.EncodeToString(binary.BigEndian.AppendUint32(nil, crc32.ChecksumIEEE(payload)))
+	var trailer string
+	var verifyErr error
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if verifyErr = verifier.VerifySignature(r); verifyErr != nil {
+			return
+		}
+		if _, verifyErr = io.ReadAll(r.Body); verifyErr == nil {
+			trailer = r.Trailer.Get("X-Sym-Checksum-Crc32")
+		}
+	}))
+	defer server.Close()
+
+	// Sends the payload as a signed request, transforming the encoded body
+	send := func(transform func([]byte) []byte) {
+		t.Helper()
+		req, _ := http.NewRequest(http.MethodPut, server.URL+"/certificates/upload", nil)
+		var encoded bytes.Buffer
+		w, err := signer.(*SigV4).SignStreamingHTTPRequest(req, int64(len(payload)), 4096, &encoded)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if req.ContentLength != -1 {
+			t.Errorf("Expected an unknown Content-Length, got: %d", req.ContentLength)
+		}
+		w.Write(payload)
+		if err := w.SetTrailer("X-Sym-Checksum-Crc32", checksum); err != nil {
+			t.Fatal(err)
+		}
+		if err := w.Close(); err != nil {
+			t.Fatal(err)
+		}
+		req.Body = io.NopCloser(bytes.NewReader(transform(encoded.Bytes())))
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.Body.Close()
+	}
+
+	send(func(b []byte) []byte { return b })
+	if verifyErr != nil {
+		t.Fatal(verifyErr)
+	}
+	if trailer != checksum {
+		t.Errorf("Expected the trailer: %s, got: %s", checksum, trailer)
+	}
+
+	send(func(b []byte) []byte { return bytes.Replace(b, []byte(checksum), []byte("AAAAAA=="), 1) })
+	if !errors.Is(verifyErr, auth.ErrSignatureMismatch) || !strings.Contains(verifyErr.Error(), ERROR_TRAILER_SIGNATURE_MISMATCH) {
+		t.Errorf("Expected: %s, got: %v", ERROR_TRAILER_SIGNATURE_MISMATCH, verifyErr)
+	}
+
+	send(func(b []byte) []byte {
+		return bytes.Replace(b, []byte("x-sym-checksum-crc32:"), []byte("x-sym-checksum-sha1:"), 1)
+	})
+	if verifyErr == nil || !strings.Contains(verifyErr.Error(), ERROR_TRAILER_NOT_DECLARED) {
+		t.Errorf("Expected: %s, got: %v", ERROR_TRAILER_NOT_DECLARED, verifyErr)
+	}
+}
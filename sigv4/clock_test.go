@@ -0,0 +1,115 @@
+package sigv4
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/jayantasamaddar/go-httpsigner/auth"
+)
+
+// Test that signatures dated by an injected clock are reproducible byte for byte
+func Test_WithClock(t *testing.T) {
+	signingTime := time.Date(2024, time.March, 15, 10, 30, 0, 0, time.UTC)
+	newRequest := func() *http.Request {
+		req, _ := http.NewRequest(http.MethodGet, "http://validate.127.0.0.1.sslip.io/api/cmagent?b=2&a=1", nil)
+		return req
+	}
+
+	signer, err := NewSigV4Signer("SYM", "sym", "certificatemanager", testEnvConfig, false, WithClock(FixedClock(signingTime)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	first, second := newRequest(), newRequest()
+	if err := signer.SignHTTPRequest(first); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(time.Millisecond)
+	if err := signer.SignHTTPRequest(second); err != nil {
+		t.Fatal(err)
+	}
+	if first.Header.Get("Authorization") != second.Header.Get("Authorization") || first.Header.Get("X-Sym-Date") != second.Header.Get("X-Sym-Date") {
+		t.Errorf("Expected identical signatures, got:\n%s\n%s", first.Header.Get("Authorization"), second.Header.Get("Authorization"))
+	}
+
+	// Signing at a given time is equivalent
+	unclocked, _ := NewSigV4Signer("SYM", "sym", "certificatemanager", testEnvConfig, false)
+	third := newRequest()
+	if err := unclocked.(*SigV4).SignHTTPRequestAt(third, signingTime); err != nil {
+		t.Fatal(err)
+	}
+	if third.Header.Get("Authorization") != first.Header.Get("Authorization") {
+		t.Errorf("Expected SignHTTPRequestAt to match the clock, got:\n%s\n%s", third.Header.Get("Authorization"), first.Header.Get("Authorization"))
+	}
+
+	// The expiry of presigned URLs is checked against the clock of the Verifier
+	server := newSecretRetrievalServer(t, testEnvConfig.SECRET_ACCESS_KEY)
+	presigned := newRequest()
+	if err := signer.(*SigV4).PresignHTTPRequest(presigned, time.Minute, nil); err != nil {
+		t.Fatal(err)
+	}
+	clocked, _ := NewSigV4Verifier("SYM", "sym", "certificatemanager", server.URL, WithClock(FixedClock(signingTime.Add(30*time.Second))))
+	if err := clocked.VerifySignature(presigned); err != nil {
+		t.Error(err)
+	}
+	verifier, _ := NewSigV4Verifier("SYM", "sym", "certificatemanager", server.URL)
+	if err := verifier.VerifySignature(presigned); !errors.Is(err, auth.ErrSignatureExpired) {
+		t.Errorf("Expected auth.ErrSignatureExpired, got: %v", err)
+	}
+}
+
+// Test that the replay window, the replay store, the rate limiter and the secret cache of the Verifier follow its clock
+func Test_WithClock_Verifier(t *testing.T) {
+	now := time.Date(2024, time.March, 15, 10, 30, 0, 0, time.UTC)
+	clock := ClockFunc(func() time.Time { return now })
+
+	var retrievals atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		retrievals.Add(1)
+		_ = json.NewEncoder(w).Encode(map[string]string{"secret_access_key": testEnvConfig.SECRET_ACCESS_KEY})
+	}))
+	defer server.Close()
+
+	store, limiter := NewMemoryReplayStore(), NewTokenBucketLimiter(RateLimit{Rate: 1, Burst: 1})
+	store.SetClock(clock)
+	limiter.SetClock(clock)
+	signer, _ := NewSigV4Signer("SYM", "sym", "certificatemanager", testEnvConfig, false, WithClock(clock))
+	verifier, err := NewSigV4Verifier("SYM", "sym", "certificatemanager", server.URL, WithClock(clock),
+		WithSecretCache(time.Minute), WithReplayStore(store, time.Minute), WithRateLimiter(limiter))
+	if err != nil {
+		t.Fatal(err)
+	}
+	signed := func() *http.Request {
+		req, _ := http.NewRequest(http.MethodGet, "http://validate.127.0.0.1.sslip.io/api/cmagent", nil)
+		if err := signer.SignHTTPRequest(req); err != nil {
+			t.Fatal(err)
+		}
+		return req
+	}
+
+	req := signed()
+	if err := verifier.VerifySignature(req); err != nil {
+		t.Fatal(err)
+	}
+	now = now.Add(2 * time.Second)
+	if err := verifier.VerifySignature(req); !errors.Is(err, auth.ErrRequestReplayed) {
+		t.Errorf("Expected auth.ErrRequestReplayed, got: %v", err)
+	}
+	if err := verifier.VerifySignature(signed()); !errors.Is(err, auth.ErrRateLimited) {
+		t.Errorf("Expected auth.ErrRateLimited, got: %v", err)
+	}
+	now = now.Add(2 * time.Second)
+	if err := verifier.VerifySignature(signed()); err != nil || retrievals.Load() != 1 {
+		t.Errorf("Expected the cached secret to be used, got: %v after %d retrievals", err, retrievals.Load())
+	}
+
+	// The cached secret expires with the clock
+	now = now.Add(2 * time.Minute)
+	if err := verifier.VerifySignature(signed()); err != nil || retrievals.Load() != 2 {
+		t.Errorf("Expected the secret to be retrieved again, got: %v after %d retrievals", err, retrievals.Load())
+	}
+}
@@ -0,0 +1,268 @@
+package sigv4
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/jayantasamaddar/go-httpsigner/sigv4core"
+)
+
+// Returns the `CanonicalURI` and the `CanonicalQueryString` of the `CanonicalRequest` the SigV4 signs the request with
+func canonicalURIAndQuery(t *testing.T, s *SigV4, req *http.Request) (uri, query string) {
+	t.Helper()
+	cr, _, err := s.canonicalRequestWithPayload(req, sigv4core.HashPayload(nil), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.SplitN(cr, "\n", 4)
+	return lines[1], lines[2]
+}
+
+// Test that pre-encoded segments of the path are preserved in the Canonical URI
+func Test_CanonicalURI_PreservesRawPath(t *testing.T) {
+	s := &SigV4{}
+
+	tests := map[string]string{
+		"http://s3.amazonaws.com":                              "/",
+		"http://s3.amazonaws.com/examplebucket/myphoto.jpg":    "/examplebucket/myphoto.jpg",
+		"http://s3.amazonaws.com/examplebucket/photos%2Fa.jpg": "/examplebucket/photos%2Fa.jpg",
+		"http://s3.amazonaws.com/examplebucket/my%20photo.jpg": "/examplebucket/my%20photo.jpg",
+		"http://s3.amazonaws.com/examplebucket/a+b.jpg":        "/examplebucket/a%2Bb.jpg",
+	}
+
+	for rawURL, expected := range tests {
+		req, err := http.NewRequest(http.MethodGet, rawURL, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if uri, _ := canonicalURIAndQuery(t, s, req); uri != expected {
+			t.Errorf("Canonical URI mismatch for %q; expected: %q, got: %q", rawURL, expected, uri)
+		}
+	}
+}
+
+// Test that the canonical URI is encoded twice with `WithDoubleURIEncode`, and that signatures of both modes are not interchangeable
+func Test_WithDoubleURIEncode(t *testing.T) {
+	s := &SigV4{}
+	WithDoubleURIEncode(true)(s)
+	req, _ := http.NewRequest(http.MethodGet, "https://example.execute-api.us-east-1.amazonaws.com/stage/my%20photo.jpg/a%2Fb", nil)
+	if uri, _ := canonicalURIAndQuery(t, s, req); uri != "/stage/my%2520photo.jpg/a%252Fb" {
+		t.Errorf("Unexpected canonical URI: %q", uri)
+	}
+
+	secretServer := newSecretRetrievalServer(t, testEnvConfig.SECRET_ACCESS_KEY)
+	signer, _ := NewSigV4Signer("SYM", "sym", "certificatemanager", testEnvConfig, false, WithDoubleURIEncode(true))
+	verifier, _ := NewSigV4Verifier("SYM", "sym", "certificatemanager", secretServer.URL, WithDoubleURIEncode(true))
+	single, _ := NewSigV4Verifier("SYM", "sym", "certificatemanager", secretServer.URL)
+	if err := signer.SignHTTPRequest(req); err != nil {
+		t.Fatal(err)
+	}
+	if err := verifier.VerifySignature(req); err != nil {
+		t.Error(err)
+	}
+	if err := single.VerifySignature(req); err == nil {
+		t.Error("Expected a Verifier encoding the path once to reject the request")
+	}
+}
+
+// Test that requests with non-ASCII paths, query values and header values round-trip in both encoding modes,
+// and that signatures of the default and the strict URI encoding are not interchangeable
+func Test_WithStrictURIEncoding(t *testing.T) {
+	newRequest := func() *http.Request {
+		req, err := http.NewRequest(http.MethodGet, "http://validate.127.0.0.1.sslip.io/api/café/5€?q=日本&k=caf%C3%A9", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("X-Display-Name", "Zoë Ørsted")
+		return req
+	}
+
+	s := &SigV4{}
+	if uri, _ := canonicalURIAndQuery(t, s, newRequest()); uri != "/api/café/5%E2%82%AC" {
+		t.Errorf("Unexpected canonical URI: %q", uri)
+	}
+	WithStrictURIEncoding(true)(s)
+	if uri, _ := canonicalURIAndQuery(t, s, newRequest()); uri != "/api/caf%C3%A9/5%E2%82%AC" {
+		t.Errorf("Unexpected strict canonical URI: %q", uri)
+	}
+	aws := &SigV4{}
+	WithStrictAWSMode()(aws)
+	if !aws.strictURIEncoding {
+		t.Error("Expected the strict AWS mode to encode URIs strictly")
+	}
+
+	secretServer := newSecretRetrievalServer(t, testEnvConfig.SECRET_ACCESS_KEY)
+	for _, strict := range []bool{false, true} {
+		signer, _ := NewSigV4Signer("SYM", "sym", "certificatemanager", testEnvConfig, false, WithStrictQueryEncoding(true), WithStrictURIEncoding(strict))
+		verifier, _ := NewSigV4Verifier("SYM", "sym", "certificatemanager", secretServer.URL, WithStrictQueryEncoding(true), WithStrictURIEncoding(strict))
+		other, _ := NewSigV4Verifier("SYM", "sym", "certificatemanager", secretServer.URL, WithStrictQueryEncoding(true), WithStrictURIEncoding(!strict))
+		req := newRequest()
+		if err := signer.SignHTTPRequest(req); err != nil {
+			t.Fatal(err)
+		}
+		if err := verifier.VerifySignature(req); err != nil {
+			t.Errorf("strict %t: %v", strict, err)
+		}
+		if err := other.VerifySignature(req); err == nil {
+			t.Errorf("strict %t: expected a Verifier of the other mode to reject the request", strict)
+		}
+	}
+}
+
+// Test that the dot segments and duplicate slashes of the path are removed with `WithPathNormalization`
+func Test_WithPathNormalization(t *testing.T) {
+	secretServer := newSecretRetrievalServer(t, testEnvConfig.SECRET_ACCESS_KEY)
+	signer, _ := NewSigV4Signer("SYM", "sym", "certificatemanager", testEnvConfig, false, WithPathNormalization(true))
+	verifier, _ := NewSigV4Verifier("SYM", "sym", "certificatemanager", secretServer.URL, WithPathNormalization(true))
+
+	// A request signed for `/a/../b//c` is verified once a proxy normalized its path
+	req, _ := http.NewRequest(http.MethodGet, "http://validate.127.0.0.1.sslip.io/a/../b//c", nil)
+	if uri, _ := canonicalURIAndQuery(t, signer.(*SigV4), req); uri != "/b/c" {
+		t.Errorf("Unexpected canonical URI: %q", uri)
+	}
+	if err := signer.SignHTTPRequest(req); err != nil {
+		t.Fatal(err)
+	}
+	req.URL.Path, req.URL.RawPath = "/b/c", ""
+	if err := verifier.VerifySignature(req); err != nil {
+		t.Error(err)
+	}
+}
+
+// Test that a request signed for a host with a default port is verified without it, and conversely, including for IPv6 literals
+func Test_CanonicalHost_DefaultPort(t *testing.T) {
+	server := newSecretRetrievalServer(t, testEnvConfig.SECRET_ACCESS_KEY)
+	signer, _ := NewSigV4Signer("SYM", "sym", "certificatemanager", testEnvConfig, false)
+	verifier, _ := NewSigV4Verifier("SYM", "sym", "certificatemanager", server.URL)
+
+	for _, test := range []struct{ signed, received string }{
+		{"example.com:443", "example.com"},
+		{"example.com", "example.com:80"},
+		{"[2001:db8::1]:443", "[2001:db8::1]"},
+	} {
+		req, _ := http.NewRequest(http.MethodGet, "https://"+test.signed+"/api/cmagent", nil)
+		if err := signer.SignHTTPRequest(req); err != nil {
+			t.Fatal(err)
+		}
+		req.Host = test.received
+		if err := verifier.VerifySignature(req); err != nil {
+			t.Errorf("Signed for %q, received for %q: %v", test.signed, test.received, err)
+		}
+	}
+
+	// Other ports are signed
+	req, _ := http.NewRequest(http.MethodGet, "https://example.com:8443/api/cmagent", nil)
+	if err := signer.SignHTTPRequest(req); err != nil {
+		t.Fatal(err)
+	}
+	req.Host = "example.com"
+	if err := verifier.VerifySignature(req); err == nil {
+		t.Error("Expected the port 8443 to be signed")
+	}
+}
+
+// Test the handling of '+', spaces and "%2B" in query values for both the default and the strict query encoding
+func Test_CanonicalQueryString_PlusAndSpace(t *testing.T) {
+	tests := []struct {
+		rawQuery, expected, expectedStrict string
+	}{
+		{"a=b+c", "a=b+c", "a=b%20c"},
+		{"a=b%20c", "a=b+c", "a=b%20c"},
+		{"a=b%2Bc", "a=b%2Bc", "a=b%2Bc"},
+		{"a+b=c", "a+b=c", "a%20b=c"},
+		{url.Values{"q": {"x y+z"}}.Encode(), "q=x+y%2Bz", "q=x%20y%2Bz"},
+	}
+
+	for _, test := range tests {
+		req, err := http.NewRequest(http.MethodGet, "http://s3.amazonaws.com/?"+test.rawQuery, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, qs := canonicalURIAndQuery(t, &SigV4{}, req); qs != test.expected {
+			t.Errorf("Canonical query string mismatch for %q; expected: %q, got: %q", test.rawQuery, test.expected, qs)
+		}
+		if _, qs := canonicalURIAndQuery(t, &SigV4{strictQueryEncoding: true}, req); qs != test.expectedStrict {
+			t.Errorf("Strict canonical query string mismatch for %q; expected: %q, got: %q", test.rawQuery, test.expectedStrict, qs)
+		}
+	}
+}
+
+// Test that a malformed query string fails signing and verification with an error instead of a panic
+func Test_CanonicalQueryString_Malformed(t *testing.T) {
+	server := newSecretRetrievalServer(t, testEnvConfig.SECRET_ACCESS_KEY)
+	signer, _ := NewSigV4Signer("SYM", "sym", "certificatemanager", testEnvConfig, false)
+	verifier, _ := NewSigV4Verifier("SYM", "sym", "certificatemanager", server.URL)
+
+	for _, rawQuery := range []string{"a=%zz", "a=b;c=d", "%"} {
+		req, _ := http.NewRequest(http.MethodGet, "http://validate.127.0.0.1.sslip.io/api/cmagent", nil)
+		req.URL.RawQuery = rawQuery
+		if err := signer.SignHTTPRequest(req); err == nil || !strings.Contains(err.Error(), sigv4core.ERROR_MALFORMED_QUERY) {
+			t.Errorf("Expected error: %q for %q, got: %v", sigv4core.ERROR_MALFORMED_QUERY, rawQuery, err)
+		}
+
+		// A hostile query string appended to a signed request
+		req.URL.RawQuery = ""
+		if err := signer.SignHTTPRequest(req); err != nil {
+			t.Fatal(err)
+		}
+		req.URL.RawQuery = rawQuery
+		if report, err := verifier.(*SigV4).Explain(req); err == nil || report.FailedStage != STAGE_CANONICALIZE {
+			t.Errorf("Expected a failure at stage %q for %q, got: %v at %q", STAGE_CANONICALIZE, rawQuery, err, report.FailedStage)
+		}
+	}
+}
+
+// Test that the Signer and the Verifier agree on headers whose names differ only by case and on empty headers, in every mode
+func Test_HeaderCollisions(t *testing.T) {
+	secretServer := newSecretRetrievalServer(t, testEnvConfig.SECRET_ACCESS_KEY)
+	newRequest := func() *http.Request {
+		req, _ := http.NewRequest(http.MethodGet, "http://validate.127.0.0.1.sslip.io/api/cmagent", nil)
+		req.Header["X-Custom"] = []string{"a"}
+		req.Header["x-custom"] = []string{"b"}
+		req.Header["X-Empty"] = []string{""}
+		return req
+	}
+
+	// Joined in the sorted order of the names, as sent over HTTP/1.1 and received by `net/http`
+	signer, _ := NewSigV4Signer("SYM", "sym", "certificatemanager", testEnvConfig, false)
+	verifier, _ := NewSigV4Verifier("SYM", "sym", "certificatemanager", secretServer.URL)
+	req := newRequest()
+	if err := signer.SignHTTPRequest(req); err != nil {
+		t.Fatal(err)
+	}
+	received := req.Clone(req.Context())
+	delete(received.Header, "x-custom")
+	received.Header["X-Custom"] = []string{"a", "b"}
+	for _, r := range []*http.Request{req, received} {
+		if err := verifier.VerifySignature(r); err != nil {
+			t.Error(err)
+		}
+	}
+	if strings.Count(req.Header.Get("Authorization"), "x-custom") != 1 || !strings.Contains(req.Header.Get("Authorization"), "x-empty") {
+		t.Errorf("Expected x-custom to be signed once and x-empty to be signed, got: %s", req.Header.Get("Authorization"))
+	}
+
+	// Empty headers omitted, so that a proxy dropping them does not break the signature
+	omitting, _ := NewSigV4Signer("SYM", "sym", "certificatemanager", testEnvConfig, false, WithOmitEmptyHeaders(true))
+	req = newRequest()
+	if err := omitting.SignHTTPRequest(req); err != nil {
+		t.Fatal(err)
+	}
+	delete(req.Header, "X-Empty")
+	if err := verifier.VerifySignature(req); err != nil {
+		t.Error(err)
+	}
+
+	// Collisions rejected
+	rejecting, _ := NewSigV4Signer("SYM", "sym", "certificatemanager", testEnvConfig, false, WithRejectHeaderCollisions(true))
+	if err := rejecting.SignHTTPRequest(newRequest()); err == nil || !strings.Contains(err.Error(), sigv4core.ERROR_HEADER_COLLISION) {
+		t.Errorf("Expected error %q, got: %v", sigv4core.ERROR_HEADER_COLLISION, err)
+	}
+	strict, _ := NewSigV4Verifier("SYM", "sym", "certificatemanager", secretServer.URL, WithRejectHeaderCollisions(true))
+	received.Header.Set("Authorization", strings.Replace(received.Header.Get("Authorization"), "x-custom", "x-custom;x-custom", 1))
+	if report, err := strict.(*SigV4).Explain(received); err == nil || report.FailedStage != STAGE_CANONICALIZE {
+		t.Errorf("Expected stage %q to fail, got: %+v, %v", STAGE_CANONICALIZE, report, err)
+	}
+}
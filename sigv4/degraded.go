@@ -0,0 +1,107 @@
+package sigv4
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/jayantasamaddar/go-httpsigner/secretapi"
+)
+
+// `isBackendUnavailable` checks if the secret retrieval failed because the secret backend is unavailable (E.g. unreachable, timing out, or responding with a 5xx or 429 status),
+// rather than because it rejected the access key (E.g. a 4xx status for an unknown or revoked key), responded with an invalid response or a forged secret,
+// or the verification was canceled or ran out of time (E.g. the client disconnected, or the verification budget was exhausted).
+func isBackendUnavailable(ctx context.Context, err error) bool {
+	if err == nil || ctx.Err() != nil || errors.Is(err, context.Canceled) {
+		return false
+	}
+	var statusErr *secretapi.StatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.Unavailable()
+	}
+	return !errors.Is(err, ErrSecretResponseSignature) && !errors.Is(err, secretapi.ErrInvalidResponse)
+}
+
+// # Graceful degradation mode
+//
+// When the secret backend is unavailable, verify requests with the cached secret of the access key, even if expired,
+// as long as it expired no more than `maxStaleness` ago, so that an outage of the secret backend does not fail every signed request.
+// Secrets of keys rejected by the secret backend, or purged with `SigV4.InvalidateSecret`, are never served.
+//
+// Requires the secret cache (See `WithSecretCache`). Every secret served stale, and every request failed for want of a cached secret,
+// is recorded in `stats` (if not nil), E.g. to be exported as metrics and alerted on.
+func WithDegradedMode(maxStaleness time.Duration, stats *DegradedModeStats) Option {
+	return func(s *SigV4) {
+		s.maxStaleness = maxStaleness
+		s.degradedStats = stats
+	}
+}
+
+// Statistics of the graceful degradation mode, as recorded by `DegradedModeStats`
+type DegradedModeSnapshot struct {
+	StaleServed   uint64        // Number of requests verified with a stale secret while the secret backend was unavailable
+	Unavailable   uint64        // Number of requests failed while the secret backend was unavailable, as no cached secret was fresh enough
+	LastDegraded  time.Time     // Time of the last request verified with a stale secret. Zero if none.
+	MaxStaleness  time.Duration // Maximum staleness of the secrets served
+	LastStaleness time.Duration // Staleness of the last secret served
+}
+
+// Whether the Verifier served a stale secret within the `window` before `now`, E.g. to report the Verifier as degraded in a health check.
+// `now` is usually the current time of the clock of the Verifier (See `WithClock`).
+func (d DegradedModeSnapshot) Degraded(now time.Time, window time.Duration) bool {
+	return !d.LastDegraded.IsZero() && now.Sub(d.LastDegraded) <= window
+}
+
+// # Degraded mode statistics
+//
+// DegradedModeStats records the secrets served stale by a Verifier in graceful degradation mode. Pass it to a Verifier with `WithDegradedMode`.
+type DegradedModeStats struct {
+	mu       sync.Mutex
+	snapshot DegradedModeSnapshot
+}
+
+// Returns empty `DegradedModeStats`
+func NewDegradedModeStats() *DegradedModeStats {
+	return new(DegradedModeStats)
+}
+
+// `served` records a secret of the given staleness served at time `t`
+func (d *DegradedModeStats) served(staleness time.Duration, t time.Time) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.snapshot.StaleServed++
+	d.snapshot.LastDegraded = t
+	d.snapshot.LastStaleness = staleness
+	d.snapshot.MaxStaleness = max(d.snapshot.MaxStaleness, staleness)
+}
+
+// `unavailable` records a request failed while the secret backend was unavailable
+func (d *DegradedModeStats) unavailable() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.snapshot.Unavailable++
+}
+
+// Returns a copy of the statistics
+func (d *DegradedModeStats) Snapshot() DegradedModeSnapshot {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.snapshot
+}
+
+// `staleSecret` returns the cached secret of the `accessKeyID` if the secret backend is unavailable, and the secret expired no more than `maxStaleness` ago.
+func (s *SigV4) staleSecret(ctx context.Context, accessKeyID string, err error) (SealedSecret, bool) {
+	if s.maxStaleness <= 0 || s.secrets == nil || !isBackendUnavailable(ctx, err) {
+		return nil, false
+	}
+	secret, staleness, ok := s.secrets.getStale(accessKeyID, s.maxStaleness, s.now())
+	if s.degradedStats != nil {
+		if ok {
+			s.degradedStats.served(staleness, s.now())
+		} else {
+			s.degradedStats.unavailable()
+		}
+	}
+	return secret, ok
+}
@@ -0,0 +1,106 @@
+package sigv4
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/jayantasamaddar/go-httpsigner/auth"
+	"github.com/jayantasamaddar/go-httpsigner/secretapi"
+	"github.com/jayantasamaddar/go-httpsigner/utils/backoff"
+)
+
+// Test that expired cached secrets are served while the secret backend is unavailable, but not once too stale or when the key is rejected
+func Test_DegradedMode(t *testing.T) {
+	status := http.StatusOK
+	secretServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if status != http.StatusOK {
+			w.WriteHeader(status)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]string{"secret_access_key": testEnvConfig.SECRET_ACCESS_KEY})
+	}))
+	defer secretServer.Close()
+
+	signer, err := NewSigV4Signer("SYM", "sym", "certificatemanager", testEnvConfig, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stats := NewDegradedModeStats()
+	verifier, err := NewSigV4Verifier("SYM", "sym", "certificatemanager", secretServer.URL,
+		WithSecretRetrievalBackoff(backoff.Exponential(0, 0, 0)),
+		WithSecretCache(time.Millisecond),
+		WithDegradedMode(time.Hour, stats),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	verify := func() error {
+		req, _ := http.NewRequest(http.MethodGet, "http://validate.127.0.0.1.sslip.io/api/cmagent", nil)
+		if err := signer.SignHTTPRequest(req); err != nil {
+			t.Fatal(err)
+		}
+		return verifier.VerifySignature(req)
+	}
+
+	// Cache the secret, and let it expire
+	if err := verify(); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(5 * time.Millisecond)
+
+	status = http.StatusServiceUnavailable
+	if err := verify(); err != nil {
+		t.Fatalf("Expected the stale secret to be served, got: %v", err)
+	}
+	snapshot := stats.Snapshot()
+	if snapshot.StaleServed != 1 || snapshot.MaxStaleness <= 0 || !snapshot.Degraded(time.Now(), time.Minute) {
+		t.Errorf("Unexpected statistics: %+v", snapshot)
+	}
+
+	// A key rejected by the secret backend is not served stale
+	status = http.StatusNotFound
+	if err := verify(); !errors.Is(err, auth.ErrSecretUnavailable) {
+		t.Errorf("Expected: %v, got: %v", auth.ErrSecretUnavailable, err)
+	}
+
+	// Nor is a purged secret
+	status = http.StatusBadGateway
+	verifier.(*SigV4).InvalidateAll()
+	if err := verify(); !errors.Is(err, auth.ErrSecretUnavailable) {
+		t.Errorf("Expected: %v, got: %v", auth.ErrSecretUnavailable, err)
+	}
+	if snapshot := stats.Snapshot(); snapshot.StaleServed != 1 || snapshot.Unavailable != 1 {
+		t.Errorf("Unexpected statistics: %+v", snapshot)
+	}
+}
+
+// Test that only the failures of the secret backend itself are outages, not the cancellation of the verification or invalid responses
+func Test_IsBackendUnavailable(t *testing.T) {
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+	for _, test := range []struct {
+		name     string
+		ctx      context.Context
+		err      error
+		expected bool
+	}{
+		{"unreachable", context.Background(), errors.New("connection refused"), true},
+		{"timeout", context.Background(), fmt.Errorf("Client.Timeout exceeded: %w", context.DeadlineExceeded), true},
+		{"5xx", context.Background(), &secretapi.StatusError{StatusCode: http.StatusServiceUnavailable}, true},
+		{"4xx", context.Background(), &secretapi.StatusError{StatusCode: http.StatusNotFound}, false},
+		{"forged secret", context.Background(), fmt.Errorf("retrieval: %w", ErrSecretResponseSignature), false},
+		{"invalid response", context.Background(), fmt.Errorf("%w: EOF", secretapi.ErrInvalidResponse), false},
+		{"canceled flight", context.Background(), context.Canceled, false},
+		{"canceled verification", canceled, errors.New("connection refused"), false},
+	} {
+		if got := isBackendUnavailable(test.ctx, test.err); got != test.expected {
+			t.Errorf("%s: expected %v, got: %v", test.name, test.expected, got)
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package sigv4
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"time"
+)
+
+// The intermediate results of signing a request. See `SigV4.DryRunSign`.
+type SigningArtifacts struct {
+	Algorithm        string
+	SigningTime      time.Time
+	CanonicalRequest string
+	StringToSign     string
+	// The credential scope the signing key is derived for. E.g. `20240315/ap-south-1/s3/aws4_request`
+	CredentialScope string
+	SignedHeaders   string // E.g. `host;x-amz-date`
+	Signature       string
+	// The headers set by signing, E.g. `Authorization` and `X-Amz-Date`
+	Headers http.Header
+}
+
+// # Dry-run signing
+//
+// Signs a copy of the request like `SignHTTPRequest`, and returns the intermediate results: the `CanonicalRequest`, the `stringToSign`,
+// the credential scope of the signing key and the signature. The request is not modified. Never returns the secret nor the signing key.
+//
+// Intended for diagnosing signature mismatches: compare the artifacts of the Signer with the `CanonicalRequestHash` of the Verifier (See `Explain`),
+// or with the canonical request and string-to-sign of another implementation.
+func (s *SigV4) DryRunSign(req *http.Request) (*SigningArtifacts, error) {
+	signed, _, err := cloneWithBody(req)
+	if err != nil {
+		return nil, err
+	}
+	signingTime := s.now()
+	trace := new(signingTrace)
+	if err := s.signHTTPRequestAt(signed.Context(), signed, signingTime, trace); err != nil {
+		return nil, err
+	}
+	credential, err := parseCredential(trace.signature.Credential)
+	if err != nil {
+		return nil, err
+	}
+
+	artifacts := &SigningArtifacts{
+		Algorithm:        trace.signature.Algorithm,
+		SigningTime:      signingTime,
+		CanonicalRequest: trace.canonicalRequest,
+		StringToSign:     s.stringToSign(trace.signature.Algorithm, signingTime, credential.Region, credential.Service, trace.canonicalRequest),
+		CredentialScope:  s.getCredentialScope(signingTime, credential.Region, credential.Service),
+		SignedHeaders:    trace.signature.SignedHeaders,
+		Signature:        trace.signature.Signature,
+		Headers:          make(http.Header),
+	}
+	for name, values := range signed.Header {
+		if _, ok := req.Header[name]; !ok {
+			artifacts.Headers[name] = values
+		}
+	}
+	return artifacts, nil
+}
+
+// `cloneWithBody` returns a clone of the request to be signed, and its payload: the body of the request is read into memory
+// and replaced, so that signing the clone leaves the request unmodified.
+func cloneWithBody(req *http.Request) (*http.Request, []byte, error) {
+	var body []byte
+	if req.Body != nil && req.Body != http.NoBody {
+		var err error
+		if body, err = io.ReadAll(req.Body); err != nil {
+			return nil, nil, err
+		}
+		req.Body = io.NopCloser(bytes.NewReader(body))
+	}
+	clone := req.Clone(req.Context())
+	clone.Body = io.NopCloser(bytes.NewReader(body))
+	return clone, body, nil
+}
@@ -0,0 +1,56 @@
+package sigv4
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jayantasamaddar/go-httpsigner/sigv4core"
+)
+
+// Test that the artifacts of a dry run describe the signature of the request, which is left unmodified
+func Test_DryRunSign(t *testing.T) {
+	server := newSecretRetrievalServer(t, testEnvConfig.SECRET_ACCESS_KEY)
+	signingTime := time.Date(2024, time.March, 15, 10, 30, 0, 0, time.UTC)
+	signer, _ := NewSigV4Signer("SYM", "sym", "certificatemanager", testEnvConfig, false, WithClock(FixedClock(signingTime)))
+	verifier, _ := NewSigV4Verifier("SYM", "sym", "certificatemanager", server.URL)
+	newRequest := func() *http.Request {
+		req, _ := http.NewRequest(http.MethodPost, "http://validate.127.0.0.1.sslip.io/api/cmagent?b=2&a=1", bytes.NewBufferString("payload"))
+		return req
+	}
+
+	req := newRequest()
+	artifacts, err := signer.(*SigV4).DryRunSign(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if req.Header.Get("Authorization") != "" {
+		t.Error("Expected the request to be left unmodified")
+	}
+	if b, _ := io.ReadAll(req.Body); string(b) != "payload" {
+		t.Errorf("Expected the body to be preserved, got: %q", b)
+	}
+	if !strings.HasPrefix(artifacts.CanonicalRequest, "POST\n/api/cmagent\na=1&b=2\n") || !strings.HasSuffix(artifacts.CanonicalRequest, sigv4core.HashPayload([]byte("payload"))) {
+		t.Errorf("Unexpected canonical request: %q", artifacts.CanonicalRequest)
+	}
+	expected := sigv4core.StringToSign(artifacts.Algorithm, signingTime.Format(time.RFC3339Nano), artifacts.CredentialScope, artifacts.CanonicalRequest)
+	if artifacts.StringToSign != expected {
+		t.Errorf("Expected string-to-sign:\n%s\ngot:\n%s", expected, artifacts.StringToSign)
+	}
+
+	// The artifacts are those of the signature actually produced
+	signed := newRequest()
+	if err := signer.SignHTTPRequest(signed); err != nil {
+		t.Fatal(err)
+	}
+	if signed.Header.Get("Authorization") != artifacts.Headers.Get("Authorization") || !strings.HasSuffix(artifacts.Headers.Get("Authorization"), "Signature="+artifacts.Signature) {
+		t.Errorf("Expected the Authorization header of the signed request, got: %q", artifacts.Headers.Get("Authorization"))
+	}
+	report, err := verifier.(*SigV4).Explain(signed)
+	if err != nil || report.CanonicalRequestHash != sigv4core.HashPayload([]byte(artifacts.CanonicalRequest)) {
+		t.Errorf("Expected the canonical request hash of the Verifier to match, got: %+v, %v", report, err)
+	}
+}
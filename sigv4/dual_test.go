@@ -0,0 +1,48 @@
+package sigv4
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/jayantasamaddar/go-httpsigner/rfc9421"
+)
+
+// Test that both the SigV4 and the RFC 9421 signatures are emitted, sharing the payload hash
+func Test_SignHTTPRequest_MessageSignature(t *testing.T) {
+	server := newSecretRetrievalServer(t, testEnvConfig.SECRET_ACCESS_KEY)
+	signer, _ := NewSigV4Signer("SYM", "sym", "certificatemanager", testEnvConfig, false, WithMessageSignature("sig1"))
+	verifier, _ := NewSigV4Verifier("SYM", "sym", "certificatemanager", server.URL)
+
+	payload := []byte(`{"hello": "world"}`)
+	req, _ := http.NewRequest(http.MethodPost, "http://validate.127.0.0.1.sslip.io/api/cmagent?x=1", bytes.NewReader(payload))
+	if err := signer.SignHTTPRequest(req); err != nil {
+		t.Fatal(err)
+	}
+
+	sum := sha256.Sum256(payload)
+	if digest := req.Header.Get("Content-Digest"); digest != rfc9421.ContentDigestSHA256(sum[:]) {
+		t.Errorf("Unexpected Content-Digest: %q", digest)
+	}
+	if !strings.Contains(req.Header.Get("Authorization"), "content-digest") {
+		t.Error("Expected the Content-Digest to be signed by the SigV4 signature")
+	}
+	if err := verifier.VerifySignature(req); err != nil {
+		t.Errorf("Expected the SigV4 signature to verify, got: %v", err)
+	}
+
+	// Recompute the RFC 9421 signature like a server would
+	signatureInput, found := strings.CutPrefix(req.Header.Get("Signature-Input"), "sig1=")
+	if !found || !strings.Contains(signatureInput, `keyid="`+testEnvConfig.ACCESS_KEY_ID+`"`) {
+		t.Fatalf("Unexpected Signature-Input: %q", req.Header.Get("Signature-Input"))
+	}
+	base, err := rfc9421.SignatureBase(req, messageSignatureComponents, signatureInput)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := "sig1=" + rfc9421.SignHMACSHA256([]byte(testEnvConfig.SECRET_ACCESS_KEY), base); req.Header.Get("Signature") != expected {
+		t.Errorf("RFC 9421 signature mismatch; expected: %q, got: %q", expected, req.Header.Get("Signature"))
+	}
+}
@@ -0,0 +1,157 @@
+package sigv4
+
+import (
+	"context"
+	"crypto/hmac"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/jayantasamaddar/go-httpsigner/auth"
+	"github.com/jayantasamaddar/go-httpsigner/sigv4core"
+)
+
+// Errors
+const (
+	ERROR_ENVELOPE_NOT_SIGNED      = "envelope is not signed"
+	ERROR_ENVELOPE_DATE_NOT_SIGNED = "date header of the envelope is not signed"
+)
+
+// The method of the `CanonicalRequest` of an `Envelope`, so that the signature of a message is never valid as the signature of an HTTP request
+const ENVELOPE_METHOD = "MESSAGE"
+
+// # Message envelope
+//
+// An Envelope carries a message between services over a queue or a topic (E.g. Kafka, SQS), authenticated with the same keys as HTTP traffic.
+// The JSON form of the Envelope is the message: `{"destination": "...", "headers": {...}, "body": "<base64>", "signature": "..."}`.
+//
+// The Envelope is canonicalized as a request with the `ENVELOPE_METHOD`, the path "/", the `Destination` as the host, the `Headers`, and the hash of the `Body`.
+// The `Signature` has the format of the `Authorization` header. See `SigV4.SignEnvelope` and `SigV4.VerifyEnvelope`.
+type Envelope struct {
+	// The queue or topic the message is published to (E.g. `orders.created`). Signed, so that a message cannot be replayed to another destination.
+	Destination string            `json:"destination"`
+	Headers     map[string]string `json:"headers,omitempty"`
+	Body        []byte            `json:"body"`
+	Signature   string            `json:"signature,omitempty"`
+}
+
+// `header` returns the value of a header of the envelope, looking up the header name case-insensitively
+func (e *Envelope) header(name string) string {
+	for key, value := range e.Headers {
+		if strings.EqualFold(key, name) {
+			return value
+		}
+	}
+	return ""
+}
+
+// `setHeader` sets a header of the envelope, replacing any existing value of the header irrespective of its casing
+func (e *Envelope) setHeader(name, value string) {
+	if e.Headers == nil {
+		e.Headers = make(map[string]string)
+	}
+	for key := range e.Headers {
+		if strings.EqualFold(key, name) {
+			delete(e.Headers, key)
+		}
+	}
+	e.Headers[name] = value
+}
+
+// `envelopeCanonicalRequest` builds the `CanonicalRequest` of the envelope. Only the `signedHeaders` are canonicalized, in order, unless nil.
+func (s *SigV4) envelopeCanonicalRequest(e *Envelope, signedHeaders []string) (canonicalRequest, sh string, err error) {
+	header := make(map[string][]string, len(e.Headers))
+	for name, value := range e.Headers {
+		header[name] = []string{value}
+	}
+	return sigv4core.CanonicalRequest(&sigv4core.Request{
+		Method:        ENVELOPE_METHOD,
+		Path:          "/",
+		Host:          e.Destination,
+		Header:        header,
+		ContentLength: int64(len(e.Body)),
+		PayloadHash:   sigv4core.HashPayload(e.Body),
+		SignedHeaders: signedHeaders,
+	}, nil)
+}
+
+// # Sign an envelope
+//
+// Sets the `X-[Abbr]-Date` header of the envelope, and signs the destination, the headers and the body in the `Signature` of the envelope.
+func (s *SigV4) SignEnvelope(ctx context.Context, e *Envelope) error {
+	signingTime := s.now()
+	e.setHeader(s.dateHeader(), s.formatDate(signingTime))
+
+	cr, sh, err := s.envelopeCanonicalRequest(e, nil)
+	if err != nil {
+		return err
+	}
+	signature, err := s.authorization(ctx, s.signingAlgorithm(), signingTime, cr, sh)
+	if err != nil {
+		return err
+	}
+	e.Signature = signature
+	return nil
+}
+
+// # Verify an envelope
+//
+// Verifies the `Signature` of the envelope, and returns the Identity of the service that signed it.
+// Headers added to the envelope after signing are ignored. Replayed envelopes are rejected if the Verifier has a replay store (See `WithReplayStore`).
+func (s *SigV4) VerifyEnvelope(ctx context.Context, e *Envelope) (*auth.Identity, error) {
+	if e.Signature == "" {
+		return nil, fmt.Errorf("%w: %s", auth.ErrSignatureMismatch, ERROR_ENVELOPE_NOT_SIGNED)
+	}
+	authHeaders, err := s.parseAuthHeaders(e.Signature)
+	if err != nil {
+		return nil, err
+	}
+	if !s.isAcceptedAlgorithm(authHeaders.Algorithm) {
+		return nil, fmt.Errorf("%s: %q", ERROR_INCORRECT_ALGORITHM, authHeaders.Algorithm)
+	}
+	if !slices.Contains(authHeaders.SignedHeaders, strings.ToLower(s.dateHeader())) {
+		return nil, fmt.Errorf("%s: %s", ERROR_ENVELOPE_DATE_NOT_SIGNED, s.dateHeader())
+	}
+	signingTime, err := s.parseDate(e.header(s.dateHeader()))
+	if err != nil {
+		return nil, err
+	}
+	credential := authHeaders.Credential
+	if err := checkCredentialScope(strings.SplitN(credential.String(), "/", 2)[1], s.getCredentialScope(signingTime, credential.Region, credential.Service)); err != nil {
+		return nil, err
+	}
+
+	if s.rateLimiter != nil {
+		if err := s.allow(credential.ACCESS_KEY_ID); err != nil {
+			return nil, err
+		}
+	}
+	secret, err := s.secretAccessKey(ctx, credential.ACCESS_KEY_ID, "")
+	if err != nil {
+		return nil, err
+	}
+
+	canonicalRequest, _, err := s.envelopeCanonicalRequest(e, authHeaders.SignedHeaders)
+	if err != nil {
+		return nil, err
+	}
+	stringToSign := s.stringToSign(authHeaders.Algorithm, signingTime, credential.Region, credential.Service, canonicalRequest)
+	signingKey, err := s.signingKeyFromSecret(authHeaders.Algorithm, secret, signingTime, credential.Region, credential.Service)
+	if err != nil {
+		return nil, err
+	}
+	computedSignature, err := s.generateSignature(authHeaders.Algorithm, signingKey, stringToSign)
+	if err != nil {
+		return nil, err
+	}
+	if !hmac.Equal([]byte(computedSignature), []byte(authHeaders.Signature)) {
+		return nil, fmt.Errorf("%w: %s", auth.ErrSignatureMismatch, ERROR_SIGNATURE_MISMATCH)
+	}
+
+	if s.replayStore != nil {
+		if err := s.checkReplay(authHeaders.Signature, signingTime); err != nil {
+			return nil, err
+		}
+	}
+	return s.identity(authHeaders.Algorithm, credential, signingTime, authHeaders.SignedHeaders), nil
+}
@@ -0,0 +1,77 @@
+package sigv4
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/jayantasamaddar/go-httpsigner/auth"
+)
+
+// Test that a signed envelope is verified after a JSON round-trip, and that tampering with any signed part is detected
+func Test_Envelope(t *testing.T) {
+	secretServer := newSecretRetrievalServer(t, testEnvConfig.SECRET_ACCESS_KEY)
+	signer, err := NewSigV4Signer("SYM", "sym", "certificatemanager", testEnvConfig, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	verifier, err := NewSigV4Verifier("SYM", "sym", "certificatemanager", secretServer.URL, WithReplayStore(NewMemoryReplayStore(), time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	envelope := &Envelope{
+		Destination: "orders.created",
+		Headers:     map[string]string{"Content-Type": "application/json"},
+		Body:        []byte(`{"order_id":42}`),
+	}
+	if err := signer.(*SigV4).SignEnvelope(context.Background(), envelope); err != nil {
+		t.Fatal(err)
+	}
+	message, err := json.Marshal(envelope)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Returns the envelope of the message, with a modification applied
+	received := func(modify func(e *Envelope)) *Envelope {
+		e := new(Envelope)
+		if err := json.Unmarshal(message, e); err != nil {
+			t.Fatal(err)
+		}
+		modify(e)
+		return e
+	}
+
+	// Headers added after signing are ignored
+	e := received(func(e *Envelope) { e.Headers["X-Broker-Offset"] = "1337" })
+	identity, err := verifier.(*SigV4).VerifyEnvelope(context.Background(), e)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if identity.KeyID != testEnvConfig.ACCESS_KEY_ID || identity.Service != "certificatemanager" {
+		t.Errorf("Unexpected identity: %s", identity)
+	}
+
+	// Replays are rejected
+	if _, err := verifier.(*SigV4).VerifyEnvelope(context.Background(), received(func(*Envelope) {})); !errors.Is(err, auth.ErrRequestReplayed) {
+		t.Errorf("Expected: %v, got: %v", auth.ErrRequestReplayed, err)
+	}
+
+	tampered := map[string]func(e *Envelope){
+		"body":        func(e *Envelope) { e.Body = []byte(`{"order_id":43}`) },
+		"destination": func(e *Envelope) { e.Destination = "orders.cancelled" },
+		"header":      func(e *Envelope) { e.Headers["Content-Type"] = "text/plain" },
+	}
+	for name, modify := range tampered {
+		if _, err := verifier.(*SigV4).VerifyEnvelope(context.Background(), received(modify)); !errors.Is(err, auth.ErrSignatureMismatch) {
+			t.Errorf("%s: expected: %v, got: %v", name, auth.ErrSignatureMismatch, err)
+		}
+	}
+
+	if _, err := verifier.(*SigV4).VerifyEnvelope(context.Background(), &Envelope{Body: []byte("unsigned")}); !errors.Is(err, auth.ErrSignatureMismatch) {
+		t.Errorf("Expected: %v, got: %v", auth.ErrSignatureMismatch, err)
+	}
+}
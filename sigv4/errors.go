@@ -0,0 +1,85 @@
+package sigv4
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/jayantasamaddar/go-httpsigner/auth"
+)
+
+// Returned by the constructors when `service` is empty or contains characters not allowed in the credential scope ('/' or whitespace).
+type ErrInvalidService struct {
+	Service string
+}
+
+func (e *ErrInvalidService) Error() string {
+	if e.Service == "" {
+		return fmt.Sprintf("%s: %s", ERROR_MANDATORY_FIELD_NOT_SPECIFIED, "service")
+	}
+	return fmt.Sprintf("%s: %q", ERROR_INVALID_SERVICE, e.Service)
+}
+
+// Returned by the constructors when `org` contains characters other than letters and digits.
+type ErrInvalidOrg struct {
+	Org string
+}
+
+func (e *ErrInvalidOrg) Error() string {
+	return fmt.Sprintf("%s: %q", ERROR_INVALID_ORG, e.Org)
+}
+
+// Returned by the constructors when `abbr` contains characters other than letters and digits.
+type ErrInvalidAbbr struct {
+	Abbr string
+}
+
+func (e *ErrInvalidAbbr) Error() string {
+	return fmt.Sprintf("%s: %q", ERROR_INVALID_ABBR, e.Abbr)
+}
+
+// Returned by the constructors when the terminator configured with `WithScopeTerminator` contains characters other than lowercase letters, digits and '_'.
+type ErrInvalidTerminator struct {
+	Terminator string
+}
+
+func (e *ErrInvalidTerminator) Error() string {
+	return fmt.Sprintf("%s: %q", ERROR_INVALID_TERMINATOR, e.Terminator)
+}
+
+// Returned by the constructors when the Date Header configured with `WithDateHeader` contains characters other than letters, digits and '-',
+// or is a header canonicalized by other means (`Authorization`, `Host` or `Content-Length`).
+type ErrInvalidHeaderName struct {
+	Header string
+}
+
+func (e *ErrInvalidHeaderName) Error() string {
+	return fmt.Sprintf("%s: %q", ERROR_INVALID_HEADER_NAME, e.Header)
+}
+
+// Returned by `NewSigV4Verifier` when `secretRetrievalURL` is empty or not an absolute http(s) URL.
+type ErrInvalidSecretRetrievalURL struct {
+	URL string
+}
+
+func (e *ErrInvalidSecretRetrievalURL) Error() string {
+	if e.URL == "" {
+		return fmt.Sprintf("%s: %s", ERROR_MANDATORY_FIELD_NOT_SPECIFIED, "secretRetrievalURL")
+	}
+	return fmt.Sprintf("%s: %q", ERROR_INVALID_SECRET_RETRIEVAL_URL, e.URL)
+}
+
+// Returned by Verifiers when a verification does not complete within the verification budget (See `WithVerificationBudget`).
+// Wraps `auth.ErrVerificationTimeout`.
+type ErrVerificationTimeout struct {
+	Budget time.Duration
+	Stage  string // The stage of the verification that was interrupted (See `VerificationReport`)
+	Err    error  // The error of the interrupted stage
+}
+
+func (e *ErrVerificationTimeout) Error() string {
+	return fmt.Sprintf("%s after %s (stage %q): %v", auth.ErrVerificationTimeout, e.Budget, e.Stage, e.Err)
+}
+
+func (e *ErrVerificationTimeout) Unwrap() []error {
+	return []error{auth.ErrVerificationTimeout, e.Err}
+}
@@ -0,0 +1,466 @@
+package sigv4
+
+import (
+	"context"
+	"crypto/hmac"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/jayantasamaddar/go-httpsigner/auth"
+	"github.com/jayantasamaddar/go-httpsigner/sigv4core"
+)
+
+// Errors
+const (
+	ERROR_INCORRECT_FORMAT_EVENT_STREAM   = "incorrectly formatted event stream message"
+	ERROR_EVENT_STREAM_MESSAGE_TOO_LARGE  = "event stream message exceeds the maximum message size"
+	ERROR_EVENT_SIGNATURE_MISMATCH        = "event stream message signature does not match"
+	ERROR_EVENT_STREAM_TRUNCATED          = "event stream ended without the final signed message"
+	ERROR_EVENT_STREAM_WRITER_CLOSED      = "event stream writer is closed"
+	ERROR_INVALID_EVENT_STREAM_HEADER_LEN = "event stream header name or value is too long"
+)
+
+// Types of the values of event stream headers
+const (
+	EVENT_HEADER_BOOL_TRUE  byte = iota // No value
+	EVENT_HEADER_BOOL_FALSE             // No value
+	EVENT_HEADER_BYTE                   // 1 byte
+	EVENT_HEADER_INT16                  // 2 bytes, big-endian
+	EVENT_HEADER_INT32                  // 4 bytes, big-endian
+	EVENT_HEADER_INT64                  // 8 bytes, big-endian
+	EVENT_HEADER_BYTES                  // Up to 32767 bytes
+	EVENT_HEADER_STRING                 // Up to 32767 bytes of UTF-8
+	EVENT_HEADER_TIMESTAMP              // 8 bytes: milliseconds since the Unix epoch, big-endian
+	EVENT_HEADER_UUID                   // 16 bytes
+)
+
+// The media type of event stream payloads
+const EVENT_STREAM_CONTENT_TYPE = "application/vnd.amazon.eventstream"
+
+// The maximum size of an event stream message, including the signed frame around it
+const MAX_EVENT_STREAM_MESSAGE_SIZE = 16 << 20
+
+// The headers of the signed frames of an event stream
+const (
+	EVENT_HEADER_DATE            = ":date"
+	EVENT_HEADER_CHUNK_SIGNATURE = ":chunk-signature"
+)
+
+// The length of the prelude (total length, headers length and prelude CRC) and of the message CRC of an event stream message
+const (
+	eventStreamPreludeLength = 12
+	eventStreamCRCLength     = 4
+)
+
+// Returns the `HashedPayload` of event stream payloads signed with the algorithm. E.g. `STREAMING-AWS4-HMAC-SHA256-EVENTS`
+func streamingEventsPayload(algorithm string) string {
+	return "STREAMING-" + algorithm + "-EVENTS"
+}
+
+// A header of an `EventStreamMessage`
+type EventStreamHeader struct {
+	Name  string
+	Type  byte   // One of the `EVENT_HEADER_*` types
+	Value []byte // The encoded value, without the length prefix of `EVENT_HEADER_BYTES` and `EVENT_HEADER_STRING`
+}
+
+// Returns a header of type `EVENT_HEADER_STRING`. E.g. `EventStringHeader(":event-type", "AudioEvent")`
+func EventStringHeader(name, value string) EventStreamHeader {
+	return EventStreamHeader{Name: name, Type: EVENT_HEADER_STRING, Value: []byte(value)}
+}
+
+// Returns a header of type `EVENT_HEADER_BYTES`
+func EventBytesHeader(name string, value []byte) EventStreamHeader {
+	return EventStreamHeader{Name: name, Type: EVENT_HEADER_BYTES, Value: value}
+}
+
+// Returns a header of type `EVENT_HEADER_TIMESTAMP`, with millisecond precision
+func EventTimestampHeader(name string, t time.Time) EventStreamHeader {
+	return EventStreamHeader{Name: name, Type: EVENT_HEADER_TIMESTAMP, Value: binary.BigEndian.AppendUint64(nil, uint64(t.UnixMilli()))}
+}
+
+// `eventHeaderValueLength` returns the length of the fixed-size values of the type, or -1 for the length-prefixed `EVENT_HEADER_BYTES` and `EVENT_HEADER_STRING`
+func eventHeaderValueLength(t byte) (int, bool) {
+	switch t {
+	case EVENT_HEADER_BOOL_TRUE, EVENT_HEADER_BOOL_FALSE:
+		return 0, true
+	case EVENT_HEADER_BYTE:
+		return 1, true
+	case EVENT_HEADER_INT16:
+		return 2, true
+	case EVENT_HEADER_INT32:
+		return 4, true
+	case EVENT_HEADER_INT64, EVENT_HEADER_TIMESTAMP:
+		return 8, true
+	case EVENT_HEADER_UUID:
+		return 16, true
+	case EVENT_HEADER_BYTES, EVENT_HEADER_STRING:
+		return -1, true
+	}
+	return 0, false
+}
+
+// # Event stream messages
+//
+// An EventStreamMessage is a message of the binary `application/vnd.amazon.eventstream` framing used by bidirectional streaming APIs
+// (E.g. Transcribe or Kinesis over HTTP/2): a prelude, the headers, the payload, and a CRC32 checksum of the message.
+type EventStreamMessage struct {
+	Headers []EventStreamHeader
+	Payload []byte
+}
+
+// Returns the first header of the message with the name, if any
+func (m *EventStreamMessage) Header(name string) (EventStreamHeader, bool) {
+	for _, header := range m.Headers {
+		if header.Name == name {
+			return header, true
+		}
+	}
+	return EventStreamHeader{}, false
+}
+
+// `encodeEventHeaders` returns the binary encoding of the headers, in order
+func encodeEventHeaders(headers []EventStreamHeader) ([]byte, error) {
+	var b []byte
+	for _, header := range headers {
+		size, ok := eventHeaderValueLength(header.Type)
+		if !ok {
+			return nil, fmt.Errorf("%s: header %q has unknown type %d", ERROR_INCORRECT_FORMAT_EVENT_STREAM, header.Name, header.Type)
+		}
+		if len(header.Name) == 0 || len(header.Name) > 255 || (size < 0 && len(header.Value) > 32767) {
+			return nil, fmt.Errorf("%s: %q", ERROR_INVALID_EVENT_STREAM_HEADER_LEN, header.Name)
+		}
+		if size >= 0 && len(header.Value) != size {
+			return nil, fmt.Errorf("%s: header %q has a value of %d bytes, expected %d", ERROR_INCORRECT_FORMAT_EVENT_STREAM, header.Name, len(header.Value), size)
+		}
+		b = append(b, byte(len(header.Name)))
+		b = append(b, header.Name...)
+		b = append(b, header.Type)
+		if size < 0 {
+			b = binary.BigEndian.AppendUint16(b, uint16(len(header.Value)))
+		}
+		b = append(b, header.Value...)
+	}
+	return b, nil
+}
+
+// `decodeEventHeaders` decodes the binary encoding of the headers
+func decodeEventHeaders(b []byte) ([]EventStreamHeader, error) {
+	var headers []EventStreamHeader
+	for len(b) > 0 {
+		nameLength := int(b[0])
+		if nameLength == 0 || len(b) < 1+nameLength+1 {
+			return nil, fmt.Errorf("%s: truncated header", ERROR_INCORRECT_FORMAT_EVENT_STREAM)
+		}
+		header := EventStreamHeader{Name: string(b[1 : 1+nameLength]), Type: b[1+nameLength]}
+		b = b[1+nameLength+1:]
+		size, ok := eventHeaderValueLength(header.Type)
+		if !ok {
+			return nil, fmt.Errorf("%s: header %q has unknown type %d", ERROR_INCORRECT_FORMAT_EVENT_STREAM, header.Name, header.Type)
+		}
+		if size < 0 {
+			if len(b) < 2 {
+				return nil, fmt.Errorf("%s: truncated header", ERROR_INCORRECT_FORMAT_EVENT_STREAM)
+			}
+			size, b = int(binary.BigEndian.Uint16(b)), b[2:]
+		}
+		if len(b) < size {
+			return nil, fmt.Errorf("%s: truncated header", ERROR_INCORRECT_FORMAT_EVENT_STREAM)
+		}
+		header.Value, b = b[:size:size], b[size:]
+		headers = append(headers, header)
+	}
+	return headers, nil
+}
+
+// `encoding.BinaryMarshaler` implementation. Returns the message in the event stream framing.
+func (m *EventStreamMessage) MarshalBinary() ([]byte, error) {
+	headers, err := encodeEventHeaders(m.Headers)
+	if err != nil {
+		return nil, err
+	}
+	totalLength := eventStreamPreludeLength + len(headers) + len(m.Payload) + eventStreamCRCLength
+	if totalLength > MAX_EVENT_STREAM_MESSAGE_SIZE {
+		return nil, fmt.Errorf("%s: %d bytes", ERROR_EVENT_STREAM_MESSAGE_TOO_LARGE, totalLength)
+	}
+	b := make([]byte, 0, totalLength)
+	b = binary.BigEndian.AppendUint32(b, uint32(totalLength))
+	b = binary.BigEndian.AppendUint32(b, uint32(len(headers)))
+	b = binary.BigEndian.AppendUint32(b, crc32.ChecksumIEEE(b))
+	b = append(b, headers...)
+	b = append(b, m.Payload...)
+	return binary.BigEndian.AppendUint32(b, crc32.ChecksumIEEE(b)), nil
+}
+
+// Reads the next message in the event stream framing from `r`, checking its checksums. Returns `io.EOF` if `r` ends before the message.
+// E.g. to read the messages of a verified event stream request from `req.Body`.
+func ReadEventStreamMessage(r io.Reader) (*EventStreamMessage, error) {
+	prelude := make([]byte, eventStreamPreludeLength)
+	if _, err := io.ReadFull(r, prelude); err != nil {
+		if err == io.EOF {
+			return nil, io.EOF
+		}
+		return nil, fmt.Errorf("%s: %w", ERROR_INCORRECT_FORMAT_EVENT_STREAM, err)
+	}
+	totalLength, headersLength := binary.BigEndian.Uint32(prelude), binary.BigEndian.Uint32(prelude[4:])
+	if binary.BigEndian.Uint32(prelude[8:]) != crc32.ChecksumIEEE(prelude[:8]) {
+		return nil, fmt.Errorf("%s: prelude checksum mismatch", ERROR_INCORRECT_FORMAT_EVENT_STREAM)
+	}
+	if totalLength > MAX_EVENT_STREAM_MESSAGE_SIZE {
+		return nil, fmt.Errorf("%s: %d bytes", ERROR_EVENT_STREAM_MESSAGE_TOO_LARGE, totalLength)
+	}
+	if uint64(totalLength) < uint64(eventStreamPreludeLength)+uint64(headersLength)+eventStreamCRCLength {
+		return nil, fmt.Errorf("%s: invalid lengths", ERROR_INCORRECT_FORMAT_EVENT_STREAM)
+	}
+
+	message := make([]byte, totalLength)
+	copy(message, prelude)
+	if _, err := io.ReadFull(r, message[eventStreamPreludeLength:]); err != nil {
+		return nil, fmt.Errorf("%s: truncated message", ERROR_INCORRECT_FORMAT_EVENT_STREAM)
+	}
+	crcOffset := len(message) - eventStreamCRCLength
+	if binary.BigEndian.Uint32(message[crcOffset:]) != crc32.ChecksumIEEE(message[:crcOffset]) {
+		return nil, fmt.Errorf("%s: message checksum mismatch", ERROR_INCORRECT_FORMAT_EVENT_STREAM)
+	}
+	headersEnd := eventStreamPreludeLength + int(headersLength)
+	headers, err := decodeEventHeaders(message[eventStreamPreludeLength:headersEnd])
+	if err != nil {
+		return nil, err
+	}
+	return &EventStreamMessage{Headers: headers, Payload: message[headersEnd:crcOffset]}, nil
+}
+
+// `eventStringToSign` returns the `stringToSign` of an event stream message, chaining the signature of the previous message (the seed signature for the first message):
+//
+//	Algorithm + "-PAYLOAD" + "\n" + MessageDateTime + "\n" + CredentialScope + "\n" + PreviousSignature + "\n" + Hex(SHA256Hash(DateHeader)) + "\n" + Hex(SHA256Hash(message))
+//
+// where `DateHeader` is the binary encoding of the `:date` header of the signed frame, and `message` is the encoded message it carries.
+func eventStringToSign(algorithm, dateTime, credentialScope, previousSignature string, dateHeader, message []byte) string {
+	return strings.Join([]string{
+		algorithm + "-PAYLOAD",
+		dateTime,
+		credentialScope,
+		previousSignature,
+		sigv4core.HashPayload(dateHeader),
+		sigv4core.HashPayload(message),
+	}, "\n")
+}
+
+// # Event stream signing
+//
+// Signs the headers of a request whose payload is a stream of event messages, E.g. a bidirectional streaming API over HTTP/2,
+// and returns the `EventStreamWriter` writing the messages to `body`, each wrapped in a signed frame.
+//
+// The `HashedPayload` of the request is `STREAMING-[Algorithm]-EVENTS`, declared in the signed `X-[Abbr]-Content-Sha256` header.
+// The length of the stream is unknown, hence the `Content-Length` of the request is -1. The signature of the `Authorization` header is the seed signature.
+// Each message is carried as the payload of a frame with a `:date` header (the time the message is signed) and a `:chunk-signature` header,
+// signed with the signature of the previous frame, so that messages cannot be dropped, reordered or replayed within the stream.
+// The credential scope of the frames is the scope of the request. The stream ends with a signed frame with an empty payload.
+//
+// Typically `body` is the writing end of an `io.Pipe` whose reading end is the request body, like `SignStreamingHTTPRequest`.
+// The Verifier verifies each frame as the body is read, and the handler reads the messages with `ReadEventStreamMessage(req.Body)`.
+func (s *SigV4) SignEventStreamHTTPRequest(req *http.Request, body io.Writer) (*EventStreamWriter, error) {
+	signingTime := s.now()
+	algorithm := s.signingAlgorithm()
+	accessKeyID, region, err := s.signingIdentity(req.Context())
+	if err != nil {
+		return nil, err
+	}
+
+	// Set Headers
+	s.setHeader(req.Header, s.dateHeader(), s.formatDate(signingTime))
+	if token := s.sessionToken(); token != "" {
+		s.setHeader(req.Header, s.securityTokenHeader(), token)
+	}
+	if _, err := s.SetIdempotencyKey(req); err != nil {
+		return nil, err
+	}
+	payloadHash := streamingEventsPayload(algorithm)
+	s.setHeader(req.Header, s.contentSHA256Header(), payloadHash)
+	if req.Header.Get("Content-Type") == "" {
+		req.Header.Set("Content-Type", EVENT_STREAM_CONTENT_TYPE)
+	}
+	req.ContentLength = -1
+
+	// The seed signature
+	cr, sh, err := s.canonicalRequestWithPayload(req, payloadHash, req.ContentLength)
+	if err != nil {
+		return nil, err
+	}
+	scope := s.getCredentialScope(signingTime, region, s.service)
+	seedSignature, err := s.sign(req.Context(), algorithm, signingTime, region, s.stringToSign(algorithm, signingTime, region, s.service, cr))
+	if err != nil {
+		return nil, err
+	}
+	sep := s.authorizationSeparator()
+	req.Header.Set("Authorization", fmt.Sprintf("%s Credential=%s/%s%sSignedHeaders=%s%sSignature=%s", algorithm, accessKeyID, scope, sep, sh, sep, seedSignature))
+
+	return &EventStreamWriter{
+		w:        body,
+		previous: seedSignature,
+		now:      s.now,
+		sign: func(ctx context.Context, t time.Time, previous string, dateHeader, message []byte) (string, error) {
+			return s.sign(ctx, algorithm, signingTime, region, eventStringToSign(algorithm, s.formatDate(t), scope, previous, dateHeader, message))
+		},
+		ctx: req.Context(),
+	}, nil
+}
+
+// # Event stream writer
+//
+// An EventStreamWriter writes event stream messages, each wrapped in a signed frame. See `SigV4.SignEventStreamHTTPRequest`.
+// The EventStreamWriter is not safe for concurrent use.
+type EventStreamWriter struct {
+	w        io.Writer
+	previous string
+	now      func() time.Time
+	sign     func(ctx context.Context, t time.Time, previous string, dateHeader, message []byte) (string, error)
+	ctx      context.Context
+	err      error
+}
+
+// Signs and writes the message, E.g. an audio event
+func (e *EventStreamWriter) WriteMessage(m *EventStreamMessage) error {
+	if e.err != nil {
+		return e.err
+	}
+	message, err := m.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	return e.writeFrame(message)
+}
+
+// Writes the final signed frame with an empty payload. If the underlying writer is an `io.Closer` (E.g. an `*io.PipeWriter`), it is closed.
+func (e *EventStreamWriter) Close() error {
+	return e.CloseWithError(nil)
+}
+
+// Closes the EventStreamWriter like `Close` if `err` is nil. Else, the stream is abandoned, and `err` is passed on if the underlying writer is an `*io.PipeWriter`,
+// so that the request fails instead of the stream ending without its final frame.
+func (e *EventStreamWriter) CloseWithError(err error) error {
+	if err == nil {
+		if err = e.err; err == nil {
+			err = e.writeFrame(nil)
+		}
+	}
+	e.err = errors.New(ERROR_EVENT_STREAM_WRITER_CLOSED)
+	if pw, ok := e.w.(*io.PipeWriter); ok {
+		return errors.Join(err, pw.CloseWithError(err))
+	}
+	if closer, ok := e.w.(io.Closer); ok && err == nil {
+		return closer.Close()
+	}
+	return err
+}
+
+// `writeFrame` signs and writes the frame carrying the encoded `message`, recording the error if any
+func (e *EventStreamWriter) writeFrame(message []byte) error {
+	// The `:date` header has millisecond precision, hence the message is signed at the millisecond
+	t := e.now().Truncate(time.Millisecond)
+	date := EventTimestampHeader(EVENT_HEADER_DATE, t)
+	dateHeader, err := encodeEventHeaders([]EventStreamHeader{date})
+	if err != nil {
+		return err
+	}
+	signature, err := e.sign(e.ctx, t, e.previous, dateHeader, message)
+	if err == nil {
+		var raw []byte
+		if raw, err = hex.DecodeString(signature); err == nil {
+			frame := &EventStreamMessage{Headers: []EventStreamHeader{date, EventBytesHeader(EVENT_HEADER_CHUNK_SIGNATURE, raw)}, Payload: message}
+			var b []byte
+			if b, err = frame.MarshalBinary(); err == nil {
+				e.previous = signature
+				_, err = e.w.Write(b)
+			}
+		}
+	}
+	if err != nil {
+		e.err = err
+	}
+	return err
+}
+
+// An eventStreamReader decodes the signed frames of an event stream payload, verifying the signature of each frame as it is read,
+// and returns the encoded messages they carry
+type eventStreamReader struct {
+	r        io.Reader
+	body     io.Closer
+	message  []byte // The encoded message of the current frame not read yet
+	previous string
+	expected func(date time.Time, previous string, dateHeader, message []byte) (string, error)
+	done     bool
+	err      error
+}
+
+// Returns a reader of the messages of the event stream `body`. See `SigV4.SignEventStreamHTTPRequest`.
+func (s *SigV4) newEventStreamReader(body io.ReadCloser, algorithm, credentialScope, seedSignature string, signingKey []byte) *eventStreamReader {
+	return &eventStreamReader{
+		r:        body,
+		body:     body,
+		previous: seedSignature,
+		expected: func(date time.Time, previous string, dateHeader, message []byte) (string, error) {
+			return s.generateSignature(algorithm, signingKey, eventStringToSign(algorithm, s.formatDate(date), credentialScope, previous, dateHeader, message))
+		},
+	}
+}
+
+func (e *eventStreamReader) Read(p []byte) (int, error) {
+	for len(e.message) == 0 {
+		if e.err != nil {
+			return 0, e.err
+		}
+		if e.done {
+			return 0, io.EOF
+		}
+		e.err = e.readFrame()
+	}
+	n := copy(p, e.message)
+	e.message = e.message[n:]
+	return n, nil
+}
+
+func (e *eventStreamReader) Close() error {
+	return e.body.Close()
+}
+
+// `readFrame` reads and verifies the next frame
+func (e *eventStreamReader) readFrame() error {
+	frame, err := ReadEventStreamMessage(e.r)
+	if err == io.EOF {
+		return errors.New(ERROR_EVENT_STREAM_TRUNCATED)
+	}
+	if err != nil {
+		return err
+	}
+	date, ok := frame.Header(EVENT_HEADER_DATE)
+	signature, signed := frame.Header(EVENT_HEADER_CHUNK_SIGNATURE)
+	if !ok || !signed || date.Type != EVENT_HEADER_TIMESTAMP || signature.Type != EVENT_HEADER_BYTES {
+		return fmt.Errorf("%s: frame is not signed", ERROR_INCORRECT_FORMAT_EVENT_STREAM)
+	}
+	dateHeader, err := encodeEventHeaders([]EventStreamHeader{date})
+	if err != nil {
+		return err
+	}
+	expected, err := e.expected(time.UnixMilli(int64(binary.BigEndian.Uint64(date.Value))), e.previous, dateHeader, frame.Payload)
+	if err != nil {
+		return err
+	}
+	received := hex.EncodeToString(signature.Value)
+	if !hmac.Equal([]byte(expected), []byte(received)) {
+		return fmt.Errorf("%w: %s", auth.ErrSignatureMismatch, ERROR_EVENT_SIGNATURE_MISMATCH)
+	}
+	e.previous = received
+	e.message = frame.Payload
+	if len(frame.Payload) == 0 {
+		e.done = true
+	}
+	return nil
+}
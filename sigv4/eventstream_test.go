@@ -0,0 +1,172 @@
+package sigv4
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jayantasamaddar/go-httpsigner/auth"
+)
+
+// Test that the messages of an event stream round-trip through the binary framing, and that corrupted messages are rejected
+func Test_EventStreamMessage(t *testing.T) {
+	message := &EventStreamMessage{
+		Headers: []EventStreamHeader{
+			EventStringHeader(":event-type", "AudioEvent"),
+			EventBytesHeader("raw", []byte{0, 1, 2}),
+			EventTimestampHeader(":date", time.UnixMilli(1700000000123)),
+			{Name: "flag", Type: EVENT_HEADER_BOOL_TRUE},
+		},
+		Payload: []byte("audio"),
+	}
+	b, err := message.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := ReadEventStreamMessage(bytes.NewReader(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(decoded.Headers) != 4 || string(decoded.Payload) != "audio" {
+		t.Fatalf("Unexpected message: %+v", decoded)
+	}
+	if header, ok := decoded.Header(":event-type"); !ok || string(header.Value) != "AudioEvent" {
+		t.Errorf("Unexpected header: %+v", header)
+	}
+
+	b[len(b)-6] ^= 0xFF
+	if _, err := ReadEventStreamMessage(bytes.NewReader(b)); err == nil || !strings.Contains(err.Error(), ERROR_INCORRECT_FORMAT_EVENT_STREAM) {
+		t.Errorf("Expected error %q, got: %v", ERROR_INCORRECT_FORMAT_EVENT_STREAM, err)
+	}
+	if _, err := ReadEventStreamMessage(bytes.NewReader(nil)); err != io.EOF {
+		t.Errorf("Expected io.EOF, got: %v", err)
+	}
+	if _, err := (&EventStreamMessage{Headers: []EventStreamHeader{{Name: "n", Type: EVENT_HEADER_INT32, Value: []byte{1}}}}).MarshalBinary(); err == nil {
+		t.Error("Expected an error for a value of the wrong length")
+	}
+}
+
+// Test that the frames of an event stream are verified as the body is read, and that dropped, reordered or tampered frames are detected
+func Test_SignEventStreamHTTPRequest(t *testing.T) {
+	secretServer := newSecretRetrievalServer(t, testEnvConfig.SECRET_ACCESS_KEY)
+	signer, err := NewSigV4Signer("SYM", "sym", "transcribe", testEnvConfig, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	verifier, err := NewSigV4Verifier("SYM", "sym", "transcribe", secretServer.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	events := []string{"first", "second", "third"}
+	var received []string
+	var verifyErr error
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = nil
+		if verifyErr = verifier.VerifySignature(r); verifyErr != nil {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		for {
+			message, err := ReadEventStreamMessage(r.Body)
+			if err == io.EOF {
+				return
+			}
+			if err != nil {
+				verifyErr = err
+				return
+			}
+			received = append(received, string(message.Payload))
+		}
+	}))
+	defer server.Close()
+
+	// Streams the events as a signed request, transforming the signed frames
+	send := func(transform func(frames [][]byte) [][]byte) {
+		t.Helper()
+		pr, pw := io.Pipe()
+		req, _ := http.NewRequest(http.MethodPost, server.URL+"/stream-transcription", pr)
+		w, err := signer.(*SigV4).SignEventStreamHTTPRequest(req, pw)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if req.Header.Get("Content-Type") != EVENT_STREAM_CONTENT_TYPE || req.Header.Get("X-Sym-Content-Sha256") != "STREAMING-SYM4-HMAC-SHA256-EVENTS" {
+			t.Errorf("Unexpected headers: %v", req.Header)
+		}
+		writeAll := func() error {
+			for _, event := range events {
+				if err := w.WriteMessage(&EventStreamMessage{Headers: []EventStreamHeader{EventStringHeader(":event-type", "AudioEvent")}, Payload: []byte(event)}); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+		if transform != nil {
+			var encoded bytes.Buffer
+			w.w = &encoded
+			if err := writeAll(); err != nil {
+				t.Fatal(err)
+			}
+			if err := w.Close(); err != nil {
+				t.Fatal(err)
+			}
+			var frames [][]byte
+			for r := bytes.NewReader(encoded.Bytes()); r.Len() > 0; {
+				frame, _ := ReadEventStreamMessage(r)
+				b, _ := frame.MarshalBinary()
+				frames = append(frames, b)
+			}
+			req.Body = io.NopCloser(bytes.NewReader(bytes.Join(transform(frames), nil)))
+		} else {
+			go func() { w.CloseWithError(writeAll()) }()
+		}
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.Body.Close()
+	}
+
+	send(nil)
+	if verifyErr != nil {
+		t.Fatal(verifyErr)
+	}
+	if strings.Join(received, ",") != strings.Join(events, ",") {
+		t.Errorf("Expected the events %v, got: %v", events, received)
+	}
+
+	tests := []struct {
+		name      string
+		transform func(frames [][]byte) [][]byte
+		expected  string
+	}{
+		{"dropped", func(frames [][]byte) [][]byte { return append(frames[:1:1], frames[2:]...) }, ERROR_EVENT_SIGNATURE_MISMATCH},
+		{"reordered", func(frames [][]byte) [][]byte {
+			frames[0], frames[1] = frames[1], frames[0]
+			return frames
+		}, ERROR_EVENT_SIGNATURE_MISMATCH},
+		{"truncated", func(frames [][]byte) [][]byte { return frames[:len(frames)-1] }, ERROR_EVENT_STREAM_TRUNCATED},
+		{"tampered", func(frames [][]byte) [][]byte {
+			frame, _ := ReadEventStreamMessage(bytes.NewReader(frames[1]))
+			inner, _ := ReadEventStreamMessage(bytes.NewReader(frame.Payload))
+			inner.Payload = []byte("forged")
+			frame.Payload, _ = inner.MarshalBinary()
+			frames[1], _ = frame.MarshalBinary()
+			return frames
+		}, ERROR_EVENT_SIGNATURE_MISMATCH},
+	}
+	for _, test := range tests {
+		send(test.transform)
+		if verifyErr == nil || !strings.Contains(verifyErr.Error(), test.expected) {
+			t.Errorf("%s: expected error %q, got: %v", test.name, test.expected, verifyErr)
+		}
+		if test.expected == ERROR_EVENT_SIGNATURE_MISMATCH && !errors.Is(verifyErr, auth.ErrSignatureMismatch) {
+			t.Errorf("%s: expected auth.ErrSignatureMismatch, got: %v", test.name, verifyErr)
+		}
+	}
+}
@@ -0,0 +1,49 @@
+package sigv4
+
+import "net/http"
+
+// Stages of the verification pipeline, in order
+const (
+	STAGE_PARSE          = "parse"          // Parsing the `Authorization` header
+	STAGE_ALGORITHM      = "algorithm"      // Negotiating the algorithm
+	STAGE_DATE           = "date"           // Parsing the Date Header
+	STAGE_SCOPE          = "scope"          // Comparing the credential scope with the scope computed from the Date Header
+	STAGE_SIGNED_HEADERS = "signed-headers" // Validating the `SignedHeaders`
+	STAGE_RATE_LIMIT     = "rate-limit"     // Throttling the access key
+	STAGE_SECRET         = "secret"         // Retrieving the `SECRET_ACCESS_KEY`
+	STAGE_CANONICALIZE   = "canonicalize"   // Building the `CanonicalRequest`
+	STAGE_SIGNATURE      = "signature"      // Computing and comparing the signature
+	STAGE_REPLAY         = "replay"         // Checking the request against the `ReplayStore`
+	STAGE_CHAIN          = "chain"          // Verifying the hops of the signature chain
+	STAGE_CONSTRAINTS    = "constraints"    // Enforcing the constraints of a presigned request
+)
+
+// A VerificationReport describes the outcome of verifying a request, and the non-sensitive inputs used by the verification pipeline.
+// It never contains secrets, signing keys or the computed signature.
+type VerificationReport struct {
+	Verified      bool
+	FailedStage   string   // The stage of the pipeline that failed (one of the `STAGE_*` constants). Empty if verified.
+	Algorithm     string   // The negotiated algorithm
+	KeyID         string   // The `ACCESS_KEY_ID` of the `Authorization` header
+	ReceivedScope string   // The credential scope of the `Authorization` header. E.g. `20240309/ap-south-1/s3/aws4_request`
+	ComputedScope string   // The credential scope computed from the Date Header
+	Date          string   // The value of the Date Header
+	SignedHeaders []string // The `SignedHeaders` of the `Authorization` header
+	// The hex-encoded SHA-256 hash of the `CanonicalRequest` computed by the Verifier,
+	// to be compared with the hash in the `stringToSign` of the Signer
+	CanonicalRequestHash string
+	// The request is an unsigned CORS preflight, accepted without verification. See `WithSkipPreflight`.
+	PreflightSkipped bool
+
+	hop bool // Verifying a hop of the signature chain
+}
+
+// # Safe structured mismatch reporting
+//
+// Verifies the request like `VerifySignature`, and returns a report of which stage of the pipeline failed and the non-sensitive inputs used.
+// The returned error is the verification error. Intended for support tooling rather than the hot path.
+func (s *SigV4) Explain(req *http.Request) (*VerificationReport, error) {
+	report := new(VerificationReport)
+	_, err := s.verifyRequest(req.Context(), req, report)
+	return report, err
+}
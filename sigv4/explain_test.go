@@ -0,0 +1,60 @@
+package sigv4
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// Test that the report identifies the failed stage and never leaks the secret or the computed signature
+func Test_Explain(t *testing.T) {
+	server := newSecretRetrievalServer(t, testEnvConfig.SECRET_ACCESS_KEY)
+	signer, _ := NewSigV4Signer("SYM", "sym", "certificatemanager", testEnvConfig, false)
+	verifier, _ := NewSigV4Verifier("SYM", "sym", "certificatemanager", server.URL)
+	explainer := verifier.(*SigV4)
+
+	newSignedRequest := func() *http.Request {
+		req, _ := http.NewRequest(http.MethodPost, "http://validate.127.0.0.1.sslip.io/api/cmagent", bytes.NewBufferString("original"))
+		if err := signer.SignHTTPRequest(req); err != nil {
+			t.Fatal(err)
+		}
+		return req
+	}
+
+	// Verified
+	report, err := explainer.Explain(newSignedRequest())
+	if err != nil || !report.Verified || report.FailedStage != "" {
+		t.Fatalf("Expected a verified report, got: %+v, %v", report, err)
+	}
+	if report.KeyID != testEnvConfig.ACCESS_KEY_ID || report.ReceivedScope != report.ComputedScope || len(report.CanonicalRequestHash) != 64 {
+		t.Errorf("Unexpected report: %+v", report)
+	}
+
+	// Malformed Authorization header
+	req := newSignedRequest()
+	req.Header.Set("Authorization", "garbage")
+	if report, _ := explainer.Explain(req); report.FailedStage != STAGE_PARSE {
+		t.Errorf("Expected stage %q, got: %q", STAGE_PARSE, report.FailedStage)
+	}
+
+	// Malformed Date Header
+	req = newSignedRequest()
+	req.Header.Set("X-Sym-Date", "yesterday")
+	if report, _ := explainer.Explain(req); report.FailedStage != STAGE_DATE {
+		t.Errorf("Expected stage %q, got: %q", STAGE_DATE, report.FailedStage)
+	}
+
+	// Tampered payload
+	req = newSignedRequest()
+	signature := req.Header.Get("Authorization")[strings.LastIndex(req.Header.Get("Authorization"), "=")+1:]
+	req.Body = io.NopCloser(bytes.NewBufferString("tampered"))
+	report, err = explainer.Explain(req)
+	if err == nil || report.Verified || report.FailedStage != STAGE_SIGNATURE {
+		t.Errorf("Expected stage %q, got: %+v, %v", STAGE_SIGNATURE, report, err)
+	}
+	if dump := strings.Join([]string{report.CanonicalRequestHash, report.ReceivedScope, report.ComputedScope}, " "); strings.Contains(dump, testEnvConfig.SECRET_ACCESS_KEY) || strings.Contains(dump, signature) {
+		t.Errorf("Report leaks sensitive data: %+v", report)
+	}
+}
@@ -0,0 +1,97 @@
+package sigv4
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/jayantasamaddar/go-httpsigner/utils"
+)
+
+// HeaderCasing controls how the names of the headers set by the Signer (E.g. `X-[Abbr]-Date`) are cased on the wire.
+//
+// The casing has no effect on the signature, as header names are lowercased in the `CanonicalHeaders`,
+// and the Verifier looks up headers case-insensitively. It matters for peers that look up headers case-sensitively.
+type HeaderCasing int
+
+const (
+	// Canonical MIME header casing as used by the `net/http` module. E.g. `X-Sym-Date`
+	CanonicalHeaderCasing HeaderCasing = iota
+	// Lowercase header casing, as used by HTTP/2. E.g. `x-sym-date`
+	LowerHeaderCasing
+)
+
+// `setHeader` sets a header with the configured `HeaderCasing`, replacing any existing values of the header irrespective of their casing.
+func (s *SigV4) setHeader(h http.Header, name, value string) {
+	deleteHeader(h, name)
+	switch s.headerCasing {
+	case LowerHeaderCasing:
+		h[strings.ToLower(name)] = []string{value}
+	default:
+		h.Set(name, value)
+	}
+}
+
+// `deleteHeader` deletes the header in any casing
+func deleteHeader(h http.Header, name string) {
+	for key := range h {
+		if strings.EqualFold(key, name) {
+			delete(h, key)
+		}
+	}
+}
+
+// `headerValues` returns all values of a header, looking up the header name case-insensitively.
+// The values of names differing only by case are joined in the sorted order of the names, as they are canonicalized. See `sigv4core.CanonicalHeaders`.
+func headerValues(h http.Header, name string) []string {
+	var keys []string
+	for key := range h {
+		if strings.EqualFold(key, name) {
+			keys = append(keys, key)
+		}
+	}
+	if len(keys) == 1 {
+		return h[keys[0]]
+	}
+	slices.Sort(keys)
+	var values []string
+	for _, key := range keys {
+		values = append(values, h[key]...)
+	}
+	return values
+}
+
+// `isValidHeaderName` checks if a configurable header name only contains letters, digits and '-',
+// and is not a header canonicalized by other means
+func isValidHeaderName(name string) bool {
+	if name == "" || strings.EqualFold(name, "Authorization") || strings.EqualFold(name, "Host") || strings.EqualFold(name, "Content-Length") {
+		return false
+	}
+	for _, r := range name {
+		if !('a' <= r && r <= 'z' || 'A' <= r && r <= 'Z' || '0' <= r && r <= '9' || r == '-') {
+			return false
+		}
+	}
+	return true
+}
+
+// `getHeader` returns the first value of a header, looking up the header name case-insensitively.
+func getHeader(h http.Header, name string) string {
+	if values := headerValues(h, name); len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+// `newRequestID` generates a random (version 4) UUID to be used as a request ID, read from the `entropy` source. See `WithEntropySource`.
+func newRequestID(entropy io.Reader) (string, error) {
+	var b [16]byte
+	if err := utils.ReadEntropy(entropy, b[:]); err != nil {
+		return "", err
+	}
+	b[6] = (b[6] & 0x0f) | 0x40 // Version 4
+	b[8] = (b[8] & 0x3f) | 0x80 // Variant 10
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16]), nil
+}
@@ -0,0 +1,98 @@
+package sigv4
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+)
+
+// Errors
+const (
+	ERROR_IDEMPOTENCY_KEY_MISSING    = "idempotency key header is missing"
+	ERROR_IDEMPOTENCY_KEY_NOT_SIGNED = "idempotency key header is not signed"
+)
+
+// The default header carrying the idempotency key. See `WithIdempotencyKey`.
+const DEFAULT_IDEMPOTENCY_KEY_HEADER = "Idempotency-Key"
+
+// # Idempotency keys
+//
+// Carry a signed idempotency key in the `header` (`DEFAULT_IDEMPOTENCY_KEY_HEADER` if empty) of requests with one of the `methods`
+// (`POST` and `PATCH` if none), so that servers can deduplicate retried requests: every attempt of a logical operation carries the same key,
+// while each attempt is signed with its own date.
+//
+// The Signer preserves the key of a request if set, else generates a random (version 4) UUID. A key generated while signing is preserved
+// when the request is signed again (See `ResignHTTPRequest`), and by the retries of `http.Transport`. Requests signed by the signing transport
+// (See `httpsigner.NewTransport`) are signed as clones, hence set the key of the logical operation on the request beforehand (See `SetIdempotencyKey`).
+//
+// The Verifier rejects requests with one of the `methods` without the header, or where the header is not signed,
+// and returns the key in the `auth.Identity` (See `SigV4.Authenticate`). The header must not be skipped (See `WithSkipHeaders`):
+// the constructors return an `*ErrInvalidHeaderName` otherwise.
+func WithIdempotencyKey(header string, methods ...string) Option {
+	return func(s *SigV4) {
+		s.idempotencyKey = true
+		s.idempotencyKeyHeader = header
+		s.idempotencyMethods = methods
+	}
+}
+
+// Generate the Idempotency Key Header name. Defaults to `DEFAULT_IDEMPOTENCY_KEY_HEADER`.
+func (s *SigV4) idempotencyKeyHeaderName() string {
+	if s.idempotencyKeyHeader == "" {
+		return DEFAULT_IDEMPOTENCY_KEY_HEADER
+	}
+	return s.idempotencyKeyHeader
+}
+
+// Checks if requests with the method carry an idempotency key
+func (s *SigV4) requiresIdempotencyKey(method string) bool {
+	if !s.idempotencyKey {
+		return false
+	}
+	if len(s.idempotencyMethods) == 0 {
+		return method == http.MethodPost || method == http.MethodPatch
+	}
+	return slices.ContainsFunc(s.idempotencyMethods, func(m string) bool {
+		return strings.EqualFold(m, method)
+	})
+}
+
+// Sets a new idempotency key on the request, unless it already has one, E.g. once per logical operation before the request is sent
+// through the signing transport and retried. Does nothing for methods not configured with `WithIdempotencyKey`.
+//
+// Returns the idempotency key of the request.
+func (s *SigV4) SetIdempotencyKey(req *http.Request) (string, error) {
+	if !s.requiresIdempotencyKey(req.Method) {
+		return "", nil
+	}
+	if key := getHeader(req.Header, s.idempotencyKeyHeaderName()); key != "" {
+		return key, nil
+	}
+	key, err := newRequestID(s.entropy)
+	if err != nil {
+		return "", err
+	}
+	s.setHeader(req.Header, s.idempotencyKeyHeaderName(), key)
+	return key, nil
+}
+
+// `checkIdempotencyKey` returns the signed idempotency key of a received request, if any.
+// Fails if the request has one of the configured methods and no key, or if its key is not signed.
+func (s *SigV4) checkIdempotencyKey(req *http.Request, signedHeaders []string) (string, error) {
+	if !s.idempotencyKey {
+		return "", nil
+	}
+	name := s.idempotencyKeyHeaderName()
+	key := getHeader(req.Header, name)
+	if key == "" {
+		if s.requiresIdempotencyKey(req.Method) {
+			return "", fmt.Errorf("%s: %s", ERROR_IDEMPOTENCY_KEY_MISSING, name)
+		}
+		return "", nil
+	}
+	if !slices.Contains(signedHeaders, strings.ToLower(name)) {
+		return "", fmt.Errorf("%s: %s", ERROR_IDEMPOTENCY_KEY_NOT_SIGNED, name)
+	}
+	return key, nil
+}
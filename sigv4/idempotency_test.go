@@ -0,0 +1,98 @@
+package sigv4
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// Test that the idempotency key is generated once per logical operation, signed, preserved across retries, and surfaced in the Identity
+func Test_WithIdempotencyKey(t *testing.T) {
+	server := newSecretRetrievalServer(t, testEnvConfig.SECRET_ACCESS_KEY)
+	signer, err := NewSigV4Signer("SYM", "sym", "certificatemanager", testEnvConfig, false, WithIdempotencyKey(""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	verifier, err := NewSigV4Verifier("SYM", "sym", "certificatemanager", server.URL, WithIdempotencyKey(""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, v := signer.(*SigV4), verifier.(*SigV4)
+
+	req, _ := http.NewRequest(http.MethodPost, "http://validate.127.0.0.1.sslip.io/api/cmagent", strings.NewReader(`{"amount":100}`))
+	if err := s.SignHTTPRequest(req); err != nil {
+		t.Fatal(err)
+	}
+	key := req.Header.Get(DEFAULT_IDEMPOTENCY_KEY_HEADER)
+	if len(key) != 36 {
+		t.Fatalf("Expected a generated UUID, got: %q", key)
+	}
+	if !strings.Contains(req.Header.Get("Authorization"), "idempotency-key") {
+		t.Errorf("Expected the idempotency key to be signed, got: %s", req.Header.Get("Authorization"))
+	}
+	identity, err := v.Authenticate(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if identity.IdempotencyKey != key {
+		t.Errorf("Expected the idempotency key %q in the Identity, got: %q", key, identity.IdempotencyKey)
+	}
+
+	// A retry carries the same key, with its own signature
+	_, _ = io.ReadAll(req.Body)
+	if err := s.ResignHTTPRequest(req); err != nil {
+		t.Fatal(err)
+	}
+	if got := req.Header.Get(DEFAULT_IDEMPOTENCY_KEY_HEADER); got != key {
+		t.Errorf("Expected the idempotency key %q to be preserved, got: %q", key, got)
+	}
+	if err := v.VerifySignature(req); err != nil {
+		t.Error(err)
+	}
+
+	// A key set beforehand for the logical operation is preserved, and GET requests carry no key
+	req, _ = http.NewRequest(http.MethodPatch, "http://validate.127.0.0.1.sslip.io/api/cmagent", nil)
+	if key, err = s.SetIdempotencyKey(req); err != nil || key == "" {
+		t.Fatalf("Expected a key, got: %q, %v", key, err)
+	}
+	if again, _ := s.SetIdempotencyKey(req); again != key {
+		t.Errorf("Expected the key %q to be preserved, got: %q", key, again)
+	}
+	_ = s.SignHTTPRequest(req)
+	if got := req.Header.Get(DEFAULT_IDEMPOTENCY_KEY_HEADER); got != key {
+		t.Errorf("Expected the key %q to be signed, got: %q", key, got)
+	}
+	req, _ = http.NewRequest(http.MethodGet, "http://validate.127.0.0.1.sslip.io/api/cmagent", nil)
+	_ = s.SignHTTPRequest(req)
+	if got := req.Header.Get(DEFAULT_IDEMPOTENCY_KEY_HEADER); got != "" {
+		t.Errorf("Expected no key for a GET request, got: %q", got)
+	}
+	if err := v.VerifySignature(req); err != nil {
+		t.Error(err)
+	}
+
+	// A POST request without a key, or with an unsigned key, is rejected
+	plain, _ := NewSigV4Signer("SYM", "sym", "certificatemanager", testEnvConfig, false)
+	req, _ = http.NewRequest(http.MethodPost, "http://validate.127.0.0.1.sslip.io/api/cmagent", strings.NewReader(`{"amount":100}`))
+	_ = plain.SignHTTPRequest(req)
+	if err := v.VerifySignature(req); err == nil || !strings.Contains(err.Error(), ERROR_IDEMPOTENCY_KEY_MISSING) {
+		t.Errorf("Expected error %q, got: %v", ERROR_IDEMPOTENCY_KEY_MISSING, err)
+	}
+	req.Header.Set(DEFAULT_IDEMPOTENCY_KEY_HEADER, "injected")
+	if err := v.VerifySignature(req); err == nil || !strings.Contains(err.Error(), ERROR_IDEMPOTENCY_KEY_NOT_SIGNED) {
+		t.Errorf("Expected error %q, got: %v", ERROR_IDEMPOTENCY_KEY_NOT_SIGNED, err)
+	}
+
+	// The header must be valid and must not be skipped
+	for _, opts := range [][]Option{
+		{WithIdempotencyKey("Idempotency Key")},
+		{WithIdempotencyKey(""), WithSkipHeaders("Idempotency-Key")},
+	} {
+		var headerErr *ErrInvalidHeaderName
+		if _, err := NewSigV4Signer("SYM", "sym", "certificatemanager", testEnvConfig, false, opts...); !errors.As(err, &headerErr) {
+			t.Errorf("Expected an *ErrInvalidHeaderName, got: %v", err)
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package sigv4
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jayantasamaddar/go-httpsigner/auth"
+	"github.com/jayantasamaddar/go-httpsigner/sigv4core"
+)
+
+// A SignatureInfo describes the signature of a request as received, without verifying it. See `SigV4.Inspect`.
+type SignatureInfo struct {
+	Algorithm     string
+	KeyID         string   // The `ACCESS_KEY_ID` of the credential
+	Scope         string   // The credential scope. E.g. `20240309/ap-south-1/s3/aws4_request`
+	Region        string   // The region of the credential scope
+	Service       string   // The service of the credential scope
+	SignedHeaders []string // The `SignedHeaders` of the signature
+	Presigned     bool     // The request is signed in the query. See `PresignHTTPRequest`.
+	SignedAt      time.Time
+	// The time elapsed since the request was signed, according to the `Clock` (See `WithClock`). Negative if dated in the future.
+	Age time.Duration
+	// The end of the validity of the signature: the expiry of a presigned request, or the end of the replay window (See `WithReplayStore`).
+	// Zero if the signature does not expire.
+	ExpiresAt time.Time
+	Expired   bool
+}
+
+// # Signature introspection
+//
+// Parses the signature of the request like the Verifier would, and returns its algorithm, key ID, scope, signed headers, age and expiry status
+// without retrieving the secret nor verifying the signature, E.g. for routers sharding requests by key ID before full verification.
+// The request is not modified, and the algorithm is not negotiated (See `WithAcceptedAlgorithms`).
+//
+// Nothing returned is authentic until the request is verified. Returns an error wrapping `auth.ErrSignatureMissing` if the request is not signed.
+func (s *SigV4) Inspect(req *http.Request) (*SignatureInfo, error) {
+	authorization, ok := s.signatureAuthorization(req)
+	if !ok && s.isPresigned(req) {
+		return s.inspectPresigned(req)
+	}
+	if authorization == "" {
+		return nil, fmt.Errorf("%w: %s", auth.ErrSignatureMissing, ERROR_SIGNATURE_MISSING)
+	}
+	authHeaders, err := s.parseAuthHeaders(authorization)
+	if err != nil {
+		return nil, err
+	}
+	info, err := s.signatureInfo(authHeaders, getHeader(req.Header, s.dateHeader()))
+	if err != nil {
+		return nil, err
+	}
+	if s.replayStore != nil {
+		info.ExpiresAt = info.SignedAt.Add(s.replayWindow)
+		info.Expired = info.Age > s.replayWindow || info.Age < -s.replayWindow
+	}
+	return info, nil
+}
+
+// `inspectPresigned` inspects a request signed in the query, like `Inspect`
+func (s *SigV4) inspectPresigned(req *http.Request) (*SignatureInfo, error) {
+	query, err := url.ParseQuery(req.URL.RawQuery)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", sigv4core.ERROR_MALFORMED_QUERY, err)
+	}
+	authHeaders, err := s.parseAuthHeaders(fmt.Sprintf("%s Credential=%s,SignedHeaders=%s,Signature=%s",
+		queryValue(query, s.queryParamName("Algorithm")),
+		queryValue(query, s.queryParamName("Credential")),
+		queryValue(query, s.queryParamName("SignedHeaders")),
+		queryValue(query, s.queryParamName("Signature")),
+	))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", ERROR_INCORRECT_FORMAT_QUERY, err)
+	}
+	info, err := s.signatureInfo(authHeaders, queryValue(query, s.queryParamName("Date")))
+	if err != nil {
+		return nil, err
+	}
+	info.Presigned = true
+	seconds, err := strconv.ParseInt(queryValue(query, s.queryParamName("Expires")), 10, 64)
+	if err != nil || seconds < 1 || seconds > int64(MAX_PRESIGN_EXPIRES/time.Second) {
+		return nil, fmt.Errorf("%s: %s", ERROR_INCORRECT_FORMAT_QUERY, ERROR_INVALID_EXPIRES)
+	}
+	info.ExpiresAt = info.SignedAt.Add(time.Duration(seconds) * time.Second)
+	info.Expired = info.Age > time.Duration(seconds)*time.Second || info.Age < -presignClockSkew
+	return info, nil
+}
+
+// `signatureInfo` returns the SignatureInfo of the parsed signature of a request dated `date`
+func (s *SigV4) signatureInfo(authHeaders *AuthHeaders, date string) (*SignatureInfo, error) {
+	signedAt, err := s.parseDate(date)
+	if err != nil {
+		return nil, err
+	}
+	return &SignatureInfo{
+		Algorithm:     authHeaders.Algorithm,
+		KeyID:         authHeaders.Credential.ACCESS_KEY_ID,
+		Scope:         strings.SplitN(authHeaders.Credential.String(), "/", 2)[1],
+		Region:        authHeaders.Credential.Region,
+		Service:       authHeaders.Credential.Service,
+		SignedHeaders: authHeaders.SignedHeaders,
+		SignedAt:      signedAt,
+		Age:           s.now().Sub(signedAt),
+	}, nil
+}
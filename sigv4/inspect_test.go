@@ -0,0 +1,63 @@
+package sigv4
+
+import (
+	"errors"
+	"net/http"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/jayantasamaddar/go-httpsigner/auth"
+)
+
+// Test that the signature of header-signed and presigned requests is described without retrieving the secret
+func Test_Inspect(t *testing.T) {
+	signingTime := time.Date(2024, time.March, 15, 10, 30, 0, 0, time.UTC)
+	signer, err := NewSigV4Signer("SYM", "sym", "certificatemanager", testEnvConfig, false, WithClock(FixedClock(signingTime)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The secret retrieval URL is never called
+	inspector, err := NewSigV4Verifier("SYM", "sym", "certificatemanager", "http://127.0.0.1:1/secret",
+		WithClock(FixedClock(signingTime.Add(10*time.Minute))), WithReplayStore(NewMemoryReplayStore(), 5*time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := inspector.(*SigV4)
+
+	req, _ := http.NewRequest(http.MethodGet, "http://validate.127.0.0.1.sslip.io/api/cmagent", nil)
+	if err := signer.SignHTTPRequest(req); err != nil {
+		t.Fatal(err)
+	}
+	info, err := s.Inspect(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.KeyID != testEnvConfig.ACCESS_KEY_ID || info.Region != testEnvConfig.REGION || info.Service != "certificatemanager" || info.Presigned {
+		t.Errorf("Unexpected info: %+v", info)
+	}
+	if !slices.Contains(info.SignedHeaders, "x-sym-date") || !info.SignedAt.Equal(signingTime) || info.Age != 10*time.Minute {
+		t.Errorf("Unexpected info: %+v", info)
+	}
+	if !info.Expired || !info.ExpiresAt.Equal(signingTime.Add(5*time.Minute)) {
+		t.Errorf("Expected the signature to be expired at the end of the replay window, got: %+v", info)
+	}
+
+	// Presigned
+	req, _ = http.NewRequest(http.MethodGet, "http://validate.127.0.0.1.sslip.io/api/cmagent", nil)
+	if err := signer.(*SigV4).PresignHTTPRequest(req, time.Hour, nil); err != nil {
+		t.Fatal(err)
+	}
+	if info, err = s.Inspect(req); err != nil {
+		t.Fatal(err)
+	}
+	if !info.Presigned || info.Expired || !info.ExpiresAt.Equal(signingTime.Add(time.Hour)) || info.KeyID != testEnvConfig.ACCESS_KEY_ID {
+		t.Errorf("Unexpected info: %+v", info)
+	}
+
+	// Unsigned
+	req, _ = http.NewRequest(http.MethodGet, "http://validate.127.0.0.1.sslip.io/api/cmagent", nil)
+	if _, err := s.Inspect(req); !errors.Is(err, auth.ErrSignatureMissing) {
+		t.Errorf("Expected auth.ErrSignatureMissing, got: %v", err)
+	}
+}
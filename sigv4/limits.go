@@ -0,0 +1,71 @@
+package sigv4
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// Errors
+const (
+	ERROR_TOO_MANY_HEADERS        = "request has too many headers"
+	ERROR_HEADERS_TOO_LARGE       = "headers of the request are too large"
+	ERROR_TOO_MANY_SIGNED_HEADERS = "signature has too many signed headers"
+)
+
+// Limits on the headers of the requests verified by the Verifier. A non-positive limit disables it. See `WithHeaderLimits`.
+type HeaderLimits struct {
+	MaxHeaders       int // Maximum number of header values of the request
+	MaxHeaderBytes   int // Maximum total size of the names and values of the headers of the request
+	MaxSignedHeaders int // Maximum number of `SignedHeaders` declared by the signature
+}
+
+// Header limits generous enough for browsers, proxies and SDKs, yet bounding the work of canonicalizing adversarial requests
+var DEFAULT_HEADER_LIMITS = HeaderLimits{
+	MaxHeaders:       200,
+	MaxHeaderBytes:   64 << 10,
+	MaxSignedHeaders: 64,
+}
+
+// # Header limits
+//
+// Reject requests exceeding the `limits` before any other work of the verification, so that the CPU spent canonicalizing
+// adversarial requests with thousands of headers, or signatures declaring thousands of `SignedHeaders`, is bounded.
+// E.g. `WithHeaderLimits(sigv4.DEFAULT_HEADER_LIMITS)`.
+//
+// The limits apply to the `Authorization` header, to presigned requests, and to the hops of a signature chain (See `WithSignatureChaining`).
+// The errors do not wrap `auth.ErrSignatureMismatch`, hence are reported as malformed requests. Without the option, requests are not limited
+// beyond the limits of the HTTP server (E.g. `http.Server.MaxHeaderBytes`).
+func WithHeaderLimits(limits HeaderLimits) Option {
+	return func(s *SigV4) {
+		s.headerLimits = limits
+	}
+}
+
+// `checkHeaderLimits` checks the number and the total size of the headers of the request against the limits
+func (s *SigV4) checkHeaderLimits(h http.Header) error {
+	if s.headerLimits.MaxHeaders <= 0 && s.headerLimits.MaxHeaderBytes <= 0 {
+		return nil
+	}
+	count, size := 0, 0
+	for name, values := range h {
+		count += len(values)
+		for _, value := range values {
+			size += len(name) + len(value)
+		}
+	}
+	if limit := s.headerLimits.MaxHeaders; limit > 0 && count > limit {
+		return fmt.Errorf("%s: %d > %d", ERROR_TOO_MANY_HEADERS, count, limit)
+	}
+	if limit := s.headerLimits.MaxHeaderBytes; limit > 0 && size > limit {
+		return fmt.Errorf("%s: %d > %d bytes", ERROR_HEADERS_TOO_LARGE, size, limit)
+	}
+	return nil
+}
+
+// `checkSignedHeadersLimit` checks the number of `SignedHeaders` declared by a signature against the limit
+func (s *SigV4) checkSignedHeadersLimit(signedHeaders []string) error {
+	if limit := s.headerLimits.MaxSignedHeaders; limit > 0 && len(signedHeaders) > limit {
+		return fmt.Errorf("%s: %d > %d", ERROR_TOO_MANY_SIGNED_HEADERS, len(signedHeaders), limit)
+	}
+	return nil
+}
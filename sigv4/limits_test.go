@@ -0,0 +1,71 @@
+package sigv4
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/jayantasamaddar/go-httpsigner/auth"
+)
+
+// Test that requests exceeding the header limits are rejected before the secret is retrieved
+func Test_WithHeaderLimits(t *testing.T) {
+	server := newSecretRetrievalServer(t, testEnvConfig.SECRET_ACCESS_KEY)
+	signer, err := NewSigV4Signer("SYM", "sym", "certificatemanager", testEnvConfig, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	verifier, err := NewSigV4Verifier("SYM", "sym", "certificatemanager", server.URL,
+		WithHeaderLimits(HeaderLimits{MaxHeaders: 20, MaxHeaderBytes: 2048, MaxSignedHeaders: 8}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	v := verifier.(*SigV4)
+
+	newSignedRequest := func(headers int, valueSize int) *http.Request {
+		req, _ := http.NewRequest(http.MethodGet, "http://validate.127.0.0.1.sslip.io/api/cmagent", nil)
+		for i := 0; i < headers; i++ {
+			req.Header.Set(fmt.Sprintf("X-Custom-%d", i), strings.Repeat("v", valueSize))
+		}
+		if err := signer.SignHTTPRequest(req); err != nil {
+			t.Fatal(err)
+		}
+		return req
+	}
+
+	if err := v.VerifySignature(newSignedRequest(4, 8)); err != nil {
+		t.Errorf("Expected a request within the limits to verify, got: %v", err)
+	}
+
+	// Headers added after signing, E.g. by an attacker
+	unsigned := newSignedRequest(0, 0)
+	for i := 0; i < 30; i++ {
+		unsigned.Header.Add("X-Unsigned", "v")
+	}
+
+	tests := []struct {
+		name     string
+		req      *http.Request
+		expected string
+	}{
+		{"too many signed headers", newSignedRequest(8, 8), ERROR_TOO_MANY_SIGNED_HEADERS},
+		{"headers too large", newSignedRequest(2, 2048), ERROR_HEADERS_TOO_LARGE},
+		{"too many headers", unsigned, ERROR_TOO_MANY_HEADERS},
+	}
+
+	for _, test := range tests {
+		report, err := v.Explain(test.req)
+		if err == nil || !strings.Contains(err.Error(), test.expected) {
+			t.Errorf("%s: expected error %q, got: %v", test.name, test.expected, err)
+		}
+		if errors.Is(err, auth.ErrSignatureMismatch) {
+			t.Errorf("%s: expected the error not to be a signature mismatch", test.name)
+		}
+		if report.FailedStage != STAGE_PARSE {
+			t.Errorf("%s: expected the stage %q to fail, got: %q", test.name, STAGE_PARSE, report.FailedStage)
+		}
+	}
+}
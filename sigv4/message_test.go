@@ -0,0 +1,75 @@
+package sigv4
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jayantasamaddar/go-httpsigner/auth"
+)
+
+// Test that a signed message is verified once, and that tampering with the payload or the signature is detected
+func Test_SignMessage(t *testing.T) {
+	secretServer := newSecretRetrievalServer(t, testEnvConfig.SECRET_ACCESS_KEY)
+	signer, err := NewSigV4Signer("SYM", "sym", "webhooks", testEnvConfig, false, WithStrictAWSMode())
+	if err != nil {
+		t.Fatal(err)
+	}
+	verifier, err := NewSigV4Verifier("SYM", "sym", "webhooks", secretServer.URL, WithStrictAWSMode(), WithReplayStore(NewMemoryReplayStore(), time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, v := signer.(*SigV4), verifier.(*SigV4)
+
+	payload := []byte(`{"event":"order.created","order_id":42}`)
+	sig, err := s.SignMessage(payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(sig, "SYM4-HMAC-SHA256 Credential="+testEnvConfig.ACCESS_KEY_ID+"/") || !strings.Contains(sig, ", Date=") {
+		t.Errorf("Unexpected signature: %s", sig)
+	}
+
+	if err := v.VerifyMessage([]byte(`{"event":"order.created","order_id":43}`), sig); !errors.Is(err, auth.ErrSignatureMismatch) {
+		t.Errorf("Expected auth.ErrSignatureMismatch for a tampered payload, got: %v", err)
+	}
+	if err := v.VerifyMessage(payload, strings.Replace(sig, "Date=", "Dated=", 1)); err == nil || !strings.Contains(err.Error(), ERROR_INCORRECT_FORMAT_MESSAGE_SIGNATURE) {
+		t.Errorf("Expected error %q, got: %v", ERROR_INCORRECT_FORMAT_MESSAGE_SIGNATURE, err)
+	}
+	if err := v.VerifyMessage(payload, sig); err != nil {
+		t.Fatal(err)
+	}
+	if err := v.VerifyMessage(payload, sig); !errors.Is(err, auth.ErrRequestReplayed) {
+		t.Errorf("Expected auth.ErrRequestReplayed, got: %v", err)
+	}
+}
+
+// Test that a message signed with a forged credential scope is rejected, and that the verification observes its context
+func Test_VerifyMessageContext(t *testing.T) {
+	secretServer := newSecretRetrievalServer(t, testEnvConfig.SECRET_ACCESS_KEY)
+	signer, _ := NewSigV4Signer("SYM", "sym", "webhooks", testEnvConfig, false)
+	verifier, _ := NewSigV4Verifier("SYM", "sym", "webhooks", secretServer.URL)
+	s, v := signer.(*SigV4), verifier.(*SigV4)
+
+	payload := []byte(`{"event":"order.created","order_id":42}`)
+	sig, err := s.SignMessage(payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := v.VerifyMessageContext(context.Background(), payload, sig); err != nil {
+		t.Fatal(err)
+	}
+
+	forged := strings.Replace(sig, "/"+s.scopeDate(time.Now())+"/", "/20000101/", 1)
+	if err := v.VerifyMessage(payload, forged); err == nil || !strings.Contains(err.Error(), ERROR_SCOPE_MISMATCH) {
+		t.Errorf("Expected error %q, got: %v", ERROR_SCOPE_MISMATCH, err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := v.VerifyMessageContext(ctx, payload, sig); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled, got: %v", err)
+	}
+}
@@ -0,0 +1,65 @@
+package sigv4
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// Test that OpenSearch requests are signed over the compressed body, and verified by a Verifier of the service
+func Test_OpenSearchTransport(t *testing.T) {
+	secretServer := newSecretRetrievalServer(t, testEnvConfig.SECRET_ACCESS_KEY)
+	payload := `{"query":{"match_all":{}}}`
+
+	for _, service := range []string{SERVICE_OPENSEARCH, SERVICE_OPENSEARCH_SERVERLESS} {
+		verifier, err := NewSigV4Verifier("AWS", "amz", service, secretServer.URL, WithStrictAWSMode())
+		if err != nil {
+			t.Fatal(err)
+		}
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if err := verifier.VerifySignature(r); err != nil {
+				http.Error(w, err.Error(), http.StatusForbidden)
+				return
+			}
+			if r.Header.Get("Content-Encoding") != "gzip" {
+				http.Error(w, "expected a compressed body", http.StatusBadRequest)
+				return
+			}
+			zr, err := gzip.NewReader(r.Body)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			io.Copy(w, zr)
+		}))
+		defer server.Close()
+
+		transport, err := NewOpenSearchTransport(service, testEnvConfig, nil, true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		client := &http.Client{Transport: transport}
+
+		req, _ := http.NewRequest(http.MethodPost, server.URL+"/my-index/_search?size=10", bytes.NewBufferString(payload))
+		req.Header.Set("Content-Type", "application/json")
+		res, err := client.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, _ := io.ReadAll(res.Body)
+		res.Body.Close()
+		if res.StatusCode != http.StatusOK || string(b) != payload {
+			t.Errorf("%s: expected status: %d with the payload, got: %d (%s)", service, http.StatusOK, res.StatusCode, b)
+		}
+		if req.Header.Get("Authorization") != "" || req.Header.Get("Content-Encoding") != "" {
+			t.Errorf("%s: expected the request to be left unmodified", service)
+		}
+	}
+
+	if _, err := NewOpenSearchTransport("s3", testEnvConfig, nil, false); err == nil {
+		t.Error("Expected an error for a service other than es and aoss")
+	}
+}
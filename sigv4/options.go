@@ -0,0 +1,220 @@
+package sigv4
+
+import (
+	"io"
+	"strings"
+	"time"
+
+	"github.com/jayantasamaddar/go-httpsigner/utils/backoff"
+)
+
+// An Option configures optional behaviour of a `SigV4` Signer or Verifier.
+// Options are passed as the trailing arguments of `NewSigV4Signer` and `NewSigV4Verifier`.
+//
+// The Signer and the Verifier must be configured with the same options that affect the canonical request,
+// or signatures will not match.
+type Option func(*SigV4)
+
+// Encode query parameter names and values following the SigV4 `UriEncode` rules while building the `CanonicalQueryString`.
+//
+// By default, query parameters are encoded as `application/x-www-form-urlencoded`, i.e. a space is encoded as '+'.
+// In strict mode, a space is encoded as "%20" and a literal '+' as "%2B", and the parameters are sorted by the encoded name,
+// then by the encoded value for duplicate names (E.g. `a=y&a=X` becomes `a=X&a=y`), which is what the SigV4 specification (RFC 3986) mandates.
+// See `sigv4core.CanonicalQueryString`.
+//
+// In both modes, the raw query is first decoded as a form, so that a '+' in the raw query is a space and "%2B" is a literal '+'.
+// E.g. the values produced by `url.Values.Encode()` round-trip unchanged.
+func WithStrictQueryEncoding(strict bool) Option {
+	return func(s *SigV4) {
+		s.strictQueryEncoding = strict
+	}
+}
+
+// URI-encode the `CanonicalURI` twice, as AWS services other than S3 (E.g. API Gateway) expect. E.g. the path `/a%20b` is canonicalized as `/a%2520b`.
+//
+// By default, the path is encoded once, as S3 expects. Signatures of both modes are not interchangeable: configure the Signer and the Verifier alike.
+func WithDoubleURIEncode(double bool) Option {
+	return func(s *SigV4) {
+		s.doubleURIEncode = double
+	}
+}
+
+// Percent-encode every non-ASCII byte of the path and of the strictly encoded query (See `WithStrictQueryEncoding`) while building the `CanonicalRequest`,
+// as the SigV4 specification and other SigV4 implementations do. E.g. the path `/café` is canonicalized as `/caf%C3%A9`. See `sigv4core.StrictURIEncode`.
+//
+// By default, non-ASCII letters and digits are not encoded (E.g. `/café`), and other non-ASCII characters are encoded as the bytes of their UTF-8 encoding.
+// Header values are canonicalized byte for byte in both modes. Signatures of both modes are not interchangeable for non-ASCII paths and queries:
+// configure the Signer and the Verifier alike.
+func WithStrictURIEncoding(strict bool) Option {
+	return func(s *SigV4) {
+		s.strictURIEncoding = strict
+	}
+}
+
+// Read the random values of the Signer and the Verifier (E.g. generated request IDs, and the nonces of the secret retrieval requests)
+// from the `entropy` source instead of `crypto/rand`. E.g. a DRBG mandated by the environment, or a fixed stream for deterministic tests.
+// The `entropy` source must be safe for concurrent use, and must never be predictable in production.
+//
+// Jittered retries (See `backoff.JitteredWith`) and guarded secret sealers (See `NewGuardedSecretSealerWithEntropy`) take their own source.
+func WithEntropySource(entropy io.Reader) Option {
+	return func(s *SigV4) {
+		s.entropy = entropy
+	}
+}
+
+// Declare headers that never participate in canonicalization, even if present on the request.
+// Header names are case-insensitive. The `Host` header cannot be skipped.
+//
+// This is intended for headers injected by infrastructure between the Signer and the Verifier, such as load balancers,
+// CDNs and reverse proxies (E.g. `X-Request-Id`, `X-Forwarded-For`, `Via`). A Signer configured with the list never signs these headers,
+// and a Verifier configured with the list rejects a request that claims to have signed one of them, instead of failing with a signature mismatch.
+//
+// Calling `WithSkipHeaders` multiple times adds to the list.
+func WithSkipHeaders(headers ...string) Option {
+	return func(s *SigV4) {
+		if s.skipHeaders == nil {
+			s.skipHeaders = make(map[string]struct{}, len(headers))
+		}
+		for _, header := range headers {
+			if header = strings.ToLower(strings.TrimSpace(header)); header != "" && header != "host" {
+				s.skipHeaders[header] = struct{}{}
+			}
+		}
+	}
+}
+
+// Fail signing and verification with `sigv4core.ERROR_HEADER_COLLISION` if the names of two canonicalized headers differ only by case
+// (E.g. `X-Custom` and `x-custom`), or if a received signature declares a header twice in its `SignedHeaders`.
+//
+// By default, the values of such headers are joined in the sorted order of their names, as `http.Header.Write` sends them.
+// Transports sending them in another order (E.g. HTTP/2) make the signature fail to verify: reject them to surface the collision to the caller instead.
+func WithRejectHeaderCollisions(reject bool) Option {
+	return func(s *SigV4) {
+		s.rejectHeaderCollisions = reject
+	}
+}
+
+// Do not sign the headers with an empty value, as some clients and proxies drop them. By default, they are signed as `name:`.
+// Only affects the Signer: the Verifier canonicalizes the headers declared by the `SignedHeaders` of the signature, whether empty or not.
+func WithOmitEmptyHeaders(omit bool) Option {
+	return func(s *SigV4) {
+		s.omitEmptyHeaders = omit
+	}
+}
+
+// Consult the given headers, in order, for the host of the request before falling back to `req.Host`.
+//
+// Reverse proxies often rewrite the `Host` header to the address of the upstream and pass the original host in a header like `X-Forwarded-Host`.
+// Only use this option when the Verifier is exclusively reachable through proxies that overwrite these headers,
+// as otherwise a client can choose the host that is verified.
+func WithHostFromHeaders(headers ...string) Option {
+	return func(s *SigV4) {
+		s.hostHeaders = append(s.hostHeaders, headers...)
+	}
+}
+
+// Lowercase the host and strip a trailing dot (E.g. `Example.COM.` becomes `example.com`) before canonicalization,
+// since proxies and clients do not agree on the casing of a hostname.
+func WithHostNormalization(normalize bool) Option {
+	return func(s *SigV4) {
+		s.normalizeHost = normalize
+	}
+}
+
+// Remove the `.` and `..` segments and the duplicate slashes of the path (E.g. `/a/../b//c` becomes `/b/c`) before encoding the `CanonicalURI`,
+// as AWS services other than S3 expect, and as clients and proxies commonly normalize paths. See `sigv4core.NormalizePath`.
+//
+// By default, the path is canonicalized as sent, as S3 expects, since object keys may contain such segments.
+// Signatures of both modes are not interchangeable for such paths: configure the Signer and the Verifier alike.
+func WithPathNormalization(normalize bool) Option {
+	return func(s *SigV4) {
+		s.normalizePath = normalize
+	}
+}
+
+// Delegate the computation of the payload hash to a `PayloadHasher`, skipping the internal buffering of the request body.
+func WithPayloadHasher(hasher PayloadHasher) Option {
+	return func(s *SigV4) {
+		s.payloadHasher = hasher
+	}
+}
+
+// Set the casing of the names of the headers set by the Signer (E.g. `X-Sym-Date` vs `x-sym-date`). Defaults to `CanonicalHeaderCasing`.
+func WithHeaderCasing(casing HeaderCasing) Option {
+	return func(s *SigV4) {
+		s.headerCasing = casing
+	}
+}
+
+// Set whether the payload hash is added to the request in the signed `x-[abbr]-content-sha256` header.
+// Overrides the `hashPayload` argument of `NewSigV4Signer`.
+//
+// A Verifier with payload hashing rejects requests without the signed header. Any Verifier compares a signed header with the hash of the payload.
+func WithPayloadHashing(hashPayload bool) Option {
+	return func(s *SigV4) {
+		s.hashPayload = hashPayload
+	}
+}
+
+// Set the region signed with when no region is configured in the `SigV4EnvConfig`, the environment or the `GlobalDir`.
+//
+// If the `ACCESS_KEY_ID` and `SECRET_ACCESS_KEY` are configured without a region, the `GlobalDir` is not read for the region alone.
+func WithDefaultRegion(region string) Option {
+	return func(s *SigV4) {
+		s.defaultRegion = region
+	}
+}
+
+// # Signed request ID
+//
+// The Signer propagates the request ID found in `header`, or generates a random one if absent, and signs it along with the other headers,
+// giving tamper-evident request correlation across services. If `header` is empty, `X-[Abbr]-Request-Id` is used.
+//
+// The Verifier rejects requests where the header is not signed, and returns the request ID in the `auth.Identity` (See `SigV4.Authenticate`).
+// The header must not be skipped (See `WithSkipHeaders`).
+func WithRequestID(header string) Option {
+	return func(s *SigV4) {
+		s.requestID = true
+		s.requestIDHeader = header
+	}
+}
+
+// Carry the signing date in the `header` (E.g. `X-Gateway-Timestamp`) instead of `X-[Abbr]-Date`, E.g. for gateways requiring a proprietary header name.
+// The header is signed like the default Date Header. An empty `header` restores the default.
+//
+// The `header` must only contain letters, digits and '-', and must not be `Authorization`, `Host` or `Content-Length`.
+// The constructors return an `*ErrInvalidHeaderName` otherwise. Configure the Signer and the Verifier with the same header.
+// Presigned URLs still carry the date in the `X-[Abbr]-Date` query parameter.
+func WithDateHeader(header string) Option {
+	return func(s *SigV4) {
+		s.dateHeaderName = header
+	}
+}
+
+// Cache the secrets retrieved from the `secretRetrievalURL` for `ttl`, instead of retrieving the secret on every request.
+// Cached secrets can be purged before expiry with `SigV4.InvalidateSecret`, `SigV4.InvalidateAll` or the `SigV4.InvalidationHandler`.
+// A non-positive `ttl` disables the cache.
+func WithSecretCache(ttl time.Duration) Option {
+	return func(s *SigV4) {
+		s.secrets = nil
+		if ttl > 0 {
+			s.secrets = newSecretCache(ttl)
+		}
+	}
+}
+
+// Record the usage of every access key verified by the Verifier in `stats`. See `UsageStats`.
+// Requests explained with `SigV4.Explain` are not recorded.
+func WithUsageStats(stats *UsageStats) Option {
+	return func(s *SigV4) {
+		s.usage = stats
+	}
+}
+
+// Set the backoff strategy of the retries of the secret retrieval. Defaults to `DEFAULT_SECRET_RETRIEVAL_BACKOFF`.
+// Retries observe the context of the request being verified. See the `utils/backoff` package.
+func WithSecretRetrievalBackoff(strategy backoff.Strategy) Option {
+	return func(s *SigV4) {
+		s.secretRetrievalBackoff = strategy
+	}
+}
@@ -0,0 +1,227 @@
+package sigv4
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/jayantasamaddar/go-httpsigner/auth"
+	"github.com/jayantasamaddar/go-httpsigner/sigv4core"
+	"github.com/jayantasamaddar/go-httpsigner/utils"
+)
+
+// Errors
+const (
+	ERROR_CONTENT_SHA256_NOT_SIGNED = "payload hash header is not signed"
+	ERROR_CONTENT_SHA256_MISMATCH   = "payload hash header does not match the payload"
+	ERROR_INVALID_PAYLOAD_HASH      = "payload hash must be a hex-encoded SHA-256 hash"
+)
+
+// The hex-encoded SHA-256 hash of an empty payload, i.e. `Hex(SHA256Hash(""))`
+const EMPTY_PAYLOAD_HASH = sigv4core.EMPTY_PAYLOAD_HASH
+
+// A PayloadHasher supplies the hex-encoded SHA-256 hash of the request payload used as the `HashedPayload` of the `CanonicalRequest`.
+//
+// Implementations may take the hash from elsewhere (E.g. an object store ETag pipeline or a prior digest middleware),
+// or wrap `req.Body` with their own hashing reader. The request body is not read or buffered by the Signer when a `PayloadHasher` is configured,
+// hence `req.ContentLength` must be set to the length of the payload.
+type PayloadHasher interface {
+	PayloadHash(req *http.Request) (string, error)
+}
+
+// The PayloadHasherFunc type is an adapter to allow the use of ordinary functions as a `PayloadHasher`.
+type PayloadHasherFunc func(req *http.Request) (string, error)
+
+// `PayloadHash` calls f(req)
+func (f PayloadHasherFunc) PayloadHash(req *http.Request) (string, error) {
+	return f(req)
+}
+
+// `payloadHash` returns the hex-encoded SHA-256 hash of the request payload and the length of the payload.
+// Uses the configured `PayloadHasher` if any, else seeks the request body back after hashing it if possible (See `seekablePayloadHash`), else buffers it.
+func (s *SigV4) payloadHash(ctx context.Context, req *http.Request) (string, int64, error) {
+	if s.isBodilessMethod(req.Method) {
+		return bodilessPayloadHash(ctx, req)
+	}
+	if s.payloadHasher != nil {
+		hash, err := s.payloadHasher.PayloadHash(req)
+		return hash, req.ContentLength, err
+	}
+	if hash, length, ok, err := seekablePayloadHash(ctx, req); ok || err != nil {
+		return hash, length, err
+	}
+	return bufferedPayloadHash(ctx, req)
+}
+
+// `seekablePayloadHash` hashes a request body implementing `io.Seeker` (E.g. an `*os.File`) from its current offset, then seeks it back,
+// instead of buffering it. Reports whether the body is seekable.
+func seekablePayloadHash(ctx context.Context, req *http.Request) (hash string, length int64, ok bool, err error) {
+	seeker, isSeeker := req.Body.(io.Seeker)
+	if !isSeeker || req.Body == http.NoBody {
+		return "", 0, false, nil
+	}
+	offset, err := seeker.Seek(0, io.SeekCurrent)
+	if err != nil {
+		// Not seekable after all (E.g. a pipe behind an `*os.File`)
+		return "", 0, false, nil
+	}
+	if hash, length, err = hashReader(ctx, req.Body); err != nil {
+		return "", 0, true, err
+	}
+	if _, err := seeker.Seek(offset, io.SeekStart); err != nil {
+		return "", 0, true, err
+	}
+	return hash, length, true, nil
+}
+
+// `getBodyPayloadHash` hashes a copy of the request body returned by `req.GetBody` (E.g. set by `http.NewRequest` for a `*bytes.Reader`),
+// leaving `req.Body` unread. Reports whether `req.GetBody` is set.
+//
+// Only used by the Signer: `req.GetBody` must return the payload of `req.Body`, which the Verifier cannot trust.
+func getBodyPayloadHash(ctx context.Context, req *http.Request) (hash string, length int64, ok bool, err error) {
+	if req.GetBody == nil || req.Body == nil || req.Body == http.NoBody {
+		return "", 0, false, nil
+	}
+	body, err := req.GetBody()
+	if err != nil {
+		return "", 0, true, err
+	}
+	defer body.Close()
+	hash, length, err = hashReader(ctx, body)
+	return hash, length, true, err
+}
+
+// Returns the hex-encoded SHA-256 hash of the data read from the reader until the context is done, and its length
+func hashReader(ctx context.Context, r io.Reader) (string, int64, error) {
+	h := sha256.New()
+	length, err := io.Copy(h, utils.ContextReader(ctx, r))
+	if err != nil {
+		return "", 0, err
+	}
+	return hex.EncodeToString(h.Sum(nil)), length, nil
+}
+
+// # Pre-computed payload hash
+//
+// ----------------------------------------
+//
+// The hex-encoded SHA-256 hash of a payload already known to the caller (E.g. computed while uploading a multi-GB file to a staging area)
+// may be supplied to the Signer, that then does not read or buffer the request body, in order of precedence:
+//   - Calling `SignWithPayloadHash`.
+//   - Adding the hash to the context of the request with `ContextWithPayloadHash`.
+//   - Setting the hash as the `X-[Abbr]-Content-Sha256` header of the request before signing.
+//
+// `req.ContentLength` must be set to the length of the payload, like with a `PayloadHasher`. The supplied hash takes precedence over the `PayloadHasher` if any.
+// The Verifier always hashes the payload it receives, hence a wrong hash fails verification (See `auth.ErrSignatureMismatch`).
+
+type payloadHashContextKey struct{}
+
+// Returns a copy of the context carrying the hex-encoded SHA-256 hash of the payload of the request it is used with. See `SignWithPayloadHash`.
+//
+// E.g. req = req.WithContext(sigv4.ContextWithPayloadHash(req.Context(), hash))
+func ContextWithPayloadHash(ctx context.Context, payloadHash string) context.Context {
+	return context.WithValue(ctx, payloadHashContextKey{}, payloadHash)
+}
+
+// Returns the payload hash carried by the context, if any. See `ContextWithPayloadHash`.
+func PayloadHashFromContext(ctx context.Context) (string, bool) {
+	payloadHash, ok := ctx.Value(payloadHashContextKey{}).(string)
+	return payloadHash, ok
+}
+
+// Signs the request like `SignHTTPRequest`, with the hex-encoded SHA-256 hash of its payload supplied instead of computed from the request body.
+// The request body is not read. Fails with `ERROR_INVALID_PAYLOAD_HASH` if the hash is not a lowercase hex-encoded SHA-256 hash.
+func (s *SigV4) SignWithPayloadHash(req *http.Request, payloadHash string) error {
+	if !isPayloadHash(payloadHash) {
+		return fmt.Errorf("%s: %q", ERROR_INVALID_PAYLOAD_HASH, payloadHash)
+	}
+	return s.signHTTPRequestAt(ContextWithPayloadHash(req.Context(), payloadHash), req, s.now(), nil)
+}
+
+// `signingPayloadHash` returns the payload hash of a request being signed, and the length of the payload.
+// Uses the payload hash supplied by the caller if any (See `SignWithPayloadHash`), else hashes a copy of the body if `req.GetBody` is set
+// (See `getBodyPayloadHash`), else falls back to `payloadHash`. The Verifier never uses a supplied hash nor `req.GetBody`.
+func (s *SigV4) signingPayloadHash(ctx context.Context, req *http.Request) (string, int64, error) {
+	if s.isBodilessMethod(req.Method) {
+		return bodilessPayloadHash(ctx, req)
+	}
+	if hash, ok := PayloadHashFromContext(ctx); ok {
+		if !isPayloadHash(hash) {
+			return "", 0, fmt.Errorf("%s: %q", ERROR_INVALID_PAYLOAD_HASH, hash)
+		}
+		return hash, req.ContentLength, nil
+	}
+	// Sentinels like `UNSIGNED-PAYLOAD` or streaming payloads are not supplied hashes
+	if hash := getHeader(req.Header, s.contentSHA256Header()); isPayloadHash(hash) {
+		return hash, req.ContentLength, nil
+	}
+	if _, isSeeker := req.Body.(io.Seeker); s.payloadHasher == nil && !isSeeker {
+		if hash, length, ok, err := getBodyPayloadHash(ctx, req); ok || err != nil {
+			return hash, length, err
+		}
+	}
+	return s.payloadHash(ctx, req)
+}
+
+// Checks if the value is a lowercase hex-encoded SHA-256 hash, as in the `CanonicalRequest`
+func isPayloadHash(value string) bool {
+	b, err := hex.DecodeString(value)
+	return err == nil && len(b) == 32 && value == strings.ToLower(value)
+}
+
+// `bufferedPayloadHash` reads the request body into memory to hash it, and resets the request body to the captured payload.
+// Requests without a body (E.g. GET, HEAD and DELETE requests) take a fast path returning `EMPTY_PAYLOAD_HASH`.
+//
+// `req.GetBody` is set to replay the captured payload if not set, so that `http.Client` can replay the body on redirects and retries,
+// and the request can be signed again (See `ResignHTTPRequest`).
+func bufferedPayloadHash(ctx context.Context, req *http.Request) (string, int64, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return EMPTY_PAYLOAD_HASH, 0, nil
+	}
+
+	// Read the request body
+	payload, err := io.ReadAll(utils.ContextReader(ctx, req.Body))
+	if err != nil {
+		return "", 0, err
+	}
+
+	// Reset the request body to the captured payload
+	req.Body = io.NopCloser(bytes.NewReader(payload))
+	if req.GetBody == nil {
+		req.GetBody = func() (io.ReadCloser, error) {
+			return io.NopCloser(bytes.NewReader(payload)), nil
+		}
+	}
+
+	return sigv4core.HashPayload(payload), int64(len(payload)), nil
+}
+
+// Generate the Payload Hash Header name. E.g. `X-Amz-Content-Sha256`
+func (s *SigV4) contentSHA256Header() string {
+	return fmt.Sprintf("X-%s-Content-Sha256", s.abbr)
+}
+
+// `checkContentSHA256` compares the signed payload hash header, if any, with the hash of the payload computed by the Verifier.
+// The header is mandatory if `hashPayload` is set (See `WithPayloadHashing`).
+func (s *SigV4) checkContentSHA256(req *http.Request, signedHeaders []string, payloadHash string) error {
+	name := s.contentSHA256Header()
+	value := getHeader(req.Header, name)
+	signed := slices.Contains(signedHeaders, strings.ToLower(name))
+	if !signed {
+		if s.hashPayload {
+			return fmt.Errorf("%s: %s", ERROR_CONTENT_SHA256_NOT_SIGNED, name)
+		}
+		return nil
+	}
+	if subtle.ConstantTimeCompare([]byte(value), []byte(payloadHash)) != 1 {
+		return fmt.Errorf("%w: %s", auth.ErrSignatureMismatch, ERROR_CONTENT_SHA256_MISMATCH)
+	}
+	return nil
+}
@@ -0,0 +1,267 @@
+package sigv4
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/jayantasamaddar/go-httpsigner/auth"
+	"github.com/jayantasamaddar/go-httpsigner/sigv4core"
+	"github.com/jayantasamaddar/go-httpsigner/utils"
+)
+
+// A body that records whether it was read
+type trackingBody struct {
+	io.Reader
+	read bool
+}
+
+func (b *trackingBody) Read(p []byte) (int, error) {
+	b.read = true
+	return b.Reader.Read(p)
+}
+
+func (b *trackingBody) Close() error { return nil }
+
+// Test that a `PayloadHasher` supplies the payload hash without the Signer reading the body
+func Test_PayloadHasher(t *testing.T) {
+	server := newSecretRetrievalServer(t, testEnvConfig.SECRET_ACCESS_KEY)
+	payload := []byte(`{"first_name":"Bruce","last_name":"Wayne"}`)
+
+	signer, err := NewSigV4Signer("SYM", "sym", "certificatemanager", testEnvConfig, false,
+		WithPayloadHasher(PayloadHasherFunc(func(req *http.Request) (string, error) {
+			return utils.Hash(payload), nil
+		})),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	verifier, err := NewSigV4Verifier("SYM", "sym", "certificatemanager", server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	body := &trackingBody{Reader: bytes.NewReader(payload)}
+	req, _ := http.NewRequest(http.MethodPut, "http://s3.amazonaws.com/examplebucket/object", body)
+	req.ContentLength = int64(len(payload))
+
+	if err := signer.SignHTTPRequest(req); err != nil {
+		t.Fatal(err)
+	}
+	if body.read {
+		t.Error("Expected request body not to be read by the Signer")
+	}
+	if err := verifier.VerifySignature(req); err != nil {
+		t.Error(err)
+	}
+}
+
+// Test that a payload hash supplied by the caller is signed without the Signer reading the body, and that the Verifier hashes the payload it receives
+func Test_PrecomputedPayloadHash(t *testing.T) {
+	server := newSecretRetrievalServer(t, testEnvConfig.SECRET_ACCESS_KEY)
+	payload := []byte(`{"first_name":"Bruce","last_name":"Wayne"}`)
+	hash := utils.Hash(payload)
+
+	signer, err := NewSigV4Signer("SYM", "sym", "certificatemanager", testEnvConfig, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	verifier, err := NewSigV4Verifier("SYM", "sym", "certificatemanager", server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := map[string]func(req *http.Request, hash string) error{
+		"SignWithPayloadHash": signer.(*SigV4).SignWithPayloadHash,
+		"Context": func(req *http.Request, hash string) error {
+			return signer.SignHTTPRequest(req.WithContext(ContextWithPayloadHash(req.Context(), hash)))
+		},
+		"Header": func(req *http.Request, hash string) error {
+			req.Header.Set("X-Sym-Content-Sha256", hash)
+			return signer.SignHTTPRequest(req)
+		},
+	}
+	for name, sign := range tests {
+		newRequest := func() (*http.Request, *trackingBody) {
+			body := &trackingBody{Reader: bytes.NewReader(payload)}
+			req, _ := http.NewRequest(http.MethodPut, "http://s3.amazonaws.com/examplebucket/object", body)
+			req.ContentLength = int64(len(payload))
+			return req, body
+		}
+
+		req, body := newRequest()
+		if err := sign(req, hash); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if body.read {
+			t.Errorf("%s: expected request body not to be read by the Signer", name)
+		}
+		if err := verifier.VerifySignature(req); err != nil {
+			t.Errorf("%s: %v", name, err)
+		}
+
+		// A wrong hash is signed as supplied, and fails verification
+		req, _ = newRequest()
+		if err := sign(req, EMPTY_PAYLOAD_HASH); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if err := verifier.VerifySignature(req); !errors.Is(err, auth.ErrSignatureMismatch) {
+			t.Errorf("%s: expected auth.ErrSignatureMismatch, got: %v", name, err)
+		}
+	}
+
+	req, _ := http.NewRequest(http.MethodPut, "http://s3.amazonaws.com/examplebucket/object", bytes.NewReader(payload))
+	for _, invalid := range []string{"", "UNSIGNED-PAYLOAD", strings.ToUpper(hash), hash[:32]} {
+		if err := signer.(*SigV4).SignWithPayloadHash(req, invalid); err == nil || !strings.Contains(err.Error(), ERROR_INVALID_PAYLOAD_HASH) {
+			t.Errorf("%q: expected error %q, got: %v", invalid, ERROR_INVALID_PAYLOAD_HASH, err)
+		}
+	}
+}
+
+// Test that seekable bodies and bodies with `req.GetBody` set are hashed without being buffered
+func Test_RereadablePayloadHash(t *testing.T) {
+	server := newSecretRetrievalServer(t, testEnvConfig.SECRET_ACCESS_KEY)
+	payload := []byte(`{"first_name":"Bruce","last_name":"Wayne"}`)
+	signer, err := NewSigV4Signer("SYM", "sym", "certificatemanager", testEnvConfig, false, WithPayloadHashing(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	verifier, err := NewSigV4Verifier("SYM", "sym", "certificatemanager", server.URL, WithPayloadHashing(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A file, hashed from its current offset and seeked back to it
+	file, err := os.CreateTemp(t.TempDir(), "payload")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	if _, err := file.Write(append([]byte("skipped"), payload...)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := file.Seek(int64(len("skipped")), io.SeekStart); err != nil {
+		t.Fatal(err)
+	}
+	req, _ := http.NewRequest(http.MethodPut, "http://s3.amazonaws.com/examplebucket/object", file)
+	req.ContentLength = int64(len(payload))
+	if err := signer.SignHTTPRequest(req); err != nil {
+		t.Fatal(err)
+	}
+	if req.Body != io.ReadCloser(file) {
+		t.Error("Expected the file not to be replaced by a buffer")
+	}
+	if hash := req.Header.Get("X-Sym-Content-Sha256"); hash != utils.Hash(payload) {
+		t.Errorf("Expected the hash of the payload from the offset, got: %q", hash)
+	}
+	if err := verifier.VerifySignature(req); err != nil {
+		t.Error(err)
+	}
+
+	// A body with `req.GetBody` set is not read by the Signer
+	body := &trackingBody{Reader: bytes.NewReader(payload)}
+	req, _ = http.NewRequest(http.MethodPut, "http://s3.amazonaws.com/examplebucket/object", body)
+	req.ContentLength = int64(len(payload))
+	req.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(payload)), nil
+	}
+	if err := signer.SignHTTPRequest(req); err != nil {
+		t.Fatal(err)
+	}
+	if body.read || req.Body != io.ReadCloser(body) {
+		t.Error("Expected request body not to be read by the Signer")
+	}
+	if err := verifier.VerifySignature(req); err != nil {
+		t.Error(err)
+	}
+
+	// The Verifier hashes the body, not the copy returned by `req.GetBody`
+	req.Body = io.NopCloser(bytes.NewBufferString(`{"first_name":"Joker"}`))
+	if err := verifier.VerifySignature(req); !errors.Is(err, auth.ErrSignatureMismatch) {
+		t.Errorf("Expected auth.ErrSignatureMismatch, got: %v", err)
+	}
+}
+
+// Test that the constant hash of an empty payload is correct, and used for requests without a body
+func Test_BodylessPayloadHash(t *testing.T) {
+	if EMPTY_PAYLOAD_HASH != utils.Hash([]byte{}) {
+		t.Fatal("EMPTY_PAYLOAD_HASH is not the SHA-256 hash of an empty string")
+	}
+
+	for _, body := range []io.Reader{nil, http.NoBody, bytes.NewReader(nil)} {
+		req, _ := http.NewRequest(http.MethodGet, "http://s3.amazonaws.com/examplebucket", body)
+		hash, length, err := bufferedPayloadHash(req.Context(), req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if hash != EMPTY_PAYLOAD_HASH || length != 0 {
+			t.Errorf("Expected hash: %q and length: 0, got: %q and %d", EMPTY_PAYLOAD_HASH, hash, length)
+		}
+	}
+}
+
+// Test that the payload hash header is signed, required by a Verifier with payload hashing, and compared with the payload
+func Test_PayloadHashing(t *testing.T) {
+	server := newSecretRetrievalServer(t, testEnvConfig.SECRET_ACCESS_KEY)
+	payload := `{"first_name":"Bruce","last_name":"Wayne"}`
+
+	signer, err := NewSigV4Signer("SYM", "sym", "certificatemanager", testEnvConfig, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	verifier, err := NewSigV4Verifier("SYM", "sym", "certificatemanager", server.URL, WithPayloadHashing(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req, _ := http.NewRequest(http.MethodPost, "https://example.com/", bytes.NewBufferString(payload))
+	if err := signer.SignHTTPRequest(req); err != nil {
+		t.Fatal(err)
+	}
+	if hash := req.Header.Get("X-Sym-Content-Sha256"); hash != sigv4core.HashPayload([]byte(payload)) {
+		t.Errorf("Expected the payload hash header, got: %q", hash)
+	}
+	if !strings.Contains(req.Header.Get("Authorization"), "x-sym-content-sha256") {
+		t.Errorf("Expected the payload hash header to be signed, got: %s", req.Header.Get("Authorization"))
+	}
+	if err := verifier.VerifySignature(req); err != nil {
+		t.Fatal(err)
+	}
+	if b, _ := io.ReadAll(req.Body); string(b) != payload {
+		t.Errorf("Expected the body to be readable after verification, got: %s", b)
+	}
+
+	// A tampered payload
+	req.Body = io.NopCloser(bytes.NewBufferString(`{"first_name":"Joker"}`))
+	if err := verifier.VerifySignature(req); !errors.Is(err, auth.ErrSignatureMismatch) || !strings.Contains(err.Error(), ERROR_CONTENT_SHA256_MISMATCH) {
+		t.Errorf("Expected: %s, got: %v", ERROR_CONTENT_SHA256_MISMATCH, err)
+	}
+
+	// A request without the payload hash header
+	unhashed, err := NewSigV4Signer("SYM", "sym", "certificatemanager", testEnvConfig, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req, _ = http.NewRequest(http.MethodPost, "https://example.com/", bytes.NewBufferString(payload))
+	if err := unhashed.SignHTTPRequest(req); err != nil {
+		t.Fatal(err)
+	}
+	if err := verifier.VerifySignature(req); err == nil || !strings.Contains(err.Error(), ERROR_CONTENT_SHA256_NOT_SIGNED) {
+		t.Errorf("Expected: %s, got: %v", ERROR_CONTENT_SHA256_NOT_SIGNED, err)
+	}
+}
+
+func Benchmark_CanonicalRequest_Bodyless(b *testing.B) {
+	s := &SigV4{}
+	req, _ := http.NewRequest(http.MethodGet, "http://s3.amazonaws.com/examplebucket?prefix=somePrefix", nil)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, _, err := s.canonicalRequest(req); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
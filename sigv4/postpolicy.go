@@ -0,0 +1,353 @@
+package sigv4
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/jayantasamaddar/go-httpsigner/auth"
+)
+
+// Errors
+const (
+	ERROR_INCORRECT_FORMAT_POLICY  = "incorrectly formatted POST policy"
+	ERROR_POLICY_EXPIRED           = "POST policy is expired"
+	ERROR_POLICY_CONDITION_FAILED  = "form field does not satisfy the POST policy"
+	ERROR_POLICY_FIELD_NOT_ALLOWED = "form field is not covered by a condition of the POST policy"
+	ERROR_AGENT_POST_POLICY        = "POST policies are not supported with a signing agent"
+)
+
+// Operators of the conditions of a POST policy
+const (
+	POLICY_EQ                   = "eq"                   // The form field equals the value
+	POLICY_STARTS_WITH          = "starts-with"          // The form field starts with the value
+	POLICY_CONTENT_LENGTH_RANGE = "content-length-range" // The size of the uploaded file is within the range
+)
+
+// The maximum size of the form fields of a POST policy upload kept in memory. The uploaded file is stored on disk beyond this size.
+const POST_POLICY_MAX_MEMORY = 10 << 20
+
+// The default maximum size of the body of a POST policy upload. See `WithPostPolicyMaxSize`.
+const POST_POLICY_MAX_SIZE = 100 << 20
+
+// Reject POST policy uploads whose body is larger than `size` bytes while parsing the form, before the signature is verified,
+// so that unauthenticated uploads are not spooled to disk in full. Defaults to `POST_POLICY_MAX_SIZE`.
+func WithPostPolicyMaxSize(size int64) Option {
+	return func(s *SigV4) {
+		s.postPolicyMaxSize = size
+	}
+}
+
+// # Presigned POST policies (browser uploads)
+//
+// A PostPolicy is an S3-style policy document, describing the HTML form uploads a browser may make directly to the server.
+// The policy is signed by the Signer (See `PresignPostPolicy`), and the browser submits the policy and its signature as form fields
+// alongside the file. The Verifier checks the signature and that every form field satisfies the conditions (See `AuthenticatePostPolicy`).
+type PostPolicy struct {
+	Expiration time.Time             `json:"expiration"`
+	Conditions []PostPolicyCondition `json:"conditions"`
+}
+
+// A condition of a `PostPolicy`. Form field names are matched case-insensitively.
+type PostPolicyCondition struct {
+	Operator string // One of the `POLICY_*` constants
+	Field    string // The name of the form field, without the leading '$'. Empty for `POLICY_CONTENT_LENGTH_RANGE`.
+	Value    string
+	Min, Max int64 // The range of `POLICY_CONTENT_LENGTH_RANGE`, in bytes
+}
+
+// The form field must equal the `value`
+func PolicyEquals(field, value string) PostPolicyCondition {
+	return PostPolicyCondition{Operator: POLICY_EQ, Field: field, Value: value}
+}
+
+// The form field must start with the `prefix`. An empty `prefix` allows any value.
+func PolicyStartsWith(field, prefix string) PostPolicyCondition {
+	return PostPolicyCondition{Operator: POLICY_STARTS_WITH, Field: field, Value: prefix}
+}
+
+// The size of the uploaded file must be between `min` and `max` bytes
+func PolicyContentLengthRange(min, max int64) PostPolicyCondition {
+	return PostPolicyCondition{Operator: POLICY_CONTENT_LENGTH_RANGE, Min: min, Max: max}
+}
+
+// `json.Marshaler` implementation. Conditions are encoded as arrays, E.g. `["starts-with", "$key", "uploads/"]`
+func (c PostPolicyCondition) MarshalJSON() ([]byte, error) {
+	if c.Operator == POLICY_CONTENT_LENGTH_RANGE {
+		return json.Marshal([]any{c.Operator, c.Min, c.Max})
+	}
+	return json.Marshal([]string{c.Operator, "$" + c.Field, c.Value})
+}
+
+// `json.Unmarshaler` implementation. Accepts the array form, and the object form of `POLICY_EQ` conditions, E.g. `{"acl": "private"}`
+func (c *PostPolicyCondition) UnmarshalJSON(b []byte) error {
+	var object map[string]string
+	if err := json.Unmarshal(b, &object); err == nil {
+		if len(object) != 1 {
+			return fmt.Errorf("%s: %s", ERROR_INCORRECT_FORMAT_POLICY, b)
+		}
+		for field, value := range object {
+			*c = PolicyEquals(field, value)
+		}
+		return nil
+	}
+
+	var array []json.RawMessage
+	if err := json.Unmarshal(b, &array); err != nil || len(array) != 3 {
+		return fmt.Errorf("%s: %s", ERROR_INCORRECT_FORMAT_POLICY, b)
+	}
+	if err := json.Unmarshal(array[0], &c.Operator); err != nil {
+		return fmt.Errorf("%s: %s", ERROR_INCORRECT_FORMAT_POLICY, b)
+	}
+	switch c.Operator {
+	case POLICY_CONTENT_LENGTH_RANGE:
+		if json.Unmarshal(array[1], &c.Min) != nil || json.Unmarshal(array[2], &c.Max) != nil {
+			return fmt.Errorf("%s: %s", ERROR_INCORRECT_FORMAT_POLICY, b)
+		}
+	case POLICY_EQ, POLICY_STARTS_WITH:
+		var field string
+		if json.Unmarshal(array[1], &field) != nil || json.Unmarshal(array[2], &c.Value) != nil || !strings.HasPrefix(field, "$") {
+			return fmt.Errorf("%s: %s", ERROR_INCORRECT_FORMAT_POLICY, b)
+		}
+		c.Field = field[1:]
+	default:
+		return fmt.Errorf("%s: unknown operator %q", ERROR_INCORRECT_FORMAT_POLICY, c.Operator)
+	}
+	return nil
+}
+
+// `matches` checks if the form field `value` satisfies the condition
+func (c *PostPolicyCondition) matches(value string) bool {
+	switch c.Operator {
+	case POLICY_EQ:
+		return value == c.Value
+	case POLICY_STARTS_WITH:
+		return strings.HasPrefix(value, c.Value)
+	}
+	return false
+}
+
+// Returns the name of a form field of POST policy uploads. E.g. `x-[abbr]-signature`
+func (s *SigV4) formFieldName(name string) string {
+	return strings.ToLower(s.queryParamName(name))
+}
+
+// Signs the `policy`, and returns the form fields to be submitted by the browser alongside the file:
+// `policy` and `x-[abbr]-algorithm`, `x-[abbr]-credential`, `x-[abbr]-date` and `x-[abbr]-signature`, the `x-[abbr]-security-token`
+// of temporary credentials, and the fields of the `POLICY_EQ` conditions of the policy (E.g. `acl`), whose values are known in advance.
+// Conditions on the algorithm, the credential, the date and the security token are added to the signed policy.
+//
+// The `stringToSign` of a POST policy is the base64-encoded policy document.
+func (s *SigV4) PresignPostPolicy(policy *PostPolicy) (map[string]string, error) {
+	if s.agent != nil {
+		// The signing agent only signs the `stringToSign` of requests
+		return nil, fmt.Errorf(ERROR_AGENT_POST_POLICY)
+	}
+	signingTime := s.now()
+	algorithm := s.signingAlgorithm()
+	fields := map[string]string{
+		s.formFieldName("Algorithm"):  algorithm,
+		s.formFieldName("Credential"): fmt.Sprintf("%s/%s", s.env.ACCESS_KEY_ID, s.getCredentialScope(signingTime, s.env.REGION, s.service)),
+		s.formFieldName("Date"):       s.formatDate(signingTime),
+	}
+	names := []string{"Algorithm", "Credential", "Date"}
+	if token := s.sessionToken(); token != "" {
+		fields[s.formFieldName("Security-Token")] = token
+		names = append(names, "Security-Token")
+	}
+
+	signed := &PostPolicy{Expiration: policy.Expiration.UTC(), Conditions: append([]PostPolicyCondition(nil), policy.Conditions...)}
+	for _, name := range names {
+		signed.Conditions = append(signed.Conditions, PolicyEquals(s.formFieldName(name), fields[s.formFieldName(name)]))
+	}
+	for _, condition := range policy.Conditions {
+		if condition.Operator == POLICY_EQ && condition.Field != "" {
+			fields[condition.Field] = condition.Value
+		}
+	}
+	b, err := json.Marshal(signed)
+	if err != nil {
+		return nil, err
+	}
+	fields["policy"] = base64.StdEncoding.EncodeToString(b)
+
+	signature, err := s.sign(context.Background(), algorithm, signingTime, s.env.REGION, fields["policy"])
+	if err != nil {
+		return nil, err
+	}
+	fields[s.formFieldName("Signature")] = signature
+	return fields, nil
+}
+
+// The HTML form of a browser upload: the form is posted to the `URL` with the `Fields` as hidden inputs, followed by the `file` input.
+// Marshals to JSON, to be handed to web frontends. See `PresignPostForm`.
+type PostPolicyForm struct {
+	URL    string            `json:"url"`
+	Fields map[string]string `json:"fields"`
+}
+
+// Signs the `policy` like `PresignPostPolicy`, and returns the form uploading to `url`, E.g. the URL of a bucket (See `PathStyleURL`).
+// The frontend sets the remaining fields of the policy (E.g. the `key`, for a `POLICY_STARTS_WITH` condition) and the file, and posts the form.
+func (s *SigV4) PresignPostForm(url string, policy *PostPolicy) (*PostPolicyForm, error) {
+	fields, err := s.PresignPostPolicy(policy)
+	if err != nil {
+		return nil, err
+	}
+	return &PostPolicyForm{URL: url, Fields: fields}, nil
+}
+
+// # Verify a POST policy upload
+//
+// Parses the multipart form of the request, verifies the signature of the policy and that the policy is not expired, and checks that:
+//   - Every condition of the policy is satisfied by the form fields, and by the size of the file in the `file` field.
+//   - Every form field is covered by a condition, except `policy`, `x-[abbr]-signature`, `file` and the fields prefixed with `x-ignore-`.
+//   - The form has at most one file, in the `file` field.
+//
+// The body is bounded by `WithPostPolicyMaxSize` while parsing the form.
+//
+// Returns the Identity of the client that signed the policy. The form is available in `req.MultipartForm` after verification.
+func (s *SigV4) AuthenticatePostPolicy(req *http.Request) (*auth.Identity, error) {
+	report := new(VerificationReport)
+	identity, err := s.verifyPostPolicy(req, report)
+	if s.usage != nil && report.KeyID != "" {
+		s.usage.record(report.KeyID, report.Verified, s.now())
+	}
+	return identity, err
+}
+
+// `verifyPostPolicy` runs the verification pipeline of POST policy uploads, recording the inputs of each stage in the `report` like `verify`.
+func (s *SigV4) verifyPostPolicy(req *http.Request, report *VerificationReport) (*auth.Identity, error) {
+	fail := func(stage string, err error) (*auth.Identity, error) {
+		report.FailedStage = stage
+		return nil, err
+	}
+
+	// Extract the form fields
+	maxSize := s.postPolicyMaxSize
+	if maxSize <= 0 {
+		maxSize = POST_POLICY_MAX_SIZE
+	}
+	if req.Body != nil {
+		req.Body = http.MaxBytesReader(nil, req.Body, maxSize)
+	}
+	if err := req.ParseMultipartForm(POST_POLICY_MAX_MEMORY); err != nil {
+		return fail(STAGE_PARSE, fmt.Errorf("%s: %w", ERROR_INCORRECT_FORMAT_POLICY, err))
+	}
+	for name, files := range req.MultipartForm.File {
+		if !strings.EqualFold(name, "file") {
+			return fail(STAGE_PARSE, fmt.Errorf("%s: form field %q is a file", ERROR_INCORRECT_FORMAT_POLICY, name))
+		}
+		if len(files) != 1 {
+			return fail(STAGE_PARSE, fmt.Errorf("%s: form field %q is repeated", ERROR_INCORRECT_FORMAT_POLICY, name))
+		}
+	}
+	fields := make(map[string]string, len(req.MultipartForm.Value))
+	for name, values := range req.MultipartForm.Value {
+		if len(values) != 1 {
+			return fail(STAGE_PARSE, fmt.Errorf("%s: form field %q is repeated", ERROR_INCORRECT_FORMAT_POLICY, name))
+		}
+		fields[strings.ToLower(name)] = values[0]
+	}
+	b, err := base64.StdEncoding.DecodeString(fields["policy"])
+	if err != nil {
+		return fail(STAGE_PARSE, fmt.Errorf("%s: %w", ERROR_INCORRECT_FORMAT_POLICY, err))
+	}
+	policy := new(PostPolicy)
+	decoder := json.NewDecoder(bytes.NewReader(b))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(policy); err != nil {
+		return fail(STAGE_PARSE, fmt.Errorf("%s: %w", ERROR_INCORRECT_FORMAT_POLICY, err))
+	}
+	credential, err := parseCredential(fields[s.formFieldName("Credential")])
+	if err != nil {
+		return fail(STAGE_PARSE, fmt.Errorf("%s: %w", ERROR_INCORRECT_FORMAT_POLICY, err))
+	}
+
+	algorithm := fields[s.formFieldName("Algorithm")]
+	if !s.isAcceptedAlgorithm(algorithm) {
+		return fail(STAGE_ALGORITHM, fmt.Errorf("%s: %q", ERROR_INCORRECT_ALGORITHM, algorithm))
+	}
+	report.Algorithm = algorithm
+	report.KeyID = credential.ACCESS_KEY_ID
+	report.ReceivedScope = strings.SplitN(credential.String(), "/", 2)[1]
+
+	// Validity
+	report.Date = fields[s.formFieldName("Date")]
+	signingTime, err := s.parseDate(report.Date)
+	if err != nil {
+		return fail(STAGE_DATE, err)
+	}
+	report.ComputedScope = s.getCredentialScope(signingTime, credential.Region, credential.Service)
+	if err := checkCredentialScope(report.ReceivedScope, report.ComputedScope); err != nil {
+		return fail(STAGE_SCOPE, err)
+	}
+	if s.now().After(policy.Expiration) {
+		return fail(STAGE_DATE, fmt.Errorf("%w: %s", auth.ErrSignatureExpired, ERROR_POLICY_EXPIRED))
+	}
+
+	if s.rateLimiter != nil {
+		if err := s.allow(credential.ACCESS_KEY_ID); err != nil {
+			return fail(STAGE_RATE_LIMIT, err)
+		}
+	}
+	secret, err := s.secretAccessKey(req.Context(), credential.ACCESS_KEY_ID, fields[s.formFieldName("Security-Token")])
+	if err != nil {
+		return fail(STAGE_SECRET, err)
+	}
+
+	// The `stringToSign` is the base64-encoded policy document
+	signingKey, err := s.signingKeyFromSecret(algorithm, secret, signingTime, credential.Region, credential.Service)
+	if err != nil {
+		return fail(STAGE_SIGNATURE, err)
+	}
+	computedSignature, err := s.generateSignature(algorithm, signingKey, fields["policy"])
+	if err != nil {
+		return fail(STAGE_SIGNATURE, err)
+	}
+	if !hmac.Equal([]byte(computedSignature), []byte(fields[s.formFieldName("Signature")])) {
+		return fail(STAGE_SIGNATURE, fmt.Errorf("%w: %s", auth.ErrSignatureMismatch, ERROR_SIGNATURE_MISMATCH))
+	}
+
+	// Enforce the conditions, once the policy is known to be authentic
+	if err := s.enforcePostPolicy(req, policy, fields); err != nil {
+		return fail(STAGE_CONSTRAINTS, err)
+	}
+
+	report.Verified = true
+	return s.identity(algorithm, credential, signingTime, nil), nil
+}
+
+// `enforcePostPolicy` checks the form fields and the uploaded file against the conditions of the policy
+func (s *SigV4) enforcePostPolicy(req *http.Request, policy *PostPolicy, fields map[string]string) error {
+	covered := map[string]bool{"policy": true, s.formFieldName("Signature"): true}
+	for _, condition := range policy.Conditions {
+		if condition.Operator == POLICY_CONTENT_LENGTH_RANGE {
+			// The only file of the form is in the `file` field, of any case
+			var size int64
+			for _, files := range req.MultipartForm.File {
+				size = files[0].Size
+			}
+			if size < condition.Min || size > condition.Max {
+				return fmt.Errorf("%w: %s: file size %d is outside [%d, %d]", auth.ErrConstraintViolated, ERROR_POLICY_CONDITION_FAILED, size, condition.Min, condition.Max)
+			}
+			continue
+		}
+		field := strings.ToLower(condition.Field)
+		if !condition.matches(fields[field]) {
+			return fmt.Errorf("%w: %s: %s", auth.ErrConstraintViolated, ERROR_POLICY_CONDITION_FAILED, field)
+		}
+		covered[field] = true
+	}
+	for field := range fields {
+		if !covered[field] && !strings.HasPrefix(field, "x-ignore-") {
+			return fmt.Errorf("%w: %s: %s", auth.ErrConstraintViolated, ERROR_POLICY_FIELD_NOT_ALLOWED, field)
+		}
+	}
+	return nil
+}
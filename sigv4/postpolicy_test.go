@@ -0,0 +1,206 @@
+package sigv4
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/jayantasamaddar/go-httpsigner/auth"
+	"github.com/jayantasamaddar/go-httpsigner/utils/backoff"
+)
+
+// Creates a POST policy upload of the form fields and the file, like a browser would
+func newPostPolicyUpload(t *testing.T, fields map[string]string, file []byte) *http.Request {
+	t.Helper()
+	var body bytes.Buffer
+	w := multipart.NewWriter(&body)
+	for name, value := range fields {
+		if err := w.WriteField(name, value); err != nil {
+			t.Fatal(err)
+		}
+	}
+	part, err := w.CreateFormFile("file", "photo.jpg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	part.Write(file)
+	w.Close()
+
+	req, _ := http.NewRequest(http.MethodPost, "http://validate.127.0.0.1.sslip.io/uploads", &body)
+	req.Header.Set("Content-Type", w.FormDataContentType())
+	return req
+}
+
+// Test that uploads satisfying a signed POST policy are verified, and uploads violating it are rejected
+func Test_PostPolicy(t *testing.T) {
+	server := newSecretRetrievalServer(t, testEnvConfig.SECRET_ACCESS_KEY)
+	signer, _ := NewSigV4Signer("SYM", "sym", "certificatemanager", testEnvConfig, false)
+	verifier, _ := NewSigV4Verifier("SYM", "sym", "certificatemanager", server.URL)
+
+	signedFields, err := signer.(*SigV4).PresignPostPolicy(&PostPolicy{
+		Expiration: time.Now().Add(time.Hour),
+		Conditions: []PostPolicyCondition{
+			PolicyStartsWith("key", "uploads/"),
+			PolicyEquals("acl", "private"),
+			PolicyStartsWith("Content-Type", "image/"),
+			PolicyContentLengthRange(1, 16),
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	form := func(overrides map[string]string) map[string]string {
+		fields := map[string]string{"key": "uploads/photo.jpg", "acl": "private", "Content-Type": "image/jpeg", "x-ignore-redirect": "/done"}
+		for name, value := range signedFields {
+			fields[name] = value
+		}
+		for name, value := range overrides {
+			fields[name] = value
+		}
+		return fields
+	}
+
+	// The tampered policy is {"expiration":"2100-01-01T00:00:00Z","conditions":[]}
+	tests := []struct {
+		name      string
+		overrides map[string]string
+		file      []byte
+		err       error
+	}{
+		{"valid", nil, []byte("jpeg"), nil},
+		{"key outside prefix", map[string]string{"key": "private/photo.jpg"}, []byte("jpeg"), auth.ErrConstraintViolated},
+		{"wrong acl", map[string]string{"acl": "public-read"}, []byte("jpeg"), auth.ErrConstraintViolated},
+		{"field not covered", map[string]string{"success_action_status": "201"}, []byte("jpeg"), auth.ErrConstraintViolated},
+		{"file too large", nil, bytes.Repeat([]byte("a"), 17), auth.ErrConstraintViolated},
+		{"empty file", nil, nil, auth.ErrConstraintViolated},
+		{"tampered policy", map[string]string{"policy": "eyJleHBpcmF0aW9uIjoiMjEwMC0wMS0wMVQwMDowMDowMFoiLCJjb25kaXRpb25zIjpbXX0="}, []byte("jpeg"), auth.ErrSignatureMismatch},
+	}
+	for _, test := range tests {
+		req := newPostPolicyUpload(t, form(test.overrides), test.file)
+		identity, err := verifier.(*SigV4).AuthenticatePostPolicy(req)
+		if !errors.Is(err, test.err) {
+			t.Errorf("%s: expected: %v, got: %v", test.name, test.err, err)
+		}
+		if err == nil && identity.KeyID != testEnvConfig.ACCESS_KEY_ID {
+			t.Errorf("%s: unexpected identity: %+v", test.name, identity)
+		}
+	}
+
+	// Expired
+	signedFields, _ = signer.(*SigV4).PresignPostPolicy(&PostPolicy{Expiration: time.Now().Add(-time.Second)})
+	req := newPostPolicyUpload(t, signedFields, []byte("jpeg"))
+	if _, err := verifier.(*SigV4).AuthenticatePostPolicy(req); !errors.Is(err, auth.ErrSignatureExpired) {
+		t.Errorf("Expected auth.ErrSignatureExpired, got: %v", err)
+	}
+}
+
+// Test that forms with files other than the single `file`, or larger than the maximum size, are rejected before the signature is verified
+func Test_PostPolicy_Files(t *testing.T) {
+	var retrievals atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		retrievals.Add(1)
+		_ = json.NewEncoder(w).Encode(map[string]string{"secret_access_key": testEnvConfig.SECRET_ACCESS_KEY})
+	}))
+	defer server.Close()
+	signer, _ := NewSigV4Signer("SYM", "sym", "certificatemanager", testEnvConfig, false)
+	verifier, _ := NewSigV4Verifier("SYM", "sym", "certificatemanager", server.URL, WithPostPolicyMaxSize(4<<10))
+
+	signedFields, err := signer.(*SigV4).PresignPostPolicy(&PostPolicy{
+		Expiration: time.Now().Add(time.Hour),
+		Conditions: []PostPolicyCondition{PolicyContentLengthRange(1, 16)},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	upload := func(files map[string][][]byte) *http.Request {
+		var body bytes.Buffer
+		w := multipart.NewWriter(&body)
+		for name, value := range signedFields {
+			_ = w.WriteField(name, value)
+		}
+		for name, contents := range files {
+			for _, content := range contents {
+				part, _ := w.CreateFormFile(name, "photo.jpg")
+				part.Write(content)
+			}
+		}
+		w.Close()
+		req, _ := http.NewRequest(http.MethodPost, "http://validate.127.0.0.1.sslip.io/uploads", &body)
+		req.Header.Set("Content-Type", w.FormDataContentType())
+		return req
+	}
+
+	if _, err := verifier.(*SigV4).AuthenticatePostPolicy(upload(map[string][][]byte{"file": {[]byte("jpeg")}})); err != nil {
+		t.Fatal(err)
+	}
+	for name, files := range map[string]map[string][][]byte{
+		"repeated file": {"file": {[]byte("jpeg"), bytes.Repeat([]byte("a"), 100)}},
+		"other file":    {"file": {[]byte("jpeg")}, "thumbnail": {bytes.Repeat([]byte("a"), 100)}},
+		"too large":     {"file": {bytes.Repeat([]byte("a"), 8<<10)}},
+	} {
+		report := new(VerificationReport)
+		if _, err := verifier.(*SigV4).verifyPostPolicy(upload(files), report); err == nil || report.FailedStage != STAGE_PARSE {
+			t.Errorf("%s: expected a failure at stage %q, got: %v at %q", name, STAGE_PARSE, err, report.FailedStage)
+		}
+	}
+	if n := retrievals.Load(); n != 1 {
+		t.Errorf("Expected the rejected uploads not to retrieve the secret, got %d retrievals", n)
+	}
+}
+
+// Test that the presigned form pre-fills the known fields and carries the session token of temporary credentials
+func Test_PresignPostForm(t *testing.T) {
+	const sessionToken = "FwoGZXIvYXdzEBYaDEXAMPLETOKEN"
+	secretServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]string
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		if body["session_token"] != sessionToken {
+			http.Error(w, "invalid session token", http.StatusForbidden)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]string{"secret_access_key": testEnvConfig.SECRET_ACCESS_KEY})
+	}))
+	defer secretServer.Close()
+
+	env := *testEnvConfig
+	env.SESSION_TOKEN = sessionToken
+	signer, _ := NewSigV4Signer("SYM", "sym", "certificatemanager", &env, false)
+	verifier, _ := NewSigV4Verifier("SYM", "sym", "certificatemanager", secretServer.URL, WithSecretRetrievalBackoff(backoff.Exponential(0, 0, 0)))
+
+	form, err := signer.(*SigV4).PresignPostForm("http://validate.127.0.0.1.sslip.io/uploads", &PostPolicy{
+		Expiration: time.Now().Add(time.Hour),
+		Conditions: []PostPolicyCondition{PolicyStartsWith("key", "uploads/"), PolicyEquals("acl", "private")},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if form.Fields["acl"] != "private" || form.Fields["x-sym-security-token"] != sessionToken {
+		t.Errorf("Expected the acl and the security token to be pre-filled, got: %v", form.Fields)
+	}
+	if _, ok := form.Fields["key"]; ok {
+		t.Errorf("Expected the key to be left to the frontend, got: %v", form.Fields)
+	}
+	b, _ := json.Marshal(form)
+	if !strings.HasPrefix(string(b), `{"url":"http://validate.127.0.0.1.sslip.io/uploads","fields":{`) {
+		t.Errorf("Unexpected JSON: %s", b)
+	}
+
+	fields := map[string]string{"key": "uploads/photo.jpg"}
+	for name, value := range form.Fields {
+		fields[name] = value
+	}
+	if _, err := verifier.(*SigV4).AuthenticatePostPolicy(newPostPolicyUpload(t, fields, []byte("jpeg"))); err != nil {
+		t.Error(err)
+	}
+	fields["x-sym-security-token"] = "forged"
+	if _, err := verifier.(*SigV4).AuthenticatePostPolicy(newPostPolicyUpload(t, fields, []byte("jpeg"))); !errors.Is(err, auth.ErrSecretUnavailable) {
+		t.Errorf("Expected: %v, got: %v", auth.ErrSecretUnavailable, err)
+	}
+}
@@ -0,0 +1,98 @@
+package sigv4
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Errors
+const (
+	ERROR_BODILESS_METHOD_PAYLOAD = "HEAD and OPTIONS requests must not have a payload"
+)
+
+// Headers of a CORS preflight request, signed alongside the minimal headers by `WithPreflightSigning`
+var PreflightHeaders = []string{
+	"Origin",
+	"Access-Control-Request-Method",
+	"Access-Control-Request-Headers",
+}
+
+// Checks if the request is a CORS preflight request: an `OPTIONS` request with the `Origin` and `Access-Control-Request-Method` headers
+func IsPreflight(req *http.Request) bool {
+	return req.Method == http.MethodOptions && req.Header.Get("Origin") != "" && req.Header.Get("Access-Control-Request-Method") != ""
+}
+
+// # HEAD and OPTIONS signing
+//
+// Sign `HEAD` and `OPTIONS` requests consistently, irrespective of the client and the proxies between the Signer and the Verifier:
+//   - The payload hash is always `EMPTY_PAYLOAD_HASH`, and signing fails with a payload (See `ERROR_BODILESS_METHOD_PAYLOAD`),
+//     even with a `PayloadHasher` (See `WithPayloadHasher`).
+//   - Only the minimal headers are signed: `host`, the Date Header, the session token, the request ID and payload hash headers if set,
+//     and the `PreflightHeaders`. Headers that browsers, CDNs and proxies add to or drop from such requests (E.g. `content-length`,
+//     `user-agent` or `sec-fetch-mode`) are not.
+//
+// The Verifier canonicalizes the `SignedHeaders` declared by the signature, hence verifies these requests without the option.
+// With the option, the Verifier also rejects `HEAD` and `OPTIONS` requests with a payload.
+// Browsers never sign the preflight of a cross-origin request: See `WithSkipPreflight` to let unsigned preflights through the Verifier.
+func WithPreflightSigning() Option {
+	return func(s *SigV4) {
+		s.preflightSigning = true
+	}
+}
+
+// # Skip unsigned CORS preflights
+//
+// Accept unsigned CORS preflight requests (See `IsPreflight`) without verification, as browsers send the preflight of a cross-origin request
+// without the headers of the actual request, hence without its signature. `VerifySignature` returns nil, and `Authenticate` a nil Identity.
+// Preflights carrying a signature are still verified.
+//
+// The handler must only answer the CORS preflight for requests without an Identity (E.g. with `Access-Control-Allow-*` headers),
+// as the preflight is not authenticated. The request that follows the preflight is verified as usual.
+func WithSkipPreflight() Option {
+	return func(s *SigV4) {
+		s.skipPreflight = true
+	}
+}
+
+// `isBodilessMethod` checks if the request is signed as a bodiless `HEAD` or `OPTIONS` request. See `WithPreflightSigning`.
+func (s *SigV4) isBodilessMethod(method string) bool {
+	return s.preflightSigning && (method == http.MethodHead || method == http.MethodOptions)
+}
+
+// `bodilessPayloadHash` returns the payload hash of a `HEAD` or `OPTIONS` request, failing if it has a payload
+func bodilessPayloadHash(ctx context.Context, req *http.Request) (string, int64, error) {
+	payloadHash, contentLength, err := bufferedPayloadHash(ctx, req)
+	if err != nil {
+		return "", 0, err
+	}
+	if contentLength > 0 {
+		return "", 0, fmt.Errorf("%s: %s request with %d bytes", ERROR_BODILESS_METHOD_PAYLOAD, req.Method, contentLength)
+	}
+	return payloadHash, 0, nil
+}
+
+// `isMinimalHeader` checks if a header is signed on a `HEAD` or `OPTIONS` request. See `WithPreflightSigning`.
+func (s *SigV4) isMinimalHeader(header string) bool {
+	for _, name := range append([]string{s.dateHeader(), s.securityTokenHeader(), s.requestIDHeaderName(), s.contentSHA256Header()}, PreflightHeaders...) {
+		if strings.EqualFold(header, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// Checks if the request is an unsigned CORS preflight accepted without verification. Implements `auth.SkippingVerifier`. See `WithSkipPreflight`.
+func (s *SigV4) SkipsVerification(req *http.Request) bool {
+	return s.isSkippedPreflight(req)
+}
+
+// `isSkippedPreflight` checks if the request is an unsigned CORS preflight accepted without verification. See `WithSkipPreflight`.
+func (s *SigV4) isSkippedPreflight(req *http.Request) bool {
+	if !s.skipPreflight || !IsPreflight(req) {
+		return false
+	}
+	_, signed := s.signatureAuthorization(req)
+	return !signed && !s.isPresigned(req)
+}
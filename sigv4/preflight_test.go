@@ -0,0 +1,85 @@
+package sigv4
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// Returns a CORS preflight request, as sent by a browser
+func newPreflightRequest() *http.Request {
+	req, _ := http.NewRequest(http.MethodOptions, "http://validate.127.0.0.1.sslip.io/api/cmagent", nil)
+	req.Header.Set("Origin", "https://app.example.com")
+	req.Header.Set("Access-Control-Request-Method", http.MethodPut)
+	req.Header.Set("User-Agent", "Mozilla/5.0")
+	req.Header.Set("Sec-Fetch-Mode", "cors")
+	return req
+}
+
+// Test that HEAD and OPTIONS requests are signed with an empty payload and the minimal headers, and verified by a default Verifier
+func Test_WithPreflightSigning(t *testing.T) {
+	server := newSecretRetrievalServer(t, testEnvConfig.SECRET_ACCESS_KEY)
+	signer, _ := NewSigV4Signer("SYM", "sym", "certificatemanager", testEnvConfig, true, WithPreflightSigning())
+	verifier, _ := NewSigV4Verifier("SYM", "sym", "certificatemanager", server.URL)
+
+	req := newPreflightRequest()
+	if !IsPreflight(req) {
+		t.Fatal("Expected a preflight request")
+	}
+	if err := signer.SignHTTPRequest(req); err != nil {
+		t.Fatal(err)
+	}
+	expected := "SignedHeaders=access-control-request-method;host;origin;x-sym-content-sha256;x-sym-date,"
+	if header := req.Header.Get("Authorization"); !strings.Contains(header, expected) {
+		t.Errorf("Expected %q, got: %q", expected, header)
+	}
+	if hash := req.Header.Get("X-Sym-Content-Sha256"); hash != EMPTY_PAYLOAD_HASH {
+		t.Errorf("Expected the empty payload hash, got: %q", hash)
+	}
+	// Headers rewritten by the browser or proxies are not signed
+	req.Header.Set("User-Agent", "curl/8.0")
+	req.Header.Del("Sec-Fetch-Mode")
+	if err := verifier.VerifySignature(req); err != nil {
+		t.Error(err)
+	}
+
+	req, _ = http.NewRequest(http.MethodHead, "http://validate.127.0.0.1.sslip.io/api/cmagent", strings.NewReader("payload"))
+	if err := signer.SignHTTPRequest(req); err == nil || !strings.Contains(err.Error(), ERROR_BODILESS_METHOD_PAYLOAD) {
+		t.Errorf("Expected error: %q, got: %v", ERROR_BODILESS_METHOD_PAYLOAD, err)
+	}
+}
+
+// Test that unsigned CORS preflights are accepted with `WithSkipPreflight`, and that every other request is still verified
+func Test_WithSkipPreflight(t *testing.T) {
+	server := newSecretRetrievalServer(t, testEnvConfig.SECRET_ACCESS_KEY)
+	signer, _ := NewSigV4Signer("SYM", "sym", "certificatemanager", testEnvConfig, false, WithPreflightSigning())
+	verifier, _ := NewSigV4Verifier("SYM", "sym", "certificatemanager", server.URL, WithSkipPreflight())
+	strict, _ := NewSigV4Verifier("SYM", "sym", "certificatemanager", server.URL)
+
+	identity, err := verifier.(*SigV4).Authenticate(newPreflightRequest())
+	if err != nil || identity != nil {
+		t.Errorf("Expected the unsigned preflight to be skipped, got: %+v, %v", identity, err)
+	}
+	if report, _ := verifier.(*SigV4).Explain(newPreflightRequest()); !report.PreflightSkipped || report.Verified {
+		t.Errorf("Unexpected report: %+v", report)
+	}
+	if err := strict.VerifySignature(newPreflightRequest()); err == nil {
+		t.Error("Expected the unsigned preflight to be rejected without the option")
+	}
+
+	// An OPTIONS request that is not a preflight
+	req, _ := http.NewRequest(http.MethodOptions, "http://validate.127.0.0.1.sslip.io/api/cmagent", nil)
+	if err := verifier.VerifySignature(req); err == nil {
+		t.Error("Expected the unsigned OPTIONS request to be rejected")
+	}
+
+	// A signed preflight is verified
+	req = newPreflightRequest()
+	if err := signer.SignHTTPRequest(req); err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Access-Control-Request-Method", http.MethodDelete)
+	if err := verifier.VerifySignature(req); err == nil {
+		t.Error("Expected the tampered preflight to be rejected")
+	}
+}
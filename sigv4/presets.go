@@ -0,0 +1,135 @@
+package sigv4
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/jayantasamaddar/go-httpsigner/sigv4core"
+)
+
+// Headers commonly injected or rewritten by CDNs, load balancers and reverse proxies (E.g. CloudFront, ALB, nginx)
+// between the client and the server. These never participate in canonicalization with the `WithProxyCompatibility` preset.
+var ProxyHeaders = []string{
+	"Via",
+	"Forwarded",
+	"X-Forwarded-For",
+	"X-Forwarded-Host",
+	"X-Forwarded-Port",
+	"X-Forwarded-Proto",
+	"X-Real-Ip",
+	"X-Request-Id",
+	"X-Amzn-Trace-Id",
+	"X-Amz-Cf-Id",
+	"Cdn-Loop",
+	"True-Client-Ip",
+	"User-Agent",
+	"Accept-Encoding",
+	"Connection",
+	"Keep-Alive",
+	"Proxy-Connection",
+	"Te",
+	"Trailer",
+	"Upgrade",
+}
+
+// # CDN/ALB compatibility preset
+//
+// Combines the options needed for signatures to survive CDNs, load balancers and reverse proxies between the Signer and the Verifier:
+//   - Headers in `ProxyHeaders` are never canonicalized (See `WithSkipHeaders`).
+//   - The host is lowercased (See `WithHostNormalization`).
+//
+// The Verifier only canonicalizes the headers listed in `SignedHeaders` of the `Authorization` header.
+// Use the preset on both the Signer and the Verifier. Additional headers can be skipped by passing `WithSkipHeaders` after the preset.
+//
+// The host is not taken from `X-Forwarded-Host`, as clients can set it: if proxies like nginx rewrite `Host`, also pass
+// `WithHostFromHeaders("X-Forwarded-Host")` to the Verifier, only if it is exclusively reachable through proxies that overwrite the header.
+func WithProxyCompatibility() Option {
+	return func(s *SigV4) {
+		WithSkipHeaders(ProxyHeaders...)(s)
+		WithHostNormalization(true)(s)
+	}
+}
+
+// The region assumed by S3-compatible backends (E.g. MinIO, Ceph RadosGW) unless configured otherwise
+const S3_COMPATIBLE_DEFAULT_REGION = "us-east-1"
+
+// # MinIO/Ceph RGW compatibility preset
+//
+// Combines the options needed for signatures to be accepted by self-hosted S3-compatible backends, such as MinIO and Ceph RadosGW:
+//   - The payload hash is required, in the `x-[abbr]-content-sha256` header (See `WithPayloadHashing`).
+//   - Query parameters are encoded following the SigV4 `UriEncode` rules (See `WithStrictQueryEncoding`).
+//   - The region defaults to `S3_COMPATIBLE_DEFAULT_REGION` if none is configured (See `WithDefaultRegion`),
+//     as both backends sign with "us-east-1" unless a region is set on the server.
+//
+// Both backends expect path-style addressing (E.g. `http://localhost:9000/bucket/key`) unless a domain is configured on the server.
+// Build the URLs of requests with `PathStyleURL`, so that the object key is escaped the way the backends canonicalize it.
+func WithS3CompatibleBackend() Option {
+	return func(s *SigV4) {
+		WithPayloadHashing(true)(s)
+		WithStrictQueryEncoding(true)(s)
+		WithDefaultRegion(S3_COMPATIBLE_DEFAULT_REGION)(s)
+	}
+}
+
+// Returns the path-style URL of an object: `endpoint/bucket/key`. E.g. `http://localhost:9000/photos/2024/a%20b.jpg`
+//
+// Each segment of the key is escaped byte for byte following the SigV4 `UriEncode` rules (See `sigv4core.StrictURIEncode`), and the '/' separating the segments are kept,
+// so that the escaped path sent on the wire is the `CanonicalURI` the backend computes. An empty key returns the URL of the bucket.
+func PathStyleURL(endpoint, bucket, key string) (string, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil || u.Host == "" {
+		return "", fmt.Errorf("%s: %q", ERROR_INVALID_ENDPOINT, endpoint)
+	}
+	if bucket == "" || strings.Contains(bucket, "/") {
+		return "", fmt.Errorf("%s: %q", ERROR_INVALID_BUCKET, bucket)
+	}
+	path := strings.TrimSuffix(u.EscapedPath(), "/") + "/" + sigv4core.StrictURIEncode(bucket, true)
+	if key != "" {
+		path += "/" + sigv4core.StrictURIEncode(key, false)
+	}
+	return fmt.Sprintf("%s://%s%s", u.Scheme, u.Host, path), nil
+}
+
+// The name of the service preset applied to services without a registered preset
+const DEFAULT_SERVICE_PRESET = "default"
+
+var (
+	servicePresetsMu sync.RWMutex
+	// Options applied automatically by the constructors when the service name matches
+	// Amazon S3 has no built-in preset, to remain compatible with the existing "s3" Signers and Verifiers:
+	// opt in with `RegisterServicePreset("s3", WithPayloadHashing(true), WithStrictQueryEncoding(true))`.
+	servicePresets = map[string][]Option{
+		// OpenSearch Serverless requires the payload hash, and both OpenSearch services SigV4 encoding of query parameters
+		SERVICE_OPENSEARCH:            {WithPayloadHashing(true), WithStrictQueryEncoding(true)},
+		SERVICE_OPENSEARCH_SERVERLESS: {WithPayloadHashing(true), WithStrictQueryEncoding(true)},
+		// No options for services without a preset, to remain compatible with existing Signers and Verifiers
+		DEFAULT_SERVICE_PRESET: {},
+	}
+)
+
+// # Per-service behaviour presets
+//
+// Registers the options applied automatically by `NewSigV4Signer` and `NewSigV4Verifier` when their `service` matches,
+// replacing any existing preset for the service. Use `DEFAULT_SERVICE_PRESET` as the `service` to configure services without a preset.
+//
+// Preset options are applied before the arguments and the options passed to the constructor, hence the latter take precedence:
+// E.g. the `hashPayload` argument of `NewSigV4Signer` overrides a preset with `WithPayloadHashing`.
+// Register presets at initialization, before any Signer or Verifier is constructed, so that both use the same presets.
+func RegisterServicePreset(service string, opts ...Option) {
+	servicePresetsMu.Lock()
+	defer servicePresetsMu.Unlock()
+	servicePresets[service] = append([]Option(nil), opts...)
+}
+
+// `servicePreset` returns the options to apply for a service, followed by the options passed to the constructor
+func servicePreset(service string, opts []Option) []Option {
+	servicePresetsMu.RLock()
+	defer servicePresetsMu.RUnlock()
+	preset, ok := servicePresets[service]
+	if !ok {
+		preset = servicePresets[DEFAULT_SERVICE_PRESET]
+	}
+	return append(append([]Option(nil), preset...), opts...)
+}
@@ -0,0 +1,205 @@
+package sigv4
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httputil"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+// Test that a request signed with the proxy compatibility preset is verified behind a reverse proxy that rewrites `Host`
+// and injects forwarding headers, as nginx or an ALB would do
+func Test_ProxyCompatibility_BehindReverseProxy(t *testing.T) {
+	secretServer := newSecretRetrievalServer(t, testEnvConfig.SECRET_ACCESS_KEY)
+
+	verifier, err := NewSigV4Verifier("SYM", "sym", "certificatemanager", secretServer.URL, WithProxyCompatibility(), WithHostFromHeaders("X-Forwarded-Host"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := verifier.VerifySignature(r); err != nil {
+			http.Error(w, err.Error(), http.StatusForbidden)
+			return
+		}
+		b, _ := io.ReadAll(r.Body)
+		_, _ = w.Write(b)
+	}))
+	defer backend.Close()
+
+	target, _ := url.Parse(backend.URL)
+	proxy := httptest.NewServer(&httputil.ReverseProxy{
+		Rewrite: func(pr *httputil.ProxyRequest) {
+			pr.SetURL(target) // Rewrites `Host` to the upstream
+			pr.SetXForwarded()
+			pr.Out.Header.Set("X-Request-Id", "proxy-generated")
+			pr.Out.Header.Set("Via", "1.1 proxy")
+			pr.Out.Header.Set("User-Agent", "Amazon CloudFront")
+		},
+	})
+	defer proxy.Close()
+
+	signer, err := NewSigV4Signer("SYM", "sym", "certificatemanager", testEnvConfig, false, WithProxyCompatibility())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req, _ := http.NewRequest(http.MethodPost, proxy.URL+"/api/Important?b=2&a=1", bytes.NewBufferString(`{"name":"Bruce"}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "client/1.0")
+	if err := signer.SignHTTPRequest(req); err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	b, _ := io.ReadAll(res.Body)
+	if res.StatusCode != http.StatusOK {
+		t.Errorf("Expected status: %d, got: %d (%s)", http.StatusOK, res.StatusCode, b)
+	}
+}
+
+// Test that the host is normalized before canonicalization
+func Test_CanonicalHost_Normalization(t *testing.T) {
+	req, _ := http.NewRequest(http.MethodGet, "http://Example.COM./", nil)
+
+	if host := (&SigV4{}).canonicalHost(req); host != "Example.COM." {
+		t.Errorf("Expected host to be unchanged by default, got: %q", host)
+	}
+	s := &SigV4{}
+	WithProxyCompatibility()(s)
+	if host := s.canonicalHost(req); host != "example.com" {
+		t.Errorf("Expected host: %q, got: %q", "example.com", host)
+	}
+	// The forwarded host, controlled by the client, is only trusted if configured explicitly
+	req.Header.Set("X-Forwarded-Host", "API.example.com, internal.example.com")
+	if host := s.canonicalHost(req); host != "example.com" {
+		t.Errorf("Expected the preset to ignore X-Forwarded-Host, got: %q", host)
+	}
+	WithHostFromHeaders("X-Forwarded-Host")(s)
+	if host := s.canonicalHost(req); host != "api.example.com" {
+		t.Errorf("Expected host: %q, got: %q", "api.example.com", host)
+	}
+}
+
+// Test that service presets are applied by the constructors, and that constructor arguments and options take precedence
+func Test_ServicePresets(t *testing.T) {
+	// Signers and Verifiers of "s3" remain compatible with those constructed before presets
+	server := newSecretRetrievalServer(t, testEnvConfig.SECRET_ACCESS_KEY)
+	signer, err := NewSigV4Signer("", "", "s3", testEnvConfig, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	verifier, err := NewSigV4Verifier("", "", "s3", server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req, _ := http.NewRequest(http.MethodPut, "http://validate.127.0.0.1.sslip.io/bucket/key?a=b%20c", bytes.NewBufferString("payload"))
+	if err := signer.SignHTTPRequest(req); err != nil {
+		t.Fatal(err)
+	}
+	if req.Header.Get("X-Amz-Content-Sha256") != "" || strings.Contains(req.Header.Get("Authorization"), "x-amz-content-sha256") {
+		t.Errorf("Expected the payload hash not to be signed with hashPayload=false, got: %s", req.Header.Get("Authorization"))
+	}
+	if err := verifier.VerifySignature(req); err != nil {
+		t.Error(err)
+	}
+
+	RegisterServicePreset("presettest", WithSkipHeaders("X-Preset"), WithPayloadHashing(true), WithStrictQueryEncoding(true))
+	defer func() {
+		servicePresetsMu.Lock()
+		delete(servicePresets, "presettest")
+		servicePresetsMu.Unlock()
+	}()
+	verifier, err = NewSigV4Verifier("", "", "presettest", "http://validate.127.0.0.1.sslip.io/api/secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v := verifier.(*SigV4); !v.isSkippedHeader("x-preset") || !v.hashPayload {
+		t.Error("Expected the registered preset to be applied")
+	}
+	signer, _ = NewSigV4Signer("", "", "presettest", testEnvConfig, false, WithStrictQueryEncoding(false))
+	if s := signer.(*SigV4); s.hashPayload || s.strictQueryEncoding || !s.isSkippedHeader("x-preset") {
+		t.Error("Expected the constructor arguments and options to take precedence over the preset")
+	}
+
+	verifier, _ = NewSigV4Verifier("", "", "certificatemanager", "http://validate.127.0.0.1.sslip.io/api/secret")
+	if v := verifier.(*SigV4); v.hashPayload || v.strictQueryEncoding || len(v.skipHeaders) != 0 {
+		t.Error("Expected no options to be applied for a service without a preset")
+	}
+}
+
+// Test that requests to path-style URLs signed with the S3-compatible backend preset default to "us-east-1", and are verified
+func Test_S3CompatibleBackend(t *testing.T) {
+	env := &SigV4EnvConfig{ACCESS_KEY_ID: testEnvConfig.ACCESS_KEY_ID, SECRET_ACCESS_KEY: testEnvConfig.SECRET_ACCESS_KEY}
+	signer, err := NewSigV4Signer("", "", "s3", env, false, WithS3CompatibleBackend())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := signer.(*SigV4); s.env.REGION != S3_COMPATIBLE_DEFAULT_REGION || !s.hashPayload || !s.strictQueryEncoding {
+		t.Errorf("Expected the preset to be applied, got region: %q", s.env.REGION)
+	}
+
+	// A configured region takes precedence
+	configured, err := NewSigV4Signer("", "", "s3", &SigV4EnvConfig{ACCESS_KEY_ID: "id", SECRET_ACCESS_KEY: "secret", REGION: "eu-central-1"}, false, WithS3CompatibleBackend())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if region := configured.(*SigV4).env.REGION; region != "eu-central-1" {
+		t.Errorf("Expected region: %q, got: %q", "eu-central-1", region)
+	}
+
+	secretServer := newSecretRetrievalServer(t, testEnvConfig.SECRET_ACCESS_KEY)
+	verifier, err := NewSigV4Verifier("", "", "s3", secretServer.URL, WithS3CompatibleBackend())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	url, err := PathStyleURL("http://localhost:9000", "photos", "2024/a b+c.jpg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := "http://localhost:9000/photos/2024/a%20b%2Bc.jpg"; url != expected {
+		t.Errorf("Expected: %s, got: %s", expected, url)
+	}
+	req, _ := http.NewRequest(http.MethodPut, url+"?x-id=PutObject", bytes.NewBufferString("content"))
+	if err := signer.SignHTTPRequest(req); err != nil {
+		t.Fatal(err)
+	}
+	if auth := req.Header.Get("Authorization"); !strings.Contains(auth, "/us-east-1/s3/") {
+		t.Errorf("Expected the scope to use the default region, got: %s", auth)
+	}
+	if err := verifier.VerifySignature(req); err != nil {
+		t.Error(err)
+	}
+}
+
+func Test_PathStyleURL(t *testing.T) {
+	tests := []struct {
+		endpoint, bucket, key, expected string
+		valid                           bool
+	}{
+		{"https://rgw.example.com", "bucket", "", "https://rgw.example.com/bucket", true},
+		{"https://rgw.example.com/", "bucket", "dir/", "https://rgw.example.com/bucket/dir/", true},
+		{"http://127.0.0.1:9000/minio", "bucket", "a&b=c", "http://127.0.0.1:9000/minio/bucket/a%26b%3Dc", true},
+		{"http://localhost:9000", "bucket", "photos/café.jpg", "http://localhost:9000/bucket/photos/caf%C3%A9.jpg", true},
+		{"localhost:9000", "bucket", "key", "", false},
+		{"http://localhost:9000", "a/b", "key", "", false},
+		{"http://localhost:9000", "", "key", "", false},
+	}
+	for _, test := range tests {
+		url, err := PathStyleURL(test.endpoint, test.bucket, test.key)
+		if test.valid && (err != nil || url != test.expected) {
+			t.Errorf("Expected: %s, got: %s, %v", test.expected, url, err)
+		}
+		if !test.valid && err == nil {
+			t.Errorf("Expected an error for %s, %s, got: %s", test.endpoint, test.bucket, url)
+		}
+	}
+}
@@ -0,0 +1,324 @@
+package sigv4
+
+import (
+	"context"
+	"crypto/hmac"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jayantasamaddar/go-httpsigner/auth"
+	"github.com/jayantasamaddar/go-httpsigner/sigv4core"
+)
+
+// Errors
+const (
+	ERROR_INCORRECT_FORMAT_QUERY = "incorrectly formatted presigned query"
+	ERROR_INVALID_EXPIRES        = "expires must be between 1 second and 7 days"
+	ERROR_PRESIGNED_EXPIRED      = "presigned request is expired or not yet valid"
+	ERROR_METHOD_NOT_ALLOWED     = "method is not allowed by the constraints of the presigned request"
+	ERROR_PATH_NOT_ALLOWED       = "path is not allowed by the constraints of the presigned request"
+	ERROR_CONTENT_TOO_LONG       = "content length exceeds the constraints of the presigned request"
+)
+
+// The `HashedPayload` of presigned requests, as the payload is not known when presigning
+const UNSIGNED_PAYLOAD = "UNSIGNED-PAYLOAD"
+
+// The maximum validity of a presigned request
+const MAX_PRESIGN_EXPIRES = 7 * 24 * time.Hour
+
+// The clock skew tolerated between the Signer and the Verifier, for presigned requests dated in the future
+const presignClockSkew = 5 * time.Minute
+
+// # Capabilities of a presigned request
+//
+// Constraints widen a presigned request from a single request to a narrowly scoped capability, E.g. to delegate uploads under a prefix to a third party.
+// The constraints are embedded in the `X-[Abbr]-Constraints` query parameter, which is covered by the signature, and enforced by the Verifier.
+type PresignConstraints struct {
+	// The HTTP methods allowed. If empty, only the method of the presigned request is allowed.
+	Methods []string `json:"methods,omitempty"`
+	// The escaped path prefix allowed (E.g. `/uploads/`), compared by whole segments. If empty, only the path of the presigned request is allowed.
+	// Paths with "." or ".." segments, or with an encoded '/' or '\' after the prefix, are never allowed with a prefix.
+	PathPrefix string `json:"path_prefix,omitempty"`
+	// The maximum length of the payload in bytes. Not limited if zero.
+	MaxContentLength int64 `json:"max_content_length,omitempty"`
+}
+
+// Returns the name of a query parameter of presigned requests. E.g. `X-[Abbr]-Signature`
+func (s *SigV4) queryParamName(name string) string {
+	return fmt.Sprintf("X-%s-%s", s.abbr, name)
+}
+
+// # Presigning
+//
+// Signs the request with query parameters instead of the `Authorization` header, so that the URL of the request (`req.URL.String()`)
+// can be handed to a third party. The presigned request is valid for `expires`, at most `MAX_PRESIGN_EXPIRES`.
+//
+// Only the host is signed among the headers, and the payload is not signed (See `UNSIGNED_PAYLOAD`).
+// If `constraints` is not nil, the signature covers the constraints instead of the method and the path of the request. See `PresignConstraints`.
+func (s *SigV4) PresignHTTPRequest(req *http.Request, expires time.Duration, constraints *PresignConstraints) error {
+	if expires < time.Second || expires > MAX_PRESIGN_EXPIRES {
+		return fmt.Errorf("%s: %v", ERROR_INVALID_EXPIRES, expires)
+	}
+	signingTime := s.now()
+	accessKeyID, region, err := s.signingIdentity(req.Context())
+	if err != nil {
+		return err
+	}
+	algorithm := s.signingAlgorithm()
+
+	query, err := url.ParseQuery(req.URL.RawQuery)
+	if err != nil {
+		return fmt.Errorf("%s: %w", sigv4core.ERROR_MALFORMED_QUERY, err)
+	}
+	query.Set(s.queryParamName("Algorithm"), algorithm)
+	query.Set(s.queryParamName("Credential"), fmt.Sprintf("%s/%s", accessKeyID, s.getCredentialScope(signingTime, region, s.service)))
+	query.Set(s.queryParamName("Date"), s.formatDate(signingTime))
+	query.Set(s.queryParamName("Expires"), strconv.FormatInt(int64(expires/time.Second), 10))
+	query.Set(s.queryParamName("SignedHeaders"), "host")
+	if token := s.sessionToken(); token != "" {
+		query.Set(s.queryParamName("Security-Token"), token)
+	}
+	if constraints != nil {
+		b, err := json.Marshal(constraints)
+		if err != nil {
+			return err
+		}
+		query.Set(s.queryParamName("Constraints"), base64.RawURLEncoding.EncodeToString(b))
+	}
+	query.Del(s.queryParamName("Signature"))
+	req.URL.RawQuery = query.Encode()
+
+	cr, err := s.presignedCanonicalRequest(req, constraints)
+	if err != nil {
+		return err
+	}
+	signature, err := s.sign(req.Context(), algorithm, signingTime, region, s.stringToSign(algorithm, signingTime, region, s.service, cr))
+	if err != nil {
+		return err
+	}
+	req.URL.RawQuery += "&" + url.QueryEscape(s.queryParamName("Signature")) + "=" + signature
+	return nil
+}
+
+// Builds the `CanonicalRequest` of a presigned request: the signature query parameter is excluded, only the host is signed,
+// and the method and the path are replaced by the `constraints`, if any.
+func (s *SigV4) presignedCanonicalRequest(req *http.Request, constraints *PresignConstraints) (string, error) {
+	method, path := req.Method, req.URL.EscapedPath()
+	if constraints != nil {
+		if len(constraints.Methods) > 0 {
+			methods := make([]string, len(constraints.Methods))
+			for i, m := range constraints.Methods {
+				methods[i] = strings.ToUpper(m)
+			}
+			slices.Sort(methods)
+			method = strings.Join(methods, ",")
+		}
+		if constraints.PathPrefix != "" {
+			path = constraints.PathPrefix
+		}
+	}
+
+	query, err := url.ParseQuery(req.URL.RawQuery)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", sigv4core.ERROR_MALFORMED_QUERY, err)
+	}
+	for key := range query {
+		if strings.EqualFold(key, s.queryParamName("Signature")) {
+			query.Del(key)
+		}
+	}
+	cr, _, err := sigv4core.CanonicalRequest(&sigv4core.Request{
+		Method:      method,
+		Path:        path,
+		RawQuery:    query.Encode(),
+		Host:        s.canonicalHost(req),
+		PayloadHash: UNSIGNED_PAYLOAD,
+	}, &sigv4core.Options{
+		StrictQueryEncoding: s.strictQueryEncoding,
+		SkipHeader:          func(name string) bool { return strings.EqualFold(name, "Content-Length") },
+		TerminateHeaders:    s.strictAWS,
+		DoubleURIEncode:     s.doubleURIEncode,
+		NormalizePath:       s.normalizePath,
+		StrictURIEncoding:   s.strictURIEncoding,
+	})
+	return cr, err
+}
+
+// `isPresigned` checks if the request is signed in the query, i.e. has a `X-[abbr]-Signature` query parameter
+func (s *SigV4) isPresigned(req *http.Request) bool {
+	// Malformed pairs are reported by the verification of the presigned request
+	query, _ := url.ParseQuery(req.URL.RawQuery)
+	return queryValue(query, s.queryParamName("Signature")) != ""
+}
+
+// `queryValue` returns the value of a query parameter, looking up the name case-insensitively
+func queryValue(query url.Values, name string) string {
+	for key, values := range query {
+		if strings.EqualFold(key, name) && len(values) > 0 {
+			return values[0]
+		}
+	}
+	return ""
+}
+
+// Verify a presigned request on the server, and return the Identity of the client that presigned the request.
+// The request must be within its validity and satisfy its constraints, if any.
+func (s *SigV4) AuthenticatePresigned(req *http.Request) (*auth.Identity, error) {
+	report := new(VerificationReport)
+	identity, err := s.withBudget(req.Context(), req, report, s.verifyPresigned)
+	if s.usage != nil && report.KeyID != "" {
+		s.usage.record(report.KeyID, report.Verified, s.now())
+	}
+	return identity, err
+}
+
+// `verifyPresigned` runs the verification pipeline of presigned requests, recording the inputs of each stage in the `report` like `verify`.
+func (s *SigV4) verifyPresigned(ctx context.Context, req *http.Request, report *VerificationReport) (*auth.Identity, error) {
+	fail := func(stage string, err error) (*auth.Identity, error) {
+		report.FailedStage = stage
+		return nil, err
+	}
+
+	// Extract the query parameters
+	query, err := url.ParseQuery(req.URL.RawQuery)
+	if err != nil {
+		return fail(STAGE_PARSE, fmt.Errorf("%s: %w", sigv4core.ERROR_MALFORMED_QUERY, err))
+	}
+	authHeaders, err := s.parseAuthHeaders(fmt.Sprintf("%s Credential=%s,SignedHeaders=%s,Signature=%s",
+		queryValue(query, s.queryParamName("Algorithm")),
+		queryValue(query, s.queryParamName("Credential")),
+		queryValue(query, s.queryParamName("SignedHeaders")),
+		queryValue(query, s.queryParamName("Signature")),
+	))
+	if err != nil {
+		return fail(STAGE_PARSE, fmt.Errorf("%s: %w", ERROR_INCORRECT_FORMAT_QUERY, err))
+	}
+	if !slices.Equal(authHeaders.SignedHeaders, []string{"host"}) {
+		return fail(STAGE_SIGNED_HEADERS, fmt.Errorf("%s: %s", ERROR_INCORRECT_FORMAT_QUERY, "only the host may be signed"))
+	}
+	var constraints *PresignConstraints
+	if encoded := queryValue(query, s.queryParamName("Constraints")); encoded != "" {
+		b, err := base64.RawURLEncoding.DecodeString(encoded)
+		if err == nil {
+			constraints = new(PresignConstraints)
+			err = json.Unmarshal(b, constraints)
+		}
+		if err != nil {
+			return fail(STAGE_PARSE, fmt.Errorf("%s: %w", ERROR_INCORRECT_FORMAT_QUERY, err))
+		}
+	}
+	if !s.isAcceptedAlgorithm(authHeaders.Algorithm) {
+		return fail(STAGE_ALGORITHM, fmt.Errorf("%s: %q", ERROR_INCORRECT_ALGORITHM, authHeaders.Algorithm))
+	}
+	report.Algorithm = authHeaders.Algorithm
+	report.KeyID = authHeaders.Credential.ACCESS_KEY_ID
+	report.ReceivedScope = strings.SplitN(authHeaders.Credential.String(), "/", 2)[1]
+	report.SignedHeaders = authHeaders.SignedHeaders
+
+	// Validity
+	report.Date = queryValue(query, s.queryParamName("Date"))
+	signingTime, err := s.parseDate(report.Date)
+	if err != nil {
+		return fail(STAGE_DATE, err)
+	}
+	report.ComputedScope = s.getCredentialScope(signingTime, authHeaders.Credential.Region, authHeaders.Credential.Service)
+	if err := checkCredentialScope(report.ReceivedScope, report.ComputedScope); err != nil {
+		return fail(STAGE_SCOPE, err)
+	}
+	seconds, err := strconv.ParseInt(queryValue(query, s.queryParamName("Expires")), 10, 64)
+	if err != nil || seconds < 1 || seconds > int64(MAX_PRESIGN_EXPIRES/time.Second) {
+		return fail(STAGE_DATE, fmt.Errorf("%s: %s", ERROR_INCORRECT_FORMAT_QUERY, ERROR_INVALID_EXPIRES))
+	}
+	if now := s.now(); now.After(signingTime.Add(time.Duration(seconds)*time.Second)) || now.Before(signingTime.Add(-presignClockSkew)) {
+		return fail(STAGE_DATE, fmt.Errorf("%w: %s", auth.ErrSignatureExpired, ERROR_PRESIGNED_EXPIRED))
+	}
+
+	if s.rateLimiter != nil {
+		if err := s.allow(authHeaders.Credential.ACCESS_KEY_ID); err != nil {
+			return fail(STAGE_RATE_LIMIT, err)
+		}
+	}
+	secret, err := s.secretAccessKey(ctx, authHeaders.Credential.ACCESS_KEY_ID, queryValue(query, s.queryParamName("Security-Token")))
+	if err != nil {
+		return fail(STAGE_SECRET, err)
+	}
+
+	canonicalRequest, err := s.presignedCanonicalRequest(req, constraints)
+	if err != nil {
+		return fail(STAGE_CANONICALIZE, err)
+	}
+	report.CanonicalRequestHash = sigv4core.HashPayload([]byte(canonicalRequest))
+	stringToSign := s.stringToSign(authHeaders.Algorithm, signingTime, authHeaders.Credential.Region, authHeaders.Credential.Service, canonicalRequest)
+	signingKey, err := s.signingKeyFromSecret(authHeaders.Algorithm, secret, signingTime, authHeaders.Credential.Region, authHeaders.Credential.Service)
+	if err != nil {
+		return fail(STAGE_SIGNATURE, err)
+	}
+	computedSignature, err := s.generateSignature(authHeaders.Algorithm, signingKey, stringToSign)
+	if err != nil {
+		return fail(STAGE_SIGNATURE, err)
+	}
+	if !hmac.Equal([]byte(computedSignature), []byte(authHeaders.Signature)) {
+		return fail(STAGE_SIGNATURE, fmt.Errorf("%w: %s", auth.ErrSignatureMismatch, ERROR_SIGNATURE_MISMATCH))
+	}
+
+	// Enforce the constraints, once they are known to be authentic
+	if constraints != nil {
+		if err := constraints.enforce(req); err != nil {
+			return fail(STAGE_CONSTRAINTS, err)
+		}
+	}
+
+	report.Verified = true
+	return s.identity(authHeaders.Algorithm, authHeaders.Credential, signingTime, authHeaders.SignedHeaders), nil
+}
+
+// `enforce` checks that the request satisfies the constraints. A request body of unknown length is limited to `MaxContentLength`.
+func (c *PresignConstraints) enforce(req *http.Request) error {
+	if len(c.Methods) > 0 && !slices.ContainsFunc(c.Methods, func(m string) bool { return strings.EqualFold(m, req.Method) }) {
+		return fmt.Errorf("%w: %s: %s", auth.ErrConstraintViolated, ERROR_METHOD_NOT_ALLOWED, req.Method)
+	}
+	if c.PathPrefix != "" && !isWithinPathPrefix(req.URL, c.PathPrefix) {
+		return fmt.Errorf("%w: %s: %s", auth.ErrConstraintViolated, ERROR_PATH_NOT_ALLOWED, req.URL.EscapedPath())
+	}
+	if c.MaxContentLength > 0 {
+		if req.ContentLength > c.MaxContentLength {
+			return fmt.Errorf("%w: %s: %d", auth.ErrConstraintViolated, ERROR_CONTENT_TOO_LONG, req.ContentLength)
+		}
+		if req.ContentLength < 0 && req.Body != nil {
+			req.Body = http.MaxBytesReader(nil, req.Body, c.MaxContentLength)
+		}
+	}
+	return nil
+}
+
+// `isWithinPathPrefix` checks if the path of the URL is within the escaped path `prefix`, compared by whole segments
+// (E.g. `/uploads` matches `/uploads/a` but not `/uploadsX`). The rest of the path must not have an encoded '/' or '\',
+// and the unescaped path (as seen by handlers using `req.URL.Path`) must not have "." or ".." segments, which could escape the prefix.
+func isWithinPathPrefix(u *url.URL, prefix string) bool {
+	path := u.EscapedPath()
+	rest, ok := strings.CutPrefix(path, prefix)
+	if !ok || (rest != "" && !strings.HasSuffix(prefix, "/") && rest[0] != '/') {
+		return false
+	}
+	if rest = strings.ToUpper(rest); strings.Contains(rest, "%2F") || strings.Contains(rest, "%5C") {
+		return false
+	}
+	return !hasDotSegment(u.Path)
+}
+
+// `hasDotSegment` checks if an unescaped path has a "." or ".." segment, separated by '/' or '\', which could escape a path prefix
+func hasDotSegment(path string) bool {
+	for _, segment := range strings.FieldsFunc(path, func(r rune) bool { return r == '/' || r == '\\' }) {
+		if segment == "." || segment == ".." {
+			return true
+		}
+	}
+	return false
+}
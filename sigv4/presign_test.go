@@ -0,0 +1,156 @@
+package sigv4
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jayantasamaddar/go-httpsigner/auth"
+)
+
+// Test that a presigned request is verified within its validity, and rejected when tampered with or expired
+func Test_PresignHTTPRequest(t *testing.T) {
+	server := newSecretRetrievalServer(t, testEnvConfig.SECRET_ACCESS_KEY)
+	signer, _ := NewSigV4Signer("SYM", "sym", "certificatemanager", testEnvConfig, false)
+	verifier, _ := NewSigV4Verifier("SYM", "sym", "certificatemanager", server.URL)
+
+	req, _ := http.NewRequest(http.MethodGet, "http://validate.127.0.0.1.sslip.io/downloads/report.pdf?version=2", nil)
+	if err := signer.(*SigV4).PresignHTTPRequest(req, time.Minute, nil); err != nil {
+		t.Fatal(err)
+	}
+	if req.Header.Get("Authorization") != "" || !strings.Contains(req.URL.RawQuery, "X-sym-Signature=") {
+		t.Fatalf("Expected the signature in the query, got: %q", req.URL.String())
+	}
+
+	// The URL handed to a third party
+	presigned, _ := http.NewRequest(http.MethodGet, req.URL.String(), nil)
+	identity, err := verifier.(*SigV4).AuthenticatePresigned(presigned)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if identity.KeyID != testEnvConfig.ACCESS_KEY_ID {
+		t.Errorf("Unexpected identity: %+v", identity)
+	}
+
+	tampered, _ := http.NewRequest(http.MethodGet, strings.Replace(req.URL.String(), "version=2", "version=3", 1), nil)
+	if _, err := verifier.(*SigV4).AuthenticatePresigned(tampered); !errors.Is(err, auth.ErrSignatureMismatch) {
+		t.Errorf("Expected auth.ErrSignatureMismatch, got: %v", err)
+	}
+	deleted, _ := http.NewRequest(http.MethodDelete, req.URL.String(), nil)
+	if _, err := verifier.(*SigV4).AuthenticatePresigned(deleted); !errors.Is(err, auth.ErrSignatureMismatch) {
+		t.Errorf("Expected auth.ErrSignatureMismatch for another method, got: %v", err)
+	}
+
+	// Expired
+	query := req.URL.Query()
+	query.Set("X-sym-Date", signer.(*SigV4).formatDate(time.Now().Add(-2*time.Minute)))
+	expired, _ := http.NewRequest(http.MethodGet, req.URL.String(), nil)
+	expired.URL.RawQuery = query.Encode()
+	if _, err := verifier.(*SigV4).AuthenticatePresigned(expired); !errors.Is(err, auth.ErrSignatureExpired) {
+		t.Errorf("Expected auth.ErrSignatureExpired, got: %v", err)
+	}
+
+	if err := signer.(*SigV4).PresignHTTPRequest(req, 8*24*time.Hour, nil); err == nil || !strings.Contains(err.Error(), ERROR_INVALID_EXPIRES) {
+		t.Errorf("Expected error: %q, got: %v", ERROR_INVALID_EXPIRES, err)
+	}
+}
+
+// Test that the constraints of a presigned request are enforced, and cannot be widened
+func Test_PresignHTTPRequest_Constraints(t *testing.T) {
+	server := newSecretRetrievalServer(t, testEnvConfig.SECRET_ACCESS_KEY)
+	signer, _ := NewSigV4Signer("SYM", "sym", "certificatemanager", testEnvConfig, false)
+	verifier, _ := NewSigV4Verifier("SYM", "sym", "certificatemanager", server.URL)
+
+	req, _ := http.NewRequest(http.MethodPut, "http://validate.127.0.0.1.sslip.io/uploads/", nil)
+	constraints := &PresignConstraints{Methods: []string{"put", "POST"}, PathPrefix: "/uploads/", MaxContentLength: 1024}
+	if err := signer.(*SigV4).PresignHTTPRequest(req, time.Minute, constraints); err != nil {
+		t.Fatal(err)
+	}
+	presignedURL := func(path string) string {
+		return strings.Replace(req.URL.String(), "/uploads/", path, 1)
+	}
+
+	tests := []struct {
+		method        string
+		path          string
+		contentLength int64
+		err           error
+	}{
+		{http.MethodPut, "/uploads/photo.jpg", 512, nil},
+		{http.MethodPost, "/uploads/2024/photo.jpg", 0, nil},
+		{http.MethodGet, "/uploads/photo.jpg", 0, auth.ErrConstraintViolated},
+		{http.MethodPut, "/private/photo.jpg", 0, auth.ErrConstraintViolated},
+		{http.MethodPut, "/uploads/../private/photo.jpg", 0, auth.ErrConstraintViolated},
+		{http.MethodPut, "/uploads/%2E%2E/private/photo.jpg", 0, auth.ErrConstraintViolated},
+		{http.MethodPut, "/uploads/..%2F..%2Fsecret", 0, auth.ErrConstraintViolated},
+		{http.MethodPut, "/uploads/a%5C..%5Csecret", 0, auth.ErrConstraintViolated},
+		{http.MethodPut, "/uploads/a%2Fb.jpg", 0, auth.ErrConstraintViolated},
+		{http.MethodPut, "/uploads/photo.jpg", 2048, auth.ErrConstraintViolated},
+	}
+	for _, test := range tests {
+		presigned, _ := http.NewRequest(test.method, presignedURL(test.path), nil)
+		presigned.ContentLength = test.contentLength
+		if _, err := verifier.(*SigV4).AuthenticatePresigned(presigned); !errors.Is(err, test.err) {
+			t.Errorf("%s %s: expected: %v, got: %v", test.method, test.path, test.err, err)
+		}
+	}
+
+	// A prefix not ending in '/' matches whole segments only
+	segment, _ := http.NewRequest(http.MethodPut, "http://validate.127.0.0.1.sslip.io/uploads", nil)
+	if err := signer.(*SigV4).PresignHTTPRequest(segment, time.Minute, &PresignConstraints{PathPrefix: "/uploads"}); err != nil {
+		t.Fatal(err)
+	}
+	for path, expected := range map[string]error{"/uploads": nil, "/uploads/photo.jpg": nil, "/uploadsX/photo.jpg": auth.ErrConstraintViolated} {
+		presigned, _ := http.NewRequest(http.MethodPut, strings.Replace(segment.URL.String(), "/uploads?", path+"?", 1), nil)
+		if _, err := verifier.(*SigV4).AuthenticatePresigned(presigned); !errors.Is(err, expected) {
+			t.Errorf("%s with the prefix /uploads: expected: %v, got: %v", path, expected, err)
+		}
+	}
+
+	// Widening the constraints invalidates the signature
+	query := req.URL.Query()
+	query.Set("X-sym-Constraints", "eyJwYXRoX3ByZWZpeCI6Ii8ifQ") // {"path_prefix":"/"}
+	widened, _ := http.NewRequest(http.MethodPut, presignedURL("/private/photo.jpg"), nil)
+	widened.URL.RawQuery = query.Encode()
+	if _, err := verifier.(*SigV4).AuthenticatePresigned(widened); !errors.Is(err, auth.ErrSignatureMismatch) {
+		t.Errorf("Expected auth.ErrSignatureMismatch, got: %v", err)
+	}
+}
+
+// Test that `VerifySignature` and `Explain` accept presigned requests alongside requests signed in the `Authorization` header
+func Test_VerifySignature_Presigned(t *testing.T) {
+	server := newSecretRetrievalServer(t, testEnvConfig.SECRET_ACCESS_KEY)
+	signer, _ := NewSigV4Signer("SYM", "sym", "certificatemanager", testEnvConfig, false)
+	verifier, _ := NewSigV4Verifier("SYM", "sym", "certificatemanager", server.URL)
+
+	req, _ := http.NewRequest(http.MethodGet, "http://validate.127.0.0.1.sslip.io/downloads/report.pdf", nil)
+	if err := signer.(*SigV4).PresignHTTPRequest(req, time.Minute, nil); err != nil {
+		t.Fatal(err)
+	}
+	presigned, _ := http.NewRequest(http.MethodGet, req.URL.String(), nil)
+	if err := verifier.VerifySignature(presigned); err != nil {
+		t.Errorf("Expected the presigned request to be verified, got: %v", err)
+	}
+
+	signed, _ := http.NewRequest(http.MethodGet, "http://validate.127.0.0.1.sslip.io/downloads/report.pdf", nil)
+	if err := signer.SignHTTPRequest(signed); err != nil {
+		t.Fatal(err)
+	}
+	if err := verifier.VerifySignature(signed); err != nil {
+		t.Errorf("Expected the signed request to be verified, got: %v", err)
+	}
+
+	// The expiry is enforced
+	query := req.URL.Query()
+	query.Set("X-sym-Date", signer.(*SigV4).formatDate(time.Now().Add(-2*time.Minute)))
+	expired, _ := http.NewRequest(http.MethodGet, req.URL.String(), nil)
+	expired.URL.RawQuery = query.Encode()
+	if err := verifier.VerifySignature(expired); !errors.Is(err, auth.ErrSignatureExpired) {
+		t.Errorf("Expected auth.ErrSignatureExpired, got: %v", err)
+	}
+	if report, _ := verifier.(*SigV4).Explain(expired); report.FailedStage != STAGE_DATE {
+		t.Errorf("Expected the presigned request to fail at stage %s, got: %s", STAGE_DATE, report.FailedStage)
+	}
+}
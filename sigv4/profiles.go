@@ -0,0 +1,87 @@
+package sigv4
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/go-ini/ini"
+	"github.com/jayantasamaddar/go-httpsigner/auth"
+	"github.com/jayantasamaddar/go-httpsigner/utils"
+)
+
+// Errors
+const (
+	ERROR_NO_PROFILES_FOUND = "No profile with credentials found"
+)
+
+// # Multi-profile signer factory
+//
+// Builds one Signer per profile with credentials found in the `.ini` files (E.g. `credentials` and `config`) of the `dir`,
+// keyed by profile name, for tools operating across many accounts (E.g. backup jobs, auditors).
+// Each Signer is built like `NewSigV4Signer` with `SigV4EnvConfig{GlobalDir: dir, GlobalProfile: profile}`, so profiles
+// inherit keys with `include` (See `utils.ReadIniFile`). Profiles without an access key ID and secret access key (E.g. shared defaults) are skipped.
+//
+// If no `dir` is provided, it defaults to `$HOME/.Lowercase(org)`, like `NewSigV4Signer`.
+func NewSignersFromSharedConfig(org, abbr, service, dir string, hashPayload bool, opts ...Option) (map[string]auth.Signer, error) {
+	if org == "" {
+		org = "AWS"
+	}
+	if dir == "" {
+		homeDir, err := utils.HomeDir()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", ERROR_READ_ENVIRONMENT_VARIABLES, err)
+		}
+		dir = filepath.Join(homeDir, fmt.Sprintf(".%s", strings.ToLower(org)))
+	}
+	profiles, err := sharedConfigProfiles(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	signers := make(map[string]auth.Signer)
+	for _, profile := range profiles {
+		signer, err := NewSigV4Signer(org, abbr, service, &SigV4EnvConfig{GlobalDir: dir, GlobalProfile: profile}, hashPayload, opts...)
+		if err != nil {
+			return nil, fmt.Errorf("profile %s: %w", profile, err)
+		}
+		if env := signer.(*SigV4).env; env.ACCESS_KEY_ID == "" || env.SECRET_ACCESS_KEY == "" {
+			continue
+		}
+		signers[profile] = signer
+	}
+	if len(signers) == 0 {
+		return nil, fmt.Errorf("%s in %s", ERROR_NO_PROFILES_FOUND, dir)
+	}
+	return signers, nil
+}
+
+// `sharedConfigProfiles` returns the names of the profiles of the `.ini` files of the `dir`, read like `NewSigV4Signer` reads them
+func sharedConfigProfiles(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("%s: Could not read from %s", ERROR_NO_CONFIG_FILE_FOUND, dir)
+	}
+	var profiles []string
+	seen := make(map[string]bool)
+	for _, file := range entries {
+		if file.IsDir() {
+			continue
+		}
+		switch strings.ToLower(filepath.Ext(file.Name())) {
+		case "", ".ini", ".conf", ".config":
+			for profile := range utils.ReadIniFile(filepath.Join(dir, file.Name())) {
+				// The implicit section of the keys preceding the first section
+				if profile.Name == ini.DefaultSection && len(profile.Map) == 0 {
+					continue
+				}
+				if !seen[profile.Name] {
+					seen[profile.Name] = true
+					profiles = append(profiles, profile.Name)
+				}
+			}
+		}
+	}
+	return profiles, nil
+}
@@ -0,0 +1,55 @@
+package sigv4
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// Test that a Signer is built per profile with credentials, with the keys of the included profiles
+func Test_NewSignersFromSharedConfig(t *testing.T) {
+	dir := t.TempDir()
+	credentials := `[shared]
+region = ap-south-1
+
+[backup]
+include = shared
+sym_access_key_id = AKIABACKUP
+sym_secret_access_key = backupsecret
+
+[audit]
+include = shared
+sym_access_key_id = AKIAAUDIT
+sym_secret_access_key = auditsecret
+region = eu-west-1
+`
+	if err := os.WriteFile(filepath.Join(dir, "credentials"), []byte(credentials), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	signers, err := NewSignersFromSharedConfig("SYM", "sym", "certificatemanager", dir, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]SigV4EnvConfig{
+		"backup": {ACCESS_KEY_ID: "AKIABACKUP", SECRET_ACCESS_KEY: "backupsecret", REGION: "ap-south-1"},
+		"audit":  {ACCESS_KEY_ID: "AKIAAUDIT", SECRET_ACCESS_KEY: "auditsecret", REGION: "eu-west-1"},
+	}
+	if len(signers) != len(expected) {
+		t.Fatalf("Expected profiles: %v, got: %v", expected, signers)
+	}
+	for profile, env := range expected {
+		signer, ok := signers[profile].(*SigV4)
+		if !ok {
+			t.Errorf("Expected a signer for profile: %s", profile)
+			continue
+		}
+		if signer.env.ACCESS_KEY_ID != env.ACCESS_KEY_ID || signer.env.SECRET_ACCESS_KEY != env.SECRET_ACCESS_KEY || signer.env.REGION != env.REGION {
+			t.Errorf("%s: expected: %+v, got: %+v", profile, env, *signer.env)
+		}
+	}
+
+	if _, err := NewSignersFromSharedConfig("SYM", "sym", "certificatemanager", t.TempDir(), false); err == nil {
+		t.Error("Expected an error for a directory without profiles")
+	}
+}
@@ -0,0 +1,161 @@
+package sigv4
+
+import (
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/jayantasamaddar/go-httpsigner/auth"
+)
+
+// Errors
+const (
+	ERROR_RATE_LIMITED = "rate limit of the access key exceeded"
+)
+
+// # Per-access-key throttling
+//
+// A RateLimiter decides whether a request of the access key is allowed. It is consulted by the Verifier after parsing the `Authorization` header,
+// but before retrieving the secret, protecting the secret backend from a single noisy or compromised client.
+// Implementations must be safe for concurrent use, and may keep their state in a shared store (E.g. Redis) for a fleet of Verifiers.
+type RateLimiter interface {
+	Allow(keyID string) (bool, error)
+}
+
+// Throttle the requests of each access key with the `limiter`. Throttled requests fail with an error wrapping `auth.ErrRateLimited`.
+func WithRateLimiter(limiter RateLimiter) Option {
+	return func(s *SigV4) {
+		s.rateLimiter = limiter
+	}
+}
+
+// `allow` consults the `RateLimiter` for the access key, returning an error wrapping `auth.ErrRateLimited` if the request is throttled
+func (s *SigV4) allow(keyID string) error {
+	allowed, err := s.rateLimiter.Allow(keyID)
+	if err != nil {
+		return err
+	}
+	if !allowed {
+		return fmt.Errorf("%w: %s", auth.ErrRateLimited, ERROR_RATE_LIMITED)
+	}
+	return nil
+}
+
+// A token bucket rate limit: `Rate` tokens per second are added to a bucket holding at most `Burst` tokens, and each request takes one token.
+type RateLimit struct {
+	Rate  float64
+	Burst int
+}
+
+// Bounds of the buckets of a `TokenBucketLimiter`: buckets idle for `idleBucketTTL` are forgotten even if they do not refill (`Rate` of 0),
+// and the least recently used bucket is forgotten beyond `maxBuckets`
+const (
+	maxBuckets    = 4096
+	idleBucketTTL = time.Hour
+)
+
+// TokenBucketLimiter is an in-memory `RateLimiter` keeping a token bucket per access key with its own `RateLimit` (See `SetLimit`).
+// Since the Verifier consults the `RateLimiter` before the access key is authenticated, every other access key is charged to a single bucket
+// limited by the default `RateLimit`, so that a client rotating made-up access key IDs is throttled as one, and the memory is bounded.
+type TokenBucketLimiter struct {
+	mu        sync.Mutex
+	limit     RateLimit
+	limits    map[string]RateLimit
+	buckets   map[string]*tokenBucket
+	unknown   *tokenBucket
+	lastSwept time.Time
+	clock     Clock
+}
+
+type tokenBucket struct {
+	tokens  float64
+	updated time.Time
+}
+
+// Returns a `TokenBucketLimiter` limiting the access keys without their own `RateLimit` by `limit`, together
+func NewTokenBucketLimiter(limit RateLimit) *TokenBucketLimiter {
+	return &TokenBucketLimiter{
+		limit:   limit,
+		limits:  make(map[string]RateLimit),
+		buckets: make(map[string]*tokenBucket),
+	}
+}
+
+// Set the `RateLimit` of an access key, E.g. for each access key of the secret backend, so that it is throttled on its own instead of with the unknown keys
+func (l *TokenBucketLimiter) SetLimit(keyID string, limit RateLimit) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.limits[keyID] = limit
+}
+
+// Read the current time from the `clock` instead of `time.Now`, E.g. the clock of the Verifier (See `WithClock`)
+func (l *TokenBucketLimiter) SetClock(clock Clock) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.clock = clock
+}
+
+// `RateLimiter` implementation
+func (l *TokenBucketLimiter) Allow(keyID string) (bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := clockNow(l.clock)
+	l.sweep(now)
+
+	limit, ok := l.limits[keyID]
+	if !ok {
+		if l.unknown == nil {
+			l.unknown = &tokenBucket{tokens: float64(l.limit.Burst), updated: now}
+		}
+		return l.unknown.take(l.limit, now), nil
+	}
+
+	bucket, ok := l.buckets[keyID]
+	if !ok {
+		if len(l.buckets) >= maxBuckets {
+			l.evictOldest()
+		}
+		bucket = &tokenBucket{tokens: float64(limit.Burst), updated: now}
+		l.buckets[keyID] = bucket
+	}
+	return bucket.take(limit, now), nil
+}
+
+// `take` refills the bucket for the time elapsed, and takes a token if there is one
+func (b *tokenBucket) take(limit RateLimit, now time.Time) bool {
+	b.tokens = math.Min(float64(limit.Burst), b.tokens+now.Sub(b.updated).Seconds()*limit.Rate)
+	b.updated = now
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// `sweep` forgets the buckets that have been idle long enough to be full again, or for `idleBucketTTL`, at most once a minute
+func (l *TokenBucketLimiter) sweep(now time.Time) {
+	if now.Sub(l.lastSwept) < time.Minute {
+		return
+	}
+	l.lastSwept = now
+	for keyID, bucket := range l.buckets {
+		limit := l.limits[keyID]
+		idle := now.Sub(bucket.updated)
+		if idle >= idleBucketTTL || limit.Rate > 0 && idle.Seconds()*limit.Rate >= float64(limit.Burst) {
+			delete(l.buckets, keyID)
+		}
+	}
+}
+
+// `evictOldest` forgets the least recently used bucket
+func (l *TokenBucketLimiter) evictOldest() {
+	var oldest string
+	var oldestUpdated time.Time
+	for keyID, bucket := range l.buckets {
+		if oldestUpdated.IsZero() || bucket.updated.Before(oldestUpdated) {
+			oldest, oldestUpdated = keyID, bucket.updated
+		}
+	}
+	delete(l.buckets, oldest)
+}
@@ -0,0 +1,72 @@
+package sigv4
+
+import (
+	"bytes"
+	"net/http"
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/jayantasamaddar/go-httpsigner/sigv4core"
+)
+
+// Test that a request signed without an `*http.Request` is verified, and signed like `SignHTTPRequestAt` would
+func Test_SignRaw(t *testing.T) {
+	server := newSecretRetrievalServer(t, testEnvConfig.SECRET_ACCESS_KEY)
+	signingTime := time.Date(2024, time.March, 15, 10, 30, 0, 0, time.UTC)
+	payload := []byte(`{"first_name":"Bruce","last_name":"Wayne"}`)
+	signer, err := NewSigV4Signer("SYM", "sym", "certificatemanager", testEnvConfig, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	verifier, err := NewSigV4Verifier("SYM", "sym", "certificatemanager", server.URL, WithClock(FixedClock(signingTime)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := signer.(*SigV4)
+
+	u, _ := url.Parse("http://validate.127.0.0.1.sslip.io/api/cmagent?b=2&a=1")
+	headers := http.Header{
+		"Content-Type":   {"application/json"},
+		"Content-Length": {strconv.Itoa(len(payload))},
+	}
+	sig, err := s.SignRaw(SignableRequest{Method: http.MethodPost, URL: u, Headers: headers, PayloadHash: sigv4core.HashPayload(payload), Time: signingTime})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if headers.Get("Authorization") != "" {
+		t.Error("Expected the headers of the signable request not to be modified")
+	}
+	if sig.SignedHeaders != "content-length;content-type;host;x-sym-date" {
+		t.Errorf("Unexpected SignedHeaders: %s", sig.SignedHeaders)
+	}
+
+	req, _ := http.NewRequest(http.MethodPost, u.String(), bytes.NewReader(payload))
+	req.Header.Set("Content-Type", "application/json")
+	reference := req.Clone(req.Context())
+	reference.Body, _ = reference.GetBody()
+	if err := s.SignHTTPRequestAt(reference, signingTime); err != nil {
+		t.Fatal(err)
+	}
+	if got := sig.Headers.Get("Authorization"); got != reference.Header.Get("Authorization") {
+		t.Errorf("Expected the Authorization header of SignHTTPRequestAt, got:\n%s\n%s", got, reference.Header.Get("Authorization"))
+	}
+	for name, values := range sig.Headers {
+		req.Header[name] = values
+	}
+	if err := verifier.VerifySignature(req); err != nil {
+		t.Error(err)
+	}
+
+	// Invalid requests
+	for name, r := range map[string]SignableRequest{
+		"no URL":                 {Method: http.MethodGet},
+		"invalid payload hash":   {Method: http.MethodGet, URL: u, PayloadHash: "abc"},
+		"invalid Content-Length": {Method: http.MethodGet, URL: u, Headers: http.Header{"Content-Length": {"-1"}}},
+	} {
+		if _, err := s.SignRaw(r); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
@@ -0,0 +1,186 @@
+package sigv4
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/jayantasamaddar/go-httpsigner/auth"
+)
+
+// Errors
+const (
+	ERROR_REQUEST_OUTSIDE_REPLAY_WINDOW = "request date is outside of the replay window"
+	ERROR_REQUEST_REPLAYED              = "request has already been verified"
+)
+
+// # Replay protection
+//
+// A ReplayStore records the nonces of verified requests. The signature of a request is used as its nonce.
+// `Seen` records the nonce until `expires` and reports whether it was already recorded.
+// Implementations must be safe for concurrent use.
+type ReplayStore interface {
+	Seen(nonce string, expires time.Time) (bool, error)
+}
+
+// Reject requests dated further than `window` from the time of verification, and requests whose signature has been seen
+// by the `store` within the window. See `NewMemoryReplayStore` and `NewBloomReplayStore`.
+func WithReplayStore(store ReplayStore, window time.Duration) Option {
+	return func(s *SigV4) {
+		s.replayStore = store
+		s.replayWindow = window
+	}
+}
+
+// `checkReplay` rejects a signature dated outside the replay window, or already seen by the replay store. See `WithReplayStore`.
+func (s *SigV4) checkReplay(signature string, signingTime time.Time) error {
+	if age := s.now().Sub(signingTime); age > s.replayWindow || age < -s.replayWindow {
+		return fmt.Errorf("%w: %s", auth.ErrSignatureExpired, ERROR_REQUEST_OUTSIDE_REPLAY_WINDOW)
+	}
+	seen, err := s.replayStore.Seen(signature, signingTime.Add(s.replayWindow))
+	if err != nil {
+		return err
+	}
+	if seen {
+		return fmt.Errorf("%w: %s", auth.ErrRequestReplayed, ERROR_REQUEST_REPLAYED)
+	}
+	return nil
+}
+
+// MemoryReplayStore is an exact `ReplayStore` keeping every nonce in memory until it expires.
+type MemoryReplayStore struct {
+	mu     sync.Mutex
+	nonces map[string]time.Time
+	pruned time.Time
+	clock  Clock
+}
+
+// Returns an empty `MemoryReplayStore`
+func NewMemoryReplayStore() *MemoryReplayStore {
+	return &MemoryReplayStore{nonces: make(map[string]time.Time)}
+}
+
+// Read the current time from the `clock` instead of `time.Now`, E.g. the clock of the Verifier (See `WithClock`)
+func (m *MemoryReplayStore) SetClock(clock Clock) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.clock = clock
+}
+
+// `ReplayStore` implementation
+func (m *MemoryReplayStore) Seen(nonce string, expires time.Time) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := clockNow(m.clock)
+
+	// Prune expired nonces at most once a second, to amortize the cost over the requests
+	if now.Sub(m.pruned) > time.Second {
+		for n, e := range m.nonces {
+			if now.After(e) {
+				delete(m.nonces, n)
+			}
+		}
+		m.pruned = now
+	}
+
+	if e, ok := m.nonces[nonce]; ok && !now.After(e) {
+		return true, nil
+	}
+	m.nonces[nonce] = expires
+	return false, nil
+}
+
+// # Memory-bounded nonce bloom filter
+//
+// BloomReplayStore is a probabilistic `ReplayStore` for very high-traffic Verifiers where exact nonce storage is too expensive.
+// Its memory is fixed at construction. It may falsely report a fresh nonce as seen (rejecting a legitimate request) at the configured rate,
+// but never reports a seen nonce as fresh within the rotation window.
+//
+// Nonces are recorded in the current of two filters, which are rotated every `window`, so that a nonce is remembered for at least `window`
+// and at most twice as long. The `expires` argument of `Seen` is ignored. The `window` should be at least the replay window of the Verifier.
+type BloomReplayStore struct {
+	mu        sync.Mutex
+	window    time.Duration
+	rotated   time.Time
+	current   []uint64
+	previous  []uint64
+	bits      uint64
+	hashCount int
+	clock     Clock
+}
+
+// Returns a `BloomReplayStore` sized for `capacity` nonces per `window` at the false positive rate `fpRate` (E.g. 0.001).
+func NewBloomReplayStore(capacity int, fpRate float64, window time.Duration) *BloomReplayStore {
+	if capacity < 1 {
+		capacity = 1
+	}
+	if fpRate <= 0 || fpRate >= 1 {
+		fpRate = 0.001
+	}
+	// Optimal number of bits and hash functions of a bloom filter
+	bits := uint64(math.Ceil(-float64(capacity) * math.Log(fpRate) / (math.Ln2 * math.Ln2)))
+	bits = (bits + 63) / 64 * 64
+	hashCount := int(math.Max(1, math.Round(float64(bits)/float64(capacity)*math.Ln2)))
+
+	return &BloomReplayStore{
+		window:    window,
+		rotated:   time.Now(),
+		current:   make([]uint64, bits/64),
+		previous:  make([]uint64, bits/64),
+		bits:      bits,
+		hashCount: hashCount,
+	}
+}
+
+// Read the current time from the `clock` instead of `time.Now`, E.g. the clock of the Verifier (See `WithClock`).
+// The current rotation window starts at the current time of the `clock`.
+func (b *BloomReplayStore) SetClock(clock Clock) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.clock = clock
+	b.rotated = clockNow(clock)
+}
+
+// `ReplayStore` implementation
+func (b *BloomReplayStore) Seen(nonce string, _ time.Time) (bool, error) {
+	sum := sha256.Sum256([]byte(nonce))
+	h1, h2 := binary.BigEndian.Uint64(sum[:8]), binary.BigEndian.Uint64(sum[8:16])|1
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.rotate(clockNow(b.clock))
+
+	seenCurrent, seenPrevious := true, true
+	for i := 0; i < b.hashCount; i++ {
+		// Double hashing: the i-th hash is h1 + i*h2
+		bit := (h1 + uint64(i)*h2) % b.bits
+		word, mask := bit/64, uint64(1)<<(bit%64)
+		if b.current[word]&mask == 0 {
+			seenCurrent = false
+			b.current[word] |= mask
+		}
+		if b.previous[word]&mask == 0 {
+			seenPrevious = false
+		}
+	}
+	return seenCurrent || seenPrevious, nil
+}
+
+// `rotate` discards the previous filter once the current one covers a full `window`
+func (b *BloomReplayStore) rotate(now time.Time) {
+	elapsed := now.Sub(b.rotated)
+	if elapsed < b.window {
+		return
+	}
+	if elapsed >= 2*b.window {
+		// Both filters are stale
+		clear(b.previous)
+	} else {
+		copy(b.previous, b.current)
+	}
+	clear(b.current)
+	b.rotated = now
+}
@@ -0,0 +1,83 @@
+package sigv4
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/jayantasamaddar/go-httpsigner/auth"
+)
+
+// Test that a replayed request is rejected by the Verifier, with both the exact and the bloom filter stores
+func Test_VerifySignature_Replay(t *testing.T) {
+	server := newSecretRetrievalServer(t, testEnvConfig.SECRET_ACCESS_KEY)
+	signer, _ := NewSigV4Signer("SYM", "sym", "certificatemanager", testEnvConfig, false)
+
+	stores := map[string]ReplayStore{
+		"memory": NewMemoryReplayStore(),
+		"bloom":  NewBloomReplayStore(1000, 0.001, time.Minute),
+	}
+	for name, store := range stores {
+		verifier, _ := NewSigV4Verifier("SYM", "sym", "certificatemanager", server.URL, WithReplayStore(store, time.Minute))
+
+		req, _ := http.NewRequest(http.MethodGet, "http://validate.127.0.0.1.sslip.io/api/cmagent", nil)
+		if err := signer.SignHTTPRequest(req); err != nil {
+			t.Fatal(err)
+		}
+		if err := verifier.VerifySignature(req); err != nil {
+			t.Errorf("%s: expected first verification to succeed, got: %v", name, err)
+		}
+		if err := verifier.VerifySignature(req); !errors.Is(err, auth.ErrRequestReplayed) {
+			t.Errorf("%s: expected auth.ErrRequestReplayed, got: %v", name, err)
+		}
+	}
+
+	// Outside of the replay window
+	verifier, _ := NewSigV4Verifier("SYM", "sym", "certificatemanager", server.URL, WithReplayStore(NewMemoryReplayStore(), time.Nanosecond))
+	req, _ := http.NewRequest(http.MethodGet, "http://validate.127.0.0.1.sslip.io/api/cmagent", nil)
+	if err := signer.SignHTTPRequest(req); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(time.Millisecond)
+	if err := verifier.VerifySignature(req); !errors.Is(err, auth.ErrSignatureExpired) {
+		t.Errorf("Expected auth.ErrSignatureExpired, got: %v", err)
+	}
+}
+
+// Test that the bloom filter remembers nonces for a window, has a bounded false positive rate, and forgets nonces after two rotations
+func Test_BloomReplayStore(t *testing.T) {
+	const capacity = 10000
+	store := NewBloomReplayStore(capacity, 0.01, time.Hour)
+
+	for i := 0; i < capacity; i++ {
+		_, _ = store.Seen(fmt.Sprintf("nonce-%d", i), time.Time{})
+	}
+	for i := 0; i < capacity; i++ {
+		if seen, _ := store.Seen(fmt.Sprintf("nonce-%d", i), time.Time{}); !seen {
+			t.Fatalf("Expected nonce-%d to be seen", i)
+		}
+	}
+
+	falsePositives := 0
+	for i := 0; i < capacity; i++ {
+		if seen, _ := store.Seen(fmt.Sprintf("fresh-%d", i), time.Time{}); seen {
+			falsePositives++
+		}
+	}
+	// The filter fills up to twice its capacity while checking, allow some slack over the configured rate
+	if rate := float64(falsePositives) / capacity; rate > 0.1 {
+		t.Errorf("False positive rate too high: %f", rate)
+	}
+
+	// Rotation
+	store.rotated = store.rotated.Add(-time.Hour)
+	if seen, _ := store.Seen("nonce-0", time.Time{}); !seen {
+		t.Error("Expected nonce-0 to be remembered from the previous filter")
+	}
+	store.rotated = store.rotated.Add(-2 * time.Hour)
+	if seen, _ := store.Seen("nonce-1", time.Time{}); seen {
+		t.Error("Expected nonce-1 to be forgotten after two rotations")
+	}
+}
@@ -0,0 +1,55 @@
+package sigv4
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/jayantasamaddar/go-httpsigner/rfc9421"
+)
+
+// Errors
+const (
+	ERROR_BODY_NOT_REPLAYABLE = "request body cannot be replayed: req.GetBody is not set"
+)
+
+// # Re-signing retried requests
+//
+// Signs a request again before it is retried, E.g. by retry logic around `http.Client.Do`, with a fresh body obtained from `req.GetBody`,
+// since the body of the earlier attempt was consumed by the transport. The signature of the earlier attempt is replaced,
+// and the request ID is preserved (See `WithRequestID`), so that the attempts are recognizably the same request.
+//
+// `req.GetBody` is set by `http.NewRequest` for bodies of type `*bytes.Buffer`, `*bytes.Reader` and `*strings.Reader`,
+// and by the Signer for any other body it buffered while signing the first attempt. Fails with `ERROR_BODY_NOT_REPLAYABLE`
+// if the request has a body and `req.GetBody` is not set.
+//
+// E.g.
+//
+//	err := signer.SignHTTPRequest(req)
+//	res, err := client.Do(req)
+//	for attempt := 1; err != nil && attempt < 3; attempt++ {
+//		if err = signer.(*sigv4.SigV4).ResignHTTPRequest(req); err == nil {
+//			res, err = client.Do(req)
+//		}
+//	}
+func (s *SigV4) ResignHTTPRequest(req *http.Request) error {
+	if req.Body != nil && req.Body != http.NoBody {
+		if req.GetBody == nil {
+			return fmt.Errorf(ERROR_BODY_NOT_REPLAYABLE)
+		}
+		body, err := req.GetBody()
+		if err != nil {
+			return err
+		}
+		req.Body = body
+	}
+	// The signature of the earlier attempt is not an earlier hop of a signature chain (See `WithSignatureChaining`)
+	req.Header.Del("Authorization")
+	if s.fallbackAlgorithm != "" {
+		req.Header.Del(s.fallbackHeaderName())
+	}
+	if s.messageSignatureLabel != "" {
+		// Otherwise signed by the SigV4 signature of the attempt
+		rfc9421.RemoveSignature(req.Header, s.messageSignatureLabel)
+	}
+	return s.SignHTTPRequest(req)
+}
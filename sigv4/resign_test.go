@@ -0,0 +1,97 @@
+package sigv4
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// Test that a request is signed again with a fresh body after its body was consumed by an attempt
+func Test_ResignHTTPRequest(t *testing.T) {
+	server := newSecretRetrievalServer(t, testEnvConfig.SECRET_ACCESS_KEY)
+	payload := `{"first_name":"Bruce","last_name":"Wayne"}`
+	signer, err := NewSigV4Signer("SYM", "sym", "certificatemanager", testEnvConfig, false, WithRequestID(""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	verifier, err := NewSigV4Verifier("SYM", "sym", "certificatemanager", server.URL, WithRequestID(""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := signer.(*SigV4)
+
+	// A body `http.NewRequest` does not set `req.GetBody` for
+	req, _ := http.NewRequest(http.MethodPost, "http://validate.127.0.0.1.sslip.io/api/cmagent", io.NopCloser(strings.NewReader(payload)))
+	if err := s.ResignHTTPRequest(req); err == nil || err.Error() != ERROR_BODY_NOT_REPLAYABLE {
+		t.Errorf("Expected error %q, got: %v", ERROR_BODY_NOT_REPLAYABLE, err)
+	}
+	if err := s.SignHTTPRequest(req); err != nil {
+		t.Fatal(err)
+	}
+	if req.GetBody == nil {
+		t.Fatal("Expected req.GetBody to be set for the buffered body")
+	}
+	requestID := req.Header.Get("X-Sym-Request-Id")
+
+	for attempt := 1; attempt <= 3; attempt++ {
+		// The attempt consumes the body
+		if b, _ := io.ReadAll(req.Body); string(b) != payload {
+			t.Fatalf("Attempt %d: expected the payload, got: %s", attempt, b)
+		}
+		if err := s.ResignHTTPRequest(req); err != nil {
+			t.Fatal(err)
+		}
+		if got := req.Header.Values("Authorization"); len(got) != 1 || got[0] == "" {
+			t.Errorf("Attempt %d: expected one Authorization header, got: %q", attempt, got)
+		}
+		if got := req.Header.Get("X-Sym-Request-Id"); got != requestID {
+			t.Errorf("Attempt %d: expected the request ID %q to be preserved, got: %q", attempt, requestID, got)
+		}
+		if err := verifier.VerifySignature(req); err != nil {
+			t.Errorf("Attempt %d: %v", attempt, err)
+		}
+	}
+
+	// A body `http.NewRequest` sets `req.GetBody` for
+	req, _ = http.NewRequest(http.MethodPut, "http://validate.127.0.0.1.sslip.io/api/cmagent", bytes.NewReader([]byte(payload)))
+	if err := s.SignHTTPRequest(req); err != nil {
+		t.Fatal(err)
+	}
+	_, _ = io.ReadAll(req.Body)
+	if err := s.ResignHTTPRequest(req); err != nil {
+		t.Fatal(err)
+	}
+	if err := verifier.VerifySignature(req); err != nil {
+		t.Error(err)
+	}
+}
+
+// Test that the RFC 9421 signature of the earlier attempt is replaced, and not signed by the SigV4 signature of the next attempt
+func Test_ResignHTTPRequest_MessageSignature(t *testing.T) {
+	server := newSecretRetrievalServer(t, testEnvConfig.SECRET_ACCESS_KEY)
+	signer, _ := NewSigV4Signer("SYM", "sym", "certificatemanager", testEnvConfig, false, WithMessageSignature("sig1"))
+	verifier, _ := NewSigV4Verifier("SYM", "sym", "certificatemanager", server.URL)
+	s := signer.(*SigV4)
+
+	req, _ := http.NewRequest(http.MethodPost, "http://validate.127.0.0.1.sslip.io/api/cmagent", strings.NewReader(`{"hello": "world"}`))
+	if err := s.SignHTTPRequest(req); err != nil {
+		t.Fatal(err)
+	}
+	_, _ = io.ReadAll(req.Body)
+	if err := s.ResignHTTPRequest(req); err != nil {
+		t.Fatal(err)
+	}
+	for _, header := range []string{"Signature-Input", "Signature"} {
+		if got := strings.Join(req.Header.Values(header), ", "); strings.Count(got, "sig1=") != 1 {
+			t.Errorf("Expected one sig1 member in %s, got: %q", header, got)
+		}
+	}
+	if strings.Contains(req.Header.Get("Authorization"), "signature") {
+		t.Errorf("Expected the RFC 9421 signature not to be signed, got: %s", req.Header.Get("Authorization"))
+	}
+	if err := verifier.VerifySignature(req); err != nil {
+		t.Error(err)
+	}
+}
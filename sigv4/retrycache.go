@@ -0,0 +1,126 @@
+package sigv4
+
+import (
+	"crypto/sha256"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// # Retry signing cache
+//
+// Memoizes the `CanonicalRequest` of up to `maxEntries` requests, so that a request retried identically (same method, URL, headers and
+// payload hash) within the same minute, E.g. by a retrying client through the signing transport (See `httpsigner.NewTransport`),
+// is signed again without being canonicalized again. The signing key is memoized as well (See `WithSigningKeyCache`, enabled with
+// `maxEntries` keys unless configured), so that retry storms cost a hash of the request and the HMACs of the signature.
+//
+// Each retry is still signed with its own date, hence its own signature, so that Verifiers with replay protection accept it (See `WithReplayStore`).
+// The payload is still hashed on every attempt, as the payload hash identifies the request. The cache is emptied when full.
+func WithRetrySigningCache(maxEntries int) Option {
+	return func(s *SigV4) {
+		s.retries = &retryCache{maxEntries: max(maxEntries, 1), entries: make(map[[sha256.Size]byte]retryEntry)}
+		if s.signingKeys == nil {
+			WithSigningKeyCache(maxEntries)(s)
+		}
+	}
+}
+
+// A retryCache holds the canonical requests of recently signed requests, by the fingerprint of the request. See `WithRetrySigningCache`.
+type retryCache struct {
+	mu         sync.Mutex
+	maxEntries int
+	entries    map[[sha256.Size]byte]retryEntry
+}
+
+// A retryEntry holds a `CanonicalRequest` split around the value of the Date Header, which differs between the attempts
+type retryEntry struct {
+	beforeDate, afterDate string
+	signedHeaders         string
+}
+
+func (c *retryCache) get(id [sha256.Size]byte) (retryEntry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.entries[id]
+	return entry, ok
+}
+
+func (c *retryCache) put(id [sha256.Size]byte, entry retryEntry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.entries) >= c.maxEntries {
+		clear(c.entries)
+	}
+	c.entries[id] = entry
+}
+
+// Returns the number of cached canonical requests
+func (c *retryCache) len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.entries)
+}
+
+// `retriedCanonicalRequest` builds the `CanonicalRequest` like `canonicalRequestWithPayload`, reusing the canonical request of
+// an identical request signed within the same minute if the retry signing cache is enabled. The Date Header must already be set.
+func (s *SigV4) retriedCanonicalRequest(req *http.Request, signingTime time.Time, payloadHash string, contentLength int64) (canonicalRequest, signedHeaders string, err error) {
+	if s.retries == nil {
+		return s.canonicalRequestWithPayload(req, payloadHash, contentLength)
+	}
+
+	date := s.formatDate(signingTime)
+	id := s.retryFingerprint(req, signingTime, payloadHash, contentLength)
+	if entry, ok := s.retries.get(id); ok {
+		return entry.beforeDate + date + entry.afterDate, entry.signedHeaders, nil
+	}
+
+	cr, sh, err := s.canonicalRequestWithPayload(req, payloadHash, contentLength)
+	if err != nil {
+		return "", "", err
+	}
+	// The Date Header is always signed, so its canonical line is found unless the value was rewritten
+	line := "\n" + strings.ToLower(s.dateHeader()) + ":"
+	if i := strings.Index(cr, line+date+"\n"); i >= 0 {
+		i += len(line)
+		s.retries.put(id, retryEntry{beforeDate: cr[:i], afterDate: cr[i+len(date):], signedHeaders: sh})
+	}
+	return cr, sh, nil
+}
+
+// `retryFingerprint` hashes every input of the `CanonicalRequest` but the value of the Date Header, and the minute of the `signingTime`.
+// The inputs are length-prefixed, so that no two requests share a fingerprint.
+func (s *SigV4) retryFingerprint(req *http.Request, signingTime time.Time, payloadHash string, contentLength int64) [sha256.Size]byte {
+	h := sha256.New()
+	write := func(str string) {
+		h.Write([]byte(strconv.Itoa(len(str)) + ":" + str))
+	}
+	write(req.Method)
+	write(req.URL.EscapedPath())
+	write(req.URL.RawQuery)
+	write(s.canonicalHost(req))
+	write(payloadHash)
+	write(strconv.FormatInt(contentLength, 10))
+	write(strconv.FormatInt(signingTime.Truncate(time.Minute).Unix(), 10))
+
+	names := make([]string, 0, len(req.Header))
+	for name := range req.Header {
+		if !strings.EqualFold(name, s.dateHeader()) {
+			names = append(names, name)
+		}
+	}
+	slices.Sort(names)
+	for _, name := range names {
+		write(name)
+		write(strconv.Itoa(len(req.Header[name])))
+		for _, value := range req.Header[name] {
+			write(value)
+		}
+	}
+
+	var id [sha256.Size]byte
+	h.Sum(id[:0])
+	return id
+}
@@ -0,0 +1,62 @@
+package sigv4
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Test that identical requests signed within the same minute reuse the canonical request, and are each signed with their own date
+func Test_WithRetrySigningCache(t *testing.T) {
+	server := newSecretRetrievalServer(t, testEnvConfig.SECRET_ACCESS_KEY)
+	signer, _ := NewSigV4Signer("SYM", "sym", "certificatemanager", testEnvConfig, true, WithRetrySigningCache(8))
+	verifier, _ := NewSigV4Verifier("SYM", "sym", "certificatemanager", server.URL, WithReplayStore(NewMemoryReplayStore(), time.Minute))
+	s := signer.(*SigV4)
+	if s.signingKeys == nil {
+		t.Error("Expected the signing key cache to be enabled")
+	}
+
+	newRequest := func(body string) *http.Request {
+		req, _ := http.NewRequest(http.MethodPost, "http://validate.127.0.0.1.sslip.io/api/cmagent?q=1", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		return req
+	}
+	signingTime := time.Now().Truncate(time.Minute)
+	var signatures []string
+	for i, test := range []struct {
+		body    string
+		offset  time.Duration
+		entries int
+	}{
+		{`{"a":1}`, time.Second, 1},
+		{`{"a":1}`, 2 * time.Second, 1}, // Retried within the minute
+		{`{"a":2}`, 3 * time.Second, 2}, // Another payload
+		{`{"a":1}`, time.Minute, 3},     // Retried in the next minute
+	} {
+		req := newRequest(test.body)
+		trace := new(signingTrace)
+		if err := s.signHTTPRequestAt(req.Context(), req, signingTime.Add(test.offset), trace); err != nil {
+			t.Fatal(err)
+		}
+		if entries := s.retries.len(); entries != test.entries {
+			t.Errorf("%d: expected %d cached canonical requests, got: %d", i, test.entries, entries)
+		}
+
+		// The cached canonical request is the one computed without the cache
+		uncached := newRequest(test.body)
+		uncached.Header = req.Header.Clone()
+		uncached.Header.Del("Authorization")
+		payloadHash, contentLength, _ := s.payloadHash(uncached.Context(), uncached)
+		if cr, _, _ := s.canonicalRequestWithPayload(uncached, payloadHash, contentLength); cr != trace.canonicalRequest {
+			t.Errorf("%d: expected the canonical request %q, got: %q", i, cr, trace.canonicalRequest)
+		}
+		if err := verifier.VerifySignature(req); err != nil {
+			t.Errorf("%d: %v", i, err)
+		}
+		signatures = append(signatures, req.Header.Get("Authorization"))
+	}
+	if signatures[0] == signatures[1] {
+		t.Error("Expected each retry to be signed with its own signature")
+	}
+}
@@ -0,0 +1,151 @@
+package sigv4
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jayantasamaddar/go-httpsigner/auth"
+	"github.com/jayantasamaddar/go-httpsigner/sigv4core"
+)
+
+// The maximum number of credential scopes cached for a day, as the Verifier looks up the scopes of the region and service
+// of unauthenticated requests. The scopes of other regions and services are formatted on each lookup.
+const maxCachedScopes = 64
+
+// `credentialScopeCache` caches the scope date and the credential scopes of the current day,
+// since they are the same for every request signed on a given day. The cache is invalidated when the day rolls over.
+type credentialScopeCache struct {
+	mu     sync.RWMutex
+	day    time.Time         // Midnight of the cached day
+	date   string            // Format: YYYYMMDD
+	scopes map[string]string // Credential scopes by `region/service`
+	// Format the date as the zero-padded UTC date. See `WithStrictAWSMode`.
+	zeroPadded bool
+	// The termination string of the credential scopes. `DEFAULT_SCOPE_TERMINATOR` if empty. See `WithScopeTerminator`.
+	terminator string
+}
+
+// `get` returns the credential scope for the day of `t`, the `region` and the `service`.
+// Only the scopes of the day of `now` are cached, so that a request dated in the future does not move the cached day past the current day.
+func (c *credentialScopeCache) get(now, t time.Time, region, service string) string {
+	if c.zeroPadded {
+		t, now = t.UTC(), now.UTC()
+	}
+	day, today := scopeDay(t), scopeDay(now)
+	if !day.Equal(today) {
+		return formatCredentialScope(formatScopeDate(t, c.zeroPadded), region, service, c.terminator)
+	}
+	key := region + "/" + service
+
+	c.mu.RLock()
+	if c.day.Equal(day) {
+		if scope, ok := c.scopes[key]; ok {
+			c.mu.RUnlock()
+			return scope
+		}
+	}
+	c.mu.RUnlock()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.day.Equal(day) {
+		// Day rollover: Invalidate the cache
+		c.day = day
+		c.date = formatScopeDate(t, c.zeroPadded)
+		c.scopes = make(map[string]string)
+	}
+	scope := formatCredentialScope(c.date, region, service, c.terminator)
+	if len(c.scopes) < maxCachedScopes {
+		c.scopes[key] = scope
+	}
+	return scope
+}
+
+// `scopeDay` returns the calendar day of `t` in its location, as midnight UTC for comparisons
+func scopeDay(t time.Time) time.Time {
+	YYYY, MM, DD := t.Date()
+	return time.Date(YYYY, MM, DD, 0, 0, 0, 0, time.UTC)
+}
+
+// `checkCredentialScope` checks that the `received` credential scope (E.g. of the `Authorization` header) is the scope `computed` from the signing date,
+// as the signature is computed with the latter: a scope with another date or terminator is never authentic, hence is a signature mismatch.
+func checkCredentialScope(received, computed string) error {
+	if received != computed {
+		return fmt.Errorf("%w: %s: %q, expected: %q", auth.ErrSignatureMismatch, ERROR_SCOPE_MISMATCH, received, computed)
+	}
+	return nil
+}
+
+// `formatScopeDate` formats the date used in the credential scope: the zero-padded UTC date if `zeroPadded` (E.g. `20240309`),
+// else the legacy unpadded date (E.g. `202439`)
+func formatScopeDate(t time.Time, zeroPadded bool) string {
+	if zeroPadded {
+		return t.UTC().Format(AWS_SCOPE_DATE_FORMAT)
+	}
+	YYYY, MM, DD := t.Date()
+	return fmt.Sprintf("%d%d%d", YYYY, MM, DD)
+}
+
+// `formatCredentialScope` formats the credential scope: YYYYMMDD/region/service/terminator. The `terminator` defaults to `DEFAULT_SCOPE_TERMINATOR`.
+func formatCredentialScope(date, region, service, terminator string) string {
+	if terminator == "" {
+		terminator = DEFAULT_SCOPE_TERMINATOR
+	}
+	return sigv4core.CredentialScope(date, region, service, terminator)
+}
+
+// The termination string of the credential scope, and the prefix of the secret in the derivation of the signing key, unless configured otherwise
+const (
+	DEFAULT_SCOPE_TERMINATOR   = "aws4_request"
+	DEFAULT_SIGNING_KEY_PREFIX = "AWS4"
+)
+
+// # Credential scope termination string
+//
+// Terminates the credential scope with `terminator` (E.g. `zen4_request`) instead of `DEFAULT_SCOPE_TERMINATOR`, and derives the signing key
+// from the secret prefixed with the uppercased org followed by "4" (E.g. `ZEN4` for the org "ZEN") instead of `DEFAULT_SIGNING_KEY_PREFIX`,
+// so that signatures of an org are never valid for the scheme of another org, E.g. AWS.
+//
+//	DateKey    = HMAC-SHA256("ZEN4" + secret, date)
+//	SigningKey = HMAC-SHA256(DateRegionServiceKey, "zen4_request")
+//
+// The `terminator` must only contain lowercase letters, digits and '_'. The constructors return an `*ErrInvalidTerminator` otherwise.
+// Configure the Signer and the Verifier with the same terminator: the Verifier rejects credential scopes with another terminator
+// (See `ERROR_SCOPE_MISMATCH`), of signed headers, presigned URLs, POST policies and envelopes alike.
+func WithScopeTerminator(terminator string) Option {
+	return func(s *SigV4) {
+		s.terminator = terminator
+		s.scopes.terminator = terminator
+	}
+}
+
+// `isValidTerminator` checks if the terminator only contains lowercase letters, digits and '_'
+func isValidTerminator(terminator string) bool {
+	if terminator == "" {
+		return false
+	}
+	for _, r := range terminator {
+		if !(r >= 'a' && r <= 'z') && !(r >= '0' && r <= '9') && r != '_' {
+			return false
+		}
+	}
+	return true
+}
+
+// `scopeTerminator` returns the termination string of the credential scope. See `WithScopeTerminator`.
+func (s *SigV4) scopeTerminator() string {
+	if s.terminator == "" {
+		return DEFAULT_SCOPE_TERMINATOR
+	}
+	return s.terminator
+}
+
+// `signingKeyPrefix` returns the prefix of the secret in the derivation of the signing key: `[ORG]4` if a terminator is configured. See `WithScopeTerminator`.
+func (s *SigV4) signingKeyPrefix() string {
+	if s.terminator == "" {
+		return DEFAULT_SIGNING_KEY_PREFIX
+	}
+	return strings.ToUpper(s.org) + "4"
+}
@@ -0,0 +1,150 @@
+package sigv4
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jayantasamaddar/go-httpsigner/auth"
+	"github.com/jayantasamaddar/go-httpsigner/sigv4core"
+)
+
+// Test that the credential scope cache is invalidated when the day rolls over, and is bounded
+func Test_CredentialScopeCache_Rollover(t *testing.T) {
+	var c credentialScopeCache
+
+	day1 := time.Date(2024, time.March, 9, 23, 59, 59, 0, time.UTC)
+	day2 := day1.Add(2 * time.Second)
+
+	if scope := c.get(day1, day1, "ap-south-1", "s3"); scope != "202439/ap-south-1/s3/aws4_request" {
+		t.Errorf("Unexpected scope: %q", scope)
+	}
+	if scope := c.get(day2, day2, "ap-south-1", "s3"); scope != "2024310/ap-south-1/s3/aws4_request" {
+		t.Errorf("Unexpected scope after rollover: %q", scope)
+	}
+	if len(c.scopes) != 1 {
+		t.Errorf("Expected the cache to be invalidated on rollover, found %d entries", len(c.scopes))
+	}
+
+	// A request dated on a previous day does not invalidate the cache
+	if scope := c.get(day2, day1, "ap-south-1", "s3"); scope != "202439/ap-south-1/s3/aws4_request" {
+		t.Errorf("Unexpected scope: %q", scope)
+	}
+	if c.date != "2024310" {
+		t.Errorf("Expected the cached day to be retained, got: %q", c.date)
+	}
+
+	// Nor does a request dated on a future day
+	if scope := c.get(day2, day2.AddDate(1, 0, 0), "ap-south-1", "s3"); scope != "2025310/ap-south-1/s3/aws4_request" {
+		t.Errorf("Unexpected scope: %q", scope)
+	}
+	if c.date != "2024310" {
+		t.Errorf("Expected the cached day not to move past the current day, got: %q", c.date)
+	}
+
+	// The scopes of arbitrary regions are not cached beyond the bound
+	for i := range 2 * maxCachedScopes {
+		c.get(day2, day2, fmt.Sprintf("region-%d", i), "s3")
+	}
+	if len(c.scopes) != maxCachedScopes {
+		t.Errorf("Expected %d cached scopes, found %d", maxCachedScopes, len(c.scopes))
+	}
+}
+
+// Test that an invalid or non-canonical date returns an error instead of panicking
+func Test_ParseDate_InvalidDate(t *testing.T) {
+	s := &SigV4{}
+	for _, date := range []string{"not-a-date", "2024-03-09T10:00:00.000Z", "2024-03-09T10:00:00+00:00"} {
+		if _, err := s.parseDate(date); err == nil {
+			t.Errorf("Expected an error for date: %q", date)
+		}
+	}
+
+	now := time.Now()
+	if parsed, err := s.parseDate(s.formatDate(now)); err != nil || !parsed.Equal(now) {
+		t.Errorf("Expected date to round-trip, got: %v, %v", parsed, err)
+	}
+}
+
+// Test that a configured terminator scopes the signature and the signing key to the org, and is honoured by the Verifier
+func Test_WithScopeTerminator(t *testing.T) {
+	secretServer := newSecretRetrievalServer(t, testEnvConfig.SECRET_ACCESS_KEY)
+	signer, err := NewSigV4Signer("ZEN", "zen", "certificatemanager", testEnvConfig, false, WithScopeTerminator("zen4_request"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	verifier, err := NewSigV4Verifier("ZEN", "zen", "certificatemanager", secretServer.URL, WithScopeTerminator("zen4_request"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	legacy, err := NewSigV4Verifier("ZEN", "zen", "certificatemanager", secretServer.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req, _ := http.NewRequest(http.MethodGet, "http://validate.127.0.0.1.sslip.io/api/cmagent", nil)
+	if err := signer.SignHTTPRequest(req); err != nil {
+		t.Fatal(err)
+	}
+	if header := req.Header.Get("Authorization"); !strings.Contains(header, "/certificatemanager/zen4_request,") {
+		t.Errorf("Expected the scope to be terminated with zen4_request, got: %s", header)
+	}
+	if err := verifier.VerifySignature(req); err != nil {
+		t.Error(err)
+	}
+	if err := legacy.VerifySignature(req); !errors.Is(err, auth.ErrSignatureMismatch) {
+		t.Errorf("Expected: %v, got: %v", auth.ErrSignatureMismatch, err)
+	}
+
+	// Presigned URLs and POST policies with another terminator are rejected alike
+	presigned, _ := http.NewRequest(http.MethodGet, "http://validate.127.0.0.1.sslip.io/api/cmagent", nil)
+	if err := signer.(*SigV4).PresignHTTPRequest(presigned, time.Minute, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := verifier.VerifySignature(presigned); err != nil {
+		t.Error(err)
+	}
+	if err := legacy.VerifySignature(presigned); err == nil || !strings.Contains(err.Error(), ERROR_SCOPE_MISMATCH) {
+		t.Errorf("Expected error %q for a presigned URL, got: %v", ERROR_SCOPE_MISMATCH, err)
+	}
+	fields, err := signer.(*SigV4).PresignPostPolicy(&PostPolicy{Expiration: time.Now().Add(time.Hour), Conditions: []PostPolicyCondition{PolicyStartsWith("key", "")}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	fields["key"] = "photo.jpg"
+	if _, err := legacy.(*SigV4).AuthenticatePostPolicy(newPostPolicyUpload(t, fields, []byte("jpeg"))); err == nil || !strings.Contains(err.Error(), ERROR_SCOPE_MISMATCH) {
+		t.Errorf("Expected error %q for a POST policy, got: %v", ERROR_SCOPE_MISMATCH, err)
+	}
+
+	// The signing key is derived with the `ZEN4` prefix
+	now := time.Now()
+	key, err := signer.(*SigV4).signingKey(DEFAULT_ALGORITHM, testEnvConfig.SECRET_ACCESS_KEY, now, testEnvConfig.REGION, "certificatemanager")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := sigv4core.SigningKey("ZEN4", testEnvConfig.SECRET_ACCESS_KEY, formatScopeDate(now, false), testEnvConfig.REGION, "certificatemanager", "zen4_request")
+	if !bytes.Equal(key, expected) {
+		t.Errorf("Expected the signing key: %x, got: %x", expected, key)
+	}
+
+	// Audit records carry the prefix, to be re-verified offline
+	record, err := verifier.(*SigV4).AuditRecord(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if record.KeyPrefix != "ZEN4" {
+		t.Errorf("Expected the key prefix: ZEN4, got: %q", record.KeyPrefix)
+	}
+	if err := ReVerify(record, testEnvConfig.SECRET_ACCESS_KEY); err != nil {
+		t.Error(err)
+	}
+
+	var terminatorErr *ErrInvalidTerminator
+	if _, err := NewSigV4Signer("ZEN", "zen", "certificatemanager", testEnvConfig, false, WithScopeTerminator("Zen4/request")); !errors.As(err, &terminatorErr) {
+		t.Errorf("Expected an *ErrInvalidTerminator, got: %v", err)
+	}
+}
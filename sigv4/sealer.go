@@ -0,0 +1,53 @@
+package sigv4
+
+// # Sealed secrets
+//
+// A SecretSealer protects the secrets held by the secret cache of a Verifier (See `WithSecretCache`): cached secrets are sealed,
+// and only unsealed while the signing key is derived from them. The default sealer holds secrets in plain memory.
+// An implementation backed by guarded memory is available with the `sealedsecrets` build tag (See `NewGuardedSecretSealer`).
+type SecretSealer interface {
+	// Seals the secret. The sealer must not retain the `secret` slice, which is wiped by the caller.
+	Seal(secret []byte) (SealedSecret, error)
+}
+
+// A secret sealed by a `SecretSealer`. Safe for concurrent use.
+type SealedSecret interface {
+	// Unseals the secret for the duration of `fn`. The slice must not be retained by `fn`, as it is wiped once `fn` returns.
+	Open(fn func(secret []byte) error) error
+	// Wipes the sealed secret. Called when the secret is evicted from the cache.
+	Destroy()
+}
+
+// Seal the secrets of the secret cache with the `sealer`, E.g. `NewGuardedSecretSealer()` with the `sealedsecrets` build tag.
+func WithSecretSealer(sealer SecretSealer) Option {
+	return func(s *SigV4) {
+		s.secretSealer = sealer
+	}
+}
+
+// `plainSealer` is the default SecretSealer, holding the secrets in plain memory
+type plainSealer struct{}
+
+type plainSecret struct {
+	secret []byte
+}
+
+func (plainSealer) Seal(secret []byte) (SealedSecret, error) {
+	return &plainSecret{secret: append([]byte(nil), secret...)}, nil
+}
+
+func (p *plainSecret) Open(fn func(secret []byte) error) error {
+	return fn(p.secret)
+}
+
+// Secrets may be shared by verifications in flight, hence they are released rather than wiped
+func (p *plainSecret) Destroy() {}
+
+// `sealSecret` seals a retrieved secret with the configured SecretSealer, and wipes the unsealed copy
+func (s *SigV4) sealSecret(secret []byte) (SealedSecret, error) {
+	defer clear(secret)
+	if s.secretSealer == nil {
+		return plainSealer{}.Seal(secret)
+	}
+	return s.secretSealer.Seal(secret)
+}
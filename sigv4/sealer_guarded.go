@@ -0,0 +1,150 @@
+//go:build sealedsecrets && (linux || darwin)
+
+package sigv4
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"syscall"
+
+	"github.com/jayantasamaddar/go-httpsigner/utils"
+)
+
+// Errors
+const (
+	ERROR_SEALED_SECRET_DESTROYED = "sealed secret destroyed"
+	ERROR_GUARDED_MEMORY          = "failed to allocate guarded memory"
+)
+
+// # Guarded secret sealer
+//
+// Returns a SecretSealer in the style of libsodium's guarded heap allocations, for high-assurance deployments:
+//   - Secrets are encrypted with AES-256-GCM under a key generated per sealer.
+//   - The key lives in a dedicated page locked in RAM (never swapped), which is inaccessible (`PROT_NONE`) except while a secret is unsealed.
+//   - Secrets are unsealed into a page locked in RAM, wiped and unmapped as soon as the signing key is derived.
+//
+// Unsealing is serialized per sealer. Secrets destroyed while a verification is in flight (E.g. on invalidation) fail to unseal.
+// Only available with the `sealedsecrets` build tag, on Linux and macOS.
+func NewGuardedSecretSealer() (SecretSealer, error) {
+	return NewGuardedSecretSealerWithEntropy(nil)
+}
+
+// Returns a guarded SecretSealer like `NewGuardedSecretSealer`, generating the key and the nonces from the `entropy` source
+// (`crypto/rand` if nil, See `utils.Entropy`). The `entropy` source must be safe for concurrent use.
+func NewGuardedSecretSealerWithEntropy(entropy io.Reader) (SecretSealer, error) {
+	page, err := guardedAlloc(32)
+	if err != nil {
+		return nil, err
+	}
+	if err := utils.ReadEntropy(entropy, page[:32]); err != nil {
+		guardedFree(page)
+		return nil, err
+	}
+	if err := syscall.Mprotect(page, syscall.PROT_NONE); err != nil {
+		guardedFree(page)
+		return nil, fmt.Errorf("%s: %w", ERROR_GUARDED_MEMORY, err)
+	}
+	return &guardedSealer{key: page, entropy: entropy}, nil
+}
+
+type guardedSealer struct {
+	mu      sync.Mutex
+	key     []byte    // The page holding the key in its first 32 bytes, `PROT_NONE` while sealed
+	entropy io.Reader // Source of the nonces. `crypto/rand` if nil.
+}
+
+type guardedSecret struct {
+	sealer     *guardedSealer
+	mu         sync.RWMutex
+	nonce      []byte
+	ciphertext []byte // nil once destroyed
+}
+
+// `aead` returns the cipher of the key, making the key page readable for the duration of the call only
+func (g *guardedSealer) aead() (cipher.AEAD, error) {
+	if err := syscall.Mprotect(g.key, syscall.PROT_READ); err != nil {
+		return nil, fmt.Errorf("%s: %w", ERROR_GUARDED_MEMORY, err)
+	}
+	block, err := aes.NewCipher(g.key[:32])
+	if err := syscall.Mprotect(g.key, syscall.PROT_NONE); err != nil {
+		return nil, fmt.Errorf("%s: %w", ERROR_GUARDED_MEMORY, err)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func (g *guardedSealer) Seal(secret []byte) (SealedSecret, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	aead, err := g.aead()
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if err := utils.ReadEntropy(g.entropy, nonce); err != nil {
+		return nil, err
+	}
+	return &guardedSecret{sealer: g, nonce: nonce, ciphertext: aead.Seal(nil, nonce, secret, nil)}, nil
+}
+
+func (s *guardedSecret) Open(fn func(secret []byte) error) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.ciphertext == nil {
+		return errors.New(ERROR_SEALED_SECRET_DESTROYED)
+	}
+
+	s.sealer.mu.Lock()
+	defer s.sealer.mu.Unlock()
+	aead, err := s.sealer.aead()
+	if err != nil {
+		return err
+	}
+	page, err := guardedAlloc(len(s.ciphertext))
+	if err != nil {
+		return err
+	}
+	defer guardedFree(page)
+	secret, err := aead.Open(page[:0], s.nonce, s.ciphertext, nil)
+	if err != nil {
+		return err
+	}
+	return fn(secret)
+}
+
+func (s *guardedSecret) Destroy() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	clear(s.ciphertext)
+	s.ciphertext = nil
+}
+
+// `guardedAlloc` maps whole pages of at least `size` bytes, locked in RAM
+func guardedAlloc(size int) ([]byte, error) {
+	pageSize := os.Getpagesize()
+	length := (size + pageSize - 1) / pageSize * pageSize
+	page, err := syscall.Mmap(-1, 0, max(length, pageSize), syscall.PROT_READ|syscall.PROT_WRITE, syscall.MAP_ANON|syscall.MAP_PRIVATE)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", ERROR_GUARDED_MEMORY, err)
+	}
+	if err := syscall.Mlock(page); err != nil {
+		syscall.Munmap(page)
+		return nil, fmt.Errorf("%s: %w", ERROR_GUARDED_MEMORY, err)
+	}
+	return page, nil
+}
+
+// `guardedFree` wipes, unlocks and unmaps pages mapped by `guardedAlloc`
+func guardedFree(page []byte) {
+	syscall.Mprotect(page, syscall.PROT_READ|syscall.PROT_WRITE)
+	clear(page)
+	syscall.Munlock(page)
+	syscall.Munmap(page)
+}
@@ -0,0 +1,51 @@
+//go:build sealedsecrets && (linux || darwin)
+
+package sigv4
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Test that requests are verified with secrets cached in guarded memory, and that destroyed secrets cannot be unsealed
+func Test_GuardedSecretSealer(t *testing.T) {
+	sealer, err := NewGuardedSecretSealer()
+	if err != nil {
+		t.Skip("Guarded memory unavailable:", err)
+	}
+	server := newSecretRetrievalServer(t, testEnvConfig.SECRET_ACCESS_KEY)
+	signer, _ := NewSigV4Signer("SYM", "sym", "certificatemanager", testEnvConfig, false)
+	verifier, _ := NewSigV4Verifier("SYM", "sym", "certificatemanager", server.URL, WithSecretCache(time.Minute), WithSecretSealer(sealer))
+
+	for i := 0; i < 2; i++ {
+		req, _ := http.NewRequest(http.MethodGet, "http://validate.127.0.0.1.sslip.io/api/cmagent", nil)
+		if err := signer.SignHTTPRequest(req); err != nil {
+			t.Fatal(err)
+		}
+		if err := verifier.VerifySignature(req); err != nil {
+			t.Errorf("Expected request %d to be verified, got: %v", i, err)
+		}
+	}
+
+	sealed, err := sealer.Seal([]byte("secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var unsealed string
+	if err := sealed.Open(func(secret []byte) error { unsealed = string(secret); return nil }); err != nil || unsealed != "secret" {
+		t.Errorf("Expected the secret to be unsealed, got: %q, %v", unsealed, err)
+	}
+	sealed.Destroy()
+	if err := sealed.Open(func([]byte) error { return nil }); err == nil {
+		t.Error("Expected a destroyed secret not to be unsealed")
+	}
+}
+
+// Test that the key of a guarded sealer is read from the entropy source
+func Test_NewGuardedSecretSealerWithEntropy(t *testing.T) {
+	if _, err := NewGuardedSecretSealerWithEntropy(strings.NewReader("too short")); err == nil {
+		t.Error("Expected an error from an exhausted entropy source")
+	}
+}
@@ -0,0 +1,56 @@
+package sigv4
+
+import (
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// A SecretSealer counting the secrets unsealed and destroyed
+type countingSealer struct {
+	opened, destroyed atomic.Int32
+}
+
+type countingSecret struct {
+	sealer *countingSealer
+	secret []byte
+}
+
+func (c *countingSealer) Seal(secret []byte) (SealedSecret, error) {
+	return &countingSecret{sealer: c, secret: append([]byte(nil), secret...)}, nil
+}
+
+func (c *countingSecret) Open(fn func(secret []byte) error) error {
+	c.sealer.opened.Add(1)
+	return fn(c.secret)
+}
+
+func (c *countingSecret) Destroy() {
+	c.sealer.destroyed.Add(1)
+}
+
+// Test that cached secrets are sealed with the configured sealer, unsealed per verification, and destroyed on invalidation
+func Test_WithSecretSealer(t *testing.T) {
+	sealer := new(countingSealer)
+	server := newSecretRetrievalServer(t, testEnvConfig.SECRET_ACCESS_KEY)
+	signer, _ := NewSigV4Signer("SYM", "sym", "certificatemanager", testEnvConfig, false)
+	verifier, _ := NewSigV4Verifier("SYM", "sym", "certificatemanager", server.URL, WithSecretCache(time.Minute), WithSecretSealer(sealer))
+
+	for i := 0; i < 3; i++ {
+		req, _ := http.NewRequest(http.MethodGet, "http://validate.127.0.0.1.sslip.io/api/cmagent", nil)
+		if err := signer.SignHTTPRequest(req); err != nil {
+			t.Fatal(err)
+		}
+		if err := verifier.VerifySignature(req); err != nil {
+			t.Errorf("Expected request %d to be verified, got: %v", i, err)
+		}
+	}
+	if sealer.opened.Load() != 3 {
+		t.Errorf("Expected the secret to be unsealed 3 times, got: %d", sealer.opened.Load())
+	}
+	verifier.(*SigV4).InvalidateSecret(testEnvConfig.ACCESS_KEY_ID)
+	if sealer.destroyed.Load() != 1 {
+		t.Errorf("Expected the invalidated secret to be destroyed, got: %d", sealer.destroyed.Load())
+	}
+}
@@ -0,0 +1,46 @@
+package sigv4
+
+import (
+	"encoding/hex"
+	"errors"
+	"io"
+
+	"github.com/jayantasamaddar/go-httpsigner/utils"
+)
+
+// Errors
+const (
+	ERROR_SECRET_RESPONSE_SIGNATURE = "signature of the secret retrieval response is missing or invalid"
+)
+
+// The error of a secret retrieval response with a missing or invalid signature. See `WithSecretResponseKey`.
+var ErrSecretResponseSignature = errors.New(ERROR_SECRET_RESPONSE_SIGNATURE)
+
+// # Signed secret retrieval responses
+//
+// Require the responses of the `secretRetrievalURL` to be signed with the `bootstrapKey`, a key shared with the key service out of band,
+// so that a compromised or spoofed endpoint cannot hand out attacker-controlled secrets.
+//
+// The Verifier sends a random `nonce` alongside the `access_key_id`, and the key service responds with a `signature`
+// computed by `SecretResponseSignature`. Responses with a missing or invalid signature are rejected, and not retried.
+func WithSecretResponseKey(bootstrapKey []byte) Option {
+	return func(s *SigV4) {
+		s.secretResponseKey = bootstrapKey
+	}
+}
+
+// Computes the signature of a secret retrieval response: the hex-encoded HMAC-SHA256 with the `bootstrapKey` of
+// the `accessKeyID`, the `nonce` of the request and the `secret`, each followed by a newline character ("\n").
+// The signature binds the secret to the access key and to the request, so that it cannot be replayed for another key or request.
+func SecretResponseSignature(bootstrapKey []byte, accessKeyID, nonce, secret string) string {
+	return hex.EncodeToString(utils.HMAC_SHA256.Sum(bootstrapKey, []byte(accessKeyID+"\n"+nonce+"\n"+secret+"\n")))
+}
+
+// `newNonce` generates a random nonce for a secret retrieval request, read from the `entropy` source. See `WithEntropySource`.
+func newNonce(entropy io.Reader) (string, error) {
+	var b [16]byte
+	if err := utils.ReadEntropy(entropy, b[:]); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b[:]), nil
+}
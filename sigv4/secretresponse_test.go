@@ -0,0 +1,69 @@
+package sigv4
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jayantasamaddar/go-httpsigner/auth"
+)
+
+// Mock key service signing its responses with the `bootstrapKey`
+func newSigningSecretRetrievalServer(t *testing.T, secret string, bootstrapKey []byte) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			AccessKeyID string `json:"access_key_id"`
+			Nonce       string `json:"nonce"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]string{
+			"secret_access_key": secret,
+			"signature":         SecretResponseSignature(bootstrapKey, body.AccessKeyID, body.Nonce, secret),
+		})
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// Test that signed secret retrieval responses are verified, and that unsigned or forged responses are rejected without retries
+func Test_SecretResponseSignature(t *testing.T) {
+	bootstrapKey := []byte("bootstrap-key")
+	signer, _ := NewSigV4Signer("SYM", "sym", "certificatemanager", testEnvConfig, false)
+
+	tests := []struct {
+		name   string
+		server *httptest.Server
+		err    error
+	}{
+		{"signed", newSigningSecretRetrievalServer(t, testEnvConfig.SECRET_ACCESS_KEY, bootstrapKey), nil},
+		{"unsigned", newSecretRetrievalServer(t, testEnvConfig.SECRET_ACCESS_KEY), auth.ErrSecretUnavailable},
+		{"forged", newSigningSecretRetrievalServer(t, "attacker-secret", []byte("attacker-key")), auth.ErrSecretUnavailable},
+	}
+	for _, test := range tests {
+		verifier, _ := NewSigV4Verifier("SYM", "sym", "certificatemanager", test.server.URL, WithSecretResponseKey(bootstrapKey))
+		req, _ := http.NewRequest(http.MethodGet, "http://validate.127.0.0.1.sslip.io/api/cmagent", nil)
+		if err := signer.SignHTTPRequest(req); err != nil {
+			t.Fatal(err)
+		}
+
+		start := time.Now()
+		err := verifier.VerifySignature(req)
+		if !errors.Is(err, test.err) {
+			t.Errorf("%s: expected: %v, got: %v", test.name, test.err, err)
+		}
+		if err != nil && !strings.Contains(err.Error(), ERROR_SECRET_RESPONSE_SIGNATURE) {
+			t.Errorf("%s: expected error: %q, got: %v", test.name, ERROR_SECRET_RESPONSE_SIGNATURE, err)
+		}
+		if elapsed := time.Since(start); elapsed > time.Second {
+			t.Errorf("%s: expected no retries, took: %v", test.name, elapsed)
+		}
+	}
+}
@@ -0,0 +1,130 @@
+package sigv4
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// `secretCache` caches the secrets retrieved from the `secretRetrievalURL` by `ACCESS_KEY_ID` for a TTL. See `WithSecretCache`.
+type secretCache struct {
+	mu      sync.RWMutex
+	ttl     time.Duration
+	secrets map[string]cachedSecret
+}
+
+type cachedSecret struct {
+	secret  SealedSecret
+	expires time.Time
+}
+
+func newSecretCache(ttl time.Duration) *secretCache {
+	return &secretCache{ttl: ttl, secrets: make(map[string]cachedSecret)}
+}
+
+// `get` returns the cached secret of the `accessKeyID`, if present and not expired at `now`.
+func (c *secretCache) get(accessKeyID string, now time.Time) (SealedSecret, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	cached, ok := c.secrets[accessKeyID]
+	if !ok || now.After(cached.expires) {
+		return nil, false
+	}
+	return cached.secret, true
+}
+
+// `getStale` returns the cached secret of the `accessKeyID`, if present and expired no more than `maxStaleness` ago,
+// along with the time since it expired at `now` (zero if not expired).
+func (c *secretCache) getStale(accessKeyID string, maxStaleness time.Duration, now time.Time) (SealedSecret, time.Duration, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	cached, ok := c.secrets[accessKeyID]
+	if !ok {
+		return nil, 0, false
+	}
+	staleness := max(now.Sub(cached.expires), 0)
+	if staleness > maxStaleness {
+		return nil, 0, false
+	}
+	return cached.secret, staleness, true
+}
+
+func (c *secretCache) set(accessKeyID string, secret SealedSecret, now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if cached, ok := c.secrets[accessKeyID]; ok {
+		cached.secret.Destroy()
+	}
+	c.secrets[accessKeyID] = cachedSecret{secret: secret, expires: now.Add(c.ttl)}
+}
+
+func (c *secretCache) delete(accessKeyID string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if cached, ok := c.secrets[accessKeyID]; ok {
+		cached.secret.Destroy()
+	}
+	delete(c.secrets, accessKeyID)
+}
+
+func (c *secretCache) clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, cached := range c.secrets {
+		cached.secret.Destroy()
+	}
+	clear(c.secrets)
+}
+
+// # Cache invalidation
+//
+// Purges the cached secret of the `accessKeyID`, so that the next request signed with it retrieves the secret again from the `secretRetrievalURL`.
+// Intended to be called on key-revocation events. A no-op if the Verifier has no secret cache (See `WithSecretCache`).
+func (s *SigV4) InvalidateSecret(accessKeyID string) {
+	if s.secrets != nil {
+		s.secrets.delete(accessKeyID)
+	}
+}
+
+// Purges all the cached secrets. A no-op if the Verifier has no secret cache (See `WithSecretCache`).
+func (s *SigV4) InvalidateAll() {
+	if s.secrets != nil {
+		s.secrets.clear()
+	}
+}
+
+// The request body accepted by the `InvalidationHandler`
+type invalidationRequest struct {
+	ACCESS_KEY_ID string `json:"access_key_id"`
+	All           bool   `json:"all"`
+}
+
+// # Admin cache invalidation handler
+//
+// Returns an `http.Handler` that purges cached secrets. It accepts a POST request with a JSON body of
+// `{"access_key_id": "..."}` to purge the secret of one key, or `{"all": true}` to purge all the secrets, and responds with `204 No Content`.
+//
+// The handler does not authenticate its callers: mount it on an internal admin listener or behind your own authorization.
+func (s *SigV4) InvalidationHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+
+		var body invalidationRequest
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil || (body.ACCESS_KEY_ID == "") == !body.All {
+			http.Error(w, `expected a JSON body of either {"access_key_id": "..."} or {"all": true}`, http.StatusBadRequest)
+			return
+		}
+
+		if body.All {
+			s.InvalidateAll()
+		} else {
+			s.InvalidateSecret(body.ACCESS_KEY_ID)
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
@@ -0,0 +1,113 @@
+package sigv4
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+)
+
+// Errors
+const (
+	ERROR_HEADER_NOT_INCLUDED        = "signed header is not included by the verifier"
+	ERROR_REQUIRED_HEADER_NOT_SIGNED = "required header is not signed"
+	ERROR_HOST_NOT_SIGNED            = "host header is not signed"
+	ERROR_DATE_NOT_SIGNED            = "date header is not signed"
+)
+
+// Hop-by-hop headers (See RFC 9110 Section 7.6.1), which proxies consume or rewrite, hence should not be signed.
+// E.g. `sigv4.WithSkipHeaders(sigv4.HopByHopHeaders...)`
+var HopByHopHeaders = []string{
+	"Connection",
+	"Keep-Alive",
+	"Proxy-Authenticate",
+	"Proxy-Authorization",
+	"Proxy-Connection",
+	"TE",
+	"Trailer",
+	"Transfer-Encoding",
+	"Upgrade",
+}
+
+// # Included headers
+//
+// Sign only the given headers among the headers of the request (E.g. `Content-Type`), instead of every header.
+// The `host`, the headers set by the Signer (E.g. the Date Header and the `X-[Abbr]-*` headers) and the required headers (See `WithRequiredHeaders`)
+// are always signed, and the skipped headers (See `WithSkipHeaders`) never are. Header names are case-insensitive.
+//
+// A Verifier configured with the list rejects a request that claims to have signed another header, like a skipped header.
+// Calling `WithIncludeHeaders` multiple times adds to the list.
+func WithIncludeHeaders(headers ...string) Option {
+	return func(s *SigV4) {
+		if s.includeHeaders == nil {
+			s.includeHeaders = make(map[string]struct{}, len(headers))
+		}
+		for _, header := range headers {
+			if header = strings.ToLower(strings.TrimSpace(header)); header != "" {
+				s.includeHeaders[header] = struct{}{}
+			}
+		}
+	}
+}
+
+// # Required headers
+//
+// Always sign the given headers when present on the request, even if not included (See `WithIncludeHeaders`),
+// and reject the requests carrying one of them unsigned in the Verifier. E.g. `Content-Type`, so that a proxy cannot change how the payload is interpreted.
+// The `host` and the Date Header are always required to be signed. Presigned requests only sign the host, and are not checked.
+//
+// Required headers cannot be skipped: the constructors return an `*ErrInvalidHeaderName` if a required header is also a skipped header.
+// Calling `WithRequiredHeaders` multiple times adds to the list.
+func WithRequiredHeaders(headers ...string) Option {
+	return func(s *SigV4) {
+		for _, header := range headers {
+			if header = strings.ToLower(strings.TrimSpace(header)); header != "" && !slices.Contains(s.requiredHeaders, header) {
+				s.requiredHeaders = append(s.requiredHeaders, header)
+			}
+		}
+	}
+}
+
+// `isIncludedHeader` checks if a header may be signed with the included headers, if any. See `WithIncludeHeaders`.
+func (s *SigV4) isIncludedHeader(header string) bool {
+	if s.includeHeaders == nil {
+		return true
+	}
+	header = strings.ToLower(header)
+	if _, ok := s.includeHeaders[header]; ok || header == "host" || slices.Contains(s.requiredHeaders, header) {
+		return true
+	}
+	return s.isSignerHeader(header)
+}
+
+// `isSignerHeader` checks if a header is set by the Signer: the Date Header, the `X-[Abbr]-*` headers (E.g. the session token or the payload hash),
+// the idempotency key and the `Content-Digest` of the RFC 9421 signature, if configured
+func (s *SigV4) isSignerHeader(header string) bool {
+	return strings.HasPrefix(strings.ToLower(header), strings.ToLower("X-"+s.abbr+"-")) ||
+		strings.EqualFold(header, s.dateHeader()) ||
+		s.idempotencyKey && strings.EqualFold(header, s.idempotencyKeyHeaderName()) ||
+		s.messageSignatureLabel != "" && strings.EqualFold(header, "Content-Digest")
+}
+
+// `checkRequiredHeaders` checks that the `host` and the Date Header are signed, so that a signature is bound to the virtual host and the signing time,
+// and that the required headers present on the request are signed. See `WithRequiredHeaders`.
+func (s *SigV4) checkRequiredHeaders(req *http.Request, signedHeaders []string) error {
+	if !slices.Contains(signedHeaders, "host") {
+		return errors.New(ERROR_HOST_NOT_SIGNED)
+	}
+	if !slices.Contains(signedHeaders, strings.ToLower(s.dateHeader())) {
+		return fmt.Errorf("%s: %s", ERROR_DATE_NOT_SIGNED, s.dateHeader())
+	}
+	for _, header := range s.requiredHeaders {
+		if len(headerValues(req.Header, header)) > 0 && !slices.Contains(signedHeaders, header) {
+			return fmt.Errorf("%s: %s", ERROR_REQUIRED_HEADER_NOT_SIGNED, http.CanonicalHeaderKey(header))
+		}
+	}
+	return nil
+}
+
+// `isExcludedHeader` checks if a header is never signed: skipped, or not included. See `WithSkipHeaders` and `WithIncludeHeaders`.
+func (s *SigV4) isExcludedHeader(header string) bool {
+	return s.isSkippedHeader(header) || !s.isIncludedHeader(header)
+}
@@ -0,0 +1,53 @@
+package sigv4
+
+import (
+	"context"
+	"sync"
+)
+
+// # Single-flight secret lookups
+//
+// `secretFlights` deduplicates the concurrent lookups of the same secret: the first lookup retrieves the secret (with its retries),
+// and the lookups started meanwhile wait for its result instead of calling the `secretRetrievalURL` themselves.
+// The zero value is ready for use.
+type secretFlights struct {
+	mu    sync.Mutex
+	calls map[string]*secretFlight
+}
+
+// A lookup in flight, shared by its waiters
+type secretFlight struct {
+	done   chan struct{}
+	secret string
+	err    error
+}
+
+// `do` returns the result of `retrieve` for the `key`, shared with the concurrent calls of the same `key`.
+// The lookup is not canceled with the context of the caller that started it, as it serves the other waiters,
+// but each caller stops waiting once its own context is done.
+func (f *secretFlights) do(ctx context.Context, key string, retrieve func(ctx context.Context) (string, error)) (string, error) {
+	f.mu.Lock()
+	call, ok := f.calls[key]
+	if !ok {
+		if f.calls == nil {
+			f.calls = make(map[string]*secretFlight)
+		}
+		call = &secretFlight{done: make(chan struct{})}
+		f.calls[key] = call
+		go func() {
+			call.secret, call.err = retrieve(context.WithoutCancel(ctx))
+			f.mu.Lock()
+			delete(f.calls, key)
+			f.mu.Unlock()
+			close(call.done)
+		}()
+	}
+	f.mu.Unlock()
+
+	select {
+	case <-call.done:
+		return call.secret, call.err
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+}
@@ -1,27 +1,49 @@
 package sigv4
 
 import (
-	"fmt"
 	"time"
 
 	"github.com/jayantasamaddar/go-httpsigner/utils"
 )
 
-// (3) Derive the Signing Key
-func (s *SigV4) signingKey(accessKey, dateString, region, service string) ([]byte, error) {
-	// Parse the date string
-	parsedTime, err := time.Parse(time.RFC3339Nano, dateString)
-	if err != nil {
-		return []byte{}, err
+// # Signing key cache
+//
+// Memoizes the derived signing keys in a `utils.KeyCache` of up to `maxEntries` keys, so that the four HMACs deriving the
+// signing key are computed once per secret, algorithm and scope, instead of once per request.
+func WithSigningKeyCache(maxEntries int) Option {
+	return func(s *SigV4) {
+		s.signingKeys = utils.NewKeyCache(maxEntries)
 	}
-	//Extract year, month, and day
-	YYYY, MM, DD := parsedTime.Date()
-	key := []byte("AWS4" + accessKey)
+}
+
+// (3) Derive the Signing Key with the HMACs of the algorithm. See `sigv4core.SigningKey`.
+func (s *SigV4) signingKey(algorithm, accessKey string, t time.Time, region, service string) ([]byte, error) {
+	return s.deriveSigningKey(algorithm, []byte(accessKey), t, region, service)
+}
 
-	key, _ = utils.HmacSHA256(key, fmt.Sprintf("%d%d%d", YYYY, MM, DD)) // (a) DateKey
-	key, _ = utils.HmacSHA256(key, region)                              // (b) DateRegionKey
-	key, _ = utils.HmacSHA256(key, service)                             // (c) DateRegionServiceKey
-	key, _ = utils.HmacSHA256(key, "aws4_request")                      // (d) SigningKey
+// (3) Derive the Signing Key like `signingKey`, unsealing the secret only for the derivation. See `WithSecretSealer`.
+func (s *SigV4) signingKeyFromSecret(algorithm string, secret SealedSecret, t time.Time, region, service string) ([]byte, error) {
+	var signingKey []byte
+	err := secret.Open(func(secret []byte) error {
+		var err error
+		signingKey, err = s.deriveSigningKey(algorithm, secret, t, region, service)
+		return err
+	})
+	return signingKey, err
+}
 
-	return key, nil
+// `deriveSigningKey` derives the Signing Key from the secret, wiping its prefixed copy
+func (s *SigV4) deriveSigningKey(algorithm string, secret []byte, t time.Time, region, service string) ([]byte, error) {
+	newHash, err := hashFunc(algorithm)
+	if err != nil {
+		return nil, err
+	}
+	mac := utils.NewHMAC(algorithm, newHash)
+	prefixed := append([]byte(s.signingKeyPrefix()), secret...)
+	defer clear(prefixed)
+	parts := []string{s.scopeDate(t), region, service, s.scopeTerminator()}
+	if s.signingKeys != nil {
+		return s.signingKeys.DeriveKey(mac, prefixed, parts...), nil
+	}
+	return utils.DeriveKey(mac, prefixed, parts...), nil
 }
@@ -0,0 +1,29 @@
+package sigv4
+
+import (
+	"net/http"
+	"testing"
+)
+
+// Test that signing keys are derived once per scope with the signing key cache, and that signatures are unchanged
+func Test_WithSigningKeyCache(t *testing.T) {
+	server := newSecretRetrievalServer(t, testEnvConfig.SECRET_ACCESS_KEY)
+	signer, _ := NewSigV4Signer("SYM", "sym", "certificatemanager", testEnvConfig, false, WithSigningKeyCache(16))
+	verifier, _ := NewSigV4Verifier("SYM", "sym", "certificatemanager", server.URL, WithSigningKeyCache(16))
+
+	for i := 0; i < 3; i++ {
+		req, _ := http.NewRequest(http.MethodGet, "http://validate.127.0.0.1.sslip.io/api/cmagent", nil)
+		if err := signer.SignHTTPRequest(req); err != nil {
+			t.Fatal(err)
+		}
+		if err := verifier.VerifySignature(req); err != nil {
+			t.Errorf("Expected request %d to be verified, got: %v", i, err)
+		}
+	}
+	// One key, unless the requests straddled midnight
+	for name, s := range map[string]*SigV4{"signer": signer.(*SigV4), "verifier": verifier.(*SigV4)} {
+		if n := s.signingKeys.Len(); n < 1 || n > 2 {
+			t.Errorf("%s: expected the signing key to be cached once, got %d cached", name, n)
+		}
+	}
+}
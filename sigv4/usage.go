@@ -0,0 +1,118 @@
+package sigv4
+
+import (
+	"sync"
+	"time"
+)
+
+// Usage of an access key, as tracked by `UsageStats`
+type KeyUsage struct {
+	Verifications uint64    // Number of verified requests
+	Failures      uint64    // Number of requests that failed verification
+	FirstSeen     time.Time // Time of the first request
+	LastSeen      time.Time // Time of the last request
+	LastVerified  time.Time // Time of the last verified request. Zero if none.
+}
+
+// Ratio of failed requests to all the requests of the key
+func (u KeyUsage) FailureRatio() float64 {
+	total := u.Verifications + u.Failures
+	if total == 0 {
+		return 0
+	}
+	return float64(u.Failures) / float64(total)
+}
+
+// # Per-access-key usage statistics
+//
+// UsageStats tracks the verification counts, last-seen timestamps and failure ratios per `ACCESS_KEY_ID`,
+// helping find dormant keys and anomalous usage patterns. Pass it to a Verifier with `WithUsageStats`.
+//
+// A key is tracked from its first verified request, so that failed requests claiming made-up key IDs cannot fill the statistics:
+// failures of keys that are not tracked are only counted in `UnknownFailures`.
+// To bound memory, at most `maxKeys` keys are tracked, and verified requests of other keys are only counted in `Untracked`.
+type UsageStats struct {
+	mu              sync.Mutex
+	maxKeys         int
+	keys            map[string]*KeyUsage
+	untracked       uint64
+	unknownFailures uint64
+}
+
+// Returns an empty `UsageStats` tracking at most `maxKeys` keys. A non-positive `maxKeys` means no limit.
+func NewUsageStats(maxKeys int) *UsageStats {
+	return &UsageStats{maxKeys: maxKeys, keys: make(map[string]*KeyUsage)}
+}
+
+// `record` records a request of the key at time `t`
+func (u *UsageStats) record(keyID string, verified bool, t time.Time) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	usage, ok := u.keys[keyID]
+	if !ok {
+		if !verified {
+			u.unknownFailures++
+			return
+		}
+		if u.maxKeys > 0 && len(u.keys) >= u.maxKeys {
+			u.untracked++
+			return
+		}
+		usage = &KeyUsage{FirstSeen: t}
+		u.keys[keyID] = usage
+	}
+	usage.LastSeen = t
+	if verified {
+		usage.Verifications++
+		usage.LastVerified = t
+	} else {
+		usage.Failures++
+	}
+}
+
+// Returns a copy of the usage of every tracked key, by `ACCESS_KEY_ID`
+func (u *UsageStats) Snapshot() map[string]KeyUsage {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	snapshot := make(map[string]KeyUsage, len(u.keys))
+	for keyID, usage := range u.keys {
+		snapshot[keyID] = *usage
+	}
+	return snapshot
+}
+
+// Returns the number of verified requests of keys that were not tracked because `maxKeys` was reached
+func (u *UsageStats) Untracked() uint64 {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return u.untracked
+}
+
+// Returns the number of failed requests of keys that are not tracked, E.g. of key IDs that do not exist
+func (u *UsageStats) UnknownFailures() uint64 {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return u.unknownFailures
+}
+
+// Returns the keys that have not been verified since `since`, E.g. to find dormant keys
+func (u *UsageStats) Dormant(since time.Time) []string {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	var dormant []string
+	for keyID, usage := range u.keys {
+		if usage.LastVerified.Before(since) {
+			dormant = append(dormant, keyID)
+		}
+	}
+	return dormant
+}
+
+// Reset forgets all the tracked keys
+func (u *UsageStats) Reset() {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	clear(u.keys)
+	u.untracked, u.unknownFailures = 0, 0
+}
@@ -0,0 +1,92 @@
+package sigv4
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// Test that verifications and failures are attributed to the access key
+func Test_UsageStats(t *testing.T) {
+	server := newSecretRetrievalServer(t, testEnvConfig.SECRET_ACCESS_KEY)
+	signer, _ := NewSigV4Signer("SYM", "sym", "certificatemanager", testEnvConfig, false)
+	stats := NewUsageStats(1)
+	verifier, _ := NewSigV4Verifier("SYM", "sym", "certificatemanager", server.URL, WithUsageStats(stats))
+	start := time.Now()
+
+	for i := 0; i < 3; i++ {
+		req, _ := http.NewRequest(http.MethodGet, "http://validate.127.0.0.1.sslip.io/api/cmagent", nil)
+		if err := signer.SignHTTPRequest(req); err != nil {
+			t.Fatal(err)
+		}
+		if i == 2 {
+			req.Method = http.MethodDelete
+		}
+		_ = verifier.VerifySignature(req)
+	}
+
+	usage, ok := stats.Snapshot()[testEnvConfig.ACCESS_KEY_ID]
+	if !ok {
+		t.Fatalf("Expected usage of %q to be tracked", testEnvConfig.ACCESS_KEY_ID)
+	}
+	if usage.Verifications != 2 || usage.Failures != 1 || usage.LastSeen.Before(start) || usage.LastVerified.Before(start) {
+		t.Errorf("Unexpected usage: %+v", usage)
+	}
+	if ratio := usage.FailureRatio(); ratio < 0.33 || ratio > 0.34 {
+		t.Errorf("Expected a failure ratio of 1/3, got: %f", ratio)
+	}
+	if dormant := stats.Dormant(start); len(dormant) != 0 {
+		t.Errorf("Expected no dormant keys, got: %v", dormant)
+	}
+	if dormant := stats.Dormant(time.Now().Add(time.Hour)); len(dormant) != 1 {
+		t.Errorf("Expected 1 dormant key, got: %v", dormant)
+	}
+
+	// Keys beyond `maxKeys` are not tracked
+	other := *testEnvConfig
+	other.ACCESS_KEY_ID = "AKIAOTHEREXAMPLE"
+	otherSigner, _ := NewSigV4Signer("SYM", "sym", "certificatemanager", &other, false)
+	req, _ := http.NewRequest(http.MethodGet, "http://validate.127.0.0.1.sslip.io/api/cmagent", nil)
+	if err := otherSigner.SignHTTPRequest(req); err != nil {
+		t.Fatal(err)
+	}
+	_ = verifier.VerifySignature(req)
+	if snapshot := stats.Snapshot(); len(snapshot) != 1 || stats.Untracked() != 1 {
+		t.Errorf("Expected 1 tracked key and 1 untracked request, got: %d, %d", len(snapshot), stats.Untracked())
+	}
+}
+
+// Test that failed requests of unknown keys do not create entries, and do not prevent the tracking of verified keys
+func Test_UsageStats_UnknownFailures(t *testing.T) {
+	server := newSecretRetrievalServer(t, testEnvConfig.SECRET_ACCESS_KEY)
+	stats := NewUsageStats(1)
+	verifier, _ := NewSigV4Verifier("SYM", "sym", "certificatemanager", server.URL, WithUsageStats(stats))
+
+	forged := *testEnvConfig
+	forged.SECRET_ACCESS_KEY = "forged"
+	for i := 0; i < 3; i++ {
+		forged.ACCESS_KEY_ID = fmt.Sprintf("AKIAFORGED%d", i)
+		forgedSigner, _ := NewSigV4Signer("SYM", "sym", "certificatemanager", &forged, false)
+		req, _ := http.NewRequest(http.MethodGet, "http://validate.127.0.0.1.sslip.io/api/cmagent", nil)
+		if err := forgedSigner.SignHTTPRequest(req); err != nil {
+			t.Fatal(err)
+		}
+		_ = verifier.VerifySignature(req)
+	}
+	if snapshot := stats.Snapshot(); len(snapshot) != 0 || stats.UnknownFailures() != 3 {
+		t.Errorf("Expected no tracked key and 3 unknown failures, got: %d, %d", len(snapshot), stats.UnknownFailures())
+	}
+
+	signer, _ := NewSigV4Signer("SYM", "sym", "certificatemanager", testEnvConfig, false)
+	req, _ := http.NewRequest(http.MethodGet, "http://validate.127.0.0.1.sslip.io/api/cmagent", nil)
+	if err := signer.SignHTTPRequest(req); err != nil {
+		t.Fatal(err)
+	}
+	if err := verifier.VerifySignature(req); err != nil {
+		t.Fatal(err)
+	}
+	if usage, ok := stats.Snapshot()[testEnvConfig.ACCESS_KEY_ID]; !ok || usage.Verifications != 1 {
+		t.Errorf("Expected the verified key to be tracked, got: %+v", stats.Snapshot())
+	}
+}
@@ -0,0 +1,103 @@
+package sigv4
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Errors
+const (
+	ERROR_TEST_VECTOR_CREDENTIALS = "test vectors require a Signer with credentials, not an agent"
+)
+
+// # Test vectors
+//
+// A TestVector records the inputs and the intermediate results of signing a request at a fixed time, so that implementations
+// in other languages can be validated against this library, including its legacy date and scope formats. See `cmd/httpsigner-vectors`.
+type TestVector struct {
+	Name            string            `json:"name"`
+	Org             string            `json:"org"`
+	Abbr            string            `json:"abbr"`
+	Service         string            `json:"service"`
+	Region          string            `json:"region"`
+	AccessKeyID     string            `json:"access_key_id"`
+	SecretAccessKey string            `json:"secret_access_key"`
+	Algorithm       string            `json:"algorithm"`
+	SigningTime     time.Time         `json:"signing_time"`
+	Request         TestVectorRequest `json:"request"` // The request before signing
+	// The headers set by signing, E.g. `Authorization` and `X-Amz-Date`
+	SignedHeaders    map[string]string `json:"signed_headers"`
+	CanonicalRequest string            `json:"canonical_request"`
+	StringToSign     string            `json:"string_to_sign"`
+	SigningKey       string            `json:"signing_key"` // Hex-encoded
+	Signature        string            `json:"signature"`
+}
+
+// The request of a TestVector
+type TestVectorRequest struct {
+	Method  string              `json:"method"`
+	URL     string              `json:"url"`
+	Headers map[string][]string `json:"headers,omitempty"`
+	Body    string              `json:"body,omitempty"`
+}
+
+// Signs a copy of the request at the `signingTime` and returns the TestVector named `name`. The request is not modified.
+//
+// The vector is deterministic for Signers without request IDs, signature chaining or agents.
+func (s *SigV4) TestVector(name string, req *http.Request, signingTime time.Time) (*TestVector, error) {
+	if s.agent != nil {
+		return nil, errors.New(ERROR_TEST_VECTOR_CREDENTIALS)
+	}
+	signed, body, err := cloneWithBody(req)
+	if err != nil {
+		return nil, err
+	}
+	trace := new(signingTrace)
+	if err := s.signHTTPRequestAt(signed.Context(), signed, signingTime, trace); err != nil {
+		return nil, err
+	}
+
+	algorithm := s.signingAlgorithm()
+	signingKey, err := s.signingKey(algorithm, s.env.SECRET_ACCESS_KEY, signingTime, s.env.REGION, s.service)
+	if err != nil {
+		return nil, err
+	}
+	vector := &TestVector{
+		Name:            name,
+		Org:             s.org,
+		Abbr:            s.abbr,
+		Service:         s.service,
+		Region:          s.env.REGION,
+		AccessKeyID:     s.env.ACCESS_KEY_ID,
+		SecretAccessKey: s.env.SECRET_ACCESS_KEY,
+		Algorithm:       algorithm,
+		SigningTime:     signingTime.UTC(),
+		Request: TestVectorRequest{
+			Method:  req.Method,
+			URL:     req.URL.String(),
+			Headers: req.Header.Clone(),
+			Body:    string(body),
+		},
+		SignedHeaders:    make(map[string]string),
+		CanonicalRequest: trace.canonicalRequest,
+		StringToSign:     s.stringToSign(algorithm, signingTime, s.env.REGION, s.service, trace.canonicalRequest),
+		SigningKey:       hex.EncodeToString(signingKey),
+	}
+	for name := range signed.Header {
+		if _, ok := req.Header[name]; !ok {
+			vector.SignedHeaders[name] = signed.Header.Get(name)
+		}
+	}
+	if vector.Signature, err = s.generateSignature(algorithm, signingKey, vector.StringToSign); err != nil {
+		return nil, err
+	}
+	// The vector must describe the signature actually produced
+	if !strings.HasSuffix(signed.Header.Get("Authorization"), "Signature="+vector.Signature) {
+		return nil, fmt.Errorf("%s: the signature of the vector %q does not match the signed request", ERROR_SIGNATURE_MISMATCH, name)
+	}
+	return vector, nil
+}
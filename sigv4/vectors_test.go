@@ -0,0 +1,50 @@
+package sigv4
+
+import (
+	"bytes"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jayantasamaddar/go-httpsigner/sigv4core"
+)
+
+// Test that test vectors are deterministic, consistent with `sigv4core`, and leave the request unmodified
+func Test_TestVector(t *testing.T) {
+	signer, _ := NewSigV4Signer("SYM", "sym", "certificatemanager", testEnvConfig, true)
+	signingTime := time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC)
+	newRequest := func() *http.Request {
+		req, _ := http.NewRequest(http.MethodPost, "https://example.com/api?b=2&a=1", bytes.NewBufferString(`{"name":"example"}`))
+		req.Header.Set("Content-Type", "application/json")
+		return req
+	}
+
+	req := newRequest()
+	vector, err := signer.(*SigV4).TestVector("post", req, signingTime)
+	if err != nil {
+		t.Fatal(err)
+	}
+	again, err := signer.(*SigV4).TestVector("post", newRequest(), signingTime)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(vector, again) {
+		t.Errorf("Expected deterministic vectors, got: %+v and %+v", vector, again)
+	}
+
+	if req.Header.Get("Authorization") != "" || vector.Request.Body != `{"name":"example"}` {
+		t.Error("Expected the request to be left unmodified")
+	}
+	if vector.SignedHeaders["Authorization"] == "" || vector.SignedHeaders["X-Sym-Date"] == "" || vector.SignedHeaders["X-Sym-Content-Sha256"] == "" {
+		t.Errorf("Expected the headers set by signing, got: %v", vector.SignedHeaders)
+	}
+	if !strings.Contains(vector.CanonicalRequest, "a=1&b=2") || !strings.HasPrefix(vector.StringToSign, "SYM4-HMAC-SHA256\n") {
+		t.Errorf("Unexpected canonical request or string-to-sign: %q, %q", vector.CanonicalRequest, vector.StringToSign)
+	}
+	key := sigv4core.SigningKey("AWS4", testEnvConfig.SECRET_ACCESS_KEY, formatScopeDate(signingTime, false), testEnvConfig.REGION, "certificatemanager", "aws4_request")
+	if sigv4core.Signature(key, vector.StringToSign) != vector.Signature {
+		t.Error("Expected the signature to be reproducible with sigv4core")
+	}
+}
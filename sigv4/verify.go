@@ -1,21 +1,30 @@
 package sigv4
 
 import (
-	"bytes"
 	"context"
-	"encoding/json"
+	"crypto/hmac"
 	"fmt"
-	"io"
 	"net/http"
+	"slices"
 	"strings"
 	"time"
+
+	"github.com/jayantasamaddar/go-httpsigner/auth"
+	"github.com/jayantasamaddar/go-httpsigner/secretapi"
+	"github.com/jayantasamaddar/go-httpsigner/sigv4core"
+	"github.com/jayantasamaddar/go-httpsigner/utils/backoff"
 )
 
 // Errors
 const (
-	ERROR_INCORRECT_FORMAT_HEADER = "incorrectly formatted Authorization header"
-	ERROR_INCORRECT_ALGORITHM     = "incorrect algorithm found"
-	ERROR_SIGNATURE_MISMATCH      = "computed signature does not match received signature"
+	ERROR_INCORRECT_FORMAT_HEADER   = "incorrectly formatted Authorization header"
+	ERROR_SIGNATURE_MISSING         = "request has no Authorization header or presigned query"
+	ERROR_INCORRECT_ALGORITHM       = "incorrect algorithm found"
+	ERROR_SIGNATURE_MISMATCH        = "computed signature does not match received signature"
+	ERROR_SCOPE_MISMATCH            = "credential scope does not match the scope of the signing date"
+	ERROR_SKIPPED_HEADER_SIGNED     = "signed header is configured to be skipped by the verifier"
+	ERROR_REQUEST_ID_NOT_SIGNED     = "request ID header is not signed"
+	ERROR_SECURITY_TOKEN_NOT_SIGNED = "security token header is not signed"
 )
 
 // All components that make up the `Authorization` header
@@ -32,17 +41,19 @@ type AuthHeaderCredentials struct {
 	Date          string // Format: YYYYMMDD
 	Region        string // Region / Data Center (E.g. For AWS: `ap-south-1`)
 	Service       string // Name of the service (E.g. `ec2`)
+	Terminator    string // The termination string (E.g. `aws4_request`)
 }
 
-type secretretrievalResponse struct {
-	SECRET_ACCESS_KEY string `json:"secret_access_key"`
+// `fmt.Stringer` implementation
+func (c *AuthHeaderCredentials) String() string {
+	return fmt.Sprintf("%s/%s/%s/%s/%s", c.ACCESS_KEY_ID, c.Date, c.Region, c.Service, c.Terminator)
 }
 
 // `fmt.Stringer` implementation
 func (h *AuthHeaders) String() string {
 	return fmt.Sprintf("%s Credential=%s,SignedHeaders=%s,Signature=%s",
 		h.Algorithm,
-		fmt.Sprintf("%s/%s/%s/%s", h.Credential.ACCESS_KEY_ID, h.Credential.Date, h.Credential.Region, h.Credential.Service),
+		h.Credential.String(),
 		strings.Join(h.SignedHeaders, ";"),
 		h.Signature,
 	)
@@ -51,7 +62,7 @@ func (h *AuthHeaders) String() string {
 // Intended to be used serverside for verification of the request received
 func (s *SigV4) parseAuthHeaders(str string) (*AuthHeaders, error) {
 	authHeaders := new(AuthHeaders)
-	headers := strings.Split(str, " ")
+	headers := strings.Split(s.normalizeAuthorization(str), " ")
 
 	// Parse Authorization header
 	if len(headers) != 2 {
@@ -73,16 +84,11 @@ func (s *SigV4) parseAuthHeaders(str string) (*AuthHeaders, error) {
 			if len(credentials) != 2 || credentials[0] != "Credential" {
 				return authHeaders, fmt.Errorf("%s OR %s", ERROR_INCORRECT_FORMAT_HEADER, "Header name 'Credential' incorrect")
 			}
-			credentialValues := strings.Split(credentials[1], "/")
-			if len(credentialValues) != 5 {
-				return authHeaders, fmt.Errorf("%s: %s", ERROR_INCORRECT_FORMAT_HEADER, "Credential format error")
-			}
-			authHeaders.Credential = &AuthHeaderCredentials{
-				ACCESS_KEY_ID: credentialValues[0],
-				Date:          credentialValues[1],
-				Region:        credentialValues[2],
-				Service:       credentialValues[3],
+			credential, err := parseCredential(credentials[1])
+			if err != nil {
+				return authHeaders, fmt.Errorf("%s: %w", ERROR_INCORRECT_FORMAT_HEADER, err)
 			}
+			authHeaders.Credential = credential
 
 		case 1:
 			// SignedHeaders
@@ -91,6 +97,9 @@ func (s *SigV4) parseAuthHeaders(str string) (*AuthHeaders, error) {
 				return authHeaders, fmt.Errorf("%s OR %s", ERROR_INCORRECT_FORMAT_HEADER, "Header name 'SignedHeaders' incorrect")
 			}
 			authHeaders.SignedHeaders = strings.Split(signedHeaders[1], ";")
+			if err := s.checkSignedHeadersLimit(authHeaders.SignedHeaders); err != nil {
+				return authHeaders, err
+			}
 
 		case 2:
 			// Signature
@@ -102,126 +111,369 @@ func (s *SigV4) parseAuthHeaders(str string) (*AuthHeaders, error) {
 		}
 	}
 
-	// // Get `SecretAccessKey` using `secretRetrievalURL`. Once the AuthHeader is successfully parsed, retrieve the secret synchronously
-	// Once the AuthHeader is successfully parsed, retrieve the secret synchronously
-	secret, err := s.retrieveSecretWithRetry(context.Background(), authHeaders.Credential.ACCESS_KEY_ID)
-	if err != nil || secret == "" {
-		return nil, fmt.Errorf("failed to retrieve secret (either server endpoint not working or returning unexpected data): %v", err)
-	}
-
-	s.env.SECRET_ACCESS_KEY = secret
-
-	return authHeaders, err
+	return authHeaders, nil
 }
 
-// RetrieveSecret tries to get the secret access key, retrying up to 3 times in case of failure
-func (s *SigV4) retrieveSecretWithRetry(ctx context.Context, accessKeyID string) (string, error) {
-	const maxAttempts = 3
-	var lastErr error
+// Parses a credential: `ACCESS_KEY_ID/YYYYMMDD/region/service/aws4_request`
+func parseCredential(str string) (*AuthHeaderCredentials, error) {
+	credentialValues := strings.Split(str, "/")
+	if len(credentialValues) != 5 {
+		return nil, fmt.Errorf("Credential format error")
+	}
+	return &AuthHeaderCredentials{
+		ACCESS_KEY_ID: credentialValues[0],
+		Date:          credentialValues[1],
+		Region:        credentialValues[2],
+		Service:       credentialValues[3],
+		Terminator:    credentialValues[4],
+	}, nil
+}
 
-	for attempt := 0; attempt < maxAttempts; attempt++ {
-		if attempt > 0 {
-			// Exponential backoff: sleep for 2^attempt seconds before retrying
-			delay := time.Duration(1<<attempt) * time.Second
-			select {
-			case <-time.After(delay):
-			case <-ctx.Done():
-				return "", ctx.Err()
-			}
+// `secretAccessKey` retrieves the `SECRET_ACCESS_KEY` of the `ACCESS_KEY_ID` from the secret cache, or using the `secretRetrievalURL`.
+// The secret is sealed (See `WithSecretSealer`), to be unsealed while deriving the signing key (See `signingKeyFromSecret`).
+//
+// The `sessionToken` of temporary credentials, if any, is validated by the `secretRetrievalURL` on every request, hence bypasses the secret cache.
+func (s *SigV4) secretAccessKey(ctx context.Context, accessKeyID, sessionToken string) (SealedSecret, error) {
+	cache := s.secrets
+	if sessionToken != "" {
+		cache = nil
+	}
+	if cache != nil {
+		if secret, ok := cache.get(accessKeyID, s.now()); ok {
+			return secret, nil
 		}
+	}
+	// Concurrent lookups of the same credentials share a single retrieval
+	secret, err := s.flights.do(ctx, accessKeyID+"\n"+sessionToken, func(ctx context.Context) (string, error) {
+		return s.retrieveSecretWithRetry(ctx, accessKeyID, sessionToken)
+	})
+	if err != nil && cache != nil {
+		// Serve a stale secret while the secret backend is down. See `WithDegradedMode`.
+		if stale, ok := s.staleSecret(ctx, accessKeyID, err); ok {
+			return stale, nil
+		}
+	}
+	if err != nil || secret == "" {
+		return nil, fmt.Errorf("%w: failed to retrieve secret (either server endpoint not working or returning unexpected data): %w", auth.ErrSecretUnavailable, err)
+	}
+	sealed, err := s.sealSecret([]byte(secret))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", auth.ErrSecretUnavailable, err)
+	}
+	if cache != nil {
+		cache.set(accessKeyID, sealed, s.now())
+	}
+	return sealed, nil
+}
 
-		secret, err := s.retrieveSecret(ctx, accessKeyID)
-		if err == nil {
-			return secret, nil
+// `negotiateAlgorithm` returns the authorization to verify: the `Authorization` header if its algorithm is accepted,
+// else the `X-[Abbr]-Fallback-Authorization` header if its algorithm is accepted. See `WithAcceptedAlgorithms`.
+func (s *SigV4) negotiateAlgorithm(req *http.Request, authHeaders *AuthHeaders) (*AuthHeaders, error) {
+	if s.isAcceptedAlgorithm(authHeaders.Algorithm) {
+		return authHeaders, nil
+	}
+	if fallback := getHeader(req.Header, s.fallbackHeaderName()); fallback != "" {
+		fallbackHeaders, err := s.parseAuthHeaders(fallback)
+		if err == nil && s.isAcceptedAlgorithm(fallbackHeaders.Algorithm) {
+			return fallbackHeaders, nil
 		}
-		lastErr = err
 	}
+	return authHeaders, fmt.Errorf("%s: %q", ERROR_INCORRECT_ALGORITHM, authHeaders.Algorithm)
+}
+
+// The default backoff of the retries of the secret retrieval: 2 retries, after 2 and 4 seconds
+var DEFAULT_SECRET_RETRIEVAL_BACKOFF = backoff.Exponential(2*time.Second, 4*time.Second, 2)
 
-	return "", fmt.Errorf("exceeded maximum attempts: %w", lastErr)
+// `retrieveSecretWithRetry` tries to get the secret access key, retrying in case of failure. See `WithSecretRetrievalBackoff`.
+func (s *SigV4) retrieveSecretWithRetry(ctx context.Context, accessKeyID, sessionToken string) (string, error) {
+	strategy := s.secretRetrievalBackoff
+	if strategy == nil {
+		strategy = DEFAULT_SECRET_RETRIEVAL_BACKOFF
+	}
+	var secret string
+	err := backoff.Retry(ctx, strategy, func(ctx context.Context) (err error) {
+		secret, err = s.retrieveSecret(ctx, accessKeyID, sessionToken)
+		return err
+	})
+	return secret, err
 }
 
-// `retrieveSecret` makes one attempt to retrieve the secret access key, observing the provided context's deadline
-func (s *SigV4) retrieveSecret(ctx context.Context, accessKeyID string) (string, error) {
-	payload, err := json.Marshal(map[string]string{"access_key_id": accessKeyID})
-	if err != nil {
-		return "", err
+// `retrieveSecret` makes one attempt to retrieve the secret access key, observing the provided context's deadline.
+// The `sessionToken`, if any, is sent along for the `secretRetrievalURL` to validate, which must respond with a non-OK status if it is invalid.
+// The request and the response follow the wire contract of `secretapi`, batched with the lookups of other access keys if configured (See `WithSecretBatching`).
+func (s *SigV4) retrieveSecret(ctx context.Context, accessKeyID, sessionToken string) (_ string, err error) {
+	body := &secretapi.Request{AccessKeyID: accessKeyID, SessionToken: sessionToken}
+	if s.secretResponseKey != nil {
+		if body.Nonce, err = newNonce(s.entropy); err != nil {
+			return "", err
+		}
 	}
 
-	req, err := http.NewRequestWithContext(ctx, "POST", s.secretRetrievalURL, bytes.NewBuffer(payload))
+	var resp *secretapi.Response
+	if s.secretBatcher != nil {
+		resp, err = s.secretBatcher.retrieve(ctx, body)
+	} else {
+		resp, err = secretapi.NewClient(s.secretRetrievalURL, &http.Client{Timeout: 15 * time.Second}).Retrieve(ctx, body)
+	}
 	if err != nil {
 		return "", err
 	}
+	if s.secretResponseKey != nil {
+		expected := SecretResponseSignature(s.secretResponseKey, accessKeyID, body.Nonce, resp.SecretAccessKey)
+		if !hmac.Equal([]byte(expected), []byte(resp.Signature)) {
+			return "", backoff.Permanent(ErrSecretResponseSignature)
+		}
+	}
 
-	client := http.Client{Timeout: 15 * time.Second}
-	res, err := client.Do(req)
-	if err != nil {
-		return "", err
+	return resp.SecretAccessKey, nil
+}
+
+// Verify the signature on the server
+//
+// Both requests signed in the `Authorization` header and presigned requests (signed in the query, See `PresignHTTPRequest`) are accepted.
+func (s *SigV4) VerifySignature(req *http.Request) error {
+	_, err := s.Authenticate(req)
+	return err
+}
+
+// Verify the signature on the server, and return the Identity of the client that signed the request. Implements `auth.Authenticator`.
+//
+// Like `VerifySignature`, presigned requests are accepted, and verified like `AuthenticatePresigned`.
+func (s *SigV4) Authenticate(req *http.Request) (*auth.Identity, error) {
+	return s.AuthenticateContext(req.Context(), req)
+}
+
+// Verify the signature on the server like `VerifySignature`, observing the deadline and the cancellation of `ctx`
+// instead of the context of the request while reading the body and retrieving the secret. Implements `auth.ContextVerifier`.
+func (s *SigV4) VerifySignatureContext(ctx context.Context, req *http.Request) error {
+	_, err := s.AuthenticateContext(ctx, req)
+	return err
+}
+
+// Verify the signature on the server like `Authenticate`, observing the deadline and the cancellation of `ctx`. See `VerifySignatureContext`.
+func (s *SigV4) AuthenticateContext(ctx context.Context, req *http.Request) (*auth.Identity, error) {
+	report := new(VerificationReport)
+	identity, err := s.verifyRequest(ctx, req, report)
+	if s.usage != nil && report.KeyID != "" {
+		s.usage.record(report.KeyID, report.Verified, s.now())
 	}
-	defer res.Body.Close()
+	return identity, err
+}
 
-	if res.StatusCode != http.StatusOK {
-		bodyBytes, _ := io.ReadAll(res.Body) // Ignoring error on purpose, main error is from status code
-		return "", fmt.Errorf("non-OK HTTP status: %d, body: %s", res.StatusCode, string(bodyBytes))
+// `identity` returns the Identity of the client that signed a request with the `credential` at the `signingTime`.
+// The scope date is computed from the `signingTime`, as the signature is.
+func (s *SigV4) identity(algorithm string, credential *AuthHeaderCredentials, signingTime time.Time, signedHeaders []string) *auth.Identity {
+	return &auth.Identity{
+		KeyID:         credential.ACCESS_KEY_ID,
+		Org:           s.org,
+		Service:       credential.Service,
+		Region:        credential.Region,
+		ScopeDate:     s.scopeDate(signingTime),
+		SignedHeaders: signedHeaders,
+		Algorithm:     algorithm,
 	}
+}
 
-	var resp secretretrievalResponse
-	if err = json.NewDecoder(res.Body).Decode(&resp); err != nil {
-		return "", err
+// `signedHeadersRequest` returns a clone of the request carrying only the `signedHeaders`, with the canonical host, to be canonicalized
+func (s *SigV4) signedHeadersRequest(req *http.Request, signedHeaders []string) (*http.Request, error) {
+	clonedReq := req.Clone(req.Context())
+	clear(clonedReq.Header) // clear all Headers; we will reassign only signed headers
+	clonedReq.Host = s.canonicalHost(req)
+	// Set signed headers to clonedReq
+	for _, header := range signedHeaders {
+		if header == "host" {
+			continue
+		}
+		if s.isSkippedHeader(header) {
+			return nil, fmt.Errorf("%s: %s", ERROR_SKIPPED_HEADER_SIGNED, header)
+		}
+		if !s.isIncludedHeader(header) {
+			return nil, fmt.Errorf("%s: %s", ERROR_HEADER_NOT_INCLUDED, header)
+		}
+		clonedReq.Header[http.CanonicalHeaderKey(header)] = headerValues(req.Header, header)
 	}
+	return clonedReq, nil
+}
 
-	return resp.SECRET_ACCESS_KEY, nil
+// `verifyRequest` runs the verification pipeline of the authentication mode of the request: query authentication
+// if the request has no `Authorization` credentials with an accepted algorithm and a signature query parameter (See `isPresigned`), else header authentication.
+func (s *SigV4) verifyRequest(ctx context.Context, req *http.Request, report *VerificationReport) (*auth.Identity, error) {
+	if err := s.checkHeaderLimits(req.Header); err != nil {
+		report.FailedStage = STAGE_PARSE
+		return nil, err
+	}
+	if s.isSkippedPreflight(req) {
+		report.PreflightSkipped = true
+		return nil, nil
+	}
+	if _, ok := s.signatureAuthorization(req); !ok && s.isPresigned(req) {
+		return s.withBudget(ctx, req, report, s.verifyPresigned)
+	}
+	return s.withBudget(ctx, req, report, s.verify)
 }
 
-// Verify the signature on the server
-func (s *SigV4) VerifySignature(req *http.Request) error {
+// `verify` runs the verification pipeline, recording the non-sensitive inputs of each stage and the stage that failed in the `report`.
+func (s *SigV4) verify(ctx context.Context, req *http.Request, report *VerificationReport) (*auth.Identity, error) {
+	fail := func(stage string, err error) (*auth.Identity, error) {
+		report.FailedStage = stage
+		return nil, err
+	}
+
 	// Extract request parameters
-	authHeaders, err := s.parseAuthHeaders(req.Header.Get("Authorization"))
+	if getHeader(req.Header, "Authorization") == "" {
+		return fail(STAGE_PARSE, fmt.Errorf("%w: %s", auth.ErrSignatureMissing, ERROR_SIGNATURE_MISSING))
+	}
+	authorization, _ := s.signatureAuthorization(req)
+	authHeaders, err := s.parseAuthHeaders(authorization)
 	if err != nil {
-		return err
+		return fail(STAGE_PARSE, err)
+	}
+	if authHeaders, err = s.negotiateAlgorithm(req, authHeaders); err != nil {
+		return fail(STAGE_ALGORITHM, err)
 	}
+	report.Algorithm = authHeaders.Algorithm
+	report.KeyID = authHeaders.Credential.ACCESS_KEY_ID
+	report.ReceivedScope = strings.SplitN(authHeaders.Credential.String(), "/", 2)[1]
+	report.SignedHeaders = authHeaders.SignedHeaders
 
-	date := req.Header.Get(s.dateHeader())
+	report.Date = getHeader(req.Header, s.dateHeader())
+	signingTime, err := s.parseDate(report.Date)
+	if err != nil {
+		return fail(STAGE_DATE, err)
+	}
+	report.ComputedScope = s.getCredentialScope(signingTime, authHeaders.Credential.Region, authHeaders.Credential.Service)
+	if err := checkCredentialScope(report.ReceivedScope, report.ComputedScope); err != nil {
+		return fail(STAGE_SCOPE, err)
+	}
 
-	if authHeaders.Algorithm != "AWS4-HMAC-SHA256" {
-		return fmt.Errorf(ERROR_INCORRECT_ALGORITHM)
+	identity := s.identity(authHeaders.Algorithm, authHeaders.Credential, signingTime, authHeaders.SignedHeaders)
+	if s.requestID {
+		// The request ID must be signed to be tamper-evident
+		if !slices.Contains(authHeaders.SignedHeaders, strings.ToLower(s.requestIDHeaderName())) {
+			return fail(STAGE_SIGNED_HEADERS, fmt.Errorf("%s: %s", ERROR_REQUEST_ID_NOT_SIGNED, s.requestIDHeaderName()))
+		}
+		identity.RequestID = getHeader(req.Header, s.requestIDHeaderName())
+	}
+	if identity.IdempotencyKey, err = s.checkIdempotencyKey(req, authHeaders.SignedHeaders); err != nil {
+		return fail(STAGE_SIGNED_HEADERS, err)
+	}
+
+	// The security token must be signed, so that it cannot be swapped for the token of another session
+	sessionToken := getHeader(req.Header, s.securityTokenHeader())
+	if sessionToken != "" && !slices.Contains(authHeaders.SignedHeaders, strings.ToLower(s.securityTokenHeader())) {
+		return fail(STAGE_SIGNED_HEADERS, fmt.Errorf("%s: %s", ERROR_SECURITY_TOKEN_NOT_SIGNED, s.securityTokenHeader()))
+	}
+	if err := s.checkRequiredHeaders(req, authHeaders.SignedHeaders); err != nil {
+		return fail(STAGE_SIGNED_HEADERS, err)
 	}
 
 	// Prepare canonical request.
-	clonedReq := req.Clone(context.Background())
-	clear(clonedReq.Header) // clear all Headers; we will reassign only signed headers
-	// Set signed headers to clonedReq
-	for _, header := range authHeaders.SignedHeaders {
-		if header == "host" {
-			continue
+	clonedReq, err := s.signedHeadersRequest(req, authHeaders.SignedHeaders)
+	if err != nil {
+		return fail(STAGE_SIGNED_HEADERS, err)
+	}
+
+	// Throttle before retrieving the secret, to protect the secret backend
+	if s.rateLimiter != nil {
+		if err := s.allow(authHeaders.Credential.ACCESS_KEY_ID); err != nil {
+			return fail(STAGE_RATE_LIMIT, err)
 		}
-		clonedReq.Header.Set(header, req.Header.Get(header))
 	}
 
-	canonicalRequest, err := s.canonicalRequest(clonedReq)
+	// Once the AuthHeader is successfully parsed and validated, retrieve the secret synchronously.
+	// Retries and backoff observe the context of the request, so that a canceled request does not hold the Verifier.
+	secret, err := s.secretAccessKey(ctx, authHeaders.Credential.ACCESS_KEY_ID, sessionToken)
 	if err != nil {
-		return err
+		return fail(STAGE_SECRET, err)
+	}
+
+	// Streaming payloads are not buffered: the chunks (or the event stream frames) are verified as the body is read
+	// (See `SignStreamingHTTPRequest` and `SignEventStreamHTTPRequest`)
+	payloadHash, contentLength := s.streamingPayloadHash(req, authHeaders.Algorithm, authHeaders.SignedHeaders), clonedReq.ContentLength
+	streaming := payloadHash != ""
+	events := payloadHash == streamingEventsPayload(authHeaders.Algorithm)
+	var decodedContentLength int64
+	var trailers []string
+	if streaming && !events {
+		if decodedContentLength, err = s.decodedContentLength(req, authHeaders.SignedHeaders); err != nil {
+			return fail(STAGE_CANONICALIZE, err)
+		}
+		if payloadHash == streamingTrailerPayload(authHeaders.Algorithm) {
+			if trailers, err = s.declaredTrailers(req, authHeaders.SignedHeaders); err != nil {
+				return fail(STAGE_CANONICALIZE, err)
+			}
+		}
 	}
-	req.Body = clonedReq.Body // The req.Body gets read inside the canonicalRequest, and needs to be reassigned
+	if !streaming {
+		if payloadHash, contentLength, err = s.payloadHash(ctx, clonedReq); err != nil {
+			return fail(STAGE_CANONICALIZE, err)
+		}
+		req.Body = clonedReq.Body // The req.Body gets read to hash the payload, and needs to be reassigned
+	}
+	if err := s.checkContentSHA256(req, authHeaders.SignedHeaders, payloadHash); err != nil {
+		return fail(STAGE_CANONICALIZE, err)
+	}
+	canonicalRequest, err := s.signedCanonicalRequest(clonedReq, authHeaders.SignedHeaders, payloadHash, contentLength)
+	if err != nil {
+		return fail(STAGE_CANONICALIZE, err)
+	}
+	// Hashing the payload does not observe the context
+	if err := checkBudget(ctx); err != nil {
+		return fail(STAGE_CANONICALIZE, err)
+	}
+	report.CanonicalRequestHash = sigv4core.HashPayload([]byte(canonicalRequest))
 
 	// Prepare string-to-sign
-	stringToSign := s.stringToSign(date, authHeaders.Credential.Region, authHeaders.Credential.Service, canonicalRequest)
+	stringToSign := s.stringToSign(authHeaders.Algorithm, signingTime, authHeaders.Credential.Region, authHeaders.Credential.Service, canonicalRequest)
 
 	// Derive signing key
-	signingKey, err := s.signingKey(s.env.SECRET_ACCESS_KEY, date, authHeaders.Credential.Region, authHeaders.Credential.Service)
+	signingKey, err := s.signingKeyFromSecret(authHeaders.Algorithm, secret, signingTime, authHeaders.Credential.Region, authHeaders.Credential.Service)
 	if err != nil {
-		return err
+		return fail(STAGE_SIGNATURE, err)
 	}
 
 	// Calculate computed signature
-	computedSignature, err := s.generateSignature(signingKey, stringToSign)
+	computedSignature, err := s.generateSignature(authHeaders.Algorithm, signingKey, stringToSign)
 	if err != nil {
-		return err
+		return fail(STAGE_SIGNATURE, err)
+	}
+
+	// Compare computed signature with the received signature, in constant time
+	if !hmac.Equal([]byte(computedSignature), []byte(authHeaders.Signature)) {
+		return fail(STAGE_SIGNATURE, fmt.Errorf("%w: %s", auth.ErrSignatureMismatch, ERROR_SIGNATURE_MISMATCH))
 	}
 
-	// Compare computed signature with the received signature
-	if computedSignature != authHeaders.Signature {
-		return fmt.Errorf(ERROR_SIGNATURE_MISMATCH)
+	// Replay protection, only for authentic requests so that forged requests cannot fill the store
+	if s.replayStore != nil {
+		if err := s.checkReplay(authHeaders.Signature, signingTime); err != nil {
+			return fail(STAGE_REPLAY, err)
+		}
+	}
+
+	// Verify the frames of an event stream as the body is read, with the seed signature
+	if events && req.Body != nil {
+		req.Body = s.newEventStreamReader(req.Body, authHeaders.Algorithm, report.ComputedScope, authHeaders.Signature, signingKey)
+	}
+
+	// Verify the chunks of a streaming payload as the body is read, with the seed signature
+	// The declared trailers are added to the `Trailer` of the request, with their values set once verified at the end of the body.
+	if streaming && !events && req.Body != nil {
+		if len(trailers) > 0 && req.Trailer == nil {
+			req.Trailer = make(http.Header, len(trailers))
+		}
+		for _, name := range trailers {
+			req.Trailer[http.CanonicalHeaderKey(name)] = nil
+		}
+		req.Body = s.newChunkedReader(req.Body, decodedContentLength, authHeaders.Algorithm, report.Date, report.ComputedScope, authHeaders.Signature, signingKey, trailers, req.Trailer)
+	}
+
+	// Verify the earlier hops, once the signature binding them is verified
+	if s.chain && !report.hop {
+		if identity.Chain, err = s.verifyChain(ctx, req); err != nil {
+			return fail(STAGE_CHAIN, err)
+		}
 	}
 
-	return nil
+	report.Verified = true
+	return identity, nil
 }
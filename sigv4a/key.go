@@ -0,0 +1,75 @@
+package sigv4a
+
+import (
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"encoding/binary"
+	"errors"
+	"math/big"
+
+	"github.com/jayantasamaddar/go-httpsigner/utils"
+)
+
+// Errors
+const (
+	ERROR_KEY_DERIVATION_EXHAUSTED = "no valid private key derived within the maximum counter"
+)
+
+// `n - 2`, where `n` is the order of the P-256 curve
+var nMinusTwo = new(big.Int).Sub(elliptic.P256().Params().N, big.NewInt(2))
+
+// # Derive the Private Key
+//
+// Derives the ECDSA P-256 private key of an `ACCESS_KEY_ID` and `SECRET_ACCESS_KEY` pair, as AWS does for SigV4A.
+// The Verifier is given the public key (`PrivateKey.PublicKey`) of the derived key.
+//
+// The key derivation is NIST SP 800-108 in counter mode, with HMAC-SHA256 as the PRF:
+//
+//	InputKey   = "AWS4A" + secret
+//	FixedInput = i || "AWS4-ECDSA-P256-SHA256" || 0x00 || accessKeyID || counter || L
+//	Candidate  = HMAC-SHA256(InputKey, FixedInput)
+//
+// where `i` is 1 and `L` is 256, both as 32-bit big-endian integers, and `counter` is a single byte starting at 1.
+// The counter is incremented until `Candidate <= n - 2`, and the private key is `Candidate + 1`, so that it is in `[1, n - 1]`.
+func DerivePrivateKey(accessKeyID, secret string) (*ecdsa.PrivateKey, error) {
+	return derivePrivateKey("AWS4A", accessKeyID, secret)
+}
+
+// `derivePrivateKey` derives the private key like `DerivePrivateKey`, with the prefix of the input key of the org
+func derivePrivateKey(keyPrefix, accessKeyID, secret string) (*ecdsa.PrivateKey, error) {
+	inputKey := []byte(keyPrefix + secret)
+	for counter := 1; counter <= 0xff; counter++ {
+		fixedInput := binary.BigEndian.AppendUint32(nil, 1)
+		fixedInput = append(fixedInput, ALGORITHM_ECDSA_P256_SHA256...)
+		fixedInput = append(fixedInput, 0x00)
+		fixedInput = append(fixedInput, accessKeyID...)
+		fixedInput = append(fixedInput, byte(counter))
+		fixedInput = binary.BigEndian.AppendUint32(fixedInput, 256)
+
+		candidate := new(big.Int).SetBytes(utils.HMAC_SHA256.Sum(inputKey, fixedInput))
+		if candidate.Cmp(nMinusTwo) > 0 {
+			continue
+		}
+		return newPrivateKey(candidate.Add(candidate, big.NewInt(1)))
+	}
+	return nil, errors.New(ERROR_KEY_DERIVATION_EXHAUSTED)
+}
+
+// `newPrivateKey` returns the P-256 private key of the scalar `d`, computing the public key with `crypto/ecdh`
+func newPrivateKey(d *big.Int) (*ecdsa.PrivateKey, error) {
+	key, err := ecdh.P256().NewPrivateKey(d.FillBytes(make([]byte, 32)))
+	if err != nil {
+		return nil, err
+	}
+	// The uncompressed point: 0x04 || X || Y
+	point := key.PublicKey().Bytes()
+	return &ecdsa.PrivateKey{
+		PublicKey: ecdsa.PublicKey{
+			Curve: elliptic.P256(),
+			X:     new(big.Int).SetBytes(point[1:33]),
+			Y:     new(big.Int).SetBytes(point[33:]),
+		},
+		D: d,
+	}, nil
+}
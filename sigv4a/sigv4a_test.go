@@ -0,0 +1,238 @@
+package sigv4a
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jayantasamaddar/go-httpsigner/auth"
+)
+
+const (
+	testAccessKeyID = "AKISORANDOMAASORANDOM"
+	testSecret      = "q+jcrXGc+0zWN6uzclKVhvMmUsIfRPa4rlRandom"
+)
+
+func Test_DerivePrivateKey(t *testing.T) {
+	// The key pair of the SigV4A tests of aws-sdk-go-v2
+	key, err := DerivePrivateKey(testAccessKeyID, testSecret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "7fd3bd010c0d9c292141c2b77bfbde1042c92e6836fff749d1269ec890fca1bd"
+	if d := fmt.Sprintf("%064x", key.D); d != expected {
+		t.Errorf("Expected: %s, got: %s", expected, d)
+	}
+	if !key.Curve.IsOnCurve(key.X, key.Y) {
+		t.Error("Expected the public key to be on the curve")
+	}
+}
+
+// Returns a Signer and a Verifier sharing the key pair of the test credentials
+func newTestSignerVerifier(t *testing.T, signerOpts, verifierOpts []Option) (*SigV4A, *SigV4A) {
+	t.Helper()
+	signer, err := NewSigV4ASigner("", "", "myapplication", testAccessKeyID, testSecret, signerOpts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := DerivePrivateKey(testAccessKeyID, testSecret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	verifier, err := NewSigV4AVerifier("", "", "myapplication", StaticPublicKeys(map[string]*ecdsa.PublicKey{testAccessKeyID: &key.PublicKey}), verifierOpts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signer.(*SigV4A), verifier.(*SigV4A)
+}
+
+func Test_SigV4A_SignAndVerify(t *testing.T) {
+	signer, verifier := newTestSignerVerifier(t, nil, []Option{WithRegion("eu-west-1")})
+
+	req, _ := http.NewRequest(http.MethodPost, "https://example.com/items?b=2&a=1", strings.NewReader(`{"name":"item"}`))
+	req.Header.Set("Content-Type", "application/json")
+	if err := signer.SignHTTPRequest(req); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(req.Header.Get("Authorization"), ALGORITHM_ECDSA_P256_SHA256+" Credential="+testAccessKeyID+"/") {
+		t.Errorf("Unexpected Authorization header: %s", req.Header.Get("Authorization"))
+	}
+	if regionSet := req.Header.Get("X-Amz-Region-Set"); regionSet != REGION_SET_ALL {
+		t.Errorf("Expected: %s, got: %s", REGION_SET_ALL, regionSet)
+	}
+
+	identity, err := verifier.Authenticate(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if identity.KeyID != testAccessKeyID || identity.Region != REGION_SET_ALL || identity.Algorithm != ALGORITHM_ECDSA_P256_SHA256 {
+		t.Errorf("Unexpected identity: %s", identity)
+	}
+
+	// Tampering with the payload
+	req.Body = http.NoBody
+	if err := verifier.VerifySignature(req); !errors.Is(err, auth.ErrSignatureMismatch) {
+		t.Errorf("Expected: %v, got: %v", auth.ErrSignatureMismatch, err)
+	}
+}
+
+func Test_SigV4A_RegionSet(t *testing.T) {
+	tests := []struct {
+		regionSet []string
+		region    string
+		valid     bool
+	}{
+		{[]string{"*"}, "ap-south-1", true},
+		{[]string{"us-east-1", "eu-west-1"}, "eu-west-1", true},
+		{[]string{"us-*"}, "us-west-2", true},
+		{[]string{"us-*"}, "eu-west-1", false},
+		{[]string{"us-east-1"}, "us-east-2", false},
+	}
+	for _, test := range tests {
+		signer, verifier := newTestSignerVerifier(t, []Option{WithRegionSet(test.regionSet...)}, []Option{WithRegion(test.region)})
+		req, _ := http.NewRequest(http.MethodGet, "https://example.com/", nil)
+		if err := signer.SignHTTPRequest(req); err != nil {
+			t.Fatal(err)
+		}
+		err := verifier.VerifySignature(req)
+		if test.valid && err != nil {
+			t.Errorf("%v in %s: expected no error, got: %v", test.regionSet, test.region, err)
+		}
+		if !test.valid && !errors.Is(err, auth.ErrConstraintViolated) {
+			t.Errorf("%v in %s: expected: %v, got: %v", test.regionSet, test.region, auth.ErrConstraintViolated, err)
+		}
+	}
+
+	// Tampering with the region set
+	signer, verifier := newTestSignerVerifier(t, []Option{WithRegionSet("us-east-1")}, nil)
+	req, _ := http.NewRequest(http.MethodGet, "https://example.com/", nil)
+	if err := signer.SignHTTPRequest(req); err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("X-Amz-Region-Set", REGION_SET_ALL)
+	if err := verifier.VerifySignature(req); !errors.Is(err, auth.ErrSignatureMismatch) {
+		t.Errorf("Expected: %v, got: %v", auth.ErrSignatureMismatch, err)
+	}
+
+	if _, err := NewSigV4ASigner("", "", "myapplication", testAccessKeyID, testSecret, WithRegionSet("us-east-1,eu-west-1")); err == nil {
+		t.Error("Expected an error for an invalid region set")
+	}
+}
+
+func Test_SigV4A_Errors(t *testing.T) {
+	signer, verifier := newTestSignerVerifier(t, nil, nil)
+
+	// Expired
+	req, _ := http.NewRequest(http.MethodGet, "https://example.com/", nil)
+	if err := signer.signHTTPRequestAt(req.Context(), req, time.Now().Add(-time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if err := verifier.VerifySignature(req); !errors.Is(err, auth.ErrSignatureExpired) {
+		t.Errorf("Expected: %v, got: %v", auth.ErrSignatureExpired, err)
+	}
+
+	// Unknown access key
+	other, err := NewSigV4ASigner("", "", "myapplication", "AKIDOTHER", testSecret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req, _ = http.NewRequest(http.MethodGet, "https://example.com/", nil)
+	if err := other.SignHTTPRequest(req); err != nil {
+		t.Fatal(err)
+	}
+	if err := verifier.VerifySignature(req); !errors.Is(err, auth.ErrSecretUnavailable) {
+		t.Errorf("Expected: %v, got: %v", auth.ErrSecretUnavailable, err)
+	}
+
+	// Another service
+	otherService, err := NewSigV4ASigner("", "", "otherapplication", testAccessKeyID, testSecret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req, _ = http.NewRequest(http.MethodGet, "https://example.com/", nil)
+	if err := otherService.SignHTTPRequest(req); err != nil {
+		t.Fatal(err)
+	}
+	if err := verifier.VerifySignature(req); !errors.Is(err, auth.ErrConstraintViolated) {
+		t.Errorf("Expected: %v, got: %v", auth.ErrConstraintViolated, err)
+	}
+
+	// Malformed header
+	req.Header.Set("Authorization", "AWS4-HMAC-SHA256 Credential=a/b/c/d/e,SignedHeaders=host,Signature=00")
+	if err := verifier.VerifySignature(req); err == nil || !strings.Contains(err.Error(), ERROR_UNSUPPORTED_ALGORITHM) {
+		t.Errorf("Expected: %s, got: %v", ERROR_UNSUPPORTED_ALGORITHM, err)
+	}
+
+	if _, err := NewSigV4AVerifier("", "", "myapplication", nil); err == nil {
+		t.Error("Expected an error without a PublicKeyResolver")
+	}
+}
+
+// Test that the randomness of the signatures is read from the entropy source
+func Test_SigV4A_WithEntropySource(t *testing.T) {
+	signer, verifier := newTestSignerVerifier(t, []Option{WithEntropySource(strings.NewReader(strings.Repeat("x", 1024)))}, nil)
+	req, _ := http.NewRequest(http.MethodGet, "https://example.com/", nil)
+	if err := signer.SignHTTPRequest(req); err != nil {
+		t.Fatal(err)
+	}
+	if err := verifier.VerifySignature(req); err != nil {
+		t.Error(err)
+	}
+
+	signer, _ = newTestSignerVerifier(t, []Option{WithEntropySource(strings.NewReader(""))}, nil)
+	req, _ = http.NewRequest(http.MethodGet, "https://example.com/", nil)
+	if err := signer.SignHTTPRequest(req); err == nil {
+		t.Error("Expected an error from an exhausted entropy source")
+	}
+}
+
+// Test that signing and verification observe the deadline and the cancellation of the context passed to them
+func Test_SigV4A_Context(t *testing.T) {
+	var _ auth.ContextSigner = (*SigV4A)(nil)
+	var _ auth.ContextVerifier = (*SigV4A)(nil)
+
+	signer, verifier := newTestSignerVerifier(t, nil, nil)
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	req, _ := http.NewRequest(http.MethodPost, "https://example.com/items", strings.NewReader(`{"name":"item"}`))
+	if err := signer.SignHTTPRequestContext(canceled, req); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled while signing, got: %v", err)
+	}
+	if err := signer.SignHTTPRequestContext(context.Background(), req); err != nil {
+		t.Fatal(err)
+	}
+	if err := verifier.VerifySignatureContext(canceled, req); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled while verifying, got: %v", err)
+	}
+	if err := verifier.VerifySignatureContext(context.Background(), req); err != nil {
+		t.Error(err)
+	}
+}
+
+// Test that the Signer dates the signatures with the clock, and that the Verifier checks the skew against its own clock
+func Test_SigV4A_WithClock(t *testing.T) {
+	signingTime := time.Date(2024, time.March, 15, 10, 30, 0, 0, time.UTC)
+	clock := func() time.Time { return signingTime }
+	signer, verifier := newTestSignerVerifier(t, []Option{WithClock(clock)}, []Option{WithClock(clock)})
+
+	req, _ := http.NewRequest(http.MethodGet, "https://example.com/items", nil)
+	if err := signer.SignHTTPRequest(req); err != nil {
+		t.Fatal(err)
+	}
+	if date := req.Header.Get("X-Amz-Date"); date != signingTime.Format(DATE_FORMAT) {
+		t.Errorf("Expected the date of the clock: %s, got: %s", signingTime.Format(DATE_FORMAT), date)
+	}
+	if err := verifier.VerifySignature(req); err != nil {
+		t.Error(err)
+	}
+	_, wallClock := newTestSignerVerifier(t, nil, nil)
+	if err := wallClock.VerifySignature(req); !errors.Is(err, auth.ErrSignatureExpired) {
+		t.Errorf("Expected: %v, got: %v", auth.ErrSignatureExpired, err)
+	}
+}
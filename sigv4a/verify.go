@@ -0,0 +1,179 @@
+package sigv4a
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/jayantasamaddar/go-httpsigner/auth"
+	"github.com/jayantasamaddar/go-httpsigner/sigv4core"
+	"github.com/jayantasamaddar/go-httpsigner/utils"
+)
+
+// The parsed `Authorization` header of a SigV4A request
+type authHeaders struct {
+	accessKeyID   string
+	date          string
+	service       string
+	terminator    string
+	signedHeaders []string
+	signature     []byte
+}
+
+// Parses the `Authorization` header:
+//
+//	AWS4-ECDSA-P256-SHA256 Credential=ACCESS_KEY_ID/YYYYMMDD/service/aws4_request, SignedHeaders=..., Signature=...
+func parseAuthHeaders(str string) (*authHeaders, error) {
+	algorithm, rest, ok := strings.Cut(str, " ")
+	if !ok {
+		return nil, fmt.Errorf(ERROR_INCORRECT_FORMAT_HEADER)
+	}
+	if algorithm != ALGORITHM_ECDSA_P256_SHA256 {
+		return nil, fmt.Errorf("%s: %q", ERROR_UNSUPPORTED_ALGORITHM, algorithm)
+	}
+
+	h := new(authHeaders)
+	parts := strings.Split(rest, ",")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf(ERROR_INCORRECT_FORMAT_HEADER)
+	}
+	for i, name := range []string{"Credential", "SignedHeaders", "Signature"} {
+		key, value, ok := strings.Cut(strings.TrimSpace(parts[i]), "=")
+		if !ok || key != name {
+			return nil, fmt.Errorf("%s: Header name '%s' incorrect", ERROR_INCORRECT_FORMAT_HEADER, name)
+		}
+		switch name {
+		case "Credential":
+			credential := strings.Split(value, "/")
+			if len(credential) != 4 {
+				return nil, fmt.Errorf("%s: Credential format error", ERROR_INCORRECT_FORMAT_HEADER)
+			}
+			h.accessKeyID, h.date, h.service, h.terminator = credential[0], credential[1], credential[2], credential[3]
+		case "SignedHeaders":
+			h.signedHeaders = strings.Split(value, ";")
+		case "Signature":
+			signature, err := hex.DecodeString(value)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", ERROR_INCORRECT_FORMAT_SIGNATURE, err)
+			}
+			h.signature = signature
+		}
+	}
+	return h, nil
+}
+
+// Verifies the signature of the request. Errors wrap the `auth` sentinel errors where applicable.
+func (s *SigV4A) VerifySignature(req *http.Request) error {
+	_, err := s.Authenticate(req)
+	return err
+}
+
+// Verifies the signature of the request, and returns the `Identity` of the client. The `Identity.Region` is the signed region set.
+func (s *SigV4A) Authenticate(req *http.Request) (*auth.Identity, error) {
+	return s.AuthenticateContext(req.Context(), req)
+}
+
+// Verifies the signature of the request like `VerifySignature`, observing the deadline and the cancellation of `ctx`
+// instead of the context of the request while resolving the public key and reading the body. Implements `auth.ContextVerifier`.
+func (s *SigV4A) VerifySignatureContext(ctx context.Context, req *http.Request) error {
+	_, err := s.AuthenticateContext(ctx, req)
+	return err
+}
+
+// Verifies the signature of the request like `Authenticate`, observing the deadline and the cancellation of `ctx`. See `VerifySignatureContext`.
+func (s *SigV4A) AuthenticateContext(ctx context.Context, req *http.Request) (*auth.Identity, error) {
+	if req.Header.Get("Authorization") == "" {
+		return nil, fmt.Errorf("%w: %s", auth.ErrSignatureMissing, ERROR_SIGNATURE_MISSING)
+	}
+	h, err := parseAuthHeaders(req.Header.Get("Authorization"))
+	if err != nil {
+		return nil, err
+	}
+	if h.service != s.service || h.terminator != s.terminator() {
+		return nil, fmt.Errorf("%w: %s: %s", auth.ErrConstraintViolated, ERROR_SERVICE_MISMATCH, h.service)
+	}
+
+	// The date and the region set must be signed
+	for _, header := range []string{"host", s.dateHeader(), s.regionSetHeader()} {
+		if !slices.Contains(h.signedHeaders, strings.ToLower(header)) {
+			return nil, fmt.Errorf("%s: %s", ERROR_REQUIRED_HEADER_NOT_SIGNED, header)
+		}
+	}
+
+	date := req.Header.Get(s.dateHeader())
+	signingTime, err := time.Parse(DATE_FORMAT, date)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", ERROR_INCORRECT_FORMAT_DATE, err)
+	}
+	if h.date != signingTime.Format("20060102") {
+		return nil, fmt.Errorf("%s: %s", ERROR_SCOPE_DATE_MISMATCH, h.date)
+	}
+	if age := s.now().Sub(signingTime); age > s.maxSkew || age < -s.maxSkew {
+		return nil, fmt.Errorf("%w: %s", auth.ErrSignatureExpired, ERROR_REQUEST_OUTSIDE_SKEW)
+	}
+
+	regionSet := req.Header.Get(s.regionSetHeader())
+	if s.region != "" && !inRegionSet(s.region, strings.Split(regionSet, ",")) {
+		return nil, fmt.Errorf("%w: %s: %s", auth.ErrConstraintViolated, ERROR_REGION_NOT_IN_REGION_SET, s.region)
+	}
+
+	publicKey, err := s.publicKeys(ctx, h.accessKeyID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Canonicalize only the signed headers, in the declared order
+	clonedReq := req.Clone(req.Context())
+	cr, _, err := s.canonicalRequest(ctx, clonedReq, h.signedHeaders)
+	if err != nil {
+		return nil, err
+	}
+	req.Body = clonedReq.Body // The req.Body gets read inside the canonicalRequest, and needs to be reassigned
+
+	s2s := sigv4core.StringToSign(ALGORITHM_ECDSA_P256_SHA256, date, s.credentialScope(signingTime), cr)
+	if !ecdsa.VerifyASN1(publicKey, digest(s2s), h.signature) {
+		return nil, fmt.Errorf("%w: %s", auth.ErrSignatureMismatch, ERROR_SIGNATURE_MISMATCH)
+	}
+
+	return &auth.Identity{
+		KeyID:         h.accessKeyID,
+		Org:           s.org,
+		Service:       h.service,
+		Region:        regionSet,
+		ScopeDate:     h.date,
+		SignedHeaders: h.signedHeaders,
+		Algorithm:     ALGORITHM_ECDSA_P256_SHA256,
+	}, nil
+}
+
+// `inRegionSet` reports whether the region matches a region of the region set. A region of the set may end with a `*` wildcard.
+func inRegionSet(region string, regionSet []string) bool {
+	for _, r := range regionSet {
+		r = strings.TrimSpace(r)
+		if prefix, ok := strings.CutSuffix(r, "*"); ok && strings.HasPrefix(region, prefix) || r == region {
+			return true
+		}
+	}
+	return false
+}
+
+// `payloadHash` reads the request body into a buffer to hash it, and resets the request body to the captured buffer.
+// Returns the hash and the length of the payload. Reading the body observes the deadline and the cancellation of `ctx`.
+func payloadHash(ctx context.Context, req *http.Request) (string, int64, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return sigv4core.EMPTY_PAYLOAD_HASH, 0, nil
+	}
+	b, err := io.ReadAll(utils.ContextReader(ctx, req.Body))
+	if err != nil {
+		return "", 0, err
+	}
+	req.Body = io.NopCloser(bytes.NewReader(b))
+	return sigv4core.HashPayload(b), int64(len(b)), nil
+}
@@ -0,0 +1,506 @@
+// Package sigv4core implements the pure computations of the SigV4 algorithm: canonicalization, the string-to-sign,
+// the derivation of the signing key and the signature.
+//
+// The package performs no I/O: it does not read environment variables, files, request bodies or the network,
+// and only depends on the standard library. The `sigv4` package orchestrates these computations for `net/http` requests.
+package sigv4core
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Errors
+const (
+	ERROR_MALFORMED_QUERY  = "malformed query string"
+	ERROR_HEADER_COLLISION = "header names differ only by case"
+)
+
+// A Request holds the parts of an HTTP request that make up the `CanonicalRequest`.
+type Request struct {
+	Method string
+	// The escaped absolute path (E.g. `url.URL.EscapedPath()`)
+	Path string
+	// The raw query, without the '?' (E.g. `url.URL.RawQuery`)
+	RawQuery string
+	// The host, canonicalized as the `host` header
+	Host string
+	// Headers, with names in any casing. The `Host` and `Content-Length` headers are ignored in favour of `Host` and `ContentLength`.
+	Header map[string][]string
+	// The length of the payload, canonicalized as the `content-length` header
+	ContentLength int64
+	// The hex-encoded SHA-256 hash of the payload
+	PayloadHash string
+	// The lowercase names of the headers to canonicalize, in order, as declared by the `SignedHeaders` of a received signature.
+	// If nil, every header of `Header` is canonicalized along with `host` and `content-length`, as when signing. See `SignedCanonicalHeaders`.
+	SignedHeaders []string
+}
+
+// Options controlling canonicalization.
+type Options struct {
+	// Encode query parameter names and values following the `URIEncode` rules (a space becomes "%20"),
+	// instead of as `application/x-www-form-urlencoded` (a space becomes '+').
+	StrictQueryEncoding bool
+	// Reports whether a header never participates in canonicalization. `nil` skips no headers.
+	SkipHeader func(name string) bool
+	// Terminate every canonical header with a newline character, as AWS does, so that the `CanonicalHeaders` are followed by a blank line.
+	// By default, the `CanonicalHeaders` are directly followed by the `SignedHeaders`.
+	TerminateHeaders bool
+	// URI-encode the `CanonicalURI` a second time, as every AWS service other than S3 does. E.g. `/a%20b` becomes `/a%2520b`.
+	// By default, the path is encoded once, as S3 does.
+	DoubleURIEncode bool
+	// Remove the `.` and `..` segments and the duplicate slashes of the path before encoding it, as every AWS service other than S3 does.
+	// E.g. `/a/../b//c` is canonicalized as `/b/c`. See `NormalizePath`.
+	NormalizePath bool
+	// Percent-encode every byte outside the unreserved ASCII characters of the path and of the strictly encoded query (See `StrictQueryEncoding`),
+	// including the bytes of non-ASCII letters and digits, as other SigV4 implementations do. E.g. `/café` is canonicalized as `/caf%C3%A9`.
+	// By default, non-ASCII letters and digits are not encoded. See `StrictURIEncode`.
+	StrictURIEncoding bool
+	// Fail with `ERROR_HEADER_COLLISION` if the names of two headers to canonicalize differ only by case (E.g. `X-Custom` and `x-custom`),
+	// or a name is declared twice by the `SignedHeaders` of a received signature. By default, their values are joined. See `CanonicalHeaders`.
+	RejectHeaderCollisions bool
+	// Do not sign the headers with an empty value, as some clients do. By default, they are signed as `name:`.
+	// A declared header with an empty value is always canonicalized when verifying, as its signer signed it.
+	OmitEmptyHeaders bool
+}
+
+// `uriEncoder` returns the `URIEncode` function of the options
+func (opts *Options) uriEncoder() func(s string, encodeSlash bool) string {
+	if opts.StrictURIEncoding {
+		return StrictURIEncode
+	}
+	return URIEncode
+}
+
+// # Create the Canonical Request
+//
+// ----------------------------------------
+//
+// The `CanonicalRequest` is built out of 6 parameters joined by a new line character ("\n") after each paramter.
+//
+// (a) `HTTPMethod`: The HTTP method, such as GET, PUT, HEAD, and DELETE.
+//
+// (b) `CanonicalURI`: The URI-encoded version of the absolute path component URI, starting with the "/" that follows the domain name
+// and up to the end of the string or to the question mark character ('?') if you have query string parameters.
+// If the absolute path is empty, use a forward slash character (/).
+// The URI in the following example: http://s3.amazonaws.com/examplebucket/myphoto.jpg,
+//
+//	/examplebucket/myphoto.jpg,
+//
+// is the absolute path and you don't encode the "/" in the absolute path:
+//
+// (c) `CanonicalQueryString`: The URI-encoded query string parameters. You URI-encode each name and values individually.
+// You must also sort the parameters in the canonical query string alphabetically by key name.
+// The sorting occurs after encoding. In this URI example: http://s3.amazonaws.com/examplebucket?prefix=somePrefix&marker=someMarker&max-keys=2
+//
+// The canonical query string is as follows (line breaks are added to this example for readability):
+//
+//	UriEncode("marker")+"="+UriEncode("someMarker")+"&"+
+//	UriEncode("max-keys")+"="+UriEncode("20") + "&" +
+//	UriEncode("prefix")+"="+UriEncode("somePrefix")
+//
+// When a request targets a subresource, the corresponding query parameter value will be an empty string ("").
+// For example, the following URI identifies the ACL subresource on the examplebucket bucket:
+//
+// E.g. http://s3.amazonaws.com/examplebucket?acl
+//
+// The `CanonicalQueryString` in this case is as follows:
+//
+//	UriEncode("acl") + "=" + ""
+//
+// If the URI does not include a '?', there is no query string in the request, and you set the canonical query string to an empty string ("").
+// You will still need to include the "\n".
+//
+// (d) `CanonicalHeaders`: A list of request headers with their values.
+// Individual header name and value pairs are separated by the newline character ("\n").
+// The following is an example of a canonicalheader:
+//
+//	Lowercase(<HeaderName1>)+":"+Trim(<value>)+"\n"
+//	Lowercase(<HeaderName2>)+":"+Trim(<value>)+"\n"
+//
+// # Example:
+//
+//	host:s3.amazonaws.com
+//	x-amz-content-sha256:e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855
+//	x-amz-date:20130708T220855Z
+//
+// (e) `SignedHeaders`: An alphabetically sorted, semicolon-separated list of lowercase request header names. The request headers in the list are the same headers that you included in the `CanonicalHeaders` string.
+// For example, for the previous example, the value of SignedHeaders would be as follows:
+//
+//	host;x-amz-content-sha256;x-amz-date
+//
+// (f) `HashedPayload`: A string created using the payload in the body of the HTTP request as input to a hash function.
+//
+//	Hex(SHA256Hash(<payload>)
+//
+// This string uses lowercase hexadecimal characters. If there is no payload in the request, you compute a hash of the empty string as follows:
+//
+//	Hex(SHA256Hash(""))
+//
+// The `SignedHeaders` are returned alongside the `CanonicalRequest`. Returns an error wrapping the parse error if the raw query is malformed.
+func CanonicalRequest(r *Request, opts *Options) (canonicalRequest, signedHeaders string, err error) {
+	if opts == nil {
+		opts = new(Options)
+	}
+
+	encode := opts.uriEncoder()
+	qs, err := canonicalQueryString(r.RawQuery, opts.StrictQueryEncoding, encode)
+	if err != nil {
+		return "", "", err
+	}
+
+	// Get the Canonical Headers and the Signed Headers
+	if opts.RejectHeaderCollisions {
+		if name, ok := headerCollision(r.Header, r.SignedHeaders, opts.SkipHeader); ok {
+			return "", "", fmt.Errorf("%s: %s", ERROR_HEADER_COLLISION, name)
+		}
+	}
+	var ch, sh string
+	if r.SignedHeaders != nil {
+		ch, sh = SignedCanonicalHeaders(r.Header, CanonicalHost(r.Host), r.ContentLength, r.SignedHeaders)
+	} else {
+		ch, sh = canonicalizeHeaders(r.Header, CanonicalHost(r.Host), r.ContentLength, opts.SkipHeader, opts.OmitEmptyHeaders)
+	}
+	if opts.TerminateHeaders {
+		ch += "\n"
+	}
+	escapedPath := r.Path
+	if opts.NormalizePath {
+		escapedPath = NormalizePath(escapedPath)
+	}
+	uri := canonicalURI(escapedPath, encode)
+	if opts.DoubleURIEncode {
+		uri = encode(uri, false)
+	}
+
+	return fmt.Sprintf("%s\n%s\n%s\n%s\n%s\n%s",
+		r.Method,
+		uri,
+		qs,
+		ch,
+		sh,
+		r.PayloadHash,
+	), sh, nil
+}
+
+// (b) `CanonicalURI` builds a canonical URI following the SigV4 Algorithm from the escaped absolute path.
+//
+// The escaped form of the path is used instead of the decoded path, so that pre-encoded characters within a segment
+// (E.g. `%2F` inside an object key) are preserved as part of that segment instead of being re-interpreted as path separators.
+// Each segment is decoded and then re-encoded individually with `URIEncode`. See `StrictCanonicalURI` to encode the segments with `StrictURIEncode`.
+func CanonicalURI(escapedPath string) string {
+	return canonicalURI(escapedPath, URIEncode)
+}
+
+// (b) `StrictCanonicalURI` builds a canonical URI like `CanonicalURI`, encoding each segment with `StrictURIEncode`.
+// E.g. `/caf%C3%A9` and `/café` are canonicalized as `/caf%C3%A9`.
+func StrictCanonicalURI(escapedPath string) string {
+	return canonicalURI(escapedPath, StrictURIEncode)
+}
+
+// (b) `canonicalURI` builds a canonical URI from the escaped absolute path, re-encoding each decoded segment with `encode`
+func canonicalURI(escapedPath string, encode func(s string, encodeSlash bool) string) string {
+	// If the absolute path is empty, use a forward slash character "/"
+	if escapedPath == "" {
+		escapedPath = "/"
+	}
+
+	segments := strings.Split(escapedPath, "/")
+	for i, segment := range segments {
+		if unescaped, err := url.PathUnescape(segment); err == nil {
+			segment = unescaped
+		}
+		// Return the encoded segment according to custom URI encoding rules. A '/' at this point was encoded in the raw path.
+		segments[i] = encode(segment, true)
+	}
+	return strings.Join(segments, "/")
+}
+
+// (b) `NormalizePath` removes the `.` and `..` segments and the duplicate slashes of an escaped absolute path, following RFC 3986.
+// A trailing slash is kept, and an empty path becomes "/". E.g. `/a/./b/../c//` becomes `/a/c/`.
+//
+// Segments are not decoded, hence a pre-encoded slash (E.g. `%2F`) is not a separator, and an encoded dot (E.g. `%2E`) is not a dot segment.
+func NormalizePath(escapedPath string) string {
+	normalized := path.Clean("/" + escapedPath)
+	if strings.HasSuffix(escapedPath, "/") && normalized != "/" {
+		normalized += "/"
+	}
+	return normalized
+}
+
+// # (b1) `URIEncode` does an URI encoding based on the SigV4 algorithm
+//
+// URI encode every byte except the unreserved characters: 'A'-'Z', 'a'-'z', '0'-'9', '-', '.', '_', and '~'.
+//   - The space character is a reserved character and must be encoded as "%20" (and not as "+").
+//   - Each URI encoded byte is formed by a '%' and the two-digit hexadecimal value of the byte.
+//   - Letters in the hexadecimal value must be uppercase, for example "%1A".
+//   - Encode the forward slash character, '/', everywhere except in the object key name. For example, if the object key name is photos/Jan/sample.jpg, the forward slash in the key name is not encoded.
+//     Set `encodeSlash` to encode the forward slash as well.
+//
+// Unlike the SigV4 specification, non-ASCII letters and digits (E.g. 'é') are not encoded, for compatibility with existing signatures.
+// Every other non-ASCII character is encoded as the bytes of its UTF-8 encoding (E.g. '€' becomes "%E2%82%AC"),
+// and each byte of an invalid UTF-8 sequence is encoded individually. See `StrictURIEncode` to encode every non-ASCII byte.
+func URIEncode(s string, encodeSlash bool) string {
+	var encoded strings.Builder
+
+	for i := 0; i < len(s); {
+		r, size := utf8.DecodeRuneInString(s[i:])
+		if (r != utf8.RuneError || size > 1) && (isUnreserved(r) || (r == '/' && !encodeSlash)) {
+			encoded.WriteString(s[i : i+size])
+		} else {
+			writeEncodedBytes(&encoded, s[i:i+size])
+		}
+		i += size
+	}
+
+	return encoded.String()
+}
+
+// # (b1) `StrictURIEncode` does an URI encoding following the SigV4 specification byte for byte
+//
+// Like `URIEncode`, but every byte other than the unreserved ASCII characters is encoded, including the bytes of non-ASCII letters and digits,
+// as other SigV4 implementations (E.g. the AWS SDKs) do. E.g. "café" becomes "caf%C3%A9". Set `encodeSlash` to encode the forward slash as well.
+func StrictURIEncode(s string, encodeSlash bool) string {
+	var encoded strings.Builder
+
+	for i := 0; i < len(s); i++ {
+		if c := s[i]; c < utf8.RuneSelf && (isUnreserved(rune(c)) || (c == '/' && !encodeSlash)) {
+			encoded.WriteByte(c)
+		} else {
+			writeEncodedBytes(&encoded, s[i:i+1])
+		}
+	}
+
+	return encoded.String()
+}
+
+// # (b1a) `isUnreserved` checks if unicode character is unreserved for the `URIEncode`. Every other character is to be UriEncoded.
+func isUnreserved(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || r == '-' || r == '.' || r == '_' || r == '~'
+}
+
+// # (b1b) `writeEncodedBytes` writes each byte of `s` as a '%' followed by its uppercase two-digit hexadecimal value. E.g. "%20"
+func writeEncodedBytes(encoded *strings.Builder, s string) {
+	const hex = "0123456789ABCDEF"
+	for i := 0; i < len(s); i++ {
+		encoded.WriteByte('%')
+		encoded.WriteByte(hex[s[i]>>4])
+		encoded.WriteByte(hex[s[i]&0x0F])
+	}
+}
+
+// # (c) Get the `CanonicalQueryString` from the raw query. Sorted by query parameter.
+//
+// The raw query is decoded as a form, so that a '+' is a space and "%2B" is a literal '+'.
+// Names and values are encoded as `application/x-www-form-urlencoded` by default (a space becomes '+', a literal '+' becomes "%2B"),
+// sorted by the decoded name, and the values of a name are kept in order.
+//
+// With `strict`, the canonical query string follows the SigV4 specification (RFC 3986): names and values are encoded following the `URIEncode`
+// rules (a space becomes "%20"), and the parameters are sorted by the encoded name, then by the encoded value for duplicate names.
+// E.g. `b=2&a=y&a=X` becomes `a=X&a=y&b=2`.
+//
+// Returns an error wrapping the parse error if the raw query is malformed (E.g. an invalid escape or a ';'),
+// as a hostile query string must fail verification rather than be canonicalized partially.
+//
+// Non-ASCII bytes are encoded byte for byte as `application/x-www-form-urlencoded`. With `strict`, they are encoded following `URIEncode`,
+// hence non-ASCII letters and digits are not encoded (See `Options.StrictURIEncoding`).
+func CanonicalQueryString(rawQuery string, strict bool) (string, error) {
+	return canonicalQueryString(rawQuery, strict, URIEncode)
+}
+
+// (c) `canonicalQueryString` builds the `CanonicalQueryString` like `CanonicalQueryString`, strictly encoding with `encode`
+func canonicalQueryString(rawQuery string, strict bool, encode func(s string, encodeSlash bool) string) (string, error) {
+	// Parse the query string into a map
+	queryParams, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", ERROR_MALFORMED_QUERY, err)
+	}
+	if strict {
+		return rfc3986QueryString(queryParams, encode), nil
+	}
+
+	// Sort query parameters alphabetically by key
+	var keys []string
+	for key := range queryParams {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	// Construct canonical query string
+	var canonicalParams []string
+	for _, key := range keys {
+		values := queryParams[key]
+		for _, value := range values {
+			canonicalParams = append(canonicalParams, url.QueryEscape(key)+"="+url.QueryEscape(value))
+		}
+	}
+
+	// Concatenate query parameters with "&" separator
+	return strings.Join(canonicalParams, "&"), nil
+}
+
+// (c1) `rfc3986QueryString` encodes the query parameters with `encode`, sorted by the encoded name, then by the encoded value.
+// The sorting occurs after encoding, as the SigV4 specification requires.
+func rfc3986QueryString(queryParams url.Values, encode func(s string, encodeSlash bool) string) string {
+	type param struct{ key, value string }
+	var params []param
+	for key, values := range queryParams {
+		encodedKey := encode(key, true)
+		for _, value := range values {
+			params = append(params, param{encodedKey, encode(value, true)})
+		}
+	}
+	sort.Slice(params, func(i, j int) bool {
+		if params[i].key != params[j].key {
+			return params[i].key < params[j].key
+		}
+		return params[i].value < params[j].value
+	})
+
+	canonicalParams := make([]string, len(params))
+	for i, p := range params {
+		canonicalParams[i] = p.key + "=" + p.value
+	}
+	return strings.Join(canonicalParams, "&")
+}
+
+// (d) `CanonicalHost` strips the default ports `:80` and `:443` from the host, irrespective of the scheme, as clients differ on sending them
+// (E.g. a client dialing `example.com:443` sends it in the `Host` header), and the Verifier does not know the scheme the client dialed.
+// Bracketed IPv6 literals keep their brackets. E.g. `example.com:443` becomes `example.com`, `[::1]:80` becomes `[::1]`, and `[::1]:8080` is unchanged.
+func CanonicalHost(host string) string {
+	hostname, port, err := net.SplitHostPort(host)
+	if err != nil || (port != "80" && port != "443") {
+		return host
+	}
+	if strings.Contains(hostname, ":") {
+		return "[" + hostname + "]"
+	}
+	return hostname
+}
+
+// # (d) Get Canonical Headers and (e) Signed Headers as two return values.
+//
+// The `host` and `content-length` are always canonicalized from `host` and `contentLength` (unless skipped),
+// irrespective of the `Host` and `Content-Length` headers. This is how the `net/http` module is implemented in Go:
+// For incoming requests, the Host header is promoted to the Request.Host field and removed from the Header map,
+// and the `Content-Length` header sent over the wire is derived from the payload.
+//
+// Header values are canonicalized byte for byte, as other SigV4 implementations do: non-ASCII bytes (E.g. UTF-8 or obs-text)
+// are neither encoded nor normalized, hence "café" in NFC and NFD forms are different values. Only the surrounding spaces are trimmed.
+//
+// The values of headers whose names differ only by case (E.g. `X-Custom` and `x-custom`) are joined in a single canonical header,
+// in the sorted order of the names, as `http.Header.Write` sends them and as `SignedCanonicalHeaders` joins them.
+func CanonicalHeaders(header map[string][]string, host string, contentLength int64, skip func(name string) bool) (canonicalHeaders, signedHeaders string) {
+	return canonicalizeHeaders(header, host, contentLength, skip, false)
+}
+
+// `canonicalizeHeaders` implements `CanonicalHeaders`, skipping the headers with an empty value if `omitEmpty`. See `Options.OmitEmptyHeaders`.
+func canonicalizeHeaders(header map[string][]string, host string, contentLength int64, skip func(name string) bool, omitEmpty bool) (canonicalHeaders, signedHeaders string) {
+	if skip == nil {
+		skip = func(string) bool { return false }
+	}
+
+	values := make(map[string][]string, len(header))
+	for _, key := range sortedKeys(header) {
+		if skip(key) || strings.EqualFold(key, "Content-Length") || strings.EqualFold(key, "Host") {
+			continue
+		}
+		name := strings.ToLower(key)
+		values[name] = append(values[name], header[key]...)
+	}
+	ch := []string{}
+	sh := []string{}
+	for name, v := range values {
+		value := strings.TrimSpace(strings.Join(v, ","))
+		if omitEmpty && value == "" {
+			continue
+		}
+		ch = append(ch, name+":"+value)
+		sh = append(sh, name)
+	}
+	if !skip("Content-Length") {
+		ch = append(ch, fmt.Sprintf("%s:%d", "content-length", contentLength))
+		sh = append(sh, "content-length")
+	}
+	ch = append(ch, fmt.Sprintf("%s:%s", "host", host))
+	sh = append(sh, "host")
+
+	// Sort the CanonicalHeaders and SignedHeaders
+	sort.Strings(ch)
+	sort.Strings(sh)
+
+	return strings.Join(ch, "\n"), strings.Join(sh, ";")
+}
+
+// # (d) Canonical Headers and (e) Signed Headers of a received signature
+//
+// Canonicalizes exactly the `signedHeaders` declared by the signature, in the declared order, like `CanonicalHeaders` does for every header when signing:
+// headers added to the request after signing (E.g. by proxies) are ignored, and `host` and `content-length` are only canonicalized if declared.
+// A declared header missing from the request is canonicalized with an empty value, so that the signature does not match.
+func SignedCanonicalHeaders(header map[string][]string, host string, contentLength int64, signedHeaders []string) (canonicalHeaders, signedHeaderNames string) {
+	ch := make([]string, 0, len(signedHeaders))
+	for _, name := range signedHeaders {
+		name = strings.ToLower(name)
+		var value string
+		switch name {
+		case "host":
+			value = host
+		case "content-length":
+			value = strconv.FormatInt(contentLength, 10)
+		default:
+			var values []string
+			for _, key := range sortedKeys(header) {
+				if strings.EqualFold(key, name) {
+					values = append(values, header[key]...)
+				}
+			}
+			value = strings.TrimSpace(strings.Join(values, ","))
+		}
+		ch = append(ch, name+":"+value)
+	}
+	return strings.Join(ch, "\n"), strings.ToLower(strings.Join(signedHeaders, ";"))
+}
+
+// `sortedKeys` returns the names of the headers in sorted order
+func sortedKeys(header map[string][]string) []string {
+	keys := make([]string, 0, len(header))
+	for key := range header {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// `headerCollision` returns the lowercase name of two headers to canonicalize differing only by case, and true.
+// When verifying, only the `signedHeaders` are canonicalized, and a name declared twice is a collision. See `Options.RejectHeaderCollisions`.
+func headerCollision(header map[string][]string, signedHeaders []string, skip func(name string) bool) (string, bool) {
+	seen := make(map[string]bool, len(header))
+	if signedHeaders != nil {
+		for _, name := range signedHeaders {
+			name = strings.ToLower(name)
+			if seen[name] {
+				return name, true
+			}
+			seen[name] = true
+		}
+	}
+	found := make(map[string]bool, len(header))
+	for key := range header {
+		name := strings.ToLower(key)
+		if name == "host" || name == "content-length" || (signedHeaders == nil && skip != nil && skip(key)) || (signedHeaders != nil && !seen[name]) {
+			continue
+		}
+		if found[name] {
+			return name, true
+		}
+		found[name] = true
+	}
+	return "", false
+}
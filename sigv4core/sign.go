@@ -0,0 +1,122 @@
+package sigv4core
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"strings"
+)
+
+// Supported signing algorithms
+const (
+	ALGORITHM_HMAC_SHA256 = "AWS4-HMAC-SHA256"
+	ALGORITHM_HMAC_SHA512 = "AWS4-HMAC-SHA512"
+)
+
+// Returns the hash function of a signing algorithm, used for the HMACs and the hash of the canonical request.
+// The label of the org prefixing the algorithm is ignored (See `AlgorithmName`), E.g. `SYM4-HMAC-SHA256` hashes like `AWS4-HMAC-SHA256`.
+// Reports false if the algorithm is not supported.
+func HashFunc(algorithm string) (func() hash.Hash, bool) {
+	label, suffix, _ := strings.Cut(algorithm, "-")
+	if !strings.HasSuffix(label, "4") {
+		return nil, false
+	}
+	switch "AWS4-" + suffix {
+	case ALGORITHM_HMAC_SHA256:
+		return sha256.New, true
+	case ALGORITHM_HMAC_SHA512:
+		return sha512.New, true
+	default:
+		return nil, false
+	}
+}
+
+// Returns the name of the `algorithm` (E.g. `ALGORITHM_HMAC_SHA256`) labelled for the `org`: the "AWS4" prefix is replaced
+// with the uppercased org followed by "4". E.g. `SYM4-HMAC-SHA256` for the org "SYM".
+func AlgorithmName(org, algorithm string) string {
+	if suffix, ok := strings.CutPrefix(algorithm, "AWS4-"); ok {
+		return strings.ToUpper(org) + "4-" + suffix
+	}
+	return algorithm
+}
+
+// The hex-encoded SHA-256 hash of an empty payload, i.e. `Hex(SHA256Hash(""))`
+const EMPTY_PAYLOAD_HASH = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+// Hash a payload using SHA-256 and return the hex-encoded checksum
+func HashPayload(b []byte) string {
+	hash := sha256.Sum256(b)
+	return hex.EncodeToString(hash[:])
+}
+
+// (3a) The credential scope. This restricts the resulting signature to the specified Region and service.
+// The string has the following format: date/region/service/terminator. E.g. `YYYYMMDD/region/service/aws4_request`
+func CredentialScope(date, region, service, terminator string) string {
+	return fmt.Sprintf("%s/%s/%s/%s", date, region, service, terminator)
+}
+
+// # Create the stringToSign
+// ------------------------------
+//
+// stringToSign is built out of four parameters, joined by a newline character ("\n") after each parameter.
+//  1. `Algorithm`: The algorithm used to create the hash of the canonical request. For SHA-256, the algorithm is `AWS4-HMAC-SHA256`.
+//  2. `RequestDateTime`: The date and time used in the credential scope. This value is the current UTC time in ISO 8601 format (for example, 20130524T000000Z).
+//  3. `CredentialScope`: The credential scope. This restricts the resulting signature to the specified Region and service.
+//     The string has the following format: YYYYMMDD/region/service/aws4_request.
+//  4. `HashedCanonicalRequest`: The hash of the canonical request using the same algorithm that you used to create the hash of the payload.
+//
+// The canonical request is hashed with the hash function of the algorithm (See `HashFunc`), or SHA-256 if the algorithm is not supported.
+func StringToSign(algorithm, dateTime, credentialScope, canonicalRequest string) string {
+	newHash, ok := HashFunc(algorithm)
+	if !ok {
+		newHash = sha256.New
+	}
+	h := newHash()
+	h.Write([]byte(canonicalRequest))
+
+	return fmt.Sprintf("%s\n%s\n%s\n%s",
+		algorithm,
+		dateTime,
+		credentialScope,
+		hex.EncodeToString(h.Sum(nil)),
+	)
+}
+
+// (3) Derive the Signing Key from the secret access key. E.g. with `keyPrefix` "AWS4" and `terminator` "aws4_request":
+//
+//	DateKey              = HMAC-SHA256("AWS4" + secret, date)
+//	DateRegionKey        = HMAC-SHA256(DateKey, region)
+//	DateRegionServiceKey = HMAC-SHA256(DateRegionKey, service)
+//	SigningKey           = HMAC-SHA256(DateRegionServiceKey, "aws4_request")
+func SigningKey(keyPrefix, secret, date, region, service, terminator string) []byte {
+	return SigningKeyHash(sha256.New, keyPrefix, secret, date, region, service, terminator)
+}
+
+// (3) Derive the Signing Key like `SigningKey`, using HMACs of the hash function `newHash` (E.g. `sha512.New` for `AWS4-HMAC-SHA512`).
+func SigningKeyHash(newHash func() hash.Hash, keyPrefix, secret, date, region, service, terminator string) []byte {
+	key := []byte(keyPrefix + secret)
+	for _, part := range []string{date, region, service, terminator} {
+		key = hmacHash(newHash, key, part)
+	}
+	return key
+}
+
+// (4) Calculate the hex-encoded signature from the `SigningKey` and the `stringToSign`.
+func Signature(signingKey []byte, stringToSign string) string {
+	return SignatureHash(sha256.New, signingKey, stringToSign)
+}
+
+// (4) Calculate the signature like `Signature`, using the HMAC of the hash function `newHash`.
+func SignatureHash(newHash func() hash.Hash, signingKey []byte, stringToSign string) string {
+	return hex.EncodeToString(hmacHash(newHash, signingKey, stringToSign))
+}
+
+// Returns the HMAC of `data` with `key`, using the hash function `newHash`
+func hmacHash(newHash func() hash.Hash, key []byte, data string) []byte {
+	mac := hmac.New(newHash, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
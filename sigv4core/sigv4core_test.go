@@ -0,0 +1,283 @@
+package sigv4core
+
+import (
+	"encoding/hex"
+	"strings"
+	"testing"
+)
+
+// Test against the `get-vanilla` vector of the AWS SigV4 test suite.
+// The `content-length` is skipped, as it is not part of the vector. Unlike AWS, the `CanonicalHeaders` are not followed by a blank line.
+func Test_GetVanilla(t *testing.T) {
+	cr, sh, err := CanonicalRequest(&Request{
+		Method:      "GET",
+		Path:        "/",
+		Host:        "example.amazonaws.com",
+		Header:      map[string][]string{"X-Amz-Date": {"20150830T123600Z"}},
+		PayloadHash: EMPTY_PAYLOAD_HASH,
+	}, &Options{SkipHeader: func(name string) bool { return name == "Content-Length" }})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expectedCR := "GET\n/\n\nhost:example.amazonaws.com\nx-amz-date:20150830T123600Z\nhost;x-amz-date\n" + EMPTY_PAYLOAD_HASH
+	if cr != expectedCR {
+		t.Fatalf("Canonical request mismatch; expected:\n%s\ngot:\n%s", expectedCR, cr)
+	}
+	if sh != "host;x-amz-date" {
+		t.Errorf("Signed headers mismatch, got: %q", sh)
+	}
+
+	// The canonical request of the vector, as computed by AWS
+	awsCR := "GET\n/\n\nhost:example.amazonaws.com\nx-amz-date:20150830T123600Z\n\nhost;x-amz-date\n" + EMPTY_PAYLOAD_HASH
+	scope := CredentialScope("20150830", "us-east-1", "service", "aws4_request")
+	s2s := StringToSign("AWS4-HMAC-SHA256", "20150830T123600Z", scope, awsCR)
+	expectedS2S := "AWS4-HMAC-SHA256\n20150830T123600Z\n20150830/us-east-1/service/aws4_request\nbb579772317eb040ac9ed261061d46c1f17a8133879d6129b6e1c25292927e63"
+	if s2s != expectedS2S {
+		t.Fatalf("String to sign mismatch; expected:\n%s\ngot:\n%s", expectedS2S, s2s)
+	}
+
+	key := SigningKey("AWS4", "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY", "20150830", "us-east-1", "service", "aws4_request")
+	if signature := Signature(key, s2s); signature != "5fa00fa31553b73ebf1942676e86291e8372ff2a2260956d9b8aae1d763fbf31" {
+		t.Errorf("Signature mismatch, got: %q", signature)
+	}
+}
+
+// Test the signing key derivation against the example of the AWS documentation
+func Test_SigningKey(t *testing.T) {
+	key := SigningKey("AWS4", "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY", "20120215", "us-east-1", "iam", "aws4_request")
+	if hex.EncodeToString(key) != "f4780e2d9f65fa895f9c67b32ce1baf0b0d8a43505a000a1a9e090d414db404d" {
+		t.Errorf("Signing key mismatch, got: %x", key)
+	}
+}
+
+// Test the URI encoding of reserved and unreserved characters
+func Test_URIEncode(t *testing.T) {
+	tests := map[string]string{
+		"photos/Jan/sample.jpg": "photos/Jan/sample.jpg",
+		"a b+c~d_e-f.g":         "a%20b%2Bc~d_e-f.g",
+		"key=value&x":           "key%3Dvalue%26x",
+	}
+	for input, expected := range tests {
+		if encoded := URIEncode(input, false); encoded != expected {
+			t.Errorf("URIEncode(%q); expected: %q, got: %q", input, expected, encoded)
+		}
+	}
+	if encoded := URIEncode("a/b", true); encoded != "a%2Fb" {
+		t.Errorf("Expected slash to be encoded, got: %q", encoded)
+	}
+}
+
+// Test that only the declared signed headers are canonicalized, in the declared order
+func Test_SignedCanonicalHeaders(t *testing.T) {
+	header := map[string][]string{
+		"X-Amz-Date":      {"20150830T123600Z"},
+		"x-custom":        {" a ", "b"},
+		"X-Forwarded-For": {"10.0.0.1"},
+	}
+	ch, sh := SignedCanonicalHeaders(header, "example.amazonaws.com", 42, []string{"host", "x-amz-date", "x-custom", "x-missing"})
+	expected := "host:example.amazonaws.com\nx-amz-date:20150830T123600Z\nx-custom:a ,b\nx-missing:"
+	if ch != expected {
+		t.Errorf("Canonical headers mismatch; expected:\n%s\ngot:\n%s", expected, ch)
+	}
+	if sh != "host;x-amz-date;x-custom;x-missing" {
+		t.Errorf("Signed headers mismatch, got: %q", sh)
+	}
+
+	// Equivalent to the canonicalization of the signer for the headers it signed
+	signed := map[string][]string{"X-Amz-Date": header["X-Amz-Date"]}
+	expectedCH, expectedSH := CanonicalHeaders(signed, "example.amazonaws.com", 42, nil)
+	ch, sh = SignedCanonicalHeaders(header, "example.amazonaws.com", 42, strings.Split(expectedSH, ";"))
+	if ch != expectedCH || sh != expectedSH {
+		t.Errorf("Expected:\n%s\n%s\ngot:\n%s\n%s", expectedCH, expectedSH, ch, sh)
+	}
+}
+
+// Test that algorithms are labelled for the org, and hashed regardless of the label
+func Test_AlgorithmName(t *testing.T) {
+	if name := AlgorithmName("sym", ALGORITHM_HMAC_SHA512); name != "SYM4-HMAC-SHA512" {
+		t.Errorf("Expected: %q, got: %q", "SYM4-HMAC-SHA512", name)
+	}
+	if name := AlgorithmName("AWS", ALGORITHM_HMAC_SHA256); name != ALGORITHM_HMAC_SHA256 {
+		t.Errorf("Expected: %q, got: %q", ALGORITHM_HMAC_SHA256, name)
+	}
+	for algorithm, supported := range map[string]bool{"SYM4-HMAC-SHA256": true, "ZEN4-HMAC-SHA512": true, "SYM-HMAC-SHA256": false, "SYM4-HMAC-MD5": false} {
+		if _, ok := HashFunc(algorithm); ok != supported {
+			t.Errorf("HashFunc(%q); expected supported: %t, got: %t", algorithm, supported, ok)
+		}
+	}
+}
+
+// Test the encoding of non-ASCII characters: multi-byte characters are encoded as the bytes of their UTF-8 encoding,
+// non-ASCII letters are only encoded byte for byte in strict mode, and the bytes of invalid UTF-8 are encoded individually
+func Test_URIEncode_NonASCII(t *testing.T) {
+	tests := []struct {
+		input, expected, expectedStrict string
+	}{
+		{"café", "café", "caf%C3%A9"},
+		{"5€", "5%E2%82%AC", "5%E2%82%AC"},
+		{"a×b", "a%C3%97b", "a%C3%97b"},
+		{"日本/語", "日本/語", "%E6%97%A5%E6%9C%AC/%E8%AA%9E"},
+		{"😀", "%F0%9F%98%80", "%F0%9F%98%80"},
+		{"a\xffb", "a%FFb", "a%FFb"},
+	}
+	for _, test := range tests {
+		if encoded := URIEncode(test.input, false); encoded != test.expected {
+			t.Errorf("URIEncode(%q); expected: %q, got: %q", test.input, test.expected, encoded)
+		}
+		if encoded := StrictURIEncode(test.input, false); encoded != test.expectedStrict {
+			t.Errorf("StrictURIEncode(%q); expected: %q, got: %q", test.input, test.expectedStrict, encoded)
+		}
+	}
+	// Formerly encoded as the code point, colliding with "%20AC"
+	if URIEncode("€", false) == URIEncode(" AC", false) {
+		t.Error("Expected distinct characters to be encoded distinctly")
+	}
+}
+
+// Test that `StrictURIEncoding` encodes the non-ASCII bytes of the path and of the strict query, whether sent raw or pre-encoded
+func Test_CanonicalRequest_StrictURIEncoding(t *testing.T) {
+	for _, path := range []string{"/café/5€", "/caf%C3%A9/5%E2%82%AC"} {
+		r := &Request{Method: "GET", Path: path, RawQuery: "q=caf%C3%A9&k=%E2%82%AC", Host: "example.amazonaws.com"}
+		tests := []struct {
+			opts          *Options
+			uri, rawQuery string
+		}{
+			{&Options{}, "/café/5%E2%82%AC", "k=%E2%82%AC&q=caf%C3%A9"},
+			{&Options{StrictQueryEncoding: true}, "/café/5%E2%82%AC", "k=%E2%82%AC&q=café"},
+			{&Options{StrictQueryEncoding: true, StrictURIEncoding: true}, "/caf%C3%A9/5%E2%82%AC", "k=%E2%82%AC&q=caf%C3%A9"},
+			{&Options{StrictURIEncoding: true, DoubleURIEncode: true}, "/caf%25C3%25A9/5%25E2%2582%25AC", "k=%E2%82%AC&q=caf%C3%A9"},
+		}
+		for _, test := range tests {
+			cr, _, err := CanonicalRequest(r, test.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			lines := strings.Split(cr, "\n")
+			if lines[1] != test.uri || lines[2] != test.rawQuery {
+				t.Errorf("%q with %+v; expected: %q and %q, got: %q and %q", path, *test.opts, test.uri, test.rawQuery, lines[1], lines[2])
+			}
+		}
+	}
+	if uri := StrictCanonicalURI("/日本"); uri != "/%E6%97%A5%E6%9C%AC" {
+		t.Errorf("Unexpected strict canonical URI: %q", uri)
+	}
+}
+
+// Test that non-ASCII header values are canonicalized byte for byte, without normalization
+func Test_CanonicalHeaders_NonASCII(t *testing.T) {
+	nfc, nfd := "caf\u00e9", "cafe\u0301"
+	ch, _ := CanonicalHeaders(map[string][]string{"X-Name": {" " + nfc + " "}, "X-Raw": {"a\xffb"}}, "example.com", 0, nil)
+	if !strings.Contains(ch, "x-name:"+nfc+"\n") || !strings.Contains(ch, "x-raw:a\xffb") {
+		t.Errorf("Expected the values byte for byte, got: %q", ch)
+	}
+	other, _ := CanonicalHeaders(map[string][]string{"X-Name": {nfd}, "X-Raw": {"a\xffb"}}, "example.com", 0, nil)
+	if ch == other {
+		t.Error("Expected the NFC and NFD forms of a value not to be normalized")
+	}
+}
+
+// Test that the canonical URI is encoded twice with `DoubleURIEncode`
+func Test_CanonicalRequest_DoubleURIEncode(t *testing.T) {
+	r := &Request{Method: "GET", Path: "/documents%20and%20settings/", Host: "example.amazonaws.com"}
+	for double, expected := range map[bool]string{false: "/documents%20and%20settings/", true: "/documents%2520and%2520settings/"} {
+		cr, _, err := CanonicalRequest(r, &Options{DoubleURIEncode: double})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if uri := strings.Split(cr, "\n")[1]; uri != expected {
+			t.Errorf("DoubleURIEncode %t; expected: %q, got: %q", double, expected, uri)
+		}
+	}
+}
+
+// Test the normalization of the path against the `get-slash*` and `get-relative*` vectors of the AWS SigV4 test suite
+func Test_NormalizePath(t *testing.T) {
+	tests := map[string]string{
+		"":                         "/",
+		"//":                       "/",
+		"/./":                      "/",
+		"//example//":              "/example/",
+		"/example/..":              "/",
+		"/example1/example2/../..": "/",
+		"/a/../b":                  "/b",
+		"/a/./b/../c//":            "/a/c/",
+		"/a/%2E%2E/b%2Fc":          "/a/%2E%2E/b%2Fc",
+	}
+	for input, expected := range tests {
+		if normalized := NormalizePath(input); normalized != expected {
+			t.Errorf("NormalizePath(%q); expected: %q, got: %q", input, expected, normalized)
+		}
+	}
+	cr, _, _ := CanonicalRequest(&Request{Method: "GET", Path: "/a/../b", Host: "example.amazonaws.com"}, &Options{NormalizePath: true})
+	if uri := strings.Split(cr, "\n")[1]; uri != "/b" {
+		t.Errorf("Expected the normalized canonical URI, got: %q", uri)
+	}
+}
+
+// Test that the strict canonical query string is sorted by the encoded names, then by the encoded values, and that the default keeps the order of the values
+func Test_CanonicalQueryString_Sorting(t *testing.T) {
+	tests := []struct {
+		rawQuery, expected, expectedStrict string
+	}{
+		// The `get-vanilla-query-order-value` vector of the AWS SigV4 test suite
+		{"Param1=value2&Param1=Value1", "Param1=value2&Param1=Value1", "Param1=Value1&Param1=value2"},
+		{"b=2&a=y&a=X", "a=y&a=X&b=2", "a=X&a=y&b=2"},
+		// Sorted after encoding: '%' (0x25) sorts before 'a'
+		{"a=1&%2F=2", "%2F=2&a=1", "%2F=2&a=1"},
+		{"k=b%20c&k=b", "k=b+c&k=b", "k=b&k=b%20c"},
+	}
+	for _, test := range tests {
+		if qs, _ := CanonicalQueryString(test.rawQuery, false); qs != test.expected {
+			t.Errorf("CanonicalQueryString(%q); expected: %q, got: %q", test.rawQuery, test.expected, qs)
+		}
+		if qs, _ := CanonicalQueryString(test.rawQuery, true); qs != test.expectedStrict {
+			t.Errorf("CanonicalQueryString(%q, strict); expected: %q, got: %q", test.rawQuery, test.expectedStrict, qs)
+		}
+	}
+}
+
+// Test that default ports are stripped from the host, including from bracketed IPv6 literals
+func Test_CanonicalHost(t *testing.T) {
+	tests := map[string]string{
+		"example.com":          "example.com",
+		"example.com:80":       "example.com",
+		"example.com:443":      "example.com",
+		"example.com:8443":     "example.com:8443",
+		"[2001:db8::1]:443":    "[2001:db8::1]",
+		"[2001:db8::1]:8080":   "[2001:db8::1]:8080",
+		"[2001:db8::1]":        "[2001:db8::1]",
+		"192.0.2.1:80":         "192.0.2.1",
+		"example.com:":         "example.com:",
+		"validate.example.com": "validate.example.com",
+	}
+	for input, expected := range tests {
+		if host := CanonicalHost(input); host != expected {
+			t.Errorf("CanonicalHost(%q); expected: %q, got: %q", input, expected, host)
+		}
+	}
+}
+
+// Test that headers differing only by case are joined deterministically, and that empty headers can be omitted
+func Test_CanonicalHeaders_Collisions(t *testing.T) {
+	header := map[string][]string{"x-custom": {"b"}, "X-Custom": {"a"}, "X-Empty": {""}}
+	ch, sh := CanonicalHeaders(header, "example.com", 0, nil)
+	if ch != "content-length:0\nhost:example.com\nx-custom:a,b\nx-empty:" || sh != "content-length;host;x-custom;x-empty" {
+		t.Errorf("Unexpected canonical headers:\n%s\n%s", ch, sh)
+	}
+	if signed, _ := SignedCanonicalHeaders(header, "example.com", 0, strings.Split(sh, ";")); signed != ch {
+		t.Errorf("Expected the canonical headers of the signer, got:\n%s", signed)
+	}
+
+	r := &Request{Method: "GET", Path: "/", Host: "example.com", Header: header, PayloadHash: EMPTY_PAYLOAD_HASH}
+	if _, sh, err := CanonicalRequest(r, &Options{OmitEmptyHeaders: true}); err != nil || sh != "content-length;host;x-custom" {
+		t.Errorf("Expected x-empty to be omitted, got: %q, %v", sh, err)
+	}
+	if _, _, err := CanonicalRequest(r, &Options{RejectHeaderCollisions: true}); err == nil || !strings.Contains(err.Error(), ERROR_HEADER_COLLISION) {
+		t.Errorf("Expected error %q, got: %v", ERROR_HEADER_COLLISION, err)
+	}
+	skip := func(name string) bool { return strings.EqualFold(name, "X-Custom") }
+	if _, _, err := CanonicalRequest(r, &Options{RejectHeaderCollisions: true, SkipHeader: skip}); err != nil {
+		t.Errorf("Expected skipped headers not to collide, got: %v", err)
+	}
+}
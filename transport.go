@@ -0,0 +1,91 @@
+package httpsigner
+
+import (
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/jayantasamaddar/go-httpsigner/auth"
+)
+
+// A TransportOption configures the signing transport.
+type TransportOption func(*transport)
+
+type transport struct {
+	signer auth.Signer
+	base   http.RoundTripper
+	hosts  []string
+}
+
+// # Host-allowlist conditional signing
+//
+// Only sign requests to the given hosts, and pass every other request through unsigned,
+// so that one shared `http.Client` can call both signed internal APIs and public APIs without leaking signatures.
+//
+// Hosts are matched case-insensitively, ignoring the port. A host starting with "*." matches any subdomain (E.g. `*.internal.example.com`).
+// Calling `WithHostAllowlist` multiple times adds to the allowlist.
+func WithHostAllowlist(hosts ...string) TransportOption {
+	return func(t *transport) {
+		for _, host := range hosts {
+			t.hosts = append(t.hosts, strings.ToLower(strings.TrimSuffix(host, ".")))
+		}
+	}
+}
+
+// # Signing transport
+//
+// Returns an `http.RoundTripper` that signs each request with the Signer before sending it with `base` (`http.DefaultTransport` if nil).
+// The request passed to `RoundTrip` is not modified: a clone is signed instead.
+// Without a host allowlist (See `WithHostAllowlist`), every request is signed.
+func NewTransport(signer auth.Signer, base http.RoundTripper, opts ...TransportOption) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	t := &transport{signer: signer, base: base}
+	for _, opt := range opts {
+		opt(t)
+	}
+	return t
+}
+
+// `http.RoundTripper` implementation
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if !t.allowed(req) {
+		return t.base.RoundTrip(req)
+	}
+
+	clone := req.Clone(req.Context())
+	if err := t.signer.SignHTTPRequest(clone); err != nil {
+		if req.Body != nil {
+			req.Body.Close()
+		}
+		return nil, err
+	}
+	return t.base.RoundTrip(clone)
+}
+
+// `allowed` checks if the host of the request matches the allowlist
+func (t *transport) allowed(req *http.Request) bool {
+	if len(t.hosts) == 0 {
+		return true
+	}
+	host := req.Host
+	if host == "" {
+		host = req.URL.Host
+	}
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+
+	for _, pattern := range t.hosts {
+		if suffix, ok := strings.CutPrefix(pattern, "*"); ok {
+			if strings.HasSuffix(host, suffix) {
+				return true
+			}
+		} else if host == pattern {
+			return true
+		}
+	}
+	return false
+}
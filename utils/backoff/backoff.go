@@ -0,0 +1,149 @@
+// Package backoff implements retry strategies, and retries operations with them while observing the context.
+package backoff
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/jayantasamaddar/go-httpsigner/utils"
+)
+
+// Errors
+const (
+	ERROR_ATTEMPTS_EXCEEDED = "exceeded maximum attempts"
+)
+
+// A Strategy computes the delays between the attempts of an operation. Strategies are stateless, hence safe for concurrent use.
+type Strategy interface {
+	// Returns the delay before the retry `attempt` (1 for the first retry), given the sum of the delays before the earlier retries,
+	// and false to stop retrying.
+	Delay(attempt int, waited time.Duration) (time.Duration, bool)
+}
+
+// The StrategyFunc type is an adapter to allow the use of ordinary functions as a `Strategy`.
+type StrategyFunc func(attempt int, waited time.Duration) (time.Duration, bool)
+
+// `Delay` calls f(attempt, waited)
+func (f StrategyFunc) Delay(attempt int, waited time.Duration) (time.Duration, bool) {
+	return f(attempt, waited)
+}
+
+// Retry up to `retries` times, waiting `delay` before each retry
+func Constant(delay time.Duration, retries int) Strategy {
+	return StrategyFunc(func(attempt int, _ time.Duration) (time.Duration, bool) {
+		return delay, attempt <= retries
+	})
+}
+
+// Retry up to `retries` times, doubling the delay before each retry starting with `base`, up to `max`.
+// E.g. `Exponential(time.Second, 4*time.Second, 4)` waits 1s, 2s, 4s and 4s.
+func Exponential(base, max time.Duration, retries int) Strategy {
+	return StrategyFunc(func(attempt int, _ time.Duration) (time.Duration, bool) {
+		if attempt > retries {
+			return 0, false
+		}
+		delay := base
+		for i := 1; i < attempt && delay < max; i++ {
+			delay *= 2
+		}
+		return min(delay, max), true
+	})
+}
+
+// Randomizes the delays of the `strategy` between zero and the delay ("full jitter"),
+// so that clients failing together do not retry together.
+func Jittered(strategy Strategy) Strategy {
+	return JitteredWith(strategy, nil)
+}
+
+// Randomizes the delays of the `strategy` like `Jittered`, reading the randomness from the `entropy` source (`crypto/rand` if nil, See `utils.Entropy`).
+// The `entropy` source must be safe for concurrent use if the strategy is. The delay is not randomized if the `entropy` source fails.
+func JitteredWith(strategy Strategy, entropy io.Reader) Strategy {
+	return StrategyFunc(func(attempt int, waited time.Duration) (time.Duration, bool) {
+		delay, ok := strategy.Delay(attempt, waited)
+		if !ok || delay <= 0 {
+			return delay, ok
+		}
+		var b [8]byte
+		if err := utils.ReadEntropy(entropy, b[:]); err != nil {
+			return delay, true
+		}
+		return time.Duration(binary.BigEndian.Uint64(b[:]) % uint64(delay+1)), true
+	})
+}
+
+// Stops retrying with the `strategy` once the sum of the delays would exceed the `budget`
+func Budget(strategy Strategy, budget time.Duration) Strategy {
+	return StrategyFunc(func(attempt int, waited time.Duration) (time.Duration, bool) {
+		delay, ok := strategy.Delay(attempt, waited)
+		return delay, ok && waited+delay <= budget
+	})
+}
+
+// An error that is not retried. See `Permanent`.
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string {
+	return e.err.Error()
+}
+
+func (e *permanentError) Unwrap() error {
+	return e.err
+}
+
+// Wraps an error of an operation that must not be retried, E.g. a rejected request. `Retry` returns the unwrapped error.
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &permanentError{err: err}
+}
+
+// # Retry an operation
+//
+// Calls `op` until it succeeds, it fails with a `Permanent` error, or the `strategy` stops retrying.
+// Returns the error of the last attempt wrapped with `ERROR_ATTEMPTS_EXCEEDED` if all attempts failed,
+// or the error of the context if it is done while waiting before a retry.
+func Retry(ctx context.Context, strategy Strategy, op func(ctx context.Context) error) error {
+	var waited time.Duration
+	for attempt := 1; ; attempt++ {
+		err := op(ctx)
+		if err == nil {
+			return nil
+		}
+		var permanent *permanentError
+		if errors.As(err, &permanent) {
+			return permanent.err
+		}
+
+		delay, ok := strategy.Delay(attempt, waited)
+		if !ok {
+			return fmt.Errorf("%s: %w", ERROR_ATTEMPTS_EXCEEDED, err)
+		}
+		if err := sleep(ctx, delay); err != nil {
+			return err
+		}
+		waited += delay
+	}
+}
+
+// `sleep` waits for the `delay`, or until the context is done
+func sleep(ctx context.Context, delay time.Duration) error {
+	if delay <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
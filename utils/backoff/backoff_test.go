@@ -0,0 +1,108 @@
+package backoff
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// Returns the delays of the strategy until it stops retrying
+func delays(strategy Strategy) []time.Duration {
+	var delays []time.Duration
+	var waited time.Duration
+	for attempt := 1; attempt < 100; attempt++ {
+		delay, ok := strategy.Delay(attempt, waited)
+		if !ok {
+			break
+		}
+		delays = append(delays, delay)
+		waited += delay
+	}
+	return delays
+}
+
+// Test the delays of each strategy
+func Test_Strategies(t *testing.T) {
+	tests := []struct {
+		name     string
+		strategy Strategy
+		expected []time.Duration
+	}{
+		{"constant", Constant(time.Second, 3), []time.Duration{time.Second, time.Second, time.Second}},
+		{"exponential", Exponential(time.Second, 5*time.Second, 5), []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second, 5 * time.Second}},
+		{"budget", Budget(Exponential(time.Second, time.Minute, 10), 7*time.Second), []time.Duration{time.Second, 2 * time.Second, 4 * time.Second}},
+		{"no retries", Constant(time.Second, 0), nil},
+	}
+	for _, test := range tests {
+		got := delays(test.strategy)
+		if len(got) != len(test.expected) {
+			t.Errorf("%s: expected: %v, got: %v", test.name, test.expected, got)
+			continue
+		}
+		for i := range got {
+			if got[i] != test.expected[i] {
+				t.Errorf("%s: expected: %v, got: %v", test.name, test.expected, got)
+				break
+			}
+		}
+	}
+
+	jittered := delays(Jittered(Exponential(time.Second, 4*time.Second, 3)))
+	for i, max := range []time.Duration{time.Second, 2 * time.Second, 4 * time.Second} {
+		if len(jittered) != 3 || jittered[i] < 0 || jittered[i] > max {
+			t.Errorf("Unexpected jittered delays: %v", jittered)
+			break
+		}
+	}
+
+	// Jitter read from a deterministic entropy source
+	entropy := bytes.NewReader(append(make([]byte, 15), 1))
+	if got := delays(JitteredWith(Exponential(time.Second, 4*time.Second, 3), entropy)); len(got) != 3 || got[0] != 0 || got[1] != 1 || got[2] != 4*time.Second {
+		t.Errorf("Unexpected jittered delays: %v", got)
+	}
+}
+
+// Test that operations are retried until they succeed, fail permanently, run out of attempts or the context is done
+func Test_Retry(t *testing.T) {
+	errFailed := errors.New("failed")
+	ctx := context.Background()
+
+	attempts := 0
+	err := Retry(ctx, Constant(0, 5), func(ctx context.Context) error {
+		if attempts++; attempts < 3 {
+			return errFailed
+		}
+		return nil
+	})
+	if err != nil || attempts != 3 {
+		t.Errorf("Expected success after 3 attempts, got: %v after %d", err, attempts)
+	}
+
+	attempts = 0
+	err = Retry(ctx, Constant(0, 2), func(ctx context.Context) error {
+		attempts++
+		return errFailed
+	})
+	if !errors.Is(err, errFailed) || attempts != 3 {
+		t.Errorf("Expected failure after 3 attempts, got: %v after %d", err, attempts)
+	}
+
+	attempts = 0
+	err = Retry(ctx, Constant(0, 2), func(ctx context.Context) error {
+		attempts++
+		return Permanent(errFailed)
+	})
+	if err != errFailed || attempts != 1 {
+		t.Errorf("Expected a permanent failure after 1 attempt, got: %v after %d", err, attempts)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	err = Retry(ctx, Constant(time.Minute, 2), func(ctx context.Context) error { return errFailed })
+	if !errors.Is(err, context.DeadlineExceeded) || time.Since(start) > time.Second {
+		t.Errorf("Expected the deadline to interrupt the backoff, got: %v after %v", err, time.Since(start))
+	}
+}
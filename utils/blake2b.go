@@ -0,0 +1,143 @@
+package utils
+
+import (
+	"encoding/binary"
+	"hash"
+	"math/bits"
+)
+
+// BLAKE2b (RFC 7693), keyed, hence a MAC by itself. See `MAC_BLAKE2B_256` and `MAC_BLAKE2B_512`.
+const (
+	blake2bBlockSize = 128
+	blake2bMaxKey    = 64
+)
+
+var blake2bIV = [8]uint64{
+	0x6a09e667f3bcc908, 0xbb67ae8584caa73b, 0x3c6ef372fe94f82b, 0xa54ff53a5f1d36f1,
+	0x510e527fade682d1, 0x9b05688c2b3e6c1f, 0x1f83d9abfb41bd6b, 0x5be0cd19137e2179,
+}
+
+var blake2bSigma = [12][16]byte{
+	{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15},
+	{14, 10, 4, 8, 9, 15, 13, 6, 1, 12, 0, 2, 11, 7, 5, 3},
+	{11, 8, 12, 0, 5, 2, 15, 13, 10, 14, 3, 6, 7, 1, 9, 4},
+	{7, 9, 3, 1, 13, 12, 11, 14, 2, 6, 5, 10, 4, 0, 15, 8},
+	{9, 0, 5, 7, 2, 4, 10, 15, 14, 1, 11, 12, 6, 8, 3, 13},
+	{2, 12, 6, 10, 0, 11, 8, 3, 4, 13, 7, 5, 15, 14, 1, 9},
+	{12, 5, 1, 15, 14, 13, 4, 10, 0, 7, 6, 3, 9, 2, 8, 11},
+	{13, 11, 7, 14, 12, 1, 3, 9, 5, 0, 15, 4, 8, 6, 2, 10},
+	{6, 15, 14, 9, 11, 3, 0, 8, 12, 2, 13, 7, 1, 4, 10, 5},
+	{10, 2, 8, 4, 7, 6, 1, 5, 15, 11, 9, 14, 3, 12, 13, 0},
+	{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15},
+	{14, 10, 4, 8, 9, 15, 13, 6, 1, 12, 0, 2, 11, 7, 5, 3},
+}
+
+// `blake2b` implements `hash.Hash` for BLAKE2b with an output of `size` bytes and an optional key
+type blake2b struct {
+	h      [8]uint64
+	t      [2]uint64 // The number of bytes compressed
+	block  [blake2bBlockSize]byte
+	offset int // The number of bytes buffered in the block
+	size   int
+	key    []byte
+}
+
+// `newBlake2b` returns a BLAKE2b hash of `size` bytes (1 to 64) keyed with `key`.
+// Keys longer than 64 bytes are hashed with BLAKE2b-512 first, like HMAC does with keys longer than its block size.
+func newBlake2b(size int, key []byte) hash.Hash {
+	if len(key) > blake2bMaxKey {
+		sum := newBlake2b(64, nil)
+		sum.Write(key)
+		key = sum.Sum(nil)
+	}
+	d := &blake2b{size: size, key: append([]byte(nil), key...)}
+	d.Reset()
+	return d
+}
+
+func (d *blake2b) Size() int      { return d.size }
+func (d *blake2b) BlockSize() int { return blake2bBlockSize }
+
+func (d *blake2b) Reset() {
+	d.h = blake2bIV
+	d.h[0] ^= uint64(d.size) | uint64(len(d.key))<<8 | 1<<16 | 1<<24
+	d.t = [2]uint64{}
+	d.block = [blake2bBlockSize]byte{}
+	d.offset = 0
+	// The key is padded to a full block, compressed as the first block
+	if len(d.key) > 0 {
+		copy(d.block[:], d.key)
+		d.offset = blake2bBlockSize
+	}
+}
+
+func (d *blake2b) Write(p []byte) (int, error) {
+	n := len(p)
+	for len(p) > 0 {
+		// The last block is compressed by `Sum`, as it is flagged, hence a full block is only compressed once more data follows
+		if d.offset == blake2bBlockSize {
+			d.compress(false)
+			d.offset = 0
+		}
+		copied := copy(d.block[d.offset:], p)
+		d.offset += copied
+		p = p[copied:]
+	}
+	return n, nil
+}
+
+func (d *blake2b) Sum(b []byte) []byte {
+	final := *d
+	for i := final.offset; i < blake2bBlockSize; i++ {
+		final.block[i] = 0
+	}
+	final.compress(true)
+	var out [64]byte
+	for i, h := range final.h {
+		binary.LittleEndian.PutUint64(out[i*8:], h)
+	}
+	return append(b, out[:d.size]...)
+}
+
+// `compress` compresses the buffered block, counting its `offset` bytes
+func (d *blake2b) compress(last bool) {
+	d.t[0] += uint64(d.offset)
+	if d.t[0] < uint64(d.offset) {
+		d.t[1]++
+	}
+	var m [16]uint64
+	for i := range m {
+		m[i] = binary.LittleEndian.Uint64(d.block[i*8:])
+	}
+	var v [16]uint64
+	copy(v[:8], d.h[:])
+	copy(v[8:], blake2bIV[:])
+	v[12] ^= d.t[0]
+	v[13] ^= d.t[1]
+	if last {
+		v[14] = ^v[14]
+	}
+	g := func(a, b, c, e int, x, y uint64) {
+		v[a] += v[b] + x
+		v[e] = bits.RotateLeft64(v[e]^v[a], -32)
+		v[c] += v[e]
+		v[b] = bits.RotateLeft64(v[b]^v[c], -24)
+		v[a] += v[b] + y
+		v[e] = bits.RotateLeft64(v[e]^v[a], -16)
+		v[c] += v[e]
+		v[b] = bits.RotateLeft64(v[b]^v[c], -63)
+	}
+	for _, s := range blake2bSigma {
+		g(0, 4, 8, 12, m[s[0]], m[s[1]])
+		g(1, 5, 9, 13, m[s[2]], m[s[3]])
+		g(2, 6, 10, 14, m[s[4]], m[s[5]])
+		g(3, 7, 11, 15, m[s[6]], m[s[7]])
+		g(0, 5, 10, 15, m[s[8]], m[s[9]])
+		g(1, 6, 11, 12, m[s[10]], m[s[11]])
+		g(2, 7, 8, 13, m[s[12]], m[s[13]])
+		g(3, 4, 9, 14, m[s[14]], m[s[15]])
+	}
+	for i := range d.h {
+		d.h[i] ^= v[i] ^ v[i+8]
+	}
+}
@@ -0,0 +1,30 @@
+package utils
+
+import (
+	"context"
+	"io"
+)
+
+// A contextReader reads from `r` until the context is done. See `ContextReader`.
+type contextReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+// Returns a reader reading from `r` that fails with the error of the context (E.g. `context.DeadlineExceeded`) once the context is done,
+// so that reading a large body, E.g. to hash it, observes the deadline and the cancellation of the request.
+// Each `Read` of `r` is not interrupted, hence `r` must not block indefinitely.
+func ContextReader(ctx context.Context, r io.Reader) io.Reader {
+	if ctx.Done() == nil {
+		// The context is never done. E.g. `context.Background()`
+		return r
+	}
+	return &contextReader{ctx: ctx, r: r}
+}
+
+func (c *contextReader) Read(p []byte) (int, error) {
+	if err := c.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return c.r.Read(p)
+}
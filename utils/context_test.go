@@ -0,0 +1,27 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+// Test that a ContextReader reads until the context is canceled
+func Test_ContextReader(t *testing.T) {
+	if r := strings.NewReader("payload"); ContextReader(context.Background(), r) != io.Reader(r) {
+		t.Error("Expected the reader to be returned as is for a context that is never done")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	r := ContextReader(ctx, strings.NewReader("payload"))
+	b := make([]byte, 3)
+	if n, err := r.Read(b); err != nil || string(b[:n]) != "pay" {
+		t.Fatalf("Expected to read %q, got: %q, %v", "pay", b[:n], err)
+	}
+	cancel()
+	if _, err := io.ReadAll(r); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled, got: %v", err)
+	}
+}
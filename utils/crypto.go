@@ -3,9 +3,103 @@ package utils
 import (
 	"crypto/hmac"
 	"crypto/sha256"
+	"crypto/sha512"
 	"encoding/hex"
+	"hash"
+	"slices"
+	"sync"
 )
 
+// Names of the built-in MACs
+const (
+	MAC_HMAC_SHA256 = "hmac-sha256"
+	MAC_HMAC_SHA384 = "hmac-sha384"
+	MAC_HMAC_SHA512 = "hmac-sha512"
+	MAC_BLAKE2B_256 = "blake2b-256" // Keyed BLAKE2b (RFC 7693) with a 32-byte output
+	MAC_BLAKE2B_512 = "blake2b-512" // Keyed BLAKE2b (RFC 7693) with a 64-byte output
+)
+
+// A MAC (Message Authentication Code) algorithm. MACs are registered by name (See `RegisterMAC`),
+// so that schemes look up the algorithm they are configured with instead of hard-coding the hash.
+type MAC interface {
+	Name() string                // The name of the algorithm. E.g. `hmac-sha256`
+	New(key []byte) hash.Hash    // Returns a new hash computing the MAC with the key
+	Sum(key, data []byte) []byte // Returns the MAC of the data with the key
+}
+
+// A MAC built from a hash function and a key. See `NewMAC`.
+type macFunc struct {
+	name string
+	new  func(key []byte) hash.Hash
+}
+
+// Returns a MAC named `name`, computed by the hashes returned by `new`. E.g. `NewMAC("hmac-sha3-256", func(key []byte) hash.Hash { return hmac.New(sha3.New256, key) })`
+func NewMAC(name string, new func(key []byte) hash.Hash) MAC {
+	return &macFunc{name: name, new: new}
+}
+
+func (m *macFunc) Name() string { return m.name }
+
+func (m *macFunc) New(key []byte) hash.Hash { return m.new(key) }
+
+func (m *macFunc) Sum(key, data []byte) []byte {
+	h := m.new(key)
+	h.Write(data)
+	return h.Sum(nil)
+}
+
+// Returns the HMAC of the hash function `newHash`, named `name`. E.g. `NewHMAC(MAC_HMAC_SHA256, sha256.New)`
+func NewHMAC(name string, newHash func() hash.Hash) MAC {
+	return NewMAC(name, func(key []byte) hash.Hash { return hmac.New(newHash, key) })
+}
+
+// The built-in MACs
+var (
+	HMAC_SHA256 = NewHMAC(MAC_HMAC_SHA256, sha256.New)
+	HMAC_SHA384 = NewHMAC(MAC_HMAC_SHA384, sha512.New384)
+	HMAC_SHA512 = NewHMAC(MAC_HMAC_SHA512, sha512.New)
+	BLAKE2B_256 = NewMAC(MAC_BLAKE2B_256, func(key []byte) hash.Hash { return newBlake2b(32, key) })
+	BLAKE2B_512 = NewMAC(MAC_BLAKE2B_512, func(key []byte) hash.Hash { return newBlake2b(64, key) })
+)
+
+var (
+	macsMu sync.RWMutex
+	macs   = map[string]MAC{}
+)
+
+func init() {
+	for _, mac := range []MAC{HMAC_SHA256, HMAC_SHA384, HMAC_SHA512, BLAKE2B_256, BLAKE2B_512} {
+		RegisterMAC(mac)
+	}
+}
+
+// Registers the MAC under its name, replacing any MAC registered under the same name
+func RegisterMAC(mac MAC) {
+	macsMu.Lock()
+	defer macsMu.Unlock()
+	macs[mac.Name()] = mac
+}
+
+// Returns the MAC registered under the name. Reports false if there is none.
+func LookupMAC(name string) (MAC, bool) {
+	macsMu.RLock()
+	defer macsMu.RUnlock()
+	mac, ok := macs[name]
+	return mac, ok
+}
+
+// Returns the sorted names of the registered MACs
+func MACs() []string {
+	macsMu.RLock()
+	defer macsMu.RUnlock()
+	names := make([]string, 0, len(macs))
+	for name := range macs {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
 // Hash a data string using SHA-256 and returns the checksum.
 func Hash(b []byte) string {
 	hash := sha256.Sum256(b)
@@ -14,7 +108,5 @@ func Hash(b []byte) string {
 
 // Returns a HMAC hash
 func HmacSHA256(key []byte, data string) ([]byte, error) {
-	hmac := hmac.New(sha256.New, key)
-	_, err := hmac.Write([]byte(data))
-	return hmac.Sum(nil), err
+	return HMAC_SHA256.Sum(key, []byte(data)), nil
 }
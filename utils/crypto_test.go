@@ -0,0 +1,60 @@
+package utils
+
+import (
+	"encoding/hex"
+	"hash"
+	"slices"
+	"testing"
+)
+
+// Test the built-in MACs against reference vectors (RFC 4231-style HMACs, the BLAKE2b test vectors of RFC 7693 and the BLAKE2 KAT)
+func Test_MACs(t *testing.T) {
+	seq := func(n int) []byte {
+		b := make([]byte, n)
+		for i := range b {
+			b[i] = byte(i)
+		}
+		return b
+	}
+	tests := []struct {
+		name     string
+		key      []byte
+		data     []byte
+		expected string
+	}{
+		{MAC_HMAC_SHA256, []byte("key"), []byte("data"), "5031fe3d989c6d1537a013fa6e739da23463fdaec3b70137d828e36ace221bd0"},
+		{MAC_HMAC_SHA384, []byte("key"), []byte("data"), "c5f97ad9fd1020c174d7dc02cf83c4c1bf15ee20ec555b690ad58e62da8a00ee44ccdb65cb8c80acfd127ebee568958a"},
+		{MAC_BLAKE2B_512, nil, []byte("abc"), "ba80a53f981c4d0d6a2797b69f12f6e94c212f14685ac4b74b12bb6fdbffa2d17d87c5392aab792dc252d5de4533cc9518d38aa8dbf1925ab92386edd4009923"},
+		{MAC_BLAKE2B_512, seq(64), nil, "10ebb67700b1868efb4417987acf4690ae9d972fb7a590c2f02871799aaa4786b5e996e8f0f4eb981fc214b005f42d2ff4233499391653df7aefcbc13fc51568"},
+		{MAC_BLAKE2B_512, seq(64), seq(255), "142709d62e28fcccd0af97fad0f8465b971e82201dc51070faa0372aa43e92484be1c1e73ba10906d5d1853db6a4106e0a7bf9800d373d6dee2d46d62ef2a461"},
+		{MAC_BLAKE2B_256, []byte("secret"), []byte("The quick brown fox"), "53ff931e38a448b9c95dfc2acc3981fd6d64f0773e28c1a45deea826e1937c06"},
+	}
+	for _, test := range tests {
+		mac, ok := LookupMAC(test.name)
+		if !ok {
+			t.Fatalf("Expected MAC %s to be registered", test.name)
+		}
+		if got := hex.EncodeToString(mac.Sum(test.key, test.data)); got != test.expected {
+			t.Errorf("%s: expected: %s, got: %s", test.name, test.expected, got)
+		}
+		// Incremental writes, across the block boundaries
+		h := mac.New(test.key)
+		for i := range test.data {
+			h.Write(test.data[i : i+1])
+		}
+		if got := hex.EncodeToString(h.Sum(nil)); got != test.expected {
+			t.Errorf("%s (incremental): expected: %s, got: %s", test.name, test.expected, got)
+		}
+	}
+
+	RegisterMAC(NewMAC("test-mac", func(key []byte) hash.Hash { return newBlake2b(16, key) }))
+	if mac, ok := LookupMAC("test-mac"); !ok || len(mac.Sum([]byte("key"), []byte("data"))) != 16 {
+		t.Error("Expected the registered MAC to be looked up")
+	}
+	if !slices.Contains(MACs(), MAC_HMAC_SHA512) || !slices.Contains(MACs(), "test-mac") {
+		t.Errorf("Unexpected MACs: %v", MACs())
+	}
+	if _, ok := LookupMAC("hmac-md5"); ok {
+		t.Error("Expected an unregistered MAC not to be found")
+	}
+}
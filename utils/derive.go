@@ -0,0 +1,66 @@
+package utils
+
+import (
+	"crypto/sha256"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// # Key derivation
+//
+// Derives a key by chaining the MAC over the `parts`, each MAC keyed with the previous one, starting with the `secret`:
+//
+//	key = MAC(...MAC(MAC(secret, parts[0]), parts[1])..., parts[n-1])
+//
+// E.g. the SigV4 signing key is `DeriveKey(HMAC_SHA256, []byte("AWS4"+secret), date, region, service, "aws4_request")`.
+func DeriveKey(mac MAC, secret []byte, parts ...string) []byte {
+	key := secret
+	for _, part := range parts {
+		key = mac.Sum(key, []byte(part))
+	}
+	return key
+}
+
+// A KeyCache memoizes derived keys (See `DeriveKey`), E.g. the signing keys of SigV4, which only change daily per secret and scope.
+// The cache holds up to `maxEntries` keys, and is emptied when full. Secrets are not retained: entries are looked up by a hash of the inputs.
+// A KeyCache is safe for concurrent use.
+type KeyCache struct {
+	mu         sync.Mutex
+	maxEntries int
+	keys       map[[sha256.Size]byte][]byte
+}
+
+// Returns a KeyCache holding up to `maxEntries` keys
+func NewKeyCache(maxEntries int) *KeyCache {
+	return &KeyCache{maxEntries: max(maxEntries, 1), keys: make(map[[sha256.Size]byte][]byte)}
+}
+
+// Returns the key derived like `DeriveKey`, from the cache if it was derived before
+func (c *KeyCache) DeriveKey(mac MAC, secret []byte, parts ...string) []byte {
+	// The secret is length-prefixed, and the other inputs are separated by NUL bytes, which cannot occur in the names of MACs or in scopes
+	id := sha256.Sum256([]byte(mac.Name() + "\x00" + strconv.Itoa(len(secret)) + ":" + string(secret) + strings.Join(parts, "\x00")))
+
+	c.mu.Lock()
+	key, ok := c.keys[id]
+	c.mu.Unlock()
+	if ok {
+		return append([]byte(nil), key...)
+	}
+
+	key = DeriveKey(mac, secret, parts...)
+	c.mu.Lock()
+	if len(c.keys) >= c.maxEntries {
+		clear(c.keys)
+	}
+	c.keys[id] = key
+	c.mu.Unlock()
+	return append([]byte(nil), key...)
+}
+
+// Returns the number of cached keys
+func (c *KeyCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.keys)
+}
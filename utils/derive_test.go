@@ -0,0 +1,37 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+)
+
+// Test the key derivation against the SigV4 signing key example of the AWS documentation, with and without the cache
+func Test_DeriveKey(t *testing.T) {
+	secret := []byte("AWS4" + "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY")
+	parts := []string{"20120215", "us-east-1", "iam", "aws4_request"}
+	expected := "f4780e2d9f65fa895f9c67b32ce1baf0b0d8a43505a000a1a9e090d414db404d"
+
+	if got := hex.EncodeToString(DeriveKey(HMAC_SHA256, secret, parts...)); got != expected {
+		t.Errorf("Expected: %s, got: %s", expected, got)
+	}
+
+	cache := NewKeyCache(2)
+	first := cache.DeriveKey(HMAC_SHA256, secret, parts...)
+	if hex.EncodeToString(first) != expected || cache.Len() != 1 {
+		t.Errorf("Expected: %s cached, got: %x (%d cached)", expected, first, cache.Len())
+	}
+	// Mutating a returned key does not corrupt the cache
+	first[0] ^= 0xff
+	if got := hex.EncodeToString(cache.DeriveKey(HMAC_SHA256, secret, parts...)); got != expected || cache.Len() != 1 {
+		t.Errorf("Expected: %s from the cache, got: %s", expected, got)
+	}
+	// Keys of other MACs are cached separately, and the full cache is emptied
+	if bytes.Equal(cache.DeriveKey(HMAC_SHA512, secret, parts...), DeriveKey(HMAC_SHA256, secret, parts...)) || cache.Len() != 2 {
+		t.Errorf("Expected the keys of each MAC to be cached separately, got %d cached", cache.Len())
+	}
+	cache.DeriveKey(HMAC_SHA256, secret, "20120216", "us-east-1", "iam", "aws4_request")
+	if cache.Len() != 1 {
+		t.Errorf("Expected the full cache to be emptied, got %d cached", cache.Len())
+	}
+}
@@ -0,0 +1,23 @@
+package utils
+
+import (
+	"crypto/rand"
+	"io"
+)
+
+// # Entropy source
+//
+// Returns the source of randomness `r`, or `crypto/rand.Reader` if nil. Every random value of the library (E.g. nonces, request IDs,
+// sealing keys and backoff jitter) is read through it, so that tests can be deterministic, and environments mandating a specific DRBG can supply it.
+func Entropy(r io.Reader) io.Reader {
+	if r == nil {
+		return rand.Reader
+	}
+	return r
+}
+
+// Fills `b` with random bytes read from the entropy source `r`. See `Entropy`.
+func ReadEntropy(r io.Reader, b []byte) error {
+	_, err := io.ReadFull(Entropy(r), b)
+	return err
+}
@@ -0,0 +1,23 @@
+package utils
+
+import (
+	"bytes"
+	"crypto/rand"
+	"testing"
+)
+
+// Test that random bytes are read from the given entropy source, and from `crypto/rand` by default
+func Test_ReadEntropy(t *testing.T) {
+	if Entropy(nil) != rand.Reader {
+		t.Error("Expected crypto/rand to be the default entropy source")
+	}
+
+	b := make([]byte, 4)
+	if err := ReadEntropy(bytes.NewReader([]byte{1, 2, 3, 4}), b); err != nil || !bytes.Equal(b, []byte{1, 2, 3, 4}) {
+		t.Errorf("Expected the bytes of the entropy source, got: %v, %v", b, err)
+	}
+	// A source running dry fails instead of returning partially random bytes
+	if err := ReadEntropy(bytes.NewReader([]byte{1}), b); err == nil {
+		t.Error("Expected an error from an exhausted entropy source")
+	}
+}
@@ -2,16 +2,34 @@ package utils
 
 import (
 	"bufio"
+	"errors"
+	"fmt"
 	"log"
+	"maps"
 	"os"
 	"os/user"
+	"runtime"
+	"slices"
 	"strings"
 
 	"github.com/go-ini/ini"
 )
 
+// Errors
+const (
+	ERROR_UNTERMINATED_QUOTE = "unterminated quoted value"
+	ERROR_INCLUDE_NOT_FOUND  = "included section not found"
+	ERROR_INCLUDE_CYCLE      = "cyclic include"
+)
+
 // Get the path to the Home Directory, irrespective of underlying operating system
+//
+// The Home Directory is read from the environment: `%USERPROFILE%` (else `%HOMEDRIVE%%HOMEPATH%`) on Windows, `$HOME` elsewhere,
+// so that it matches the directory other tools (E.g. the AWS CLI) read credentials from. Falls back to the directory of the current user.
 func HomeDir() (string, error) {
+	if dir := homeDirFromEnv(runtime.GOOS, os.Getenv); dir != "" {
+		return dir, nil
+	}
 	currentUser, err := user.Current()
 	if err != nil {
 		return "", err
@@ -19,6 +37,20 @@ func HomeDir() (string, error) {
 	return currentUser.HomeDir, nil
 }
 
+// `homeDirFromEnv` returns the Home Directory of the operating system `goos` read from the environment, or an empty string
+func homeDirFromEnv(goos string, getenv func(string) string) string {
+	if goos == "windows" {
+		if dir := getenv("USERPROFILE"); dir != "" {
+			return dir
+		}
+		if drive, path := getenv("HOMEDRIVE"), getenv("HOMEPATH"); drive != "" && path != "" {
+			return drive + path
+		}
+		return ""
+	}
+	return getenv("HOME")
+}
+
 // Struct representing the contents of a config file as a
 //
 //	Profile.Name
@@ -31,7 +63,21 @@ type Profile struct {
 	Map  map[string]string
 }
 
+// The key of a section of an .ini file naming the sections it inherits the keys of. See `ReadIniFile`.
+const INI_INCLUDE_KEY = "include"
+
 // Function to read .ini file and return a `Profile`
+//
+// A section may inherit the keys of other sections of the file with `include`, a comma-separated list of section names,
+// so that shared defaults (E.g. the region) live in one section. The keys of the section override the keys it includes,
+// and later includes override earlier ones. Includes are resolved recursively. Sections with a missing or cyclic include are skipped.
+//
+//	[shared]
+//	region = ap-south-1
+//
+//	[team-a]
+//	include = shared
+//	aws_access_key_id = ...
 func ReadIniFile(filename string) <-chan *Profile {
 	out := make(chan *Profile)
 	go func() {
@@ -43,14 +89,24 @@ func ReadIniFile(filename string) <-chan *Profile {
 			return
 		}
 
+		sections := make(map[string]map[string]string)
 		for _, section := range cfg.Sections() {
-			name := section.Name()
 			sectionMap := make(map[string]string)
 
 			keys := section.Keys()
 			for _, key := range keys {
 				sectionMap[key.Name()] = key.String()
 			}
+			sections[section.Name()] = sectionMap
+		}
+
+		for _, section := range cfg.Sections() {
+			name := section.Name()
+			sectionMap, err := resolveIniIncludes(sections, name, nil)
+			if err != nil {
+				log.Printf("Skipping section %s of INI file %s: %v", name, filename, err)
+				continue
+			}
 
 			out <- &Profile{
 				Name: name,
@@ -61,7 +117,40 @@ func ReadIniFile(filename string) <-chan *Profile {
 	return out
 }
 
+// `resolveIniIncludes` returns the keys of the section merged over the keys of the sections it includes.
+// `visiting` holds the sections being resolved, to detect cycles.
+func resolveIniIncludes(sections map[string]map[string]string, name string, visiting []string) (map[string]string, error) {
+	if slices.Contains(visiting, name) {
+		return nil, fmt.Errorf("%s: %s", ERROR_INCLUDE_CYCLE, strings.Join(append(visiting, name), " -> "))
+	}
+	section, ok := sections[name]
+	if !ok {
+		return nil, fmt.Errorf("%s: %s", ERROR_INCLUDE_NOT_FOUND, name)
+	}
+
+	resolved := make(map[string]string)
+	if include, ok := section[INI_INCLUDE_KEY]; ok {
+		for _, included := range strings.Split(include, ",") {
+			includedMap, err := resolveIniIncludes(sections, strings.TrimSpace(included), append(visiting, name))
+			if err != nil {
+				return nil, err
+			}
+			maps.Copy(resolved, includedMap)
+		}
+	}
+	maps.Copy(resolved, section)
+	delete(resolved, INI_INCLUDE_KEY)
+	return resolved, nil
+}
+
 // Function to read .env file and return a `Profile`
+//
+// Each line is a `KEY=value` pair, optionally prefixed with `export`. Blank lines and lines starting with `#` are ignored.
+//   - Unquoted values are trimmed, and end at an inline comment: a `#` preceded by whitespace.
+//   - Single-quoted values are taken literally.
+//   - Double-quoted values may contain `#`, and the escapes `\"`, `\\`, `\n`, `\r` and `\t`.
+//   - `${VAR}` in unquoted and double-quoted values is replaced with the value of `VAR` defined earlier in the file,
+//     else of the environment variable `VAR`, else with an empty string.
 func ReadEnvFile(filename string) (*Profile, error) {
 	profile := Profile{
 		Name: "default",
@@ -77,15 +166,96 @@ func ReadEnvFile(filename string) (*Profile, error) {
 
 	// Read lines from the file
 	scanner := bufio.NewScanner(file)
-	for scanner.Scan() {
-		line := scanner.Text()
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimPrefix(line, "export ")
 		parts := strings.SplitN(line, "=", 2)
 		if len(parts) == 2 {
 			key := strings.TrimSpace(parts[0])
-			value := strings.TrimSpace(parts[1])
+			value, err := parseEnvValue(strings.TrimSpace(parts[1]), profile.Map)
+			if err != nil {
+				return &profile, fmt.Errorf("%s:%d: %w", filename, lineNumber, err)
+			}
 			profile.Map[key] = value
 		}
 	}
 	err = scanner.Err()
 	return &profile, err
 }
+
+// `parseEnvValue` unquotes and interpolates the value of a line of a .env file, given the values defined earlier
+func parseEnvValue(value string, defined map[string]string) (string, error) {
+	switch {
+	case strings.HasPrefix(value, "'"):
+		end := strings.Index(value[1:], "'")
+		if end < 0 {
+			return "", errors.New(ERROR_UNTERMINATED_QUOTE)
+		}
+		return value[1 : end+1], nil
+
+	case strings.HasPrefix(value, `"`):
+		var b strings.Builder
+		for i := 1; i < len(value); i++ {
+			switch c := value[i]; {
+			case c == '"':
+				return interpolateEnv(b.String(), defined), nil
+			case c == '\\' && i+1 < len(value):
+				i++
+				switch value[i] {
+				case 'n':
+					b.WriteByte('\n')
+				case 'r':
+					b.WriteByte('\r')
+				case 't':
+					b.WriteByte('\t')
+				case '"', '\\':
+					b.WriteByte(value[i])
+				default:
+					b.WriteByte('\\')
+					b.WriteByte(value[i])
+				}
+			default:
+				b.WriteByte(c)
+			}
+		}
+		return "", errors.New(ERROR_UNTERMINATED_QUOTE)
+
+	default:
+		// Strip the inline comment
+		for i := 1; i < len(value); i++ {
+			if value[i] == '#' && (value[i-1] == ' ' || value[i-1] == '\t') {
+				value = strings.TrimSpace(value[:i])
+				break
+			}
+		}
+		return interpolateEnv(value, defined), nil
+	}
+}
+
+// `interpolateEnv` replaces `${VAR}` with the value of `VAR` defined earlier in the file, else in the environment
+func interpolateEnv(value string, defined map[string]string) string {
+	var b strings.Builder
+	for {
+		start := strings.Index(value, "${")
+		if start < 0 {
+			break
+		}
+		end := strings.Index(value[start:], "}")
+		if end < 0 {
+			break
+		}
+		name := value[start+2 : start+end]
+		b.WriteString(value[:start])
+		if v, ok := defined[name]; ok {
+			b.WriteString(v)
+		} else {
+			b.WriteString(os.Getenv(name))
+		}
+		value = value[start+end+1:]
+	}
+	b.WriteString(value)
+	return b.String()
+}
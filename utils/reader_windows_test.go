@@ -0,0 +1,17 @@
+package utils
+
+import (
+	"testing"
+)
+
+// Test that the Home Directory is `%USERPROFILE%` on Windows, where the AWS CLI reads `.aws` from
+func Test_HomeDir_Windows(t *testing.T) {
+	t.Setenv("USERPROFILE", `C:\Users\httpsigner`)
+	dir, err := HomeDir()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dir != `C:\Users\httpsigner` {
+		t.Errorf("Expected: %s, got: %s", `C:\Users\httpsigner`, dir)
+	}
+}
@@ -0,0 +1,118 @@
+// Package verifyd implements a verification service: a `/verify` endpoint accepting a serialized request and returning the verdict as JSON,
+// so that services not written in Go can outsource verification. See `cmd/httpsigner-verifyd`.
+package verifyd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	httpsigner "github.com/jayantasamaddar/go-httpsigner"
+	"github.com/jayantasamaddar/go-httpsigner/auth"
+	"github.com/jayantasamaddar/go-httpsigner/sigv4"
+)
+
+// Errors
+const (
+	ERROR_BODY_HASH_NOT_SPECIFIED = "body_hash not specified"
+	ERROR_INVALID_REQUEST         = "invalid serialized request"
+)
+
+// The maximum size of a serialized request
+const MAX_REQUEST_SIZE = 1 << 20
+
+// # A serialized request
+//
+// The request to verify, with the hash of its body instead of the body itself.
+type Request struct {
+	Method string `json:"method"`
+	// The absolute URL (E.g. `https://api.example.com/items?id=1`), or the request URI (E.g. `/items?id=1`) with the `Host`
+	URL string `json:"url"`
+	// The host of the request, if not in the `URL`, or if different from the host of the `URL`
+	Host    string              `json:"host,omitempty"`
+	Headers map[string][]string `json:"headers"`
+	// The hex-encoded SHA-256 hash of the body. Use `sigv4.EMPTY_PAYLOAD_HASH` for requests without a body.
+	BodyHash      string `json:"body_hash"`
+	ContentLength int64  `json:"content_length"`
+}
+
+// The verdict of a verification
+type Response struct {
+	Verified bool           `json:"verified"`
+	Outcome  string         `json:"outcome"` // One of the `httpsigner.OUTCOME_*` constants
+	Error    string         `json:"error,omitempty"`
+	Identity *auth.Identity `json:"identity,omitempty"`
+}
+
+type bodyHashContextKey struct{}
+
+// The `sigv4.PayloadHasher` the Verifier of the service must be configured with (See `sigv4.WithPayloadHasher`),
+// supplying the `BodyHash` of the serialized request.
+var PayloadHasher = sigv4.PayloadHasherFunc(func(req *http.Request) (string, error) {
+	hash, ok := req.Context().Value(bodyHashContextKey{}).(string)
+	if !ok || hash == "" {
+		return "", errors.New(ERROR_BODY_HASH_NOT_SPECIFIED)
+	}
+	return hash, nil
+})
+
+// `httpRequest` deserializes the request to verify
+func (r *Request) httpRequest(ctx context.Context) (*http.Request, error) {
+	if r.BodyHash == "" {
+		return nil, fmt.Errorf("%s: %s", ERROR_INVALID_REQUEST, ERROR_BODY_HASH_NOT_SPECIFIED)
+	}
+	req, err := http.NewRequestWithContext(context.WithValue(ctx, bodyHashContextKey{}, r.BodyHash), r.Method, r.URL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", ERROR_INVALID_REQUEST, err)
+	}
+	req.Header = make(http.Header, len(r.Headers))
+	for name, values := range r.Headers {
+		req.Header[http.CanonicalHeaderKey(name)] = append(req.Header[http.CanonicalHeaderKey(name)], values...)
+	}
+	if r.Host != "" {
+		req.Host = r.Host
+	}
+	if req.Host == "" {
+		return nil, fmt.Errorf("%s: %s", ERROR_INVALID_REQUEST, "host not specified")
+	}
+	req.ContentLength = r.ContentLength
+	return req, nil
+}
+
+// # The verification handler
+//
+// Serves `POST` requests with a JSON `Request` body, responding with a JSON `Response`: `200 OK` with the verdict,
+// `400 Bad Request` if the serialized request is invalid, or `405 Method Not Allowed`.
+//
+// The `authenticator` must be configured with `PayloadHasher`, E.g. `sigv4.NewSigV4Verifier(org, abbr, service, url, sigv4.WithPayloadHasher(verifyd.PayloadHasher))`.
+func Handler(authenticator auth.Authenticator) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		serialized := new(Request)
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, MAX_REQUEST_SIZE)).Decode(serialized); err != nil {
+			http.Error(w, fmt.Sprintf("%s: %v", ERROR_INVALID_REQUEST, err), http.StatusBadRequest)
+			return
+		}
+		req, err := serialized.httpRequest(r.Context())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		identity, err := authenticator.Authenticate(req)
+		res := &Response{Verified: err == nil, Outcome: httpsigner.Outcome(err)}
+		if err != nil {
+			res.Error = err.Error()
+		} else {
+			res.Identity = identity
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(res)
+	})
+}